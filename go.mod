@@ -7,7 +7,7 @@ require (
 	github.com/DusanKasan/parsemail v0.0.0-20190115161936-abc648830b9a
 	github.com/aws/aws-lambda-go v1.13.1
 	github.com/aws/aws-sdk-go v1.23.18
-	github.com/cenkalti/backoff v2.2.1+incompatible // indirect
+	github.com/cenkalti/backoff v2.2.1+incompatible
 	github.com/dchest/uniuri v0.0.0-20160212164326-8902c56451e9
 	github.com/eclipse/paho.mqtt.golang v1.2.0
 	github.com/fnproject/fdk-go v0.0.1
@@ -21,6 +21,7 @@ require (
 	github.com/onsi/gomega v1.7.0
 	github.com/rodrigopavezi/appsync-client-go v0.0.0-20190902195154-afb2c6dd4ab5
 	github.com/satori/go.uuid v1.2.0
+	github.com/vektah/gqlparser v1.1.2
 	github.com/yunspace/serverless-golang v0.0.0-20171225122248-bbcd01f86ee4
 	go.opencensus.io v0.22.1
 	golang.org/x/net v0.0.0-20190909003024-a7b16738d86b
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	HireController "gitlab.com/ncent/arber/api/services/arber/hire"
+	MergeController "gitlab.com/ncent/arber/api/services/arber/merge"
+)
+
+var resolver = Resolver.New()
+
+// mergeRequest names the user to keep and the one to fold into it, as
+// proposed on either user's mergeProposals. The admin token comes in the
+// Authorization header as "Bearer <token>".
+type mergeRequest struct {
+	SurvivorID string `json:"survivorId"`
+	MergedID   string `json:"mergedId"`
+}
+
+func handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if !authorized(event.Headers) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 401,
+			Body:       "Not an admin",
+		}, nil
+	}
+
+	var body mergeRequest
+	if err := json.Unmarshal([]byte(event.Body), &body); err != nil || body.SurvivorID == "" || body.MergedID == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       "Missing survivorId or mergedId",
+		}, nil
+	}
+
+	result, err := MergeController.Merge(ctx, resolver, body.SurvivorID, body.MergedID)
+	if err != nil {
+		log.Printf("Failed to merge users: %v", err)
+		statusCode := 0
+		switch {
+		case errors.Is(err, MergeController.ErrSameUser):
+			statusCode = 400
+		case errors.Is(err, MergeController.ErrAlreadyMerged):
+			statusCode = 409
+		case errors.Is(err, Resolver.ErrNotFound):
+			statusCode = 404
+		default:
+			return events.APIGatewayProxyResponse{
+				StatusCode: 500,
+				Body:       err.Error(),
+			}, err
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: statusCode,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Failed to create merge result json: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+			Body:       err.Error(),
+		}, err
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Body:       string(resultJSON),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+// authorized compares the bearer token of the Authorization header with
+// ADMIN_TOKEN. Without ADMIN_TOKEN nobody is an admin.
+func authorized(headers map[string]string) bool {
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		return false
+	}
+	token := HireController.BearerToken(headers)
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+}
+
+func main() {
+	lambda.Start(handler)
+}
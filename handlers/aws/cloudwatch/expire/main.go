@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
+)
+
+var resolver = Resolver.New()
+
+// handler runs on a schedule and expires the challenges whose expiration has
+// passed since the last run.
+func handler(ctx context.Context, event events.CloudWatchEvent) error {
+	at := event.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	swept, err := ChallengeController.SweepExpired(ctx, resolver, at)
+	for _, challenge := range swept {
+		log.Printf("Expired challenge: %v", *challenge.ID)
+	}
+	return err
+}
+
+func main() {
+	lambda.Start(handler)
+}
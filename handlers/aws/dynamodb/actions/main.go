@@ -1,14 +1,15 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/aws/aws-lambda-go/lambda"
-	clients "gitlab.com/ncent/arber/api/services/dynamodb/client"
+	clients "gitlab.com/ncent/arber/api/services/aws/dynamodb/client"
 )
 
-func handler(event DynamoDBEvent) error {
-	var dynamoRecords []DynamoDBStreamRecord
+func handler(ctx context.Context, event clients.DynamoDBEvent) (clients.DynamoDBEventResponse, error) {
+	var dynamoRecords []clients.DynamoDBStreamRecord
 	for _, record := range event.Records {
 		switch record.EventName {
 		case "INSERT":
@@ -19,7 +20,7 @@ func handler(event DynamoDBEvent) error {
 			log.Printf("No handler for event %v %v, skipping.", record.EventName, record.EventID)
 		}
 	}
-	return clients.DynamoDBClient.UpdateState("actions", dynamoRecords)
+	return clients.TransactionStates.ProcessRecords(ctx, dynamoRecords), nil
 }
 
 func main() {
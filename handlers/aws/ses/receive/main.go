@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -13,10 +15,37 @@ import (
 
 var resolver = Resolver.New()
 
-func handler(ctx context.Context, event events.S3Event) error {
+// record is a record of either trigger of the function: the S3 event of a
+// message stored in the inbox bucket, or the SNS notification of the SES
+// receipt rule, which also carries the envelope recipients and verdicts.
+type record struct {
+	events.S3EventRecord
+	SNS events.SNSEntity `json:"Sns"`
+}
+
+type event struct {
+	Records []record `json:"Records"`
+}
+
+func handler(ctx context.Context, event event) error {
 	for _, record := range event.Records {
+		var receipt *clients.Receipt
+		switch record.EventSource {
+		case "aws:sns":
+			var err error
+			receipt, err = clients.ParseReceiptNotification(record.SNS.Message)
+			if errors.Is(err, clients.ErrNotReceived) {
+				log.Printf("Skipping SES notification: %v", err)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("Event Error %v", err)
+			}
+		default:
+			receipt = clients.ReceiptFromS3(record.S3EventRecord)
+		}
 
-		error := clients.SESClient.ConsumeEmail(ctx, record, Arber.ProcessInbound)
+		error := clients.SESClient.ConsumeEmail(ctx, receipt, Arber.ProcessReceived)
 		if error != nil {
 			return fmt.Errorf("Event Error %v", error)
 		}
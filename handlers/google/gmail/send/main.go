@@ -23,7 +23,7 @@ func handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.A
 	json.Unmarshal([]byte(event.Body), &emailerRequestData)
 	log.Printf("Found message: %+v", emailerRequestData)
 
-	user, err := resolver.GetUser(emailerRequestData.ID)
+	user, err := resolver.GetUserContext(ctx, emailerRequestData.ID)
 
 	token := oauth2.Token{
 		RefreshToken: *user.Token,
@@ -17,7 +17,7 @@ var resolver = Resolver.New()
 func Handler(ctx context.Context, event googleClient.GetContactsPayload) error {
 	log.Printf("Found message: %+v", event)
 
-	user, err := resolver.GetUser(event.ID)
+	user, err := resolver.GetUserContext(ctx, event.ID)
 
 	if err != nil {
 		log.Printf("Failed to get user: %v", err)
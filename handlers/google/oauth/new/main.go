@@ -8,6 +8,8 @@ import (
 	"encoding/json"
 
 	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	MergeController "gitlab.com/ncent/arber/api/services/arber/merge"
+	UserController "gitlab.com/ncent/arber/api/services/arber/user"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -50,7 +52,11 @@ func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.A
 
 	emails, names, phones, photos := helpers.ExtractGooglePersonInformation(resolver, googleUserInfo)
 
-	existingUsers, err := resolver.ListUsersByEmails(
+	// Matched on canonical addresses too, so signing in with an alias of
+	// an address someone shared with finds the sparse user they created.
+	existingUsers, err := UserController.FindUsers(
+		ctx,
+		resolver,
 		emails,
 	)
 
@@ -60,7 +66,7 @@ func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.A
 		log.Printf("Got existing user: %+v", existingUsers)
 	}
 
-	user, err := helpers.CreateOrUpdateUser(resolver, existingUsers, usr, emails, googleUserInfo, names, phones, photos, token)
+	user, err := helpers.CreateOrUpdateUser(ctx, resolver, existingUsers, usr, emails, googleUserInfo, names, phones, photos, token)
 	if err != nil {
 		log.Printf("Failed to create or update user: %v", err)
 		return events.APIGatewayProxyResponse{
@@ -69,6 +75,12 @@ func Handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.A
 		}, err
 	}
 
+	// A sparse user created from mail before they signed up is proposed
+	// for merging into this account; an admin confirms the merge.
+	if _, err := MergeController.Propose(ctx, resolver, user); err != nil {
+		log.Printf("Failed to propose merges for user %v: %v", *user.ID, err)
+	}
+
 	populateUserContactsAsync(*user.ID)
 
 	userJSON, err := json.Marshal(user)
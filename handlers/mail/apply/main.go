@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/mail"
+
+	"github.com/DusanKasan/parsemail"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ApplyService "gitlab.com/ncent/arber/api/services/arber/apply"
+	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
+)
+
+var resolver = Resolver.New()
+
+// applyRequest is the body the client app posts from /apply/{transactionId}.
+// The resume is optional and base64 encoded.
+type applyRequest struct {
+	TransactionID string `json:"transactionId"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	Message       string `json:"message"`
+	Resume        *struct {
+		Filename    string `json:"filename"`
+		ContentType string `json:"contentType"`
+		Data        string `json:"data"`
+	} `json:"resume"`
+}
+
+func handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var body applyRequest
+	if err := json.Unmarshal([]byte(event.Body), &body); err != nil {
+		return respond(400, map[string]string{"error": "Invalid application: " + err.Error()}), nil
+	}
+
+	request := ApplyService.Request{
+		TransactionID: body.TransactionID,
+		Candidate:     &mail.Address{Name: body.Name, Address: body.Email},
+		Message:       body.Message,
+	}
+	if body.Resume != nil {
+		data, err := base64.StdEncoding.DecodeString(body.Resume.Data)
+		if err != nil {
+			return respond(400, map[string]string{"error": "Invalid resume: " + err.Error()}), nil
+		}
+		request.Resume = &parsemail.Attachment{
+			Filename:    body.Resume.Filename,
+			ContentType: body.Resume.ContentType,
+			Data:        bytes.NewReader(data),
+		}
+	}
+
+	application, err := ApplyService.Apply(ctx, resolver, request)
+	if errors.Is(err, ApplyService.ErrAlreadyApplied) {
+		// Anyone with the link and the candidate's address gets here, so
+		// the stored application, resume link included, stays private.
+		return respond(409, map[string]string{
+			"error":         ApplyService.ErrAlreadyApplied.Error(),
+			"applicationId": stringValue(application.ID),
+		}), nil
+	}
+	var closedErr *ChallengeController.ClosedError
+	if errors.As(err, &closedErr) {
+		return respond(410, map[string]string{"error": closedErr.Reason}), nil
+	}
+	if errors.Is(err, ApplyService.ErrInvalidRequest) {
+		return respond(400, map[string]string{"error": err.Error()}), nil
+	}
+	if err != nil {
+		log.Printf("Failed to apply: %v", err)
+		statusCode := 500
+		if errors.Is(err, Resolver.ErrNotFound) {
+			statusCode = 404
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: statusCode,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	return respond(201, application), nil
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func respond(statusCode int, value interface{}) events.APIGatewayProxyResponse {
+	body, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Failed to marshal response: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: err.Error()}
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Body:       string(body),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}
+}
+
+func main() {
+	lambda.Start(handler)
+}
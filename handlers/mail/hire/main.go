@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	HireController "gitlab.com/ncent/arber/api/services/arber/hire"
+)
+
+var resolver = Resolver.New()
+
+// hireRequest is the body the client app posts from the hire link mailed to
+// the sponsor. The sponsor token comes in the Authorization header as
+// "Bearer <token>".
+type hireRequest struct {
+	ApplicationID string `json:"applicationId"`
+}
+
+func handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var body hireRequest
+	if err := json.Unmarshal([]byte(event.Body), &body); err != nil || body.ApplicationID == "" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       "Missing applicationId",
+		}, nil
+	}
+
+	outcome, err := HireController.ConfirmHire(ctx, resolver, body.ApplicationID, HireController.BearerToken(event.Headers))
+	if err != nil {
+		log.Printf("Failed to confirm hire: %v", err)
+		statusCode := 0
+		switch {
+		case errors.Is(err, HireController.ErrUnauthorized):
+			statusCode = 401
+		case errors.Is(err, HireController.ErrAlreadyFilled), errors.Is(err, HireController.ErrRetired):
+			statusCode = 409
+		case errors.Is(err, Resolver.ErrNotFound):
+			statusCode = 404
+		default:
+			return events.APIGatewayProxyResponse{
+				StatusCode: 500,
+				Body:       err.Error(),
+			}, err
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: statusCode,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	outcomeJSON, err := json.Marshal(outcome)
+	if err != nil {
+		log.Printf("Failed to create outcome json: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+			Body:       err.Error(),
+		}, err
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Body:       string(outcomeJSON),
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
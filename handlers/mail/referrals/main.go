@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ExportService "gitlab.com/ncent/arber/api/services/arber/export"
+	HireController "gitlab.com/ncent/arber/api/services/arber/hire"
+)
+
+var resolver = Resolver.New()
+
+// handler exports the referral tree of ?challengeId= in ?format=, one of
+// json (the default), dot or graphml. Only the sponsor of the challenge may
+// export it, with the sponsor token in the Authorization header as
+// "Bearer <token>", like on /hire.
+func handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	format, err := ExportService.ParseFormat(event.QueryStringParameters["format"])
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	challengeID := event.QueryStringParameters["challengeId"]
+	authorized, err := HireController.Authorized(ctx, resolver, challengeID, HireController.BearerToken(event.Headers))
+	if err != nil {
+		log.Printf("Failed to get challenge %v: %v", challengeID, err)
+		statusCode := 500
+		if errors.Is(err, Resolver.ErrNotFound) {
+			statusCode = 404
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: statusCode,
+			Body:       err.Error(),
+		}, nil
+	}
+	if !authorized {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 401,
+			Body:       HireController.ErrUnauthorized.Error(),
+		}, nil
+	}
+
+	// Senders come up again as recipients further down the tree, so users
+	// are memoized for the length of the export.
+	cachedResolver := Resolver.NewCachedResolver(resolver, Resolver.CacheTTL(), nil)
+	tree, err := ExportService.BuildTree(ctx, cachedResolver, challengeID)
+	if err != nil {
+		log.Printf("Failed to build referral tree: %v", err)
+		statusCode := 500
+		if errors.Is(err, Resolver.ErrNotFound) {
+			statusCode = 404
+		}
+		return events.APIGatewayProxyResponse{
+			StatusCode: statusCode,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	var body strings.Builder
+	if err := ExportService.Write(&body, tree, format); err != nil {
+		log.Printf("Failed to write referral tree: %v", err)
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+			Body:       err.Error(),
+		}, nil
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Body:       body.String(),
+		Headers: map[string]string{
+			"Content-Type": format.ContentType(),
+		},
+	}, nil
+}
+
+func main() {
+	lambda.Start(handler)
+}
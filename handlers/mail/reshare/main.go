@@ -2,26 +2,65 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 
 	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
 	ReshareService "gitlab.com/ncent/arber/api/services/arber/mail/reshare"
+	ShareController "gitlab.com/ncent/arber/api/services/arber/share"
 )
 
 var (
 	resolver = Resolver.New()
+	// Challenges are read on every reshare page load, so they are kept
+	// across invocations of a warm container.
+	sharedCache = Resolver.NewGroupCache("reshare", 16<<20, Resolver.CacheTTL())
 )
 
 func handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	reshareBody, err := ReshareService.GenerateReshareBodyByChallenge(resolver, event.QueryStringParameters["transactionId"], event.QueryStringParameters["challengeId"])
+	challengeID := event.QueryStringParameters["challengeId"]
+	var reshareBody *string
+	err := checkOpen(ctx, challengeID)
+	if err == nil {
+		cachedResolver := Resolver.NewCachedResolver(resolver, Resolver.CacheTTL(), sharedCache)
+		reshareBody, err = ReshareService.GenerateReshareBodyByChallenge(ctx, cachedResolver, event.QueryStringParameters["transactionId"], challengeID)
+	}
+
+	var closedErr *ChallengeController.ClosedError
+	if errors.As(err, &closedErr) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 410,
+			Body:       ReshareService.GenerateClosedBody(closedErr),
+			Headers: map[string]string{
+				"Content-Type": "text/html",
+			},
+		}, nil
+	}
+
+	var limitErr *ShareController.LimitError
+	if errors.As(err, &limitErr) {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 403,
+			Body:       ReshareService.GenerateRejectionBody(limitErr.Reason),
+			Headers: map[string]string{
+				"Content-Type": "text/html",
+			},
+		}, nil
+	}
 
 	if err != nil {
 		log.Printf("Failed to get challenge: %v", err)
+		statusCode := 500
+		if errors.Is(err, Resolver.ErrNotFound) {
+			statusCode = 404
+		}
 		return events.APIGatewayProxyResponse{
-			StatusCode: 404,
+			StatusCode: statusCode,
 			Body:       err.Error(),
 		}, err
 	}
@@ -35,6 +74,17 @@ func handler(ctx context.Context, event events.APIGatewayProxyRequest) (events.A
 	}, nil
 }
 
+// checkOpen reads the challenge past the shared cache: a Close or Expire
+// only invalidates the cache of the container that made it, and the others
+// would keep accepting reshares until their copy expires.
+func checkOpen(ctx context.Context, challengeID string) error {
+	challenge, err := resolver.GetChallengeContext(ctx, challengeID)
+	if err != nil {
+		return err
+	}
+	return ChallengeController.CheckOpen(challenge, time.Now())
+}
+
 func main() {
 	lambda.Start(handler)
 }
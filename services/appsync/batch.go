@@ -0,0 +1,362 @@
+package appsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	uuid "github.com/satori/go.uuid"
+)
+
+// DefaultBatchSize is how many aliased mutations BatchUpsertUsers sends in
+// one GraphQL request.
+const DefaultBatchSize = 25
+
+// UserUpsert is one user of a UserBatch. Exactly one of Create and Update is
+// set: Update for a user that is known to exist, Create otherwise.
+type UserUpsert struct {
+	Create *CreateUserInput
+	Update *UpdateUserInput
+}
+
+// UserBatch is a set of users to write and the contact edges between them.
+// Edges may name users created in the same batch, so give those creates an
+// ID up front; creates without one get a fresh ID before they are sent.
+type UserBatch struct {
+	Users    []UserUpsert
+	Contacts []CreateUserContactInput
+}
+
+// UserUpsertResult is the outcome of one UserUpsert.
+type UserUpsertResult struct {
+	User *User
+	Err  error
+}
+
+// UserContactResult is the outcome of one contact edge.
+type UserContactResult struct {
+	Contact *UserContact
+	Err     error
+}
+
+// UserBatchResult holds one result per item of the UserBatch, in order.
+type UserBatchResult struct {
+	Users    []UserUpsertResult
+	Contacts []UserContactResult
+}
+
+// Failed counts the users and contact edges that were not written.
+func (r *UserBatchResult) Failed() int {
+	failed := 0
+	for _, user := range r.Users {
+		if user.Err != nil {
+			failed++
+		}
+	}
+	for _, contact := range r.Contacts {
+		if contact.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+func (u UserUpsert) validate() error {
+	if (u.Create == nil) == (u.Update == nil) {
+		return newError(ErrValidation, "BatchUpsertUsers", "exactly one of Create and Update must be set")
+	}
+	return nil
+}
+
+func (u UserUpsert) id() string {
+	if u.Create != nil && u.Create.ID != nil {
+		return *u.Create.ID
+	}
+	if u.Update != nil {
+		return u.Update.ID
+	}
+	return ""
+}
+
+// assignIDs gives every create of the batch an ID, so contact edges and
+// replays refer to the same user.
+func (b UserBatch) assignIDs() {
+	for _, user := range b.Users {
+		if user.Create != nil && (user.Create.ID == nil || *user.Create.ID == "") {
+			id := uuid.NewV4().String()
+			user.Create.ID = &id
+		}
+	}
+}
+
+// failedUsers maps the ID of every user whose upsert failed to its error.
+func (r *UserBatchResult) failedUsers(batch UserBatch) map[string]error {
+	failed := map[string]error{}
+	for i, user := range batch.Users {
+		if r.Users[i].Err != nil && user.id() != "" {
+			failed[user.id()] = r.Users[i].Err
+		}
+	}
+	return failed
+}
+
+// skippedContact returns the error of an edge whose user or contact failed to
+// be written in this batch.
+func skippedContact(contact CreateUserContactInput, failed map[string]error) error {
+	for _, id := range []*string{contact.UserContactUserID, contact.UserContactContactID} {
+		if id == nil {
+			continue
+		}
+		if err, ok := failed[*id]; ok {
+			return fmt.Errorf("BatchUpsertUsers: skipped contact edge of user %v: %w", *id, err)
+		}
+	}
+	return nil
+}
+
+func (r AppSyncResolver) BatchUpsertUsers(batch UserBatch) (*UserBatchResult, error) {
+	return r.BatchUpsertUsersContext(context.Background(), batch)
+}
+
+// BatchUpsertUsersContext writes the users first and then the contact edges,
+// each in chunks of DefaultBatchSize aliased mutations per request. Failures
+// are reported per item; the error is only set when ctx ended before every
+// chunk was sent, and the items that were never sent carry it too.
+func (r AppSyncResolver) BatchUpsertUsersContext(ctx context.Context, batch UserBatch) (*UserBatchResult, error) {
+	batch.assignIDs()
+	result := &UserBatchResult{
+		Users:    make([]UserUpsertResult, len(batch.Users)),
+		Contacts: make([]UserContactResult, len(batch.Contacts)),
+	}
+
+	for start := 0; start < len(batch.Users); start += DefaultBatchSize {
+		end := start + DefaultBatchSize
+		if end > len(batch.Users) {
+			end = len(batch.Users)
+		}
+		if err := ctx.Err(); err != nil {
+			for i := start; i < len(batch.Users); i++ {
+				result.Users[i].Err = err
+			}
+			for i := range batch.Contacts {
+				result.Contacts[i].Err = err
+			}
+			return result, err
+		}
+		r.upsertUsers(ctx, batch.Users[start:end], result.Users[start:end])
+	}
+
+	failed := result.failedUsers(batch)
+	var contacts []CreateUserContactInput
+	var indexes []int
+	for i, contact := range batch.Contacts {
+		if err := skippedContact(contact, failed); err != nil {
+			result.Contacts[i].Err = err
+			continue
+		}
+		contacts = append(contacts, contact)
+		indexes = append(indexes, i)
+	}
+
+	for start := 0; start < len(contacts); start += DefaultBatchSize {
+		end := start + DefaultBatchSize
+		if end > len(contacts) {
+			end = len(contacts)
+		}
+		if err := ctx.Err(); err != nil {
+			for _, index := range indexes[start:] {
+				result.Contacts[index].Err = err
+			}
+			return result, err
+		}
+		chunk := make([]UserContactResult, end-start)
+		r.createUserContacts(ctx, contacts[start:end], chunk)
+		for i, contact := range chunk {
+			result.Contacts[indexes[start+i]] = contact
+		}
+	}
+
+	log.Printf("BatchUpsertUsers wrote %d users and %d contacts, %d failed", len(batch.Users), len(batch.Contacts), result.Failed())
+	return result, nil
+}
+
+// upsertUsers sends one chunk as a single request of aliased createUser and
+// updateUser mutations.
+func (r AppSyncResolver) upsertUsers(ctx context.Context, users []UserUpsert, results []UserUpsertResult) {
+	var pending []int
+	for i, user := range users {
+		if err := user.validate(); err != nil {
+			results[i].Err = err
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	build := func(pending []int) (string, map[string]interface{}) {
+		var definitions []string
+		var selections []string
+		variables := map[string]interface{}{}
+		for _, i := range pending {
+			user := users[i]
+			alias := fmt.Sprintf("u%d", i)
+			if user.Create != nil {
+				definitions = append(definitions, fmt.Sprintf("$%s: CreateUserInput!", alias))
+				selections = append(selections, fmt.Sprintf("  %s: createUser(input: $%s) {\n    ...UserFields\n  }", alias, alias))
+				variables[alias] = user.Create
+			} else {
+				definitions = append(definitions, fmt.Sprintf("$%s: UpdateUserInput!", alias))
+				selections = append(selections, fmt.Sprintf("  %s: updateUser(input: $%s) {\n    ...UserFields\n  }", alias, alias))
+				variables[alias] = user.Update
+			}
+		}
+		query := fmt.Sprintf("mutation BatchUpsertUsers(%s) {\n%s\n}\n\n%s", strings.Join(definitions, ", "), strings.Join(selections, "\n"), userFieldsFragment)
+		return query, variables
+	}
+	decode := func(i int, data interface{}, err error) error {
+		var item User
+		itemErr := decodeAlias("BatchUpsertUsers", data, err, fmt.Sprintf("u%d", i), &item)
+		if itemErr != nil && users[i].Update != nil && users[i].Update.ExpectedVersion != nil {
+			itemErr = asConflict(itemErr)
+		}
+		results[i] = UserUpsertResult{Err: itemErr}
+		if itemErr == nil {
+			results[i].User = &item
+		}
+		return itemErr
+	}
+	r.postAliases(ctx, "BatchUpsertUsers", pending, build, decode)
+}
+
+// createUserContacts sends one chunk of aliased createUserContact mutations.
+func (r AppSyncResolver) createUserContacts(ctx context.Context, contacts []CreateUserContactInput, results []UserContactResult) {
+	pending := make([]int, len(contacts))
+	for i := range contacts {
+		pending[i] = i
+	}
+
+	build := func(pending []int) (string, map[string]interface{}) {
+		var definitions []string
+		var selections []string
+		variables := map[string]interface{}{}
+		for _, i := range pending {
+			alias := fmt.Sprintf("c%d", i)
+			definitions = append(definitions, fmt.Sprintf("$%s: CreateUserContactInput!", alias))
+			selections = append(selections, fmt.Sprintf("  %s: createUserContact(input: $%s) {\n    id\n    user {\n      id\n    }\n    contact {\n      id\n    }\n  }", alias, alias))
+			variables[alias] = contacts[i]
+		}
+		query := fmt.Sprintf("mutation BatchCreateUserContacts(%s) {\n%s\n}", strings.Join(definitions, ", "), strings.Join(selections, "\n"))
+		return query, variables
+	}
+	decode := func(i int, data interface{}, err error) error {
+		var item UserContact
+		itemErr := decodeAlias("BatchCreateUserContacts", data, err, fmt.Sprintf("c%d", i), &item)
+		results[i] = UserContactResult{Err: itemErr}
+		if itemErr == nil {
+			results[i].Contact = &item
+		}
+		return itemErr
+	}
+	r.postAliases(ctx, "BatchCreateUserContacts", pending, build, decode)
+}
+
+// postAliases sends the aliased mutations of pending as one request made by
+// build and hands the outcome of each to decode. The request as a whole is
+// never replayed, since the aliases that succeeded would run again: creates
+// would fail their conditional check and contact edges would be duplicated.
+// Instead the aliases that failed without writing anything are sent again on
+// their own, under the resolver's retry policy.
+func (r AppSyncResolver) postAliases(ctx context.Context, operation string, pending []int, build func(pending []int) (string, map[string]interface{}), decode func(i int, data interface{}, err error) error) {
+	waits := r.retry.backOff(ctx)
+	waits.Reset()
+	for len(pending) > 0 {
+		query, variables := build(pending)
+		data, err := r.postAttempt(ctx, operation, query, variables)
+
+		var failed []int
+		for _, i := range pending {
+			if itemErr := decode(i, data, err); retryableAlias(itemErr, err) {
+				failed = append(failed, i)
+			}
+		}
+		if len(failed) == 0 {
+			return
+		}
+
+		wait := waits.NextBackOff()
+		if wait == backoff.Stop {
+			return
+		}
+		log.Printf("%s: %d of %d aliases failed, retrying them in %v", operation, len(failed), len(pending), wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		pending = failed
+	}
+}
+
+// retryableAlias reports whether an alias that failed with itemErr, in a
+// request that failed with requestErr, can be sent again. Its own errors say
+// it did not write; an error of the whole request only says so when AppSync
+// throttled the request before running any of it.
+func retryableAlias(itemErr error, requestErr error) bool {
+	if !errors.Is(itemErr, ErrThrottled) && !errors.Is(itemErr, ErrUnavailable) {
+		return false
+	}
+	return itemErr != requestErr || errors.Is(requestErr, ErrThrottled)
+}
+
+// decodeAlias decodes the data of one aliased mutation into result, or
+// returns the errors AppSync reported under that alias. When the request as
+// a whole failed, every alias without data gets that error.
+func decodeAlias(operation string, data interface{}, err error, alias string, result interface{}) error {
+	fields, _ := data.(map[string]interface{})
+	if fields != nil && fields[alias] != nil {
+		encoded, marshalErr := json.Marshal(fields[alias])
+		if marshalErr == nil {
+			marshalErr = json.Unmarshal(encoded, result)
+		}
+		if marshalErr != nil {
+			return newError(ErrUnknown, operation, fmt.Sprintf("failed to decode %s: %v", alias, marshalErr))
+		}
+		return nil
+	}
+
+	var appSyncError *Error
+	if !errors.As(err, &appSyncError) {
+		if err != nil {
+			return err
+		}
+		return newError(ErrNotFound, operation, fmt.Sprintf("%s returned null", alias))
+	}
+
+	var aliasErrors []GraphQLError
+	for _, graphQLError := range appSyncError.Errors {
+		if len(graphQLError.Path) > 0 && graphQLError.Path[0] == alias {
+			aliasErrors = append(aliasErrors, graphQLError)
+		}
+	}
+	if len(aliasErrors) == 0 {
+		return err
+	}
+
+	kind := ErrUnknown
+	for _, graphQLError := range aliasErrors {
+		if errorKind := kindFromErrorType(graphQLError.ErrorType, graphQLError.Message); errorKind != ErrUnknown {
+			kind = errorKind
+			break
+		}
+	}
+	return &Error{
+		Kind:       kind,
+		Operation:  operation,
+		StatusCode: appSyncError.StatusCode,
+		Errors:     aliasErrors,
+	}
+}
@@ -0,0 +1,125 @@
+package appsync
+
+import (
+	"errors"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestBatchUpsertUsers(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	ada := "ada@example.com"
+	bob := "bob@example.com"
+
+	g.Describe("MemoryResolver.BatchUpsertUsers", func() {
+		var resolver *MemoryResolver
+		var owner *User
+
+		g.BeforeEach(func() {
+			resolver = NewMemoryResolver()
+			var err error
+			owner, err = resolver.CreateUser(CreateUserInput{Emails: []*string{&ada}})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		g.It("Should create users and the contact edges that name them", func() {
+			id := "new-contact"
+			result, err := resolver.BatchUpsertUsers(UserBatch{
+				Users: []UserUpsert{
+					{Create: &CreateUserInput{ID: &id, Emails: []*string{&bob}}},
+					{Update: &UpdateUserInput{ID: *owner.ID, Names: []*string{&ada}}},
+				},
+				Contacts: []CreateUserContactInput{{UserContactUserID: &id, UserContactContactID: owner.ID}},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.Failed()).Should(Equal(0))
+			Expect(*result.Users[0].User.ID).Should(Equal(id))
+			Expect(result.Users[1].User.Names).Should(HaveLen(1))
+			Expect(*result.Contacts[0].Contact.User.ID).Should(Equal(id))
+		})
+
+		g.It("Should report failures per item and skip edges of failed users", func() {
+			stale := 0
+			missing := "missing"
+			result, err := resolver.BatchUpsertUsers(UserBatch{
+				Users: []UserUpsert{
+					{Update: &UpdateUserInput{ID: *owner.ID, ExpectedVersion: &stale}},
+					{Update: &UpdateUserInput{ID: missing}},
+					{},
+					{Create: &CreateUserInput{Emails: []*string{&bob}}},
+				},
+				Contacts: []CreateUserContactInput{{UserContactUserID: &missing, UserContactContactID: owner.ID}},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(errors.Is(result.Users[0].Err, ErrConflict)).Should(BeTrue())
+			Expect(errors.Is(result.Users[1].Err, ErrNotFound)).Should(BeTrue())
+			Expect(errors.Is(result.Users[2].Err, ErrValidation)).Should(BeTrue())
+			Expect(result.Users[3].Err).ShouldNot(HaveOccurred())
+			Expect(errors.Is(result.Contacts[0].Err, ErrNotFound)).Should(BeTrue())
+			Expect(result.Failed()).Should(Equal(4))
+		})
+	})
+
+	g.Describe("decodeAlias", func() {
+		data := map[string]interface{}{
+			"u0": map[string]interface{}{"id": "u-0"},
+			"u1": nil,
+		}
+		err := &Error{
+			Kind:      ErrConditionalCheckFailed,
+			Operation: "BatchUpsertUsers",
+			Errors: []GraphQLError{{
+				Message:   "The conditional request failed",
+				ErrorType: "DynamoDB:ConditionalCheckFailedException",
+				Path:      []interface{}{"u1"},
+			}},
+		}
+
+		g.It("Should decode the aliases that succeeded", func() {
+			var user User
+			Expect(decodeAlias("BatchUpsertUsers", data, err, "u0", &user)).Should(Succeed())
+			Expect(*user.ID).Should(Equal("u-0"))
+		})
+
+		g.It("Should give each alias only its own errors", func() {
+			var user User
+			aliasErr := decodeAlias("BatchUpsertUsers", data, err, "u1", &user)
+			Expect(errors.Is(aliasErr, ErrConditionalCheckFailed)).Should(BeTrue())
+			Expect(aliasErr.(*Error).Errors).Should(HaveLen(1))
+		})
+
+		g.It("Should fall back to the request error when the alias has none", func() {
+			var user User
+			requestErr := newError(ErrThrottled, "BatchUpsertUsers", "Too Many Requests")
+			Expect(errors.Is(decodeAlias("BatchUpsertUsers", nil, requestErr, "u0", &user), ErrThrottled)).Should(BeTrue())
+		})
+	})
+
+	g.Describe("retryableAlias", func() {
+		aliasErr := func(kind error) error {
+			requestErr := &Error{Kind: kind, Operation: "BatchCreateUserContacts", Errors: []GraphQLError{{Path: []interface{}{"c0"}}}}
+			return decodeAlias("BatchCreateUserContacts", nil, requestErr, "c0", &UserContact{})
+		}
+
+		g.It("Should retry aliases that failed transiently on their own", func() {
+			requestErr := newError(ErrUnknown, "BatchCreateUserContacts", "partial failure")
+			Expect(retryableAlias(newError(ErrThrottled, "BatchCreateUserContacts", "c0"), requestErr)).Should(BeTrue())
+			Expect(retryableAlias(newError(ErrUnavailable, "BatchCreateUserContacts", "c0"), requestErr)).Should(BeTrue())
+			Expect(retryableAlias(aliasErr(ErrConditionalCheckFailed), requestErr)).Should(BeFalse())
+			Expect(retryableAlias(nil, nil)).Should(BeFalse())
+		})
+
+		g.It("Should only retry a failed request when it was throttled", func() {
+			throttled := newError(ErrThrottled, "BatchCreateUserContacts", "Too Many Requests")
+			Expect(retryableAlias(throttled, throttled)).Should(BeTrue())
+			unavailable := newError(ErrUnavailable, "BatchCreateUserContacts", "Service Unavailable")
+			Expect(retryableAlias(unavailable, unavailable)).Should(BeFalse())
+		})
+	})
+}
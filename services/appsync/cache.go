@@ -0,0 +1,496 @@
+package appsync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+// DefaultCacheTTL is how long a cached challenge, user or email lookup is
+// served before AppSync is asked again.
+const DefaultCacheTTL = 30 * time.Second
+
+// CacheTTL reads AWS_APP_SYNC_CACHE_TTL (a duration such as "1m"), falling
+// back to DefaultCacheTTL.
+func CacheTTL() time.Duration {
+	return lookupDurationEnv("AWS_APP_SYNC_CACHE_TTL", DefaultCacheTTL)
+}
+
+// SharedCache outlives a single invocation. Get returns the value stored
+// under key, calling load to fill it on a miss. Invalidate drops the key so
+// the next Get loads it again.
+type SharedCache interface {
+	Get(ctx context.Context, key string, load func(ctx context.Context) ([]byte, error)) ([]byte, error)
+	Invalidate(key string)
+}
+
+// GroupCache is a SharedCache backed by a groupcache group. groupcache
+// entries are immutable, so keys are versioned: a TTL bucket expires them and
+// Invalidate bumps a local generation. Invalidation is therefore only seen by
+// the container that made the mutation; other containers catch up within the
+// TTL.
+type GroupCache struct {
+	group *groupcache.Group
+	ttl   time.Duration
+
+	mu          sync.Mutex
+	generations map[string]int
+}
+
+type groupLoad struct {
+	ctx  context.Context
+	load func(ctx context.Context) ([]byte, error)
+}
+
+// NewGroupCache registers a groupcache group holding up to cacheBytes. The
+// name must be unique within the process.
+func NewGroupCache(name string, cacheBytes int64, ttl time.Duration) *GroupCache {
+	getter := groupcache.GetterFunc(func(groupContext groupcache.Context, key string, dest groupcache.Sink) error {
+		request, ok := groupContext.(groupLoad)
+		if !ok {
+			return fmt.Errorf("no loader for shared cache key %v", key)
+		}
+		value, err := request.load(request.ctx)
+		if err != nil {
+			return err
+		}
+		return dest.SetBytes(value)
+	})
+
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &GroupCache{
+		group:       groupcache.NewGroup(name, cacheBytes, getter),
+		ttl:         ttl,
+		generations: map[string]int{},
+	}
+}
+
+func (c *GroupCache) Get(ctx context.Context, key string, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	generation := c.generations[key]
+	c.mu.Unlock()
+
+	bucket := time.Now().UnixNano() / int64(c.ttl)
+	versionedKey := fmt.Sprintf("%s@%d#%d", key, bucket, generation)
+
+	var value []byte
+	err := c.group.Get(groupLoad{ctx, load}, versionedKey, groupcache.AllocatingByteSliceSink(&value))
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (c *GroupCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations[key]++
+}
+
+type memoEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// CachedResolver memoizes GetChallenge, GetUser and the email lookups of the
+// Resolver it wraps for the life of one invocation, and reads through an
+// optional SharedCache on a miss. Mutations made through it invalidate the
+// entries they touch. Values are stored encoded, so callers always get their
+// own copy. Build one per invocation; every other method goes straight to the
+// wrapped Resolver.
+type CachedResolver struct {
+	Resolver
+
+	ttl    time.Duration
+	shared SharedCache
+
+	mu   sync.Mutex
+	memo map[string]memoEntry
+}
+
+// NewCachedResolver wraps resolver. shared may be nil to only memoize within
+// the invocation.
+func NewCachedResolver(resolver Resolver, ttl time.Duration, shared SharedCache) *CachedResolver {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &CachedResolver{
+		Resolver: resolver,
+		ttl:      ttl,
+		shared:   shared,
+		memo:     map[string]memoEntry{},
+	}
+}
+
+func challengeKey(id string) string {
+	return "challenge:" + id
+}
+
+func userKey(id string) string {
+	return "user:" + id
+}
+
+func emailKey(email string) string {
+	return "email:" + email
+}
+
+// load fills result from the memo, then the shared cache, then fetch.
+func (c *CachedResolver) load(ctx context.Context, key string, result interface{}, fetch func(ctx context.Context) (interface{}, error)) error {
+	c.mu.Lock()
+	entry, ok := c.memo[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return json.Unmarshal(entry.value, result)
+	}
+
+	encode := func(ctx context.Context) ([]byte, error) {
+		value, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(value)
+	}
+
+	var value []byte
+	var err error
+	if c.shared != nil {
+		value, err = c.shared.Get(ctx, key, encode)
+	} else {
+		value, err = encode(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.store(key, value)
+	return json.Unmarshal(value, result)
+}
+
+func (c *CachedResolver) store(key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.memo[key] = memoEntry{value: value, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *CachedResolver) lookup(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.memo[key]
+	if !ok || !time.Now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// invalidate drops keys from the memo and the shared cache.
+func (c *CachedResolver) invalidate(keys ...string) {
+	c.mu.Lock()
+	for _, key := range keys {
+		delete(c.memo, key)
+	}
+	c.mu.Unlock()
+
+	if c.shared != nil {
+		for _, key := range keys {
+			c.shared.Invalidate(key)
+		}
+	}
+}
+
+// invalidateUser drops the user and every email lookup that could have
+// returned it, before and after the mutation.
+func (c *CachedResolver) invalidateUser(id *string, emails ...[]*string) {
+	var keys []string
+	if id != nil {
+		keys = append(keys, userKey(*id))
+		if value, ok := c.lookup(userKey(*id)); ok {
+			var previous User
+			if json.Unmarshal(value, &previous) == nil {
+				emails = append(emails, previous.Emails)
+			}
+		}
+	}
+	for _, list := range emails {
+		for _, email := range list {
+			if email != nil {
+				keys = append(keys, emailKey(*email))
+			}
+		}
+	}
+	c.invalidate(keys...)
+}
+
+func (c *CachedResolver) GetChallenge(id string) (*Challenge, error) {
+	return c.GetChallengeContext(context.Background(), id)
+}
+
+func (c *CachedResolver) GetChallengeContext(ctx context.Context, id string) (*Challenge, error) {
+	var challenge Challenge
+	err := c.load(ctx, challengeKey(id), &challenge, func(ctx context.Context) (interface{}, error) {
+		return c.Resolver.GetChallengeContext(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (c *CachedResolver) CreateChallenge(input CreateChallengeInput) (*Challenge, error) {
+	return c.CreateChallengeContext(context.Background(), input)
+}
+
+func (c *CachedResolver) CreateChallengeContext(ctx context.Context, input CreateChallengeInput) (*Challenge, error) {
+	challenge, err := c.Resolver.CreateChallengeContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.ID != nil {
+		c.invalidate(challengeKey(*challenge.ID))
+	}
+	return challenge, nil
+}
+
+func (c *CachedResolver) UpdateChallenge(input UpdateChallengeInput) (*Challenge, error) {
+	return c.UpdateChallengeContext(context.Background(), input)
+}
+
+func (c *CachedResolver) UpdateChallengeContext(ctx context.Context, input UpdateChallengeInput) (*Challenge, error) {
+	challenge, err := c.Resolver.UpdateChallengeContext(ctx, input)
+	c.invalidate(challengeKey(input.ID))
+	return challenge, err
+}
+
+func (c *CachedResolver) GetUser(id string) (*User, error) {
+	return c.GetUserContext(context.Background(), id)
+}
+
+func (c *CachedResolver) GetUserContext(ctx context.Context, id string) (*User, error) {
+	var user User
+	err := c.load(ctx, userKey(id), &user, func(ctx context.Context) (interface{}, error) {
+		return c.Resolver.GetUserContext(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (c *CachedResolver) CreateUser(input CreateUserInput) (*User, error) {
+	return c.CreateUserContext(context.Background(), input)
+}
+
+func (c *CachedResolver) CreateUserContext(ctx context.Context, input CreateUserInput) (*User, error) {
+	user, err := c.Resolver.CreateUserContext(ctx, input)
+	// A failed create may still have raced another writer, so the email
+	// lookups are dropped either way.
+	c.invalidateUser(input.ID, input.Emails)
+	if err != nil {
+		return nil, err
+	}
+	c.invalidateUser(user.ID, user.Emails)
+	return user, nil
+}
+
+func (c *CachedResolver) UpdateUser(input UpdateUserInput) (*User, error) {
+	return c.UpdateUserContext(context.Background(), input)
+}
+
+func (c *CachedResolver) UpdateUserContext(ctx context.Context, input UpdateUserInput) (*User, error) {
+	// Lookups by an email the update removes must be dropped too, so read
+	// the emails the user has now when they are about to change.
+	var previousEmails []*string
+	if input.Emails != nil {
+		if previous, err := c.GetUserContext(ctx, input.ID); err == nil {
+			previousEmails = previous.Emails
+		}
+	}
+
+	user, err := c.Resolver.UpdateUserContext(ctx, input)
+	c.invalidateUser(&input.ID, input.Emails, previousEmails)
+	if err != nil {
+		return nil, err
+	}
+	c.invalidateUser(user.ID, user.Emails)
+	return user, nil
+}
+
+func (c *CachedResolver) MapUsersByEmails(emails []*string) (map[string]User, error) {
+	return c.MapUsersByEmailsContext(context.Background(), emails)
+}
+
+func (c *CachedResolver) MapUsersByEmailsContext(ctx context.Context, emails []*string) (map[string]User, error) {
+	users, err := c.ListUsersByEmailsContext(ctx, emails)
+	if err != nil {
+		return nil, err
+	}
+	return mapUsersByEmails(users), nil
+}
+
+func (c *CachedResolver) ListUsersByEmails(emails []*string) ([]User, error) {
+	return c.ListUsersByEmailsContext(context.Background(), emails)
+}
+
+// ListUsersByEmailsContext caches the users of every email separately, so a
+// lookup for one recipient is reused by a later lookup for a list containing
+// it. Only emails missing from the memo go to the shared cache or AppSync.
+func (c *CachedResolver) ListUsersByEmailsContext(ctx context.Context, emails []*string) ([]User, error) {
+	var users []User
+	seen := map[string]bool{}
+	add := func(found []User) {
+		for _, user := range found {
+			if user.ID != nil && seen[*user.ID] {
+				continue
+			}
+			if user.ID != nil {
+				seen[*user.ID] = true
+			}
+			users = append(users, user)
+		}
+	}
+
+	var missing []*string
+	for _, email := range emails {
+		if email == nil {
+			continue
+		}
+		if value, ok := c.lookup(emailKey(*email)); ok {
+			var found []User
+			if err := json.Unmarshal(value, &found); err == nil {
+				add(found)
+				continue
+			}
+		}
+		missing = append(missing, email)
+	}
+	if len(missing) == 0 {
+		return users, nil
+	}
+
+	if c.shared != nil && len(missing) == 1 {
+		email := missing[0]
+		var found []User
+		err := c.load(ctx, emailKey(*email), &found, func(ctx context.Context) (interface{}, error) {
+			return c.Resolver.ListUsersByEmailsContext(ctx, []*string{email})
+		})
+		if err != nil {
+			return nil, err
+		}
+		add(found)
+		return users, nil
+	}
+
+	// Several misses are fetched in one query and then split per email.
+	found, err := c.Resolver.ListUsersByEmailsContext(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, email := range missing {
+		var matches []User
+		for _, user := range found {
+			for _, userEmail := range user.Emails {
+				if userEmail != nil && *userEmail == *email {
+					matches = append(matches, user)
+					break
+				}
+			}
+		}
+		value, err := json.Marshal(matches)
+		if err != nil {
+			return nil, err
+		}
+		c.store(emailKey(*email), value)
+		if c.shared != nil {
+			c.shared.Get(ctx, emailKey(*email), func(ctx context.Context) ([]byte, error) {
+				return value, nil
+			})
+		}
+	}
+	add(found)
+	return users, nil
+}
+
+// IncludeDeleted returns a cache over the include-deleted view of the wrapped
+// Resolver. It starts empty and has no shared cache, so entries that hide
+// deleted rows are never served to it and its entries never leak back.
+func (c *CachedResolver) IncludeDeleted() Resolver {
+	return NewCachedResolver(c.Resolver.IncludeDeleted(), c.ttl, nil)
+}
+
+func (c *CachedResolver) DeleteUser(id string) (*User, error) {
+	return c.DeleteUserContext(context.Background(), id)
+}
+
+func (c *CachedResolver) DeleteUserContext(ctx context.Context, id string) (*User, error) {
+	user, err := c.Resolver.DeleteUserContext(ctx, id)
+	c.invalidateUser(&id)
+	if err != nil {
+		return nil, err
+	}
+	c.invalidateUser(user.ID, user.Emails)
+	return user, nil
+}
+
+func (c *CachedResolver) RestoreUser(id string) (*User, error) {
+	return c.RestoreUserContext(context.Background(), id)
+}
+
+func (c *CachedResolver) RestoreUserContext(ctx context.Context, id string) (*User, error) {
+	user, err := c.Resolver.RestoreUserContext(ctx, id)
+	c.invalidateUser(&id)
+	if err != nil {
+		return nil, err
+	}
+	c.invalidateUser(user.ID, user.Emails)
+	return user, nil
+}
+
+func (c *CachedResolver) DeleteChallenge(id string) (*Challenge, error) {
+	return c.DeleteChallengeContext(context.Background(), id)
+}
+
+func (c *CachedResolver) DeleteChallengeContext(ctx context.Context, id string) (*Challenge, error) {
+	challenge, err := c.Resolver.DeleteChallengeContext(ctx, id)
+	c.invalidate(challengeKey(id))
+	return challenge, err
+}
+
+func (c *CachedResolver) RestoreChallenge(id string) (*Challenge, error) {
+	return c.RestoreChallengeContext(context.Background(), id)
+}
+
+func (c *CachedResolver) RestoreChallengeContext(ctx context.Context, id string) (*Challenge, error) {
+	challenge, err := c.Resolver.RestoreChallengeContext(ctx, id)
+	c.invalidate(challengeKey(id))
+	return challenge, err
+}
+
+func (c *CachedResolver) BatchUpsertUsers(batch UserBatch) (*UserBatchResult, error) {
+	return c.BatchUpsertUsersContext(context.Background(), batch)
+}
+
+func (c *CachedResolver) BatchUpsertUsersContext(ctx context.Context, batch UserBatch) (*UserBatchResult, error) {
+	batch.assignIDs()
+	result, err := c.Resolver.BatchUpsertUsersContext(ctx, batch)
+	for _, user := range batch.Users {
+		switch {
+		case user.Create != nil:
+			c.invalidateUser(user.Create.ID, user.Create.Emails)
+		case user.Update != nil:
+			c.invalidateUser(&user.Update.ID, user.Update.Emails)
+		}
+	}
+	if result != nil {
+		for _, item := range result.Users {
+			if item.User != nil {
+				c.invalidateUser(item.User.ID, item.User.Emails)
+			}
+		}
+	}
+	return result, err
+}
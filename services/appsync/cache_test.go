@@ -0,0 +1,155 @@
+package appsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+// countingResolver counts the reads that reach the wrapped MemoryResolver.
+type countingResolver struct {
+	*MemoryResolver
+	challenges int
+	users      int
+	emails     int
+}
+
+func (r *countingResolver) GetChallengeContext(ctx context.Context, id string) (*Challenge, error) {
+	r.challenges++
+	return r.MemoryResolver.GetChallengeContext(ctx, id)
+}
+
+func (r *countingResolver) GetUserContext(ctx context.Context, id string) (*User, error) {
+	r.users++
+	return r.MemoryResolver.GetUserContext(ctx, id)
+}
+
+func (r *countingResolver) ListUsersByEmailsContext(ctx context.Context, emails []*string) ([]User, error) {
+	r.emails++
+	return r.MemoryResolver.ListUsersByEmailsContext(ctx, emails)
+}
+
+func TestCachedResolver(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	var base *countingResolver
+	var cached *CachedResolver
+	ada := "ada@example.com"
+	bob := "bob@example.com"
+
+	g.Describe("CachedResolver", func() {
+		g.BeforeEach(func() {
+			base = &countingResolver{MemoryResolver: NewMemoryResolver()}
+			cached = NewCachedResolver(base, time.Minute, nil)
+		})
+
+		g.It("Should memoize GetChallenge and hand out copies", func() {
+			name := "Engineer"
+			challenge, err := base.CreateChallenge(CreateChallengeInput{Name: &name})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			first, err := cached.GetChallenge(*challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			renamed := "Changed"
+			first.Name = &renamed
+
+			second, err := cached.GetChallenge(*challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*second.Name).Should(Equal("Engineer"))
+			Expect(base.challenges).Should(Equal(1))
+		})
+
+		g.It("Should invalidate a challenge when it is updated", func() {
+			challenge, err := base.CreateChallenge(CreateChallengeInput{})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = cached.GetChallenge(*challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			closed := "CLOSED"
+			_, err = cached.UpdateChallenge(UpdateChallengeInput{ID: *challenge.ID, Status: &closed})
+			Expect(err).ShouldNot(HaveOccurred())
+			updated, err := cached.GetChallenge(*challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*updated.Status).Should(Equal(closed))
+			Expect(base.challenges).Should(Equal(2))
+		})
+
+		g.It("Should not cache errors", func() {
+			_, err := cached.GetUser("missing")
+			Expect(errors.Is(err, ErrNotFound)).Should(BeTrue())
+			_, err = cached.GetUser("missing")
+			Expect(errors.Is(err, ErrNotFound)).Should(BeTrue())
+			Expect(base.users).Should(Equal(2))
+		})
+
+		g.It("Should cache email lookups per email, including misses", func() {
+			_, err := base.CreateUser(CreateUserInput{Emails: []*string{&ada}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			users, err := cached.ListUsersByEmails([]*string{&ada, &bob})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(HaveLen(1))
+
+			users, err = cached.ListUsersByEmails([]*string{&bob})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(BeEmpty())
+			users, err = cached.ListUsersByEmails([]*string{&ada})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(HaveLen(1))
+			Expect(base.emails).Should(Equal(1))
+		})
+
+		g.It("Should invalidate on user mutations", func() {
+			users, err := cached.ListUsersByEmails([]*string{&bob})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(BeEmpty())
+
+			created, err := cached.CreateUser(CreateUserInput{Emails: []*string{&bob}})
+			Expect(err).ShouldNot(HaveOccurred())
+			users, err = cached.ListUsersByEmails([]*string{&bob})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(HaveLen(1))
+
+			_, err = cached.GetUser(*created.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = cached.UpdateUser(UpdateUserInput{ID: *created.ID, Emails: []*string{&ada}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			user, err := cached.GetUser(*created.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*user.Emails[0]).Should(Equal(ada))
+			users, err = cached.ListUsersByEmails([]*string{&bob})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(BeEmpty())
+			Expect(base.users).Should(Equal(2))
+		})
+
+		g.It("Should read through and invalidate the shared cache", func() {
+			shared := NewGroupCache("cache-test", 1<<20, time.Minute)
+			_, err := base.CreateUser(CreateUserInput{Emails: []*string{&ada}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = NewCachedResolver(base, time.Minute, shared).ListUsersByEmails([]*string{&ada})
+			Expect(err).ShouldNot(HaveOccurred())
+			users, err := NewCachedResolver(base, time.Minute, shared).ListUsersByEmails([]*string{&ada})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(HaveLen(1))
+			Expect(base.emails).Should(Equal(1))
+
+			writer := NewCachedResolver(base, time.Minute, shared)
+			_, err = writer.UpdateUser(UpdateUserInput{ID: *users[0].ID, Emails: []*string{&bob}})
+			Expect(err).ShouldNot(HaveOccurred())
+			users, err = NewCachedResolver(base, time.Minute, shared).ListUsersByEmails([]*string{&ada})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(BeEmpty())
+			Expect(base.emails).Should(Equal(2))
+		})
+	})
+}
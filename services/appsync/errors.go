@@ -0,0 +1,169 @@
+package appsync
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Sentinel kinds carried by every Error. Callers branch with errors.Is.
+var (
+	ErrNotFound               = errors.New("not found")
+	ErrConditionalCheckFailed = errors.New("conditional check failed")
+	ErrConflict               = errors.New("version conflict")
+	ErrUnauthorized           = errors.New("unauthorized")
+	ErrThrottled              = errors.New("throttled")
+	ErrUnavailable            = errors.New("unavailable")
+	ErrValidation             = errors.New("validation failed")
+	ErrUnknown                = errors.New("appsync error")
+)
+
+// GraphQLError is one entry of the GraphQL `errors` array as AppSync sends it.
+type GraphQLError struct {
+	Message   string        `json:"message"`
+	ErrorType string        `json:"errorType"`
+	Path      []interface{} `json:"path"`
+}
+
+// Error is returned by every resolver method when AppSync reports a failure
+// or returns no entity. Kind is one of the sentinel errors above.
+type Error struct {
+	Kind       error
+	Operation  string
+	StatusCode int
+	Errors     []GraphQLError
+}
+
+func (e *Error) Error() string {
+	var messages []string
+	for _, graphQLError := range e.Errors {
+		if graphQLError.ErrorType != "" {
+			messages = append(messages, fmt.Sprintf("%s: %s", graphQLError.ErrorType, graphQLError.Message))
+		} else {
+			messages = append(messages, graphQLError.Message)
+		}
+	}
+	if len(messages) == 0 {
+		return fmt.Sprintf("%s: %v", e.Operation, e.Kind)
+	}
+	return fmt.Sprintf("%s: %v (%s)", e.Operation, e.Kind, strings.Join(messages, "; "))
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func newError(kind error, operation string, message string) *Error {
+	return &Error{
+		Kind:      kind,
+		Operation: operation,
+		Errors:    []GraphQLError{{Message: message}},
+	}
+}
+
+// errorFromResponse builds an Error from the HTTP status and the raw GraphQL
+// errors, or returns nil when the response carries neither.
+func errorFromResponse(operation string, statusCode *int, rawErrors *[]interface{}) error {
+	var graphQLErrors []GraphQLError
+	if rawErrors != nil {
+		for _, rawError := range *rawErrors {
+			graphQLErrors = append(graphQLErrors, decodeGraphQLError(rawError))
+		}
+	}
+
+	status := 0
+	if statusCode != nil {
+		status = *statusCode
+	}
+
+	if len(graphQLErrors) == 0 && (status == 0 || status == http.StatusOK) {
+		return nil
+	}
+
+	kind := kindFromStatus(status)
+	for _, graphQLError := range graphQLErrors {
+		if errorKind := kindFromErrorType(graphQLError.ErrorType, graphQLError.Message); errorKind != ErrUnknown {
+			kind = errorKind
+			break
+		}
+	}
+
+	return &Error{
+		Kind:       kind,
+		Operation:  operation,
+		StatusCode: status,
+		Errors:     graphQLErrors,
+	}
+}
+
+// asConflict reports a failed condition on a versioned update as ErrConflict:
+// the row exists, another writer just bumped its version first.
+func asConflict(err error) error {
+	var appSyncError *Error
+	if errors.As(err, &appSyncError) && appSyncError.Kind == ErrConditionalCheckFailed {
+		conflict := *appSyncError
+		conflict.Kind = ErrConflict
+		return &conflict
+	}
+	return err
+}
+
+func decodeGraphQLError(rawError interface{}) GraphQLError {
+	switch value := rawError.(type) {
+	case string:
+		return GraphQLError{Message: value}
+	case map[string]interface{}:
+		var graphQLError GraphQLError
+		if err := mapstructure.Decode(value, &graphQLError); err != nil {
+			return GraphQLError{Message: fmt.Sprintf("%v", value)}
+		}
+		return graphQLError
+	default:
+		return GraphQLError{Message: fmt.Sprintf("%v", value)}
+	}
+}
+
+func kindFromStatus(status int) error {
+	switch status {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrThrottled
+	case http.StatusBadRequest:
+		return ErrValidation
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrUnavailable
+	default:
+		return ErrUnknown
+	}
+}
+
+func kindFromErrorType(errorType string, message string) error {
+	switch {
+	case strings.Contains(errorType, "ConflictUnhandled"):
+		return ErrConflict
+	case strings.Contains(errorType, "ConditionalCheckFailed"):
+		return ErrConditionalCheckFailed
+	case strings.Contains(errorType, "Unauthorized"), strings.Contains(errorType, "AccessDenied"):
+		return ErrUnauthorized
+	case strings.Contains(errorType, "Throttl"), strings.Contains(errorType, "ProvisionedThroughputExceeded"),
+		strings.Contains(errorType, "RequestLimitExceeded"), strings.Contains(errorType, "TooManyRequests"):
+		return ErrThrottled
+	case strings.Contains(errorType, "ServiceUnavailable"), strings.Contains(errorType, "InternalFailure"),
+		strings.Contains(errorType, "InternalServerError"):
+		return ErrUnavailable
+	case strings.Contains(errorType, "Validation"), strings.Contains(errorType, "MappingTemplate"):
+		return ErrValidation
+	case strings.Contains(errorType, "NotFound"), strings.Contains(errorType, "ResourceNotFound"):
+		return ErrNotFound
+	case errorType == "" && strings.Contains(message, "Validation error"):
+		return ErrValidation
+	default:
+		return ErrUnknown
+	}
+}
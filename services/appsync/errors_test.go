@@ -0,0 +1,65 @@
+package appsync
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestErrors(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	status := func(code int) *int { return &code }
+
+	g.Describe("errorFromResponse", func() {
+		g.It("Should return nil for a clean response", func() {
+			Expect(errorFromResponse("GetUser", status(http.StatusOK), nil)).Should(BeNil())
+		})
+
+		g.It("Should classify GraphQL error types", func() {
+			rawErrors := []interface{}{
+				map[string]interface{}{
+					"errorType": "DynamoDB:ConditionalCheckFailedException",
+					"message":   "The conditional request failed",
+				},
+			}
+			err := errorFromResponse("CreateUser", status(http.StatusOK), &rawErrors)
+			Expect(errors.Is(err, ErrConditionalCheckFailed)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("The conditional request failed"))
+
+			rawErrors = []interface{}{map[string]interface{}{"errorType": "DynamoDB:ProvisionedThroughputExceededException"}}
+			Expect(errors.Is(errorFromResponse("GetUser", status(http.StatusOK), &rawErrors), ErrThrottled)).Should(BeTrue())
+		})
+
+		g.It("Should fall back to the HTTP status", func() {
+			rawErrors := []interface{}{"Unauthorized"}
+			err := errorFromResponse("GetUser", status(http.StatusUnauthorized), &rawErrors)
+			Expect(errors.Is(err, ErrUnauthorized)).Should(BeTrue())
+
+			var appsyncErr *Error
+			Expect(errors.As(err, &appsyncErr)).Should(BeTrue())
+			Expect(appsyncErr.StatusCode).Should(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	g.Describe("decode", func() {
+		g.It("Should report a null entity as not found", func() {
+			var result GetUserResponse
+			err := decode("GetUser", map[string]interface{}{"getUser": nil}, "getUser", &result)
+			Expect(errors.Is(err, ErrNotFound)).Should(BeTrue())
+		})
+
+		g.It("Should decode a present entity", func() {
+			var result GetUserResponse
+			err := decode("GetUser", map[string]interface{}{"getUser": map[string]interface{}{"id": "u1"}}, "getUser", &result)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*result.GetUser.ID).Should(Equal("u1"))
+		})
+	})
+}
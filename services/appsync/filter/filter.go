@@ -0,0 +1,123 @@
+// Package filter builds AppSync Model*FilterInput values.
+package filter
+
+import (
+	"encoding/json"
+)
+
+// Condition is the per-field operator set of an AppSync Model*FilterInput,
+// e.g. ModelStringFilterInput or ModelIDFilterInput.
+type Condition struct {
+	Ne          interface{}   `json:"ne,omitempty"`
+	Eq          interface{}   `json:"eq,omitempty"`
+	Le          interface{}   `json:"le,omitempty"`
+	Lt          interface{}   `json:"lt,omitempty"`
+	Ge          interface{}   `json:"ge,omitempty"`
+	Gt          interface{}   `json:"gt,omitempty"`
+	Contains    interface{}   `json:"contains,omitempty"`
+	NotContains interface{}   `json:"notContains,omitempty"`
+	Between     []interface{} `json:"between,omitempty"`
+	BeginsWith  interface{}   `json:"beginsWith,omitempty"`
+	Exists      *bool         `json:"attributeExists,omitempty"`
+}
+
+// Filter is a Model*FilterInput. Values only ever reach the request through
+// encoding/json, so user supplied strings cannot alter the filter structure.
+type Filter struct {
+	fields map[string]Condition
+	and    []Filter
+	or     []Filter
+	not    *Filter
+}
+
+func fieldFilter(field string, condition Condition) Filter {
+	return Filter{fields: map[string]Condition{field: condition}}
+}
+
+func Eq(field string, value interface{}) Filter {
+	return fieldFilter(field, Condition{Eq: value})
+}
+
+func Ne(field string, value interface{}) Filter {
+	return fieldFilter(field, Condition{Ne: value})
+}
+
+func Lt(field string, value interface{}) Filter {
+	return fieldFilter(field, Condition{Lt: value})
+}
+
+func Le(field string, value interface{}) Filter {
+	return fieldFilter(field, Condition{Le: value})
+}
+
+func Gt(field string, value interface{}) Filter {
+	return fieldFilter(field, Condition{Gt: value})
+}
+
+func Ge(field string, value interface{}) Filter {
+	return fieldFilter(field, Condition{Ge: value})
+}
+
+func Contains(field string, value interface{}) Filter {
+	return fieldFilter(field, Condition{Contains: value})
+}
+
+func NotContains(field string, value interface{}) Filter {
+	return fieldFilter(field, Condition{NotContains: value})
+}
+
+func BeginsWith(field string, prefix string) Filter {
+	return fieldFilter(field, Condition{BeginsWith: prefix})
+}
+
+func Between(field string, low interface{}, high interface{}) Filter {
+	return fieldFilter(field, Condition{Between: []interface{}{low, high}})
+}
+
+// Exists matches rows where the attribute is (or is not) present.
+func Exists(field string, exists bool) Filter {
+	return fieldFilter(field, Condition{Exists: &exists})
+}
+
+// And matches rows satisfying every filter.
+func And(filters ...Filter) Filter {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return Filter{and: filters}
+}
+
+// Or matches rows satisfying at least one filter.
+func Or(filters ...Filter) Filter {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return Filter{or: filters}
+}
+
+// Not negates a filter.
+func Not(filter Filter) Filter {
+	return Filter{not: &filter}
+}
+
+// IsEmpty reports whether the filter has no conditions at all.
+func (f Filter) IsEmpty() bool {
+	return len(f.fields) == 0 && len(f.and) == 0 && len(f.or) == 0 && f.not == nil
+}
+
+func (f Filter) MarshalJSON() ([]byte, error) {
+	input := make(map[string]interface{}, len(f.fields)+3)
+	for field, condition := range f.fields {
+		input[field] = condition
+	}
+	if len(f.and) > 0 {
+		input["and"] = f.and
+	}
+	if len(f.or) > 0 {
+		input["or"] = f.or
+	}
+	if f.not != nil {
+		input["not"] = f.not
+	}
+	return json.Marshal(input)
+}
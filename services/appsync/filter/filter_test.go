@@ -0,0 +1,57 @@
+package filter_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	"gitlab.com/ncent/arber/api/services/appsync/filter"
+)
+
+func TestFilter(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	marshal := func(f filter.Filter) string {
+		bytes, err := json.Marshal(map[string]interface{}{"filter": f})
+		Expect(err).ShouldNot(HaveOccurred())
+		return string(bytes)
+	}
+
+	g.Describe("Filter", func() {
+		g.It("Should marshal field conditions", func() {
+			Expect(marshal(filter.Eq("challengeId", "c1"))).Should(MatchJSON(`{"filter": {"challengeId": {"eq": "c1"}}}`))
+			Expect(marshal(filter.Between("createdAt", "a", "b"))).Should(MatchJSON(`{"filter": {"createdAt": {"between": ["a", "b"]}}}`))
+			Expect(marshal(filter.BeginsWith("name", "Eng"))).Should(MatchJSON(`{"filter": {"name": {"beginsWith": "Eng"}}}`))
+			Expect(marshal(filter.Lt("expiration", "t"))).Should(MatchJSON(`{"filter": {"expiration": {"lt": "t"}}}`))
+			Expect(marshal(filter.Ge("maxDepth", 2))).Should(MatchJSON(`{"filter": {"maxDepth": {"ge": 2}}}`))
+		})
+
+		g.It("Should nest and, or and not inside the filter", func() {
+			f := filter.And(
+				filter.Eq("challengeId", "c1"),
+				filter.Or(filter.Eq("userId", "u1"), filter.Ne("userId", "u2")),
+				filter.Not(filter.Contains("emails", "a@b.c")),
+			)
+			Expect(marshal(f)).Should(MatchJSON(`{"filter": {"and": [
+				{"challengeId": {"eq": "c1"}},
+				{"or": [{"userId": {"eq": "u1"}}, {"userId": {"ne": "u2"}}]},
+				{"not": {"emails": {"contains": "a@b.c"}}}
+			]}}`))
+		})
+
+		g.It("Should escape values instead of splicing them", func() {
+			hostile := `x" } }, "or": { "id": { "ne": "`
+			Expect(marshal(filter.Contains("emails", hostile))).Should(MatchJSON(
+				`{"filter": {"emails": {"contains": "x\" } }, \"or\": { \"id\": { \"ne\": \""}}}`,
+			))
+		})
+
+		g.It("Should collapse single element combinators", func() {
+			Expect(marshal(filter.Or(filter.Eq("id", "1")))).Should(MatchJSON(`{"filter": {"id": {"eq": "1"}}}`))
+		})
+	})
+}
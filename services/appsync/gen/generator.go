@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"github.com/vektah/gqlparser"
+	"github.com/vektah/gqlparser/ast"
+)
+
+const header = "// Code generated by services/appsync/gen from schema.graphql and operations/*.graphql. DO NOT EDIT.\n\n"
+
+const filterImport = "gitlab.com/ncent/arber/api/services/appsync/filter"
+
+// scalars maps GraphQL scalars, including the AWS ones, to Go types.
+var scalars = map[string]string{
+	"ID":          "string",
+	"String":      "string",
+	"Int":         "int",
+	"Float":       "float64",
+	"Boolean":     "bool",
+	"AWSDateTime": "string",
+	"AWSDate":     "string",
+	"AWSTime":     "string",
+	"AWSEmail":    "string",
+	"AWSJSON":     "string",
+	"AWSURL":      "string",
+	"AWSPhone":    "string",
+}
+
+type variable struct {
+	name    string
+	goName  string
+	goType  string
+	param   string
+	nonNull bool
+}
+
+type operation struct {
+	name      string
+	document  string
+	variables []variable
+	field     string
+	fieldType string
+	paginated bool
+	itemType  string
+	// softDelete is set on queries of types with a deletedAt field, whose
+	// deleted rows are hidden unless the resolver includes them.
+	softDelete bool
+}
+
+type generator struct {
+	schema      *ast.Schema
+	packageName string
+	types       map[string]bool
+	ops         []operation
+	usesFilter  bool
+	// fragments holds every fragment an operation uses, printed with the
+	// fragments it spreads, for documents assembled at run time.
+	fragments map[string]string
+}
+
+func newGenerator(schema *ast.Schema, packageName string) *generator {
+	return &generator{
+		schema:      schema,
+		packageName: packageName,
+		types:       map[string]bool{},
+		fragments:   map[string]string{},
+	}
+}
+
+// addOperation validates one operation, with the fragments it uses, against
+// the schema and records the types it needs.
+func (g *generator) addOperation(op *ast.OperationDefinition, fragments ast.FragmentDefinitionList) error {
+	if op.Name == "" {
+		return fmt.Errorf("operations must be named")
+	}
+
+	used := map[string]*ast.FragmentDefinition{}
+	if err := collectFragments(op.SelectionSet, fragments, used); err != nil {
+		return err
+	}
+	var names []string
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := []string{printOperation(op)}
+	for _, name := range names {
+		parts = append(parts, printFragment(used[name]))
+		if err := g.addFragment(used[name], fragments); err != nil {
+			return err
+		}
+	}
+	document := strings.Join(parts, "\n\n")
+	if strings.Contains(document, "`") {
+		return fmt.Errorf("document contains a backtick")
+	}
+
+	validated, errs := gqlparser.LoadQuery(g.schema, document)
+	if errs != nil {
+		return errs
+	}
+	validatedOp := validated.Operations.ForName(op.Name)
+
+	if len(validatedOp.SelectionSet) != 1 {
+		return fmt.Errorf("operations must select exactly one root field")
+	}
+	root, ok := validatedOp.SelectionSet[0].(*ast.Field)
+	if !ok {
+		return fmt.Errorf("the root selection must be a field")
+	}
+
+	result := operation{
+		name:     op.Name,
+		document: document,
+		field:    root.Alias,
+	}
+	if result.field == "" {
+		result.field = root.Name
+	}
+
+	for _, definition := range validatedOp.VariableDefinitions {
+		goType := g.inputGoType(definition.Type)
+		result.variables = append(result.variables, variable{
+			name:    definition.Variable,
+			goName:  goName(definition.Variable),
+			goType:  goType,
+			param:   paramName(definition.Variable),
+			nonNull: definition.Type.NonNull,
+		})
+	}
+
+	rootType := root.Definition.Type
+	if rootType.Elem != nil {
+		return fmt.Errorf("list root fields are not supported")
+	}
+	g.markType(rootType.Name())
+	result.fieldType = "*" + goName(rootType.Name())
+
+	definition := g.schema.Types[rootType.Name()]
+	items := definition.Fields.ForName("items")
+	if items != nil && definition.Fields.ForName("nextToken") != nil &&
+		result.hasVariable("limit") && result.hasVariable("nextToken") {
+		result.paginated = true
+		result.itemType = g.outputGoType(items.Type)
+		definition = g.schema.Types[items.Type.Name()]
+	}
+	if validatedOp.Operation == ast.Query && definition.Fields.ForName("deletedAt") != nil {
+		result.softDelete = !result.paginated || result.hasVariable("filter")
+	}
+
+	g.ops = append(g.ops, result)
+	return nil
+}
+
+func (g *generator) addFragment(fragment *ast.FragmentDefinition, fragments ast.FragmentDefinitionList) error {
+	if _, ok := g.fragments[fragment.Name]; ok {
+		return nil
+	}
+	used := map[string]*ast.FragmentDefinition{}
+	if err := collectFragments(fragment.SelectionSet, fragments, used); err != nil {
+		return err
+	}
+	delete(used, fragment.Name)
+	var names []string
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := []string{printFragment(fragment)}
+	for _, name := range names {
+		parts = append(parts, printFragment(used[name]))
+	}
+	g.fragments[fragment.Name] = strings.Join(parts, "\n\n")
+	return nil
+}
+
+func (o operation) hasVariable(name string) bool {
+	for _, v := range o.variables {
+		if v.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func collectFragments(set ast.SelectionSet, fragments ast.FragmentDefinitionList, used map[string]*ast.FragmentDefinition) error {
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if err := collectFragments(s.SelectionSet, fragments, used); err != nil {
+				return err
+			}
+		case *ast.InlineFragment:
+			if err := collectFragments(s.SelectionSet, fragments, used); err != nil {
+				return err
+			}
+		case *ast.FragmentSpread:
+			if _, ok := used[s.Name]; ok {
+				continue
+			}
+			fragment := fragments.ForName(s.Name)
+			if fragment == nil {
+				return fmt.Errorf("unknown fragment %s", s.Name)
+			}
+			used[s.Name] = fragment
+			if err := collectFragments(fragment.SelectionSet, fragments, used); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isFilterInput reports whether an input type is an AppSync filter, which
+// is built with package filter instead of a generated struct.
+func isFilterInput(name string) bool {
+	return strings.HasPrefix(name, "Model") && strings.HasSuffix(name, "FilterInput")
+}
+
+// markType records a schema type and everything reachable from its fields.
+func (g *generator) markType(name string) {
+	if _, ok := scalars[name]; ok || g.types[name] || isFilterInput(name) {
+		return
+	}
+	definition := g.schema.Types[name]
+	if definition == nil || definition.BuiltIn {
+		return
+	}
+	g.types[name] = true
+	for _, field := range definition.Fields {
+		g.markType(field.Type.Name())
+	}
+}
+
+func (g *generator) namedGoType(name string) string {
+	if goType, ok := scalars[name]; ok {
+		return goType
+	}
+	if isFilterInput(name) {
+		g.usesFilter = true
+		return "filter.Filter"
+	}
+	return goName(name)
+}
+
+// inputGoType maps a variable or input field type. Nullable values become
+// pointers so that unset fields are omitted from the request.
+func (g *generator) inputGoType(t *ast.Type) string {
+	if t.Elem != nil {
+		return "[]" + g.inputGoType(t.Elem)
+	}
+	g.markType(t.NamedType)
+	if t.NonNull {
+		return g.namedGoType(t.NamedType)
+	}
+	return "*" + g.namedGoType(t.NamedType)
+}
+
+// outputGoType maps a response field type. Scalars are always pointers since
+// a selection set may leave any field out.
+func (g *generator) outputGoType(t *ast.Type) string {
+	if t.Elem != nil {
+		return "[]" + g.outputElemGoType(t.Elem)
+	}
+	return "*" + g.namedGoType(t.NamedType)
+}
+
+func (g *generator) outputElemGoType(t *ast.Type) string {
+	if t.Elem != nil {
+		return "[]" + g.outputElemGoType(t.Elem)
+	}
+	definition := g.schema.Types[t.NamedType]
+	if t.NonNull && definition != nil && definition.IsLeafType() {
+		return g.namedGoType(t.NamedType)
+	}
+	return "*" + g.namedGoType(t.NamedType)
+}
+
+func (g *generator) models() ([]byte, error) {
+	var names []string
+	for name := range g.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString(header)
+	fmt.Fprintf(&b, "package %s\n\n", g.packageName)
+
+	for _, name := range names {
+		definition := g.schema.Types[name]
+		switch definition.Kind {
+		case ast.Enum:
+			fmt.Fprintf(&b, "type %s string\n\nconst (\n", goName(name))
+			for _, value := range definition.EnumValues {
+				fmt.Fprintf(&b, "\t%s%s %s = %q\n", goName(name), goName(strings.ToLower(value.Name)), goName(name), value.Name)
+			}
+			b.WriteString(")\n\n")
+		case ast.Object:
+			fmt.Fprintf(&b, "type %s struct {\n", goName(name))
+			for _, field := range definition.Fields {
+				if strings.HasPrefix(field.Name, "__") {
+					continue
+				}
+				fmt.Fprintf(&b, "\t%s %s `json:\"%s,omitempty\"`\n", goName(field.Name), g.outputGoType(field.Type), field.Name)
+			}
+			b.WriteString("}\n\n")
+		case ast.InputObject:
+			fmt.Fprintf(&b, "type %s struct {\n", goName(name))
+			for _, field := range definition.Fields {
+				tag := field.Name + ",omitempty"
+				if field.Type.NonNull {
+					tag = field.Name
+				}
+				fmt.Fprintf(&b, "\t%s %s `json:\"%s\"`\n", goName(field.Name), g.inputGoType(field.Type), tag)
+			}
+			b.WriteString("}\n\n")
+		}
+	}
+	return format.Source(b.Bytes())
+}
+
+func (g *generator) operations() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(header)
+	fmt.Fprintf(&b, "package %s\n\n", g.packageName)
+	b.WriteString("import (\n\t\"context\"\n")
+	if g.usesFilter {
+		fmt.Fprintf(&b, "\n\t%q\n", filterImport)
+	}
+	b.WriteString(")\n\n")
+
+	var fragmentNames []string
+	for name := range g.fragments {
+		fragmentNames = append(fragmentNames, name)
+	}
+	sort.Strings(fragmentNames)
+	for _, name := range fragmentNames {
+		fmt.Fprintf(&b, "// %sFragment is the %s fragment with the fragments it spreads.\n", lowerFirst(name), name)
+		fmt.Fprintf(&b, "const %sFragment = `%s`\n\n", lowerFirst(name), g.fragments[name])
+	}
+
+	for _, op := range g.ops {
+		documentName := lowerFirst(op.name) + "Document"
+		fmt.Fprintf(&b, "const %s = `%s`\n\n", documentName, op.document)
+
+		fmt.Fprintf(&b, "// %sVariables are the variables of the %s operation.\n", op.name, op.name)
+		fmt.Fprintf(&b, "type %sVariables struct {\n", op.name)
+		for _, v := range op.variables {
+			tag := v.name + ",omitempty"
+			if v.nonNull {
+				tag = v.name
+			}
+			fmt.Fprintf(&b, "\t%s %s `json:\"%s\"`\n", v.goName, v.goType, tag)
+		}
+		b.WriteString("}\n\n")
+
+		fmt.Fprintf(&b, "// %sResponse is the data returned by the %s operation.\n", op.name, op.name)
+		fmt.Fprintf(&b, "type %sResponse struct {\n\t%s %s `json:\"%s\"`\n}\n\n", op.name, goName(op.field), op.fieldType, op.field)
+
+		if op.paginated {
+			g.writePaginatedMethod(&b, op, documentName)
+		} else {
+			g.writeMethod(&b, op, documentName)
+		}
+	}
+	return format.Source(b.Bytes())
+}
+
+// writeWrapper writes the method without a context, which runs the Context
+// variant with context.Background().
+func (g *generator) writeWrapper(b *bytes.Buffer, op operation, params []string, args []string, resultType string) {
+	fmt.Fprintf(b, "// %s runs the %s operation without a deadline.\n", op.name, op.name)
+	fmt.Fprintf(b, "func (r AppSyncResolver) %s(%s) (%s, error) {\n", op.name, strings.Join(params, ", "), resultType)
+	fmt.Fprintf(b, "\treturn r.%sContext(%s)\n}\n\n", op.name, strings.Join(append([]string{"context.Background()"}, args...), ", "))
+}
+
+func (g *generator) writeMethod(b *bytes.Buffer, op operation, documentName string) {
+	var params, args, assignments []string
+	for _, v := range op.variables {
+		params = append(params, v.param+" "+v.goType)
+		args = append(args, v.param)
+		assignments = append(assignments, v.goName+": "+v.param)
+	}
+	g.writeWrapper(b, op, params, args, op.fieldType)
+
+	fmt.Fprintf(b, "// %sContext runs the %s operation, retrying transient failures until ctx is done.\n", op.name, op.name)
+	fmt.Fprintf(b, "func (r AppSyncResolver) %sContext(%s) (%s, error) {\n", op.name, strings.Join(append([]string{"ctx context.Context"}, params...), ", "), op.fieldType)
+	fmt.Fprintf(b, "\tvar response %sResponse\n", op.name)
+	fmt.Fprintf(b, "\terr := r.do(ctx, %q, %s, %sVariables{%s}, %q, &response)\n", op.name, documentName, op.name, strings.Join(assignments, ", "), op.field)
+	b.WriteString("\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+	if op.softDelete {
+		fmt.Fprintf(b, "\tif !r.includeDeleted && response.%s.DeletedAt != nil {\n", goName(op.field))
+		fmt.Fprintf(b, "\t\treturn nil, newError(ErrNotFound, %q, %q)\n\t}\n", op.name, op.field+" returned a deleted entity")
+	}
+	fmt.Fprintf(b, "\treturn response.%s, nil\n}\n\n", goName(op.field))
+}
+
+func (g *generator) writePaginatedMethod(b *bytes.Buffer, op operation, documentName string) {
+	var params, args, assignments []string
+	for _, v := range op.variables {
+		switch v.name {
+		case "limit":
+			if strings.HasPrefix(v.goType, "*") {
+				assignments = append(assignments, v.goName+": &limit")
+			} else {
+				assignments = append(assignments, v.goName+": limit")
+			}
+		case "nextToken":
+			assignments = append(assignments, v.goName+": nextToken")
+		default:
+			params = append(params, v.param+" "+v.goType)
+			args = append(args, v.param)
+			assignments = append(assignments, v.goName+": "+v.param)
+		}
+	}
+	field := goName(op.field)
+	g.writeWrapper(b, op, params, args, op.itemType)
+
+	fmt.Fprintf(b, "// %sContext runs the %s operation, following nextToken across every page.\n", op.name, op.name)
+	fmt.Fprintf(b, "func (r AppSyncResolver) %sContext(%s) (%s, error) {\n", op.name, strings.Join(append([]string{"ctx context.Context"}, params...), ", "), op.itemType)
+	if op.softDelete {
+		b.WriteString("\tmodelFilter = r.excludeDeleted(modelFilter)\n")
+	}
+	fmt.Fprintf(b, "\tvar items %s\n", op.itemType)
+	b.WriteString("\terr := r.pagination.Each(func(limit int, nextToken *string) (*string, int, error) {\n")
+	fmt.Fprintf(b, "\t\tvar response %sResponse\n", op.name)
+	fmt.Fprintf(b, "\t\terr := r.do(ctx, %q, %s, %sVariables{%s}, %q, &response)\n", op.name, documentName, op.name, strings.Join(assignments, ", "), op.field)
+	b.WriteString("\t\tif err != nil {\n\t\t\treturn nil, 0, err\n\t\t}\n")
+	fmt.Fprintf(b, "\t\titems = append(items, response.%s.Items...)\n", field)
+	fmt.Fprintf(b, "\t\treturn response.%s.NextToken, len(response.%s.Items), nil\n", field, field)
+	b.WriteString("\t})\n")
+	b.WriteString("\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+	b.WriteString("\treturn items, nil\n}\n\n")
+}
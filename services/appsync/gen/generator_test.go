@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	"github.com/vektah/gqlparser"
+	"github.com/vektah/gqlparser/ast"
+)
+
+func TestGenerator(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("goName", func() {
+		g.It("Should keep initialisms upper case", func() {
+			Expect(goName("id")).Should(Equal("ID"))
+			Expect(goName("imageUrl")).Should(Equal("ImageURL"))
+			Expect(goName("attachmentURL")).Should(Equal("AttachmentURL"))
+			Expect(goName("transactionActionId")).Should(Equal("TransactionActionID"))
+		})
+
+		g.It("Should camel case enum values", func() {
+			Expect(goName("share_action")).Should(Equal("ShareAction"))
+		})
+	})
+
+	g.Describe("Generated files", func() {
+		g.It("Should match schema.graphql and operations", func() {
+			source, err := ioutil.ReadFile("../schema.graphql")
+			Expect(err).ShouldNot(HaveOccurred())
+			schema, gqlErr := gqlparser.LoadSchema(&ast.Source{Name: "schema.graphql", Input: string(source)})
+			Expect(gqlErr).Should(BeNil())
+
+			document, err := loadOperations("../operations")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			generator := newGenerator(schema, "appsync")
+			for _, operation := range document.Operations {
+				Expect(generator.addOperation(operation, document.Fragments)).Should(Succeed())
+			}
+
+			models, err := generator.models()
+			Expect(err).ShouldNot(HaveOccurred())
+			operations, err := generator.operations()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			current, err := ioutil.ReadFile(filepath.Join("..", "models_gen.go"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(models)).Should(Equal(string(current)), "models_gen.go is stale, run make generate")
+			current, err = ioutil.ReadFile(filepath.Join("..", "operations_gen.go"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(operations)).Should(Equal(string(current)), "operations_gen.go is stale, run make generate")
+		})
+	})
+}
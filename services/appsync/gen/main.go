@@ -0,0 +1,90 @@
+// Command gen reads the AppSync schema and the .graphql operation files and
+// writes the Go models and resolver methods for package appsync.
+//
+// Usage (from services/appsync):
+//
+//	go run ./gen -schema schema.graphql -operations operations -out .
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vektah/gqlparser"
+	"github.com/vektah/gqlparser/ast"
+	"github.com/vektah/gqlparser/parser"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "schema.graphql", "AppSync schema in SDL")
+	operationsDir := flag.String("operations", "operations", "directory of .graphql operation and fragment files")
+	outDir := flag.String("out", ".", "directory to write models_gen.go and operations_gen.go into")
+	packageName := flag.String("package", "appsync", "package name of the generated files")
+	flag.Parse()
+
+	schemaSource, err := ioutil.ReadFile(*schemaPath)
+	if err != nil {
+		log.Fatalf("Failed to read schema: %v", err)
+	}
+	schema, gqlErr := gqlparser.LoadSchema(&ast.Source{Name: *schemaPath, Input: string(schemaSource)})
+	if gqlErr != nil {
+		log.Fatalf("Failed to load schema: %v", gqlErr)
+	}
+
+	document, err := loadOperations(*operationsDir)
+	if err != nil {
+		log.Fatalf("Failed to load operations: %v", err)
+	}
+
+	g := newGenerator(schema, *packageName)
+	for _, operation := range document.Operations {
+		if err := g.addOperation(operation, document.Fragments); err != nil {
+			log.Fatalf("Failed to generate %s: %v", operation.Name, err)
+		}
+	}
+
+	models, err := g.models()
+	if err != nil {
+		log.Fatalf("Failed to generate models: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(*outDir, "models_gen.go"), models, 0644); err != nil {
+		log.Fatalf("Failed to write models: %v", err)
+	}
+
+	operations, err := g.operations()
+	if err != nil {
+		log.Fatalf("Failed to generate operations: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(*outDir, "operations_gen.go"), operations, 0644); err != nil {
+		log.Fatalf("Failed to write operations: %v", err)
+	}
+}
+
+// loadOperations parses every .graphql file in dir into one document so
+// fragments can be shared between files.
+func loadOperations(dir string) (*ast.QueryDocument, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.graphql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var sources []string
+	for _, path := range paths {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, string(source))
+	}
+
+	document, gqlErr := parser.ParseQuery(&ast.Source{Name: dir, Input: strings.Join(sources, "\n")})
+	if gqlErr != nil {
+		return nil, gqlErr
+	}
+	return document, nil
+}
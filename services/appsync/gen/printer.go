@@ -0,0 +1,166 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/vektah/gqlparser/ast"
+)
+
+// initialisms are upper-cased in Go names, following golint.
+var initialisms = map[string]bool{
+	"id":   true,
+	"url":  true,
+	"json": true,
+	"api":  true,
+	"http": true,
+	"aws":  true,
+}
+
+var keywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true,
+	"default": true, "defer": true, "else": true, "fallthrough": true, "for": true,
+	"func": true, "go": true, "goto": true, "if": true, "import": true,
+	"interface": true, "map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true, "var": true,
+}
+
+// goName turns a GraphQL name such as attachmentURL, transactionActionId or
+// SHARE_ACTION into an exported Go identifier.
+func goName(name string) string {
+	var words []string
+	var word []rune
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-':
+			if len(word) > 0 {
+				words = append(words, string(word))
+			}
+			word = nil
+			continue
+		case unicode.IsUpper(r) && len(word) > 0:
+			previousLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if previousLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+
+	var b strings.Builder
+	for _, w := range words {
+		lower := strings.ToLower(w)
+		if initialisms[lower] {
+			b.WriteString(strings.ToUpper(lower))
+			continue
+		}
+		b.WriteString(strings.ToUpper(lower[:1]) + lower[1:])
+	}
+	return b.String()
+}
+
+func lowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// paramName is the method parameter for a variable. filter is renamed so it
+// does not shadow the filter package.
+func paramName(name string) string {
+	if name == "filter" {
+		return "modelFilter"
+	}
+	if keywords[name] {
+		return name + "Value"
+	}
+	return name
+}
+
+func printOperation(op *ast.OperationDefinition) string {
+	var b strings.Builder
+	b.WriteString(string(op.Operation) + " " + op.Name)
+	if len(op.VariableDefinitions) > 0 {
+		var definitions []string
+		for _, definition := range op.VariableDefinitions {
+			text := "$" + definition.Variable + ": " + definition.Type.String()
+			if definition.DefaultValue != nil {
+				text += " = " + definition.DefaultValue.String()
+			}
+			definitions = append(definitions, text)
+		}
+		b.WriteString("(" + strings.Join(definitions, ", ") + ")")
+	}
+	printDirectives(&b, op.Directives)
+	b.WriteString(" ")
+	printSelectionSet(&b, op.SelectionSet, 0)
+	return b.String()
+}
+
+func printFragment(fragment *ast.FragmentDefinition) string {
+	var b strings.Builder
+	b.WriteString("fragment " + fragment.Name + " on " + fragment.TypeCondition)
+	printDirectives(&b, fragment.Directives)
+	b.WriteString(" ")
+	printSelectionSet(&b, fragment.SelectionSet, 0)
+	return b.String()
+}
+
+func printSelectionSet(b *strings.Builder, set ast.SelectionSet, depth int) {
+	b.WriteString("{\n")
+	indent := strings.Repeat("  ", depth+1)
+	for _, selection := range set {
+		b.WriteString(indent)
+		switch s := selection.(type) {
+		case *ast.Field:
+			if s.Alias != "" && s.Alias != s.Name {
+				b.WriteString(s.Alias + ": ")
+			}
+			b.WriteString(s.Name)
+			printArguments(b, s.Arguments)
+			printDirectives(b, s.Directives)
+			if len(s.SelectionSet) > 0 {
+				b.WriteString(" ")
+				printSelectionSet(b, s.SelectionSet, depth+1)
+			}
+		case *ast.FragmentSpread:
+			b.WriteString("..." + s.Name)
+			printDirectives(b, s.Directives)
+		case *ast.InlineFragment:
+			b.WriteString("...")
+			if s.TypeCondition != "" {
+				b.WriteString(" on " + s.TypeCondition)
+			}
+			printDirectives(b, s.Directives)
+			b.WriteString(" ")
+			printSelectionSet(b, s.SelectionSet, depth+1)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(strings.Repeat("  ", depth) + "}")
+}
+
+func printArguments(b *strings.Builder, arguments ast.ArgumentList) {
+	if len(arguments) == 0 {
+		return
+	}
+	var parts []string
+	for _, argument := range arguments {
+		parts = append(parts, argument.Name+": "+argument.Value.String())
+	}
+	b.WriteString("(" + strings.Join(parts, ", ") + ")")
+}
+
+func printDirectives(b *strings.Builder, directives ast.DirectiveList) {
+	for _, directive := range directives {
+		b.WriteString(" @" + directive.Name)
+		printArguments(b, directive.Arguments)
+	}
+}
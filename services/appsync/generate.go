@@ -0,0 +1,6 @@
+package appsync
+
+// models_gen.go and operations_gen.go are generated from schema.graphql and
+// the operations and fragments in operations/. After changing either, run
+// `go generate ./services/appsync` (or `make generate`).
+//go:generate go run ./gen -schema schema.graphql -operations operations -out .
@@ -0,0 +1,1560 @@
+package appsync
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+type memoryUserContact struct {
+	id        string
+	userID    string
+	contactID string
+}
+
+type memoryChallenge struct {
+	input     CreateChallengeInput
+	closedAt  *string
+	deletedAt *string
+}
+
+type memoryShareAction struct {
+	id                 string
+	challengeID        *string
+	userID             *string
+	mailAuthentication *string
+	deletedAt          *string
+}
+
+type memoryShareActionContact struct {
+	id            string
+	shareActionID *string
+	contactID     *string
+	createdAt     string
+}
+
+type memoryTransaction struct {
+	id                  string
+	parentTransactionID *string
+	actionID            *string
+	status              *string
+	attemptCounter      *int
+	transitionErrors    []*string
+	fraudFlags          []*string
+	deletedAt           *string
+}
+
+type memoryApplication struct {
+	input      CreateApplicationInput
+	hiredAt    *string
+	payoutPlan *string
+	createdAt  string
+	updatedAt  string
+}
+
+// MemoryResolver is an in-process Resolver backed by maps. It applies the
+// same filters and relations as the AppSync queries so controllers can be
+// exercised without AWS, including hiding soft-deleted rows.
+type MemoryResolver struct {
+	*memoryStore
+	includeDeleted bool
+}
+
+// memoryStore holds the rows, so IncludeDeleted views share them.
+type memoryStore struct {
+	mu sync.RWMutex
+
+	users               map[string]User
+	userIDs             []string
+	userContacts        map[string]memoryUserContact
+	userContactIDs      []string
+	challenges          map[string]memoryChallenge
+	challengeIDs        []string
+	shareActions        map[string]memoryShareAction
+	shareActionIDs      []string
+	shareActionContacts map[string]memoryShareActionContact
+	contactIDs          []string
+	transactions        map[string]memoryTransaction
+	transactionIDs      []string
+	applications        map[string]memoryApplication
+	applicationIDs      []string
+}
+
+func NewMemoryResolver() *MemoryResolver {
+	return &MemoryResolver{memoryStore: &memoryStore{
+		users:               map[string]User{},
+		userContacts:        map[string]memoryUserContact{},
+		challenges:          map[string]memoryChallenge{},
+		shareActions:        map[string]memoryShareAction{},
+		shareActionContacts: map[string]memoryShareActionContact{},
+		transactions:        map[string]memoryTransaction{},
+		applications:        map[string]memoryApplication{},
+	}}
+}
+
+// IncludeDeleted returns a view of the same rows that also returns
+// soft-deleted ones.
+func (r *MemoryResolver) IncludeDeleted() Resolver {
+	return &MemoryResolver{memoryStore: r.memoryStore, includeDeleted: true}
+}
+
+// visible reports whether a row stamped with deletedAt should be returned.
+func (r *MemoryResolver) visible(deletedAt *string) bool {
+	return r.includeDeleted || deletedAt == nil
+}
+
+func newMemoryID(id *string) string {
+	if id != nil && *id != "" {
+		return *id
+	}
+	return uuid.NewV4().String()
+}
+
+func copyStrings(values []*string) []*string {
+	if values == nil {
+		return nil
+	}
+	copied := make([]*string, 0, len(values))
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		v := *value
+		copied = append(copied, &v)
+	}
+	return copied
+}
+
+func copyString(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	v := *value
+	return &v
+}
+
+func setString(field **string, value *string) {
+	if value != nil {
+		*field = copyString(value)
+	}
+}
+
+func setInt(field **int, value *int) {
+	if value != nil {
+		*field = copyInt(value)
+	}
+}
+
+func setBool(field **bool, value *bool) {
+	if value != nil {
+		*field = copyBool(value)
+	}
+}
+
+func copyInt(value *int) *int {
+	if value == nil {
+		return nil
+	}
+	v := *value
+	return &v
+}
+
+func copyBool(value *bool) *bool {
+	if value == nil {
+		return nil
+	}
+	v := *value
+	return &v
+}
+
+func (r *MemoryResolver) CreateUser(input CreateUserInput) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := newMemoryID(input.ID)
+	if _, exists := r.users[id]; exists {
+		return nil, newError(ErrConditionalCheckFailed, "CreateUser", fmt.Sprintf("User %v already exists", id))
+	}
+	version := 1
+	r.users[id] = User{
+		ID:              &id,
+		Version:         &version,
+		Emails:          copyStrings(input.Emails),
+		CanonicalEmails: copyStrings(input.CanonicalEmails),
+		Etag:            copyString(input.Etag),
+		Identity:        copyString(input.Identity),
+		Names:           copyStrings(input.Names),
+		PhoneNumbers:    copyStrings(input.PhoneNumbers),
+		Pictures:        copyStrings(input.Pictures),
+		Token:           copyString(input.Token),
+	}
+	r.userIDs = append(r.userIDs, id)
+	return r.user(id), nil
+}
+
+func (r *MemoryResolver) UpdateUser(input UpdateUserInput) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[input.ID]
+	if !ok {
+		return nil, newError(ErrNotFound, "UpdateUser", fmt.Sprintf("User %v not found", input.ID))
+	}
+	if input.ExpectedVersion != nil && versionOf(user.Version) != *input.ExpectedVersion {
+		return nil, newError(ErrConflict, "UpdateUser", fmt.Sprintf("User %v is no longer at version %v", input.ID, *input.ExpectedVersion))
+	}
+	user.Version = nextVersion(user.Version)
+	if input.Emails != nil {
+		user.Emails = copyStrings(input.Emails)
+	}
+	if input.CanonicalEmails != nil {
+		user.CanonicalEmails = copyStrings(input.CanonicalEmails)
+	}
+	if input.MergedInto != nil {
+		user.MergedInto = copyString(input.MergedInto)
+	}
+	if input.MergeProposals != nil {
+		user.MergeProposals = copyStrings(input.MergeProposals)
+	}
+	if input.UnsubscribedAt != nil {
+		user.UnsubscribedAt = copyString(input.UnsubscribedAt)
+	}
+	if input.Etag != nil {
+		user.Etag = copyString(input.Etag)
+	}
+	if input.Identity != nil {
+		user.Identity = copyString(input.Identity)
+	}
+	if input.Names != nil {
+		user.Names = copyStrings(input.Names)
+	}
+	if input.PhoneNumbers != nil {
+		user.PhoneNumbers = copyStrings(input.PhoneNumbers)
+	}
+	if input.Pictures != nil {
+		user.Pictures = copyStrings(input.Pictures)
+	}
+	if input.Token != nil {
+		user.Token = copyString(input.Token)
+	}
+	if input.DeletedAt != nil {
+		user.DeletedAt = copyString(input.DeletedAt)
+	}
+	r.users[input.ID] = user
+	return r.user(input.ID), nil
+}
+
+func (r *MemoryResolver) GetUser(id string) (*User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if user, ok := r.users[id]; !ok || !r.visible(user.DeletedAt) {
+		return nil, newError(ErrNotFound, "GetUser", fmt.Sprintf("User %v not found", id))
+	}
+	return r.user(id), nil
+}
+
+func (r *MemoryResolver) MapUsersByEmails(emails []*string) (map[string]User, error) {
+	users, err := r.ListUsersByEmails(emails)
+	if err != nil {
+		return nil, err
+	}
+	return mapUsersByEmails(users), nil
+}
+
+// ListUsersByEmails returns every user whose emails contain at least one of
+// the given addresses, matching the `contains` filter on the emails list.
+func (r *MemoryResolver) ListUsersByEmails(emails []*string) ([]User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := map[string]bool{}
+	for _, email := range emails {
+		if email != nil {
+			wanted[*email] = true
+		}
+	}
+
+	var users []User
+	for _, id := range r.userIDs {
+		if !r.visible(r.users[id].DeletedAt) {
+			continue
+		}
+		for _, email := range r.users[id].Emails {
+			if wanted[*email] {
+				users = append(users, *r.user(id))
+				break
+			}
+		}
+	}
+	return users, nil
+}
+
+// ListUsersByCanonicalEmails returns every user whose canonical emails
+// contain at least one of the given canonical addresses.
+func (r *MemoryResolver) ListUsersByCanonicalEmails(emails []*string) ([]User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := map[string]bool{}
+	for _, email := range emails {
+		if email != nil {
+			wanted[*email] = true
+		}
+	}
+
+	var users []User
+	for _, id := range r.userIDs {
+		if !r.visible(r.users[id].DeletedAt) {
+			continue
+		}
+		for _, email := range r.users[id].CanonicalEmails {
+			if email != nil && wanted[*email] {
+				users = append(users, *r.user(id))
+				break
+			}
+		}
+	}
+	return users, nil
+}
+
+func (r *MemoryResolver) CreateUserContact(input CreateUserContactInput) (*UserContact, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if input.UserContactUserID == nil || input.UserContactContactID == nil {
+		return nil, newError(ErrValidation, "CreateUserContact", "UserContact requires both user and contact ids")
+	}
+	if _, ok := r.users[*input.UserContactUserID]; !ok {
+		return nil, newError(ErrNotFound, "CreateUserContact", fmt.Sprintf("User %v not found", *input.UserContactUserID))
+	}
+	if _, ok := r.users[*input.UserContactContactID]; !ok {
+		return nil, newError(ErrNotFound, "CreateUserContact", fmt.Sprintf("User %v not found", *input.UserContactContactID))
+	}
+
+	id := newMemoryID(nil)
+	r.userContacts[id] = memoryUserContact{
+		id:        id,
+		userID:    *input.UserContactUserID,
+		contactID: *input.UserContactContactID,
+	}
+	r.userContactIDs = append(r.userContactIDs, id)
+	return r.userContact(id), nil
+}
+
+func (r *MemoryResolver) UpdateUserContact(input UpdateUserContactInput) (*UserContact, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	contact, ok := r.userContacts[input.ID]
+	if !ok {
+		return nil, newError(ErrNotFound, "UpdateUserContact", fmt.Sprintf("UserContact %v not found", input.ID))
+	}
+	for _, id := range []*string{input.UserContactUserID, input.UserContactContactID} {
+		if id == nil {
+			continue
+		}
+		if _, ok := r.users[*id]; !ok {
+			return nil, newError(ErrNotFound, "UpdateUserContact", fmt.Sprintf("User %v not found", *id))
+		}
+	}
+	if input.UserContactUserID != nil {
+		contact.userID = *input.UserContactUserID
+	}
+	if input.UserContactContactID != nil {
+		contact.contactID = *input.UserContactContactID
+	}
+	r.userContacts[input.ID] = contact
+	return r.userContact(input.ID), nil
+}
+
+// DeleteUserContact removes the edge for good; contact edges are not
+// soft-deleted.
+func (r *MemoryResolver) DeleteUserContact(input DeleteUserContactInput) (*UserContact, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.userContacts[input.ID]; !ok {
+		return nil, newError(ErrNotFound, "DeleteUserContact", fmt.Sprintf("UserContact %v not found", input.ID))
+	}
+	deleted := r.userContact(input.ID)
+	delete(r.userContacts, input.ID)
+	for i, id := range r.userContactIDs {
+		if id == input.ID {
+			r.userContactIDs = append(r.userContactIDs[:i], r.userContactIDs[i+1:]...)
+			break
+		}
+	}
+	return deleted, nil
+}
+
+func (r *MemoryResolver) GetUserContactsByUser(userID string) ([]*UserContact, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var contacts []*UserContact
+	for _, id := range r.userContactIDs {
+		if r.userContacts[id].userID == userID {
+			contacts = append(contacts, r.userContact(id))
+		}
+	}
+	return contacts, nil
+}
+
+func (r *MemoryResolver) GetUserContactsByContact(contactID string) ([]*UserContact, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var contacts []*UserContact
+	for _, id := range r.userContactIDs {
+		if r.userContacts[id].contactID == contactID {
+			contacts = append(contacts, r.userContact(id))
+		}
+	}
+	return contacts, nil
+}
+
+// ListUsersByPhoneNumbers returns every user whose phone numbers contain at
+// least one of the given numbers, compared as stored.
+func (r *MemoryResolver) ListUsersByPhoneNumbers(phoneNumbers []*string) ([]User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := map[string]bool{}
+	for _, phoneNumber := range phoneNumbers {
+		if phoneNumber != nil {
+			wanted[*phoneNumber] = true
+		}
+	}
+
+	var users []User
+	for _, id := range r.userIDs {
+		if !r.visible(r.users[id].DeletedAt) {
+			continue
+		}
+		for _, phoneNumber := range r.users[id].PhoneNumbers {
+			if phoneNumber != nil && wanted[*phoneNumber] {
+				users = append(users, *r.user(id))
+				break
+			}
+		}
+	}
+	return users, nil
+}
+
+func (r *MemoryResolver) CreateChallenge(input CreateChallengeInput) (*Challenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := newMemoryID(input.ID)
+	if _, exists := r.challenges[id]; exists {
+		return nil, newError(ErrConditionalCheckFailed, "CreateChallenge", fmt.Sprintf("Challenge %v already exists", id))
+	}
+	input.ID = &id
+	r.challenges[id] = memoryChallenge{input: input}
+	r.challengeIDs = append(r.challengeIDs, id)
+	return r.challenge(id), nil
+}
+
+// UpdateChallenge sets the fields present in input and keeps the rest. Like
+// the updateChallenge resolver it refuses to write when an expected status is
+// given and the stored one differs.
+func (r *MemoryResolver) UpdateChallenge(input UpdateChallengeInput) (*Challenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.challenges[input.ID]
+	if !ok {
+		return nil, newError(ErrNotFound, "UpdateChallenge", fmt.Sprintf("Challenge %v not found", input.ID))
+	}
+	if input.ExpectedStatus != nil && statusOf(stored.input.Status) != *input.ExpectedStatus {
+		return nil, newError(ErrConditionalCheckFailed, "UpdateChallenge", fmt.Sprintf("Challenge %v is no longer %q", input.ID, *input.ExpectedStatus))
+	}
+	challenge := &stored.input
+	setString(&challenge.Name, input.Name)
+	setString(&challenge.Description, input.Description)
+	setString(&challenge.ImageURL, input.ImageURL)
+	setString(&challenge.SponsorName, input.SponsorName)
+	setString(&challenge.SponsorEmail, input.SponsorEmail)
+	setString(&challenge.Expiration, input.Expiration)
+	setString(&challenge.ShareExpiration, input.ShareExpiration)
+	setInt(&challenge.MaxShares, input.MaxShares)
+	setInt(&challenge.MaxRewards, input.MaxRewards)
+	setBool(&challenge.OffChain, input.OffChain)
+	setInt(&challenge.MaxDistributionFeeReward, input.MaxDistributionFeeReward)
+	setInt(&challenge.MaxSharesPerReceivedShare, input.MaxSharesPerReceivedShare)
+	setInt(&challenge.MaxDepth, input.MaxDepth)
+	setInt(&challenge.MaxNodes, input.MaxNodes)
+	setString(&challenge.PublicKey, input.PublicKey)
+	setString(&challenge.Reward, input.Reward)
+	setBool(&challenge.Active, input.Active)
+	setString(&challenge.Status, input.Status)
+	setString(&challenge.AttributionModel, input.AttributionModel)
+	setString(&challenge.ChallengeTemplateID, input.ChallengeTemplateID)
+	setString(&challenge.ChallengeParentChallengeID, input.ChallengeParentChallengeID)
+	setString(&challenge.AttachmentURL, input.AttachmentURL)
+	setString(&challenge.MailAuthentication, input.MailAuthentication)
+	setString(&stored.closedAt, input.ClosedAt)
+	setString(&stored.deletedAt, input.DeletedAt)
+	r.challenges[input.ID] = stored
+	return r.challenge(input.ID), nil
+}
+
+// GetChallengesExpiringBefore compares expirations as times, where the
+// AppSync filter compares the stored strings.
+func (r *MemoryResolver) GetChallengesExpiringBefore(at string) ([]*Challenge, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	before, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return nil, newError(ErrValidation, "ListChallenges", fmt.Sprintf("Invalid time %q", at))
+	}
+	var challenges []*Challenge
+	for _, id := range r.challengeIDs {
+		stored := r.challenges[id]
+		if !r.visible(stored.deletedAt) || stored.input.Expiration == nil {
+			continue
+		}
+		expiration, err := time.Parse(time.RFC3339, *stored.input.Expiration)
+		if err != nil || !expiration.Before(before) {
+			continue
+		}
+		challenges = append(challenges, r.challenge(id))
+	}
+	return challenges, nil
+}
+
+func (r *MemoryResolver) GetChallenge(id string) (*Challenge, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if challenge, ok := r.challenges[id]; !ok || !r.visible(challenge.deletedAt) {
+		return nil, newError(ErrNotFound, "GetChallenge", fmt.Sprintf("Challenge %v not found", id))
+	}
+	return r.challenge(id), nil
+}
+
+func (r *MemoryResolver) CreateShareAction(input CreateShareActionInput) (*ShareAction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := newMemoryID(input.ID)
+	if _, exists := r.shareActions[id]; exists {
+		return nil, newError(ErrConditionalCheckFailed, "CreateShareAction", fmt.Sprintf("ShareAction %v already exists", id))
+	}
+	r.shareActions[id] = memoryShareAction{
+		id:          id,
+		challengeID: copyString(input.ChallengeID),
+		userID:      copyString(input.UserID),
+	}
+	r.shareActionIDs = append(r.shareActionIDs, id)
+	return r.shareAction(id), nil
+}
+
+func (r *MemoryResolver) UpdateShareAction(input UpdateShareActionInput) (*ShareAction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	shareAction, ok := r.shareActions[input.ID]
+	if !ok {
+		return nil, newError(ErrNotFound, "UpdateShareAction", fmt.Sprintf("ShareAction %v not found", input.ID))
+	}
+	if input.ChallengeID != nil {
+		shareAction.challengeID = copyString(input.ChallengeID)
+	}
+	if input.UserID != nil {
+		shareAction.userID = copyString(input.UserID)
+	}
+	if input.MailAuthentication != nil {
+		shareAction.mailAuthentication = copyString(input.MailAuthentication)
+	}
+	if input.DeletedAt != nil {
+		shareAction.deletedAt = copyString(input.DeletedAt)
+	}
+	r.shareActions[input.ID] = shareAction
+	return r.shareAction(input.ID), nil
+}
+
+func (r *MemoryResolver) CreateShareActionContact(input CreateShareActionContactInput) (*ShareActionContact, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if input.ShareActionContactShareActionID != nil {
+		if _, ok := r.shareActions[*input.ShareActionContactShareActionID]; !ok {
+			return nil, newError(ErrNotFound, "CreateShareActionContact", fmt.Sprintf("ShareAction %v not found", *input.ShareActionContactShareActionID))
+		}
+	}
+	if input.ShareActionContactContactID != nil {
+		if _, ok := r.users[*input.ShareActionContactContactID]; !ok {
+			return nil, newError(ErrNotFound, "CreateShareActionContact", fmt.Sprintf("User %v not found", *input.ShareActionContactContactID))
+		}
+	}
+
+	id := newMemoryID(input.ID)
+	if _, exists := r.shareActionContacts[id]; exists {
+		return nil, newError(ErrConditionalCheckFailed, "CreateShareActionContact", fmt.Sprintf("ShareActionContact %v already exists", id))
+	}
+	r.shareActionContacts[id] = memoryShareActionContact{
+		id:            id,
+		shareActionID: copyString(input.ShareActionContactShareActionID),
+		contactID:     copyString(input.ShareActionContactContactID),
+		createdAt:     time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	r.contactIDs = append(r.contactIDs, id)
+	return r.shareActionContact(id), nil
+}
+
+// ShareActionContacts returns the contacts recorded against a share action,
+// in creation order. It is not part of Resolver and exists for assertions.
+func (r *MemoryResolver) ShareActionContacts(shareActionID string) []*ShareActionContact {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var contacts []*ShareActionContact
+	for _, id := range r.contactIDs {
+		contact := r.shareActionContacts[id]
+		if contact.shareActionID != nil && *contact.shareActionID == shareActionID {
+			contacts = append(contacts, r.shareActionContact(id))
+		}
+	}
+	return contacts
+}
+
+func (r *MemoryResolver) GetShareActionContactsByShareAction(shareActionID string) ([]*ShareActionContact, error) {
+	return r.ShareActionContacts(shareActionID), nil
+}
+
+// CreateApplication checks the challenge, candidate and transaction exist and
+// stamps createdAt, which the AppSync resolver does server side.
+func (r *MemoryResolver) CreateApplication(input CreateApplicationInput) (*Application, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if input.ChallengeID != nil {
+		if _, ok := r.challenges[*input.ChallengeID]; !ok {
+			return nil, newError(ErrNotFound, "CreateApplication", fmt.Sprintf("Challenge %v not found", *input.ChallengeID))
+		}
+	}
+	if input.CandidateID != nil {
+		if _, ok := r.users[*input.CandidateID]; !ok {
+			return nil, newError(ErrNotFound, "CreateApplication", fmt.Sprintf("User %v not found", *input.CandidateID))
+		}
+	}
+	if input.TransactionID != nil {
+		if _, ok := r.transactions[*input.TransactionID]; !ok {
+			return nil, newError(ErrNotFound, "CreateApplication", fmt.Sprintf("Transaction %v not found", *input.TransactionID))
+		}
+	}
+
+	id := newMemoryID(input.ID)
+	if _, exists := r.applications[id]; exists {
+		return nil, newError(ErrConditionalCheckFailed, "CreateApplication", fmt.Sprintf("Application %v already exists", id))
+	}
+	input.ID = &id
+	stamp := time.Now().UTC().Format(time.RFC3339Nano)
+	r.applications[id] = memoryApplication{input: input, createdAt: stamp, updatedAt: stamp}
+	r.applicationIDs = append(r.applicationIDs, id)
+	return r.application(id), nil
+}
+
+// UpdateApplication sets the fields present in input and keeps the rest.
+func (r *MemoryResolver) UpdateApplication(input UpdateApplicationInput) (*Application, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.applications[input.ID]
+	if !ok {
+		return nil, newError(ErrNotFound, "UpdateApplication", fmt.Sprintf("Application %v not found", input.ID))
+	}
+	application := &stored.input
+	setString(&application.CandidateID, input.CandidateID)
+	setString(&application.TransactionID, input.TransactionID)
+	setString(&application.ResumeURL, input.ResumeURL)
+	setString(&application.Message, input.Message)
+	setString(&application.Status, input.Status)
+	setString(&application.Attribution, input.Attribution)
+	setString(&stored.hiredAt, input.HiredAt)
+	setString(&stored.payoutPlan, input.PayoutPlan)
+	stored.updatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	r.applications[input.ID] = stored
+	return r.application(input.ID), nil
+}
+
+func (r *MemoryResolver) GetApplication(id string) (*Application, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.applications[id]; !ok {
+		return nil, newError(ErrNotFound, "GetApplication", fmt.Sprintf("Application %v not found", id))
+	}
+	return r.application(id), nil
+}
+
+func (r *MemoryResolver) GetApplicationsByChallenge(challengeID string) ([]*Application, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var applications []*Application
+	for _, id := range r.applicationIDs {
+		application := r.applications[id].input
+		if application.ChallengeID != nil && *application.ChallengeID == challengeID {
+			applications = append(applications, r.application(id))
+		}
+	}
+	return applications, nil
+}
+
+func (r *MemoryResolver) GetApplicationsByCandidate(candidateID string) ([]*Application, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var applications []*Application
+	for _, id := range r.applicationIDs {
+		application := r.applications[id].input
+		if application.CandidateID != nil && *application.CandidateID == candidateID {
+			applications = append(applications, r.application(id))
+		}
+	}
+	return applications, nil
+}
+
+func (r *MemoryResolver) GetApplicationsByChallengeAndCandidate(challengeID string, candidateID string) ([]*Application, error) {
+	applications, err := r.GetApplicationsByChallenge(challengeID)
+	if err != nil {
+		return nil, err
+	}
+	var matching []*Application
+	for _, application := range applications {
+		if application.CandidateID != nil && *application.CandidateID == candidateID {
+			matching = append(matching, application)
+		}
+	}
+	return matching, nil
+}
+
+func (r *MemoryResolver) UpdateShareActionContact(input UpdateShareActionContactInput) (*ShareActionContact, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	contact, ok := r.shareActionContacts[input.ID]
+	if !ok {
+		return nil, newError(ErrNotFound, "UpdateShareActionContact", fmt.Sprintf("ShareActionContact %v not found", input.ID))
+	}
+	if input.ShareActionContactContactID != nil {
+		if _, ok := r.users[*input.ShareActionContactContactID]; !ok {
+			return nil, newError(ErrNotFound, "UpdateShareActionContact", fmt.Sprintf("User %v not found", *input.ShareActionContactContactID))
+		}
+		contact.contactID = copyString(input.ShareActionContactContactID)
+	}
+	r.shareActionContacts[input.ID] = contact
+	return r.shareActionContact(input.ID), nil
+}
+
+// GetShareActionContactsByContact returns every share addressed to the
+// user, in creation order.
+func (r *MemoryResolver) GetShareActionContactsByContact(contactID string) ([]*ShareActionContact, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var contacts []*ShareActionContact
+	for _, id := range r.contactIDs {
+		contact := r.shareActionContacts[id]
+		if contact.contactID != nil && *contact.contactID == contactID {
+			contacts = append(contacts, r.shareActionContact(id))
+		}
+	}
+	return contacts, nil
+}
+
+func (r *MemoryResolver) CreateTransaction(input CreateTransactionInput) (*Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if input.ParentTransactionID != nil {
+		if _, ok := r.transactions[*input.ParentTransactionID]; !ok {
+			return nil, newError(ErrNotFound, "CreateTransaction", fmt.Sprintf("Transaction %v not found", *input.ParentTransactionID))
+		}
+	}
+	if input.TransactionActionID != nil {
+		if _, ok := r.shareActions[*input.TransactionActionID]; !ok {
+			return nil, newError(ErrNotFound, "CreateTransaction", fmt.Sprintf("ShareAction %v not found", *input.TransactionActionID))
+		}
+	}
+
+	id := newMemoryID(input.ID)
+	if _, exists := r.transactions[id]; exists {
+		return nil, newError(ErrConditionalCheckFailed, "CreateTransaction", fmt.Sprintf("Transaction %v already exists", id))
+	}
+	r.transactions[id] = memoryTransaction{
+		id:                  id,
+		parentTransactionID: copyString(input.ParentTransactionID),
+		actionID:            copyString(input.TransactionActionID),
+		status:              copyString(input.Status),
+	}
+	r.transactionIDs = append(r.transactionIDs, id)
+	return &Transaction{ID: &id}, nil
+}
+
+func (r *MemoryResolver) UpdateTransaction(input UpdateTransactionInput) (*Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transaction, ok := r.transactions[input.ID]
+	if !ok {
+		return nil, newError(ErrNotFound, "UpdateTransaction", fmt.Sprintf("Transaction %v not found", input.ID))
+	}
+	if input.ParentTransactionID != nil {
+		transaction.parentTransactionID = copyString(input.ParentTransactionID)
+	}
+	if input.TransactionActionID != nil {
+		transaction.actionID = copyString(input.TransactionActionID)
+	}
+	if input.Status != nil {
+		transaction.status = copyString(input.Status)
+	}
+	if input.AttemptCounter != nil {
+		transaction.attemptCounter = copyInt(input.AttemptCounter)
+	}
+	if input.TransitionErrors != nil {
+		transaction.transitionErrors = copyStrings(input.TransitionErrors)
+	}
+	if input.FraudFlags != nil {
+		transaction.fraudFlags = copyStrings(input.FraudFlags)
+	}
+	if input.DeletedAt != nil {
+		transaction.deletedAt = copyString(input.DeletedAt)
+	}
+	r.transactions[input.ID] = transaction
+	return r.transaction(input.ID), nil
+}
+
+// GetTransaction resolves the action and one level of parent transaction,
+// the same depth the AppSync query selects.
+func (r *MemoryResolver) GetTransaction(id string) (*Transaction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if transaction, ok := r.transactions[id]; !ok || !r.visible(transaction.deletedAt) {
+		return nil, newError(ErrNotFound, "GetTransaction", fmt.Sprintf("Transaction %v not found", id))
+	}
+	transaction := r.transaction(id)
+	if transaction.ParentTransactionID != nil {
+		transaction.ParentTransaction = r.transaction(*transaction.ParentTransactionID)
+	}
+	return transaction, nil
+}
+
+func (r *MemoryResolver) GetShareActionsByChallengeAndUser(challengeID string, userID string) ([]*ShareAction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var shareActions []*ShareAction
+	for _, id := range r.shareActionIDs {
+		shareAction := r.shareActions[id]
+		if !r.visible(shareAction.deletedAt) {
+			continue
+		}
+		if shareAction.challengeID == nil || *shareAction.challengeID != challengeID {
+			continue
+		}
+		if shareAction.userID == nil || *shareAction.userID != userID {
+			continue
+		}
+		shareActions = append(shareActions, r.shareAction(id))
+	}
+	return shareActions, nil
+}
+
+func (r *MemoryResolver) GetShareActionsByUser(userID string) ([]*ShareAction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var shareActions []*ShareAction
+	for _, id := range r.shareActionIDs {
+		shareAction := r.shareActions[id]
+		if !r.visible(shareAction.deletedAt) {
+			continue
+		}
+		if shareAction.userID != nil && *shareAction.userID == userID {
+			shareActions = append(shareActions, r.shareAction(id))
+		}
+	}
+	return shareActions, nil
+}
+
+func (r *MemoryResolver) GetShareActionsByChallenge(challengeID string) ([]*ShareAction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var shareActions []*ShareAction
+	for _, id := range r.shareActionIDs {
+		shareAction := r.shareActions[id]
+		if !r.visible(shareAction.deletedAt) {
+			continue
+		}
+		if shareAction.challengeID != nil && *shareAction.challengeID == challengeID {
+			shareActions = append(shareActions, r.shareAction(id))
+		}
+	}
+	return shareActions, nil
+}
+
+func (r *MemoryResolver) GetTransactionsByShareAction(actionID string) ([]*Transaction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var transactions []*Transaction
+	for _, id := range r.transactionIDs {
+		transaction := r.transactions[id]
+		if !r.visible(transaction.deletedAt) {
+			continue
+		}
+		if transaction.actionID != nil && *transaction.actionID == actionID {
+			transactions = append(transactions, r.transaction(id))
+		}
+	}
+	return transactions, nil
+}
+
+func (r *MemoryResolver) GetTransactionsByParentTransaction(parentTransactionID string) ([]*Transaction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var transactions []*Transaction
+	for _, id := range r.transactionIDs {
+		transaction := r.transactions[id]
+		if !r.visible(transaction.deletedAt) {
+			continue
+		}
+		if transaction.parentTransactionID != nil && *transaction.parentTransactionID == parentTransactionID {
+			transactions = append(transactions, r.transaction(id))
+		}
+	}
+	return transactions, nil
+}
+
+func (r *MemoryResolver) user(id string) *User {
+	user := r.users[id]
+	return &User{
+		ID:              copyString(user.ID),
+		Emails:          copyStrings(user.Emails),
+		CanonicalEmails: copyStrings(user.CanonicalEmails),
+		Etag:            copyString(user.Etag),
+		Identity:        copyString(user.Identity),
+		Names:           copyStrings(user.Names),
+		PhoneNumbers:    copyStrings(user.PhoneNumbers),
+		Pictures:        copyStrings(user.Pictures),
+		Token:           copyString(user.Token),
+		Version:         copyInt(user.Version),
+		MergedInto:      copyString(user.MergedInto),
+		MergeProposals:  copyStrings(user.MergeProposals),
+		UnsubscribedAt:  copyString(user.UnsubscribedAt),
+		DeletedAt:       copyString(user.DeletedAt),
+	}
+}
+
+func (r *MemoryResolver) userContact(id string) *UserContact {
+	contact := r.userContacts[id]
+	return &UserContact{
+		ID:                   &contact.id,
+		UserContactUserID:    &contact.userID,
+		UserContactContactID: &contact.contactID,
+		User:                 r.user(contact.userID),
+		Contact:              r.user(contact.contactID),
+	}
+}
+
+func versionOf(version *int) int {
+	if version == nil {
+		return 0
+	}
+	return *version
+}
+
+// statusOf reads a stored challenge status the way the updateChallenge
+// resolver compares it, with no status matching "".
+func statusOf(status *string) string {
+	if status == nil {
+		return ""
+	}
+	return *status
+}
+
+// nextVersion bumps a user's version the way the versioned updateUser
+// resolver does.
+func nextVersion(version *int) *int {
+	next := versionOf(version) + 1
+	return &next
+}
+
+func (r *MemoryResolver) challenge(id string) *Challenge {
+	stored := r.challenges[id]
+	challenge := stored.input
+	return &Challenge{
+		ID:                         copyString(challenge.ID),
+		Name:                       copyString(challenge.Name),
+		Description:                copyString(challenge.Description),
+		ImageURL:                   copyString(challenge.ImageURL),
+		SponsorName:                copyString(challenge.SponsorName),
+		SponsorEmail:               copyString(challenge.SponsorEmail),
+		Expiration:                 copyString(challenge.Expiration),
+		ShareExpiration:            copyString(challenge.ShareExpiration),
+		MaxShares:                  copyInt(challenge.MaxShares),
+		MaxRewards:                 copyInt(challenge.MaxRewards),
+		OffChain:                   copyBool(challenge.OffChain),
+		MaxDistributionFeeReward:   copyInt(challenge.MaxDistributionFeeReward),
+		MaxSharesPerReceivedShare:  copyInt(challenge.MaxSharesPerReceivedShare),
+		MaxDepth:                   copyInt(challenge.MaxDepth),
+		MaxNodes:                   copyInt(challenge.MaxNodes),
+		PublicKey:                  copyString(challenge.PublicKey),
+		Reward:                     copyString(challenge.Reward),
+		Active:                     copyBool(challenge.Active),
+		Status:                     copyString(challenge.Status),
+		ClosedAt:                   copyString(stored.closedAt),
+		AttributionModel:           copyString(challenge.AttributionModel),
+		ChallengeTemplateID:        copyString(challenge.ChallengeTemplateID),
+		ChallengeParentChallengeID: copyString(challenge.ChallengeParentChallengeID),
+		AttachmentURL:              copyString(challenge.AttachmentURL),
+		MailAuthentication:         copyString(challenge.MailAuthentication),
+		DeletedAt:                  copyString(stored.deletedAt),
+	}
+}
+
+func (r *MemoryResolver) shareAction(id string) *ShareAction {
+	shareAction := r.shareActions[id]
+	return &ShareAction{
+		ID:                 copyString(&shareAction.id),
+		ChallengeID:        copyString(shareAction.challengeID),
+		UserID:             copyString(shareAction.userID),
+		MailAuthentication: copyString(shareAction.mailAuthentication),
+		DeletedAt:          copyString(shareAction.deletedAt),
+	}
+}
+
+func (r *MemoryResolver) shareActionContact(id string) *ShareActionContact {
+	contact := r.shareActionContacts[id]
+	shareActionContact := &ShareActionContact{
+		ID:                              copyString(&contact.id),
+		ShareActionContactShareActionID: copyString(contact.shareActionID),
+		ShareActionContactContactID:     copyString(contact.contactID),
+		CreatedAt:                       copyString(&contact.createdAt),
+	}
+	if contact.shareActionID != nil {
+		shareActionContact.ShareAction = r.shareAction(*contact.shareActionID)
+	}
+	if contact.contactID != nil {
+		shareActionContact.Contact = r.user(*contact.contactID)
+	}
+	return shareActionContact
+}
+
+func (r *MemoryResolver) transaction(id string) *Transaction {
+	transaction := r.transactions[id]
+	result := &Transaction{
+		ID:                  copyString(&transaction.id),
+		ParentTransactionID: copyString(transaction.parentTransactionID),
+		TransactionActionID: copyString(transaction.actionID),
+		Status:              copyString(transaction.status),
+		AttemptCounter:      copyInt(transaction.attemptCounter),
+		TransitionErrors:    copyStrings(transaction.transitionErrors),
+		FraudFlags:          copyStrings(transaction.fraudFlags),
+		DeletedAt:           copyString(transaction.deletedAt),
+	}
+	if transaction.actionID != nil {
+		result.Action = r.shareAction(*transaction.actionID)
+	}
+	return result
+}
+
+func (r *MemoryResolver) application(id string) *Application {
+	stored := r.applications[id]
+	application := stored.input
+	return &Application{
+		ID:                 copyString(application.ID),
+		ChallengeID:        copyString(application.ChallengeID),
+		CandidateID:        copyString(application.CandidateID),
+		TransactionID:      copyString(application.TransactionID),
+		ResumeURL:          copyString(application.ResumeURL),
+		Message:            copyString(application.Message),
+		Status:             copyString(application.Status),
+		Attribution:        copyString(application.Attribution),
+		MailAuthentication: copyString(application.MailAuthentication),
+		HiredAt:            copyString(stored.hiredAt),
+		PayoutPlan:         copyString(stored.payoutPlan),
+		CreatedAt:          copyString(&stored.createdAt),
+		UpdatedAt:          copyString(&stored.updatedAt),
+	}
+}
+
+// Deletes stamp deletedAt and restores clear it, like the AppSync resolver.
+// Both reach rows whether or not they are already deleted.
+
+func memoryDeletedAt(restore bool) *string {
+	if restore {
+		return nil
+	}
+	deletedAt := time.Now().UTC().Format(time.RFC3339)
+	return &deletedAt
+}
+
+func (r *MemoryResolver) setUserDeletedAt(operation string, id string, restore bool) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, newError(ErrNotFound, operation, fmt.Sprintf("User %v not found", id))
+	}
+	user.DeletedAt = memoryDeletedAt(restore)
+	user.Version = nextVersion(user.Version)
+	r.users[id] = user
+	return r.user(id), nil
+}
+
+func (r *MemoryResolver) DeleteUser(id string) (*User, error) {
+	return r.setUserDeletedAt("SoftDeleteUser", id, false)
+}
+
+func (r *MemoryResolver) RestoreUser(id string) (*User, error) {
+	return r.setUserDeletedAt("RestoreUser", id, true)
+}
+
+func (r *MemoryResolver) setChallengeDeletedAt(operation string, id string, restore bool) (*Challenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	challenge, ok := r.challenges[id]
+	if !ok {
+		return nil, newError(ErrNotFound, operation, fmt.Sprintf("Challenge %v not found", id))
+	}
+	challenge.deletedAt = memoryDeletedAt(restore)
+	r.challenges[id] = challenge
+	return r.challenge(id), nil
+}
+
+func (r *MemoryResolver) DeleteChallenge(id string) (*Challenge, error) {
+	return r.setChallengeDeletedAt("SoftDeleteChallenge", id, false)
+}
+
+func (r *MemoryResolver) RestoreChallenge(id string) (*Challenge, error) {
+	return r.setChallengeDeletedAt("RestoreChallenge", id, true)
+}
+
+func (r *MemoryResolver) setShareActionDeletedAt(operation string, id string, restore bool) (*ShareAction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	shareAction, ok := r.shareActions[id]
+	if !ok {
+		return nil, newError(ErrNotFound, operation, fmt.Sprintf("ShareAction %v not found", id))
+	}
+	shareAction.deletedAt = memoryDeletedAt(restore)
+	r.shareActions[id] = shareAction
+	return r.shareAction(id), nil
+}
+
+func (r *MemoryResolver) DeleteShareAction(id string) (*ShareAction, error) {
+	return r.setShareActionDeletedAt("SoftDeleteShareAction", id, false)
+}
+
+func (r *MemoryResolver) RestoreShareAction(id string) (*ShareAction, error) {
+	return r.setShareActionDeletedAt("RestoreShareAction", id, true)
+}
+
+func (r *MemoryResolver) setTransactionDeletedAt(operation string, id string, restore bool) (*Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transaction, ok := r.transactions[id]
+	if !ok {
+		return nil, newError(ErrNotFound, operation, fmt.Sprintf("Transaction %v not found", id))
+	}
+	transaction.deletedAt = memoryDeletedAt(restore)
+	r.transactions[id] = transaction
+	return r.transaction(id), nil
+}
+
+func (r *MemoryResolver) DeleteTransaction(id string) (*Transaction, error) {
+	return r.setTransactionDeletedAt("SoftDeleteTransaction", id, false)
+}
+
+func (r *MemoryResolver) RestoreTransaction(id string) (*Transaction, error) {
+	return r.setTransactionDeletedAt("RestoreTransaction", id, true)
+}
+
+// The Context variants only check ctx before running, since the in-memory
+// operations never block.
+
+func (r *MemoryResolver) CreateUserContext(ctx context.Context, input CreateUserInput) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.CreateUser(input)
+}
+
+func (r *MemoryResolver) UpdateUserContext(ctx context.Context, input UpdateUserInput) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.UpdateUser(input)
+}
+
+func (r *MemoryResolver) GetUserContext(ctx context.Context, id string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetUser(id)
+}
+
+func (r *MemoryResolver) MapUsersByEmailsContext(ctx context.Context, emails []*string) (map[string]User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.MapUsersByEmails(emails)
+}
+
+func (r *MemoryResolver) ListUsersByEmailsContext(ctx context.Context, emails []*string) ([]User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.ListUsersByEmails(emails)
+}
+
+func (r *MemoryResolver) ListUsersByCanonicalEmailsContext(ctx context.Context, emails []*string) ([]User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.ListUsersByCanonicalEmails(emails)
+}
+
+func (r *MemoryResolver) CreateUserContactContext(ctx context.Context, input CreateUserContactInput) (*UserContact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.CreateUserContact(input)
+}
+
+func (r *MemoryResolver) ListUsersByPhoneNumbersContext(ctx context.Context, phoneNumbers []*string) ([]User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.ListUsersByPhoneNumbers(phoneNumbers)
+}
+
+func (r *MemoryResolver) UpdateUserContactContext(ctx context.Context, input UpdateUserContactInput) (*UserContact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.UpdateUserContact(input)
+}
+
+func (r *MemoryResolver) DeleteUserContactContext(ctx context.Context, input DeleteUserContactInput) (*UserContact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.DeleteUserContact(input)
+}
+
+func (r *MemoryResolver) GetUserContactsByUserContext(ctx context.Context, userID string) ([]*UserContact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetUserContactsByUser(userID)
+}
+
+func (r *MemoryResolver) GetUserContactsByContactContext(ctx context.Context, contactID string) ([]*UserContact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetUserContactsByContact(contactID)
+}
+
+func (r *MemoryResolver) GetShareActionsByUserContext(ctx context.Context, userID string) ([]*ShareAction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetShareActionsByUser(userID)
+}
+
+func (r *MemoryResolver) UpdateShareActionContactContext(ctx context.Context, input UpdateShareActionContactInput) (*ShareActionContact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.UpdateShareActionContact(input)
+}
+
+func (r *MemoryResolver) GetApplicationsByCandidateContext(ctx context.Context, candidateID string) ([]*Application, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetApplicationsByCandidate(candidateID)
+}
+
+func (r *MemoryResolver) CreateChallengeContext(ctx context.Context, input CreateChallengeInput) (*Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.CreateChallenge(input)
+}
+
+// GetChallengeSponsorToken returns only what the GetChallengeSponsorToken
+// operation selects, the one read that carries the sponsor token.
+func (r *MemoryResolver) GetChallengeSponsorToken(id string) (*Challenge, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.challenges[id]
+	if !ok || !r.visible(stored.deletedAt) {
+		return nil, newError(ErrNotFound, "GetChallengeSponsorToken", fmt.Sprintf("Challenge %v not found", id))
+	}
+	return &Challenge{
+		ID:           copyString(stored.input.ID),
+		SponsorToken: copyString(stored.input.SponsorToken),
+		DeletedAt:    copyString(stored.deletedAt),
+	}, nil
+}
+
+func (r *MemoryResolver) GetChallengeSponsorTokenContext(ctx context.Context, id string) (*Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetChallengeSponsorToken(id)
+}
+
+func (r *MemoryResolver) GetChallengeContext(ctx context.Context, id string) (*Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetChallenge(id)
+}
+
+func (r *MemoryResolver) CreateShareActionContext(ctx context.Context, input CreateShareActionInput) (*ShareAction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.CreateShareAction(input)
+}
+
+func (r *MemoryResolver) UpdateShareActionContext(ctx context.Context, input UpdateShareActionInput) (*ShareAction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.UpdateShareAction(input)
+}
+
+func (r *MemoryResolver) CreateShareActionContactContext(ctx context.Context, input CreateShareActionContactInput) (*ShareActionContact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.CreateShareActionContact(input)
+}
+
+func (r *MemoryResolver) CreateTransactionContext(ctx context.Context, input CreateTransactionInput) (*Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.CreateTransaction(input)
+}
+
+func (r *MemoryResolver) UpdateTransactionContext(ctx context.Context, input UpdateTransactionInput) (*Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.UpdateTransaction(input)
+}
+
+func (r *MemoryResolver) CreateApplicationContext(ctx context.Context, input CreateApplicationInput) (*Application, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.CreateApplication(input)
+}
+
+func (r *MemoryResolver) UpdateApplicationContext(ctx context.Context, input UpdateApplicationInput) (*Application, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.UpdateApplication(input)
+}
+
+func (r *MemoryResolver) GetApplicationContext(ctx context.Context, id string) (*Application, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetApplication(id)
+}
+
+func (r *MemoryResolver) GetApplicationsByChallengeContext(ctx context.Context, challengeID string) ([]*Application, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetApplicationsByChallenge(challengeID)
+}
+
+func (r *MemoryResolver) GetApplicationsByChallengeAndCandidateContext(ctx context.Context, challengeID string, candidateID string) ([]*Application, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetApplicationsByChallengeAndCandidate(challengeID, candidateID)
+}
+
+func (r *MemoryResolver) GetTransactionContext(ctx context.Context, id string) (*Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetTransaction(id)
+}
+
+func (r *MemoryResolver) UpdateChallengeContext(ctx context.Context, input UpdateChallengeInput) (*Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.UpdateChallenge(input)
+}
+
+func (r *MemoryResolver) GetChallengesExpiringBeforeContext(ctx context.Context, at string) ([]*Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetChallengesExpiringBefore(at)
+}
+
+func (r *MemoryResolver) GetShareActionsByChallengeAndUserContext(ctx context.Context, challengeID string, userID string) ([]*ShareAction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetShareActionsByChallengeAndUser(challengeID, userID)
+}
+
+func (r *MemoryResolver) GetShareActionsByChallengeContext(ctx context.Context, challengeID string) ([]*ShareAction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetShareActionsByChallenge(challengeID)
+}
+
+func (r *MemoryResolver) GetTransactionsByShareActionContext(ctx context.Context, actionID string) ([]*Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetTransactionsByShareAction(actionID)
+}
+
+func (r *MemoryResolver) GetShareActionContactsByShareActionContext(ctx context.Context, shareActionID string) ([]*ShareActionContact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetShareActionContactsByShareAction(shareActionID)
+}
+
+func (r *MemoryResolver) GetShareActionContactsByContactContext(ctx context.Context, contactID string) ([]*ShareActionContact, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetShareActionContactsByContact(contactID)
+}
+
+func (r *MemoryResolver) GetTransactionsByParentTransactionContext(ctx context.Context, parentTransactionID string) ([]*Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.GetTransactionsByParentTransaction(parentTransactionID)
+}
+
+func (r *MemoryResolver) DeleteUserContext(ctx context.Context, id string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.DeleteUser(id)
+}
+
+func (r *MemoryResolver) RestoreUserContext(ctx context.Context, id string) (*User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.RestoreUser(id)
+}
+
+func (r *MemoryResolver) DeleteChallengeContext(ctx context.Context, id string) (*Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.DeleteChallenge(id)
+}
+
+func (r *MemoryResolver) RestoreChallengeContext(ctx context.Context, id string) (*Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.RestoreChallenge(id)
+}
+
+func (r *MemoryResolver) DeleteShareActionContext(ctx context.Context, id string) (*ShareAction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.DeleteShareAction(id)
+}
+
+func (r *MemoryResolver) RestoreShareActionContext(ctx context.Context, id string) (*ShareAction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.RestoreShareAction(id)
+}
+
+func (r *MemoryResolver) DeleteTransactionContext(ctx context.Context, id string) (*Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.DeleteTransaction(id)
+}
+
+func (r *MemoryResolver) RestoreTransactionContext(ctx context.Context, id string) (*Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.RestoreTransaction(id)
+}
+
+func (r *MemoryResolver) BatchUpsertUsers(batch UserBatch) (*UserBatchResult, error) {
+	return r.BatchUpsertUsersContext(context.Background(), batch)
+}
+
+// BatchUpsertUsersContext writes the items one at a time with the same
+// ordering and per-item results as the AppSync batch.
+func (r *MemoryResolver) BatchUpsertUsersContext(ctx context.Context, batch UserBatch) (*UserBatchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	batch.assignIDs()
+	result := &UserBatchResult{
+		Users:    make([]UserUpsertResult, len(batch.Users)),
+		Contacts: make([]UserContactResult, len(batch.Contacts)),
+	}
+	for i, user := range batch.Users {
+		if err := user.validate(); err != nil {
+			result.Users[i].Err = err
+			continue
+		}
+		if user.Create != nil {
+			result.Users[i].User, result.Users[i].Err = r.CreateUser(*user.Create)
+		} else {
+			result.Users[i].User, result.Users[i].Err = r.UpdateUser(*user.Update)
+		}
+	}
+
+	failed := result.failedUsers(batch)
+	for i, contact := range batch.Contacts {
+		if err := skippedContact(contact, failed); err != nil {
+			result.Contacts[i].Err = err
+			continue
+		}
+		result.Contacts[i].Contact, result.Contacts[i].Err = r.CreateUserContact(contact)
+	}
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package appsync
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// DefaultMergeAttempts bounds how many times MergeUser re-reads a user after
+// losing a race to another writer.
+const DefaultMergeAttempts = 5
+
+// MergeUserInput rebases input onto the stored user: every list the input
+// sets becomes the union of the stored values and the new ones, and the
+// update is made conditional on the version that was read. Scalar fields are
+// left as given, so they still replace the stored ones.
+func MergeUserInput(user *User, input UpdateUserInput) UpdateUserInput {
+	if input.Emails != nil {
+		input.Emails = unionStrings(user.Emails, input.Emails)
+	}
+	if input.CanonicalEmails != nil {
+		input.CanonicalEmails = unionStrings(user.CanonicalEmails, input.CanonicalEmails)
+	}
+	if input.Names != nil {
+		input.Names = unionStrings(user.Names, input.Names)
+	}
+	if input.PhoneNumbers != nil {
+		input.PhoneNumbers = unionStrings(user.PhoneNumbers, input.PhoneNumbers)
+	}
+	if input.Pictures != nil {
+		input.Pictures = unionStrings(user.Pictures, input.Pictures)
+	}
+	input.ExpectedVersion = copyInt(user.Version)
+	if input.ExpectedVersion == nil {
+		// Rows written before versioning have none; the resolver treats a
+		// missing version as 0.
+		unversioned := 0
+		input.ExpectedVersion = &unversioned
+	}
+	return input
+}
+
+// MergeUser reads the user, merges input into it with MergeUserInput and
+// writes it back conditionally. On ErrConflict it reads the user again and
+// retries, so concurrent writers add to each other's lists instead of
+// replacing them.
+func MergeUser(ctx context.Context, resolver Resolver, input UpdateUserInput) (*User, error) {
+	var err error
+	for attempt := 1; attempt <= DefaultMergeAttempts; attempt++ {
+		var current *User
+		current, err = resolver.GetUserContext(ctx, input.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		var user *User
+		user, err = resolver.UpdateUserContext(ctx, MergeUserInput(current, input))
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return nil, err
+		}
+		log.Printf("UpdateUser conflict on user %v (attempt %d), merging again: %v", input.ID, attempt, err)
+	}
+	return nil, err
+}
+
+// unionStrings appends the values missing from existing, keeping the order
+// they were first seen in and dropping nils.
+func unionStrings(existing []*string, values []*string) []*string {
+	seen := map[string]bool{}
+	union := []*string{}
+	for _, list := range [][]*string{existing, values} {
+		for _, value := range list {
+			if value == nil || seen[*value] {
+				continue
+			}
+			seen[*value] = true
+			v := *value
+			union = append(union, &v)
+		}
+	}
+	return union
+}
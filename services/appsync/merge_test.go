@@ -0,0 +1,99 @@
+package appsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+// racingResolver lets another writer update the user between MergeUser's
+// read and its conditional write, a fixed number of times.
+type racingResolver struct {
+	*MemoryResolver
+	races int
+	other UpdateUserInput
+}
+
+func (r *racingResolver) UpdateUserContext(ctx context.Context, input UpdateUserInput) (*User, error) {
+	if r.races > 0 {
+		r.races--
+		if _, err := r.MemoryResolver.UpdateUserContext(ctx, r.other); err != nil {
+			return nil, err
+		}
+	}
+	return r.MemoryResolver.UpdateUserContext(ctx, input)
+}
+
+func TestMergeUser(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	ada := "ada@example.com"
+	lovelace := "ada@lovelace.org"
+	name := "Ada"
+	picture := "https://example.com/ada.png"
+
+	g.Describe("MergeUser", func() {
+		var resolver *racingResolver
+		var user *User
+
+		g.BeforeEach(func() {
+			resolver = &racingResolver{MemoryResolver: NewMemoryResolver()}
+			var err error
+			user, err = resolver.CreateUser(CreateUserInput{Emails: []*string{&ada}})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		g.It("Should reject an update made against a stale version", func() {
+			stale := *user.Version
+			_, err := resolver.MemoryResolver.UpdateUser(UpdateUserInput{ID: *user.ID, Names: []*string{&name}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = resolver.MemoryResolver.UpdateUser(UpdateUserInput{ID: *user.ID, Names: []*string{&name}, ExpectedVersion: &stale})
+			Expect(errors.Is(err, ErrConflict)).Should(BeTrue())
+		})
+
+		g.It("Should union lists instead of replacing them", func() {
+			merged, err := MergeUser(context.Background(), resolver, UpdateUserInput{ID: *user.ID, Emails: []*string{&lovelace, &ada}})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(merged.Emails).Should(HaveLen(2))
+			Expect(*merged.Emails[0]).Should(Equal(ada))
+			Expect(*merged.Emails[1]).Should(Equal(lovelace))
+			Expect(*merged.Version).Should(Equal(2))
+		})
+
+		g.It("Should keep the other writer's values after a conflict", func() {
+			resolver.races = 2
+			resolver.other = UpdateUserInput{ID: *user.ID, Pictures: []*string{&picture}}
+
+			merged, err := MergeUser(context.Background(), resolver, UpdateUserInput{ID: *user.ID, Names: []*string{&name}, Pictures: []*string{}})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(merged.Names).Should(HaveLen(1))
+			Expect(merged.Pictures).Should(HaveLen(1))
+			Expect(*merged.Pictures[0]).Should(Equal(picture))
+			Expect(*merged.Version).Should(Equal(4))
+		})
+
+		g.It("Should give up after DefaultMergeAttempts conflicts", func() {
+			resolver.races = DefaultMergeAttempts
+			resolver.other = UpdateUserInput{ID: *user.ID, Names: []*string{&name}}
+
+			_, err := MergeUser(context.Background(), resolver, UpdateUserInput{ID: *user.ID, Emails: []*string{&lovelace}})
+			Expect(errors.Is(err, ErrConflict)).Should(BeTrue())
+		})
+	})
+
+	g.Describe("asConflict", func() {
+		g.It("Should report a failed condition on a versioned update as a conflict", func() {
+			err := asConflict(newError(ErrConditionalCheckFailed, "UpdateUser", "The conditional request failed"))
+			Expect(errors.Is(err, ErrConflict)).Should(BeTrue())
+			Expect(hasExpectedVersion([]byte(`{"input":{"id":"u1","expectedVersion":3}}`))).Should(BeTrue())
+			Expect(hasExpectedVersion([]byte(`{"input":{"id":"u1"}}`))).Should(BeFalse())
+		})
+	})
+}
@@ -0,0 +1,328 @@
+// Code generated by services/appsync/gen from schema.graphql and operations/*.graphql. DO NOT EDIT.
+
+package appsync
+
+type Application struct {
+	ID                 *string `json:"id,omitempty"`
+	ChallengeID        *string `json:"challengeId,omitempty"`
+	CandidateID        *string `json:"candidateId,omitempty"`
+	TransactionID      *string `json:"transactionId,omitempty"`
+	ResumeURL          *string `json:"resumeURL,omitempty"`
+	Message            *string `json:"message,omitempty"`
+	Status             *string `json:"status,omitempty"`
+	Attribution        *string `json:"attribution,omitempty"`
+	MailAuthentication *string `json:"mailAuthentication,omitempty"`
+	HiredAt            *string `json:"hiredAt,omitempty"`
+	PayoutPlan         *string `json:"payoutPlan,omitempty"`
+	CreatedAt          *string `json:"createdAt,omitempty"`
+	UpdatedAt          *string `json:"updatedAt,omitempty"`
+}
+
+type Challenge struct {
+	ID                         *string                     `json:"id,omitempty"`
+	Name                       *string                     `json:"name,omitempty"`
+	Description                *string                     `json:"description,omitempty"`
+	ImageURL                   *string                     `json:"imageUrl,omitempty"`
+	SponsorName                *string                     `json:"sponsorName,omitempty"`
+	SponsorEmail               *string                     `json:"sponsorEmail,omitempty"`
+	SponsorToken               *string                     `json:"sponsorToken,omitempty"`
+	Expiration                 *string                     `json:"expiration,omitempty"`
+	ShareExpiration            *string                     `json:"shareExpiration,omitempty"`
+	MaxShares                  *int                        `json:"maxShares,omitempty"`
+	MaxRewards                 *int                        `json:"maxRewards,omitempty"`
+	OffChain                   *bool                       `json:"offChain,omitempty"`
+	MaxDistributionFeeReward   *int                        `json:"maxDistributionFeeReward,omitempty"`
+	MaxSharesPerReceivedShare  *int                        `json:"maxSharesPerReceivedShare,omitempty"`
+	MaxDepth                   *int                        `json:"maxDepth,omitempty"`
+	MaxNodes                   *int                        `json:"maxNodes,omitempty"`
+	PublicKey                  *string                     `json:"publicKey,omitempty"`
+	Reward                     *string                     `json:"reward,omitempty"`
+	Active                     *bool                       `json:"active,omitempty"`
+	Status                     *string                     `json:"status,omitempty"`
+	ClosedAt                   *string                     `json:"closedAt,omitempty"`
+	AttributionModel           *string                     `json:"attributionModel,omitempty"`
+	ChallengeTemplateID        *string                     `json:"challengeTemplateId,omitempty"`
+	ChallengeParentChallengeID *string                     `json:"challengeParentChallengeId,omitempty"`
+	AttachmentURL              *string                     `json:"attachmentURL,omitempty"`
+	MailAuthentication         *string                     `json:"mailAuthentication,omitempty"`
+	ShareActions               *ModelShareActionConnection `json:"shareActions,omitempty"`
+	CreatedAt                  *string                     `json:"createdAt,omitempty"`
+	UpdatedAt                  *string                     `json:"updatedAt,omitempty"`
+	DeletedAt                  *string                     `json:"deletedAt,omitempty"`
+}
+
+type CreateApplicationInput struct {
+	ID                 *string `json:"id,omitempty"`
+	ChallengeID        *string `json:"challengeId,omitempty"`
+	CandidateID        *string `json:"candidateId,omitempty"`
+	TransactionID      *string `json:"transactionId,omitempty"`
+	ResumeURL          *string `json:"resumeURL,omitempty"`
+	Message            *string `json:"message,omitempty"`
+	Status             *string `json:"status,omitempty"`
+	Attribution        *string `json:"attribution,omitempty"`
+	MailAuthentication *string `json:"mailAuthentication,omitempty"`
+}
+
+type CreateChallengeInput struct {
+	ID                         *string `json:"id,omitempty"`
+	Name                       *string `json:"name,omitempty"`
+	Description                *string `json:"description,omitempty"`
+	ImageURL                   *string `json:"imageUrl,omitempty"`
+	SponsorName                *string `json:"sponsorName,omitempty"`
+	SponsorEmail               *string `json:"sponsorEmail,omitempty"`
+	SponsorToken               *string `json:"sponsorToken,omitempty"`
+	Expiration                 *string `json:"expiration,omitempty"`
+	ShareExpiration            *string `json:"shareExpiration,omitempty"`
+	MaxShares                  *int    `json:"maxShares,omitempty"`
+	MaxRewards                 *int    `json:"maxRewards,omitempty"`
+	OffChain                   *bool   `json:"offChain,omitempty"`
+	MaxDistributionFeeReward   *int    `json:"maxDistributionFeeReward,omitempty"`
+	MaxSharesPerReceivedShare  *int    `json:"maxSharesPerReceivedShare,omitempty"`
+	MaxDepth                   *int    `json:"maxDepth,omitempty"`
+	MaxNodes                   *int    `json:"maxNodes,omitempty"`
+	PublicKey                  *string `json:"publicKey,omitempty"`
+	Reward                     *string `json:"reward,omitempty"`
+	Active                     *bool   `json:"active,omitempty"`
+	Status                     *string `json:"status,omitempty"`
+	AttributionModel           *string `json:"attributionModel,omitempty"`
+	ChallengeTemplateID        *string `json:"challengeTemplateId,omitempty"`
+	ChallengeParentChallengeID *string `json:"challengeParentChallengeId,omitempty"`
+	AttachmentURL              *string `json:"attachmentURL,omitempty"`
+	MailAuthentication         *string `json:"mailAuthentication,omitempty"`
+}
+
+type CreateShareActionContactInput struct {
+	ID                              *string `json:"id,omitempty"`
+	ShareActionContactShareActionID *string `json:"shareActionContactShareActionId,omitempty"`
+	ShareActionContactContactID     *string `json:"shareActionContactContactId,omitempty"`
+}
+
+type CreateShareActionInput struct {
+	ID          *string `json:"id,omitempty"`
+	ChallengeID *string `json:"challengeId,omitempty"`
+	UserID      *string `json:"userId,omitempty"`
+}
+
+type CreateTransactionInput struct {
+	ID                  *string `json:"id,omitempty"`
+	ParentTransactionID *string `json:"parentTransactionId,omitempty"`
+	TransactionActionID *string `json:"transactionActionId,omitempty"`
+	Status              *string `json:"status,omitempty"`
+}
+
+type CreateUserContactInput struct {
+	ID                   *string `json:"id,omitempty"`
+	UserContactUserID    *string `json:"userContactUserId,omitempty"`
+	UserContactContactID *string `json:"userContactContactId,omitempty"`
+}
+
+type CreateUserInput struct {
+	ID              *string   `json:"id,omitempty"`
+	Names           []*string `json:"names,omitempty"`
+	Emails          []*string `json:"emails,omitempty"`
+	CanonicalEmails []*string `json:"canonicalEmails,omitempty"`
+	PhoneNumbers    []*string `json:"phoneNumbers,omitempty"`
+	Pictures        []*string `json:"pictures,omitempty"`
+	Identity        *string   `json:"identity,omitempty"`
+	Token           *string   `json:"token,omitempty"`
+	Etag            *string   `json:"etag,omitempty"`
+}
+
+type DeleteUserContactInput struct {
+	ID string `json:"id"`
+}
+
+type ModelApplicationConnection struct {
+	Items     []*Application `json:"items,omitempty"`
+	NextToken *string        `json:"nextToken,omitempty"`
+}
+
+type ModelChallengeConnection struct {
+	Items     []*Challenge `json:"items,omitempty"`
+	NextToken *string      `json:"nextToken,omitempty"`
+}
+
+type ModelShareActionConnection struct {
+	Items     []*ShareAction `json:"items,omitempty"`
+	NextToken *string        `json:"nextToken,omitempty"`
+}
+
+type ModelShareActionContactConnection struct {
+	Items     []*ShareActionContact `json:"items,omitempty"`
+	NextToken *string               `json:"nextToken,omitempty"`
+}
+
+type ModelTransactionConnection struct {
+	Items     []*Transaction `json:"items,omitempty"`
+	NextToken *string        `json:"nextToken,omitempty"`
+}
+
+type ModelUserConnection struct {
+	Items     []*User `json:"items,omitempty"`
+	NextToken *string `json:"nextToken,omitempty"`
+}
+
+type ModelUserContactConnection struct {
+	Items     []*UserContact `json:"items,omitempty"`
+	NextToken *string        `json:"nextToken,omitempty"`
+}
+
+type ShareAction struct {
+	ID                 *string                            `json:"id,omitempty"`
+	ChallengeID        *string                            `json:"challengeId,omitempty"`
+	UserID             *string                            `json:"userId,omitempty"`
+	Challenge          *Challenge                         `json:"challenge,omitempty"`
+	User               *User                              `json:"user,omitempty"`
+	MailAuthentication *string                            `json:"mailAuthentication,omitempty"`
+	Contacts           *ModelShareActionContactConnection `json:"contacts,omitempty"`
+	Transactions       *ModelTransactionConnection        `json:"transactions,omitempty"`
+	CreatedAt          *string                            `json:"createdAt,omitempty"`
+	UpdatedAt          *string                            `json:"updatedAt,omitempty"`
+	DeletedAt          *string                            `json:"deletedAt,omitempty"`
+}
+
+type ShareActionContact struct {
+	ID                              *string      `json:"id,omitempty"`
+	ShareActionContactShareActionID *string      `json:"shareActionContactShareActionId,omitempty"`
+	ShareActionContactContactID     *string      `json:"shareActionContactContactId,omitempty"`
+	ShareAction                     *ShareAction `json:"shareAction,omitempty"`
+	Contact                         *User        `json:"contact,omitempty"`
+	CreatedAt                       *string      `json:"createdAt,omitempty"`
+	UpdatedAt                       *string      `json:"updatedAt,omitempty"`
+}
+
+type Transaction struct {
+	ID                  *string      `json:"id,omitempty"`
+	ParentTransactionID *string      `json:"parentTransactionId,omitempty"`
+	ParentTransaction   *Transaction `json:"parentTransaction,omitempty"`
+	TransactionActionID *string      `json:"transactionActionId,omitempty"`
+	Action              *ShareAction `json:"action,omitempty"`
+	Status              *string      `json:"status,omitempty"`
+	AttemptCounter      *int         `json:"attemptCounter,omitempty"`
+	TransitionErrors    []*string    `json:"transitionErrors,omitempty"`
+	FraudFlags          []*string    `json:"fraudFlags,omitempty"`
+	CreatedAt           *string      `json:"createdAt,omitempty"`
+	UpdatedAt           *string      `json:"updatedAt,omitempty"`
+	DeletedAt           *string      `json:"deletedAt,omitempty"`
+}
+
+type UpdateApplicationInput struct {
+	ID            string  `json:"id"`
+	CandidateID   *string `json:"candidateId,omitempty"`
+	TransactionID *string `json:"transactionId,omitempty"`
+	ResumeURL     *string `json:"resumeURL,omitempty"`
+	Message       *string `json:"message,omitempty"`
+	Status        *string `json:"status,omitempty"`
+	Attribution   *string `json:"attribution,omitempty"`
+	HiredAt       *string `json:"hiredAt,omitempty"`
+	PayoutPlan    *string `json:"payoutPlan,omitempty"`
+}
+
+type UpdateChallengeInput struct {
+	ID                         string  `json:"id"`
+	Name                       *string `json:"name,omitempty"`
+	Description                *string `json:"description,omitempty"`
+	ImageURL                   *string `json:"imageUrl,omitempty"`
+	SponsorName                *string `json:"sponsorName,omitempty"`
+	SponsorEmail               *string `json:"sponsorEmail,omitempty"`
+	Expiration                 *string `json:"expiration,omitempty"`
+	ShareExpiration            *string `json:"shareExpiration,omitempty"`
+	MaxShares                  *int    `json:"maxShares,omitempty"`
+	MaxRewards                 *int    `json:"maxRewards,omitempty"`
+	OffChain                   *bool   `json:"offChain,omitempty"`
+	MaxDistributionFeeReward   *int    `json:"maxDistributionFeeReward,omitempty"`
+	MaxSharesPerReceivedShare  *int    `json:"maxSharesPerReceivedShare,omitempty"`
+	MaxDepth                   *int    `json:"maxDepth,omitempty"`
+	MaxNodes                   *int    `json:"maxNodes,omitempty"`
+	PublicKey                  *string `json:"publicKey,omitempty"`
+	Reward                     *string `json:"reward,omitempty"`
+	Active                     *bool   `json:"active,omitempty"`
+	Status                     *string `json:"status,omitempty"`
+	ClosedAt                   *string `json:"closedAt,omitempty"`
+	AttributionModel           *string `json:"attributionModel,omitempty"`
+	ChallengeTemplateID        *string `json:"challengeTemplateId,omitempty"`
+	ChallengeParentChallengeID *string `json:"challengeParentChallengeId,omitempty"`
+	AttachmentURL              *string `json:"attachmentURL,omitempty"`
+	MailAuthentication         *string `json:"mailAuthentication,omitempty"`
+	DeletedAt                  *string `json:"deletedAt,omitempty"`
+	ExpectedStatus             *string `json:"expectedStatus,omitempty"`
+}
+
+type UpdateShareActionContactInput struct {
+	ID                          string  `json:"id"`
+	ShareActionContactContactID *string `json:"shareActionContactContactId,omitempty"`
+}
+
+type UpdateShareActionInput struct {
+	ID                 string  `json:"id"`
+	ChallengeID        *string `json:"challengeId,omitempty"`
+	UserID             *string `json:"userId,omitempty"`
+	MailAuthentication *string `json:"mailAuthentication,omitempty"`
+	DeletedAt          *string `json:"deletedAt,omitempty"`
+}
+
+type UpdateTransactionInput struct {
+	ID                  string    `json:"id"`
+	ParentTransactionID *string   `json:"parentTransactionId,omitempty"`
+	TransactionActionID *string   `json:"transactionActionId,omitempty"`
+	Status              *string   `json:"status,omitempty"`
+	AttemptCounter      *int      `json:"attemptCounter,omitempty"`
+	TransitionErrors    []*string `json:"transitionErrors,omitempty"`
+	FraudFlags          []*string `json:"fraudFlags,omitempty"`
+	DeletedAt           *string   `json:"deletedAt,omitempty"`
+}
+
+type UpdateUserContactInput struct {
+	ID                   string  `json:"id"`
+	UserContactUserID    *string `json:"userContactUserId,omitempty"`
+	UserContactContactID *string `json:"userContactContactId,omitempty"`
+}
+
+type UpdateUserInput struct {
+	ID              string    `json:"id"`
+	Names           []*string `json:"names,omitempty"`
+	Emails          []*string `json:"emails,omitempty"`
+	CanonicalEmails []*string `json:"canonicalEmails,omitempty"`
+	PhoneNumbers    []*string `json:"phoneNumbers,omitempty"`
+	Pictures        []*string `json:"pictures,omitempty"`
+	Identity        *string   `json:"identity,omitempty"`
+	Token           *string   `json:"token,omitempty"`
+	Etag            *string   `json:"etag,omitempty"`
+	MergedInto      *string   `json:"mergedInto,omitempty"`
+	MergeProposals  []*string `json:"mergeProposals,omitempty"`
+	UnsubscribedAt  *string   `json:"unsubscribedAt,omitempty"`
+	DeletedAt       *string   `json:"deletedAt,omitempty"`
+	ExpectedVersion *int      `json:"expectedVersion,omitempty"`
+}
+
+type User struct {
+	ID               *string                     `json:"id,omitempty"`
+	Names            []*string                   `json:"names,omitempty"`
+	Emails           []*string                   `json:"emails,omitempty"`
+	CanonicalEmails  []*string                   `json:"canonicalEmails,omitempty"`
+	PhoneNumbers     []*string                   `json:"phoneNumbers,omitempty"`
+	Pictures         []*string                   `json:"pictures,omitempty"`
+	Identity         *string                     `json:"identity,omitempty"`
+	Token            *string                     `json:"token,omitempty"`
+	Etag             *string                     `json:"etag,omitempty"`
+	Version          *int                        `json:"version,omitempty"`
+	SharedActions    *ModelShareActionConnection `json:"sharedActions,omitempty"`
+	Contacts         *ModelUserContactConnection `json:"contacts,omitempty"`
+	UsersImContactOf *ModelUserContactConnection `json:"usersImContactOf,omitempty"`
+	MergedInto       *string                     `json:"mergedInto,omitempty"`
+	MergeProposals   []*string                   `json:"mergeProposals,omitempty"`
+	UnsubscribedAt   *string                     `json:"unsubscribedAt,omitempty"`
+	CreatedAt        *string                     `json:"createdAt,omitempty"`
+	UpdatedAt        *string                     `json:"updatedAt,omitempty"`
+	DeletedAt        *string                     `json:"deletedAt,omitempty"`
+}
+
+type UserContact struct {
+	ID                   *string `json:"id,omitempty"`
+	UserContactUserID    *string `json:"userContactUserId,omitempty"`
+	UserContactContactID *string `json:"userContactContactId,omitempty"`
+	User                 *User   `json:"user,omitempty"`
+	Contact              *User   `json:"contact,omitempty"`
+	CreatedAt            *string `json:"createdAt,omitempty"`
+	UpdatedAt            *string `json:"updatedAt,omitempty"`
+}
@@ -0,0 +1,1977 @@
+// Code generated by services/appsync/gen from schema.graphql and operations/*.graphql. DO NOT EDIT.
+
+package appsync
+
+import (
+	"context"
+
+	"gitlab.com/ncent/arber/api/services/appsync/filter"
+)
+
+// applicationFieldsFragment is the ApplicationFields fragment with the fragments it spreads.
+const applicationFieldsFragment = `fragment ApplicationFields on Application {
+  id
+  challengeId
+  candidateId
+  transactionId
+  resumeURL
+  message
+  status
+  attribution
+  mailAuthentication
+  hiredAt
+  payoutPlan
+  createdAt
+  updatedAt
+}`
+
+// challengeFieldsFragment is the ChallengeFields fragment with the fragments it spreads.
+const challengeFieldsFragment = `fragment ChallengeFields on Challenge {
+  id
+  name
+  description
+  imageUrl
+  sponsorName
+  sponsorEmail
+  expiration
+  shareExpiration
+  maxShares
+  maxRewards
+  offChain
+  maxDistributionFeeReward
+  maxSharesPerReceivedShare
+  maxDepth
+  maxNodes
+  publicKey
+  reward
+  active
+  status
+  closedAt
+  attributionModel
+  challengeTemplateId
+  challengeParentChallengeId
+  attachmentURL
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// shareActionFieldsFragment is the ShareActionFields fragment with the fragments it spreads.
+const shareActionFieldsFragment = `fragment ShareActionFields on ShareAction {
+  id
+  challengeId
+  userId
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// transactionFieldsFragment is the TransactionFields fragment with the fragments it spreads.
+const transactionFieldsFragment = `fragment TransactionFields on Transaction {
+  id
+  parentTransactionId
+  transactionActionId
+  status
+  attemptCounter
+  transitionErrors
+  fraudFlags
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// userFieldsFragment is the UserFields fragment with the fragments it spreads.
+const userFieldsFragment = `fragment UserFields on User {
+  id
+  names
+  emails
+  canonicalEmails
+  phoneNumbers
+  pictures
+  identity
+  token
+  etag
+  version
+  mergedInto
+  mergeProposals
+  unsubscribedAt
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+const getApplicationDocument = `query GetApplication($id: ID!) {
+  getApplication(id: $id) {
+    ...ApplicationFields
+  }
+}
+
+fragment ApplicationFields on Application {
+  id
+  challengeId
+  candidateId
+  transactionId
+  resumeURL
+  message
+  status
+  attribution
+  mailAuthentication
+  hiredAt
+  payoutPlan
+  createdAt
+  updatedAt
+}`
+
+// GetApplicationVariables are the variables of the GetApplication operation.
+type GetApplicationVariables struct {
+	ID string `json:"id"`
+}
+
+// GetApplicationResponse is the data returned by the GetApplication operation.
+type GetApplicationResponse struct {
+	GetApplication *Application `json:"getApplication"`
+}
+
+// GetApplication runs the GetApplication operation without a deadline.
+func (r AppSyncResolver) GetApplication(id string) (*Application, error) {
+	return r.GetApplicationContext(context.Background(), id)
+}
+
+// GetApplicationContext runs the GetApplication operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) GetApplicationContext(ctx context.Context, id string) (*Application, error) {
+	var response GetApplicationResponse
+	err := r.do(ctx, "GetApplication", getApplicationDocument, GetApplicationVariables{ID: id}, "getApplication", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.GetApplication, nil
+}
+
+const listApplicationsDocument = `query ListApplications($filter: ModelApplicationFilterInput, $limit: Int, $nextToken: String) {
+  listApplications(filter: $filter, limit: $limit, nextToken: $nextToken) {
+    items {
+      ...ApplicationFields
+    }
+    nextToken
+  }
+}
+
+fragment ApplicationFields on Application {
+  id
+  challengeId
+  candidateId
+  transactionId
+  resumeURL
+  message
+  status
+  attribution
+  mailAuthentication
+  hiredAt
+  payoutPlan
+  createdAt
+  updatedAt
+}`
+
+// ListApplicationsVariables are the variables of the ListApplications operation.
+type ListApplicationsVariables struct {
+	Filter    *filter.Filter `json:"filter,omitempty"`
+	Limit     *int           `json:"limit,omitempty"`
+	NextToken *string        `json:"nextToken,omitempty"`
+}
+
+// ListApplicationsResponse is the data returned by the ListApplications operation.
+type ListApplicationsResponse struct {
+	ListApplications *ModelApplicationConnection `json:"listApplications"`
+}
+
+// ListApplications runs the ListApplications operation without a deadline.
+func (r AppSyncResolver) ListApplications(modelFilter *filter.Filter) ([]*Application, error) {
+	return r.ListApplicationsContext(context.Background(), modelFilter)
+}
+
+// ListApplicationsContext runs the ListApplications operation, following nextToken across every page.
+func (r AppSyncResolver) ListApplicationsContext(ctx context.Context, modelFilter *filter.Filter) ([]*Application, error) {
+	var items []*Application
+	err := r.pagination.Each(func(limit int, nextToken *string) (*string, int, error) {
+		var response ListApplicationsResponse
+		err := r.do(ctx, "ListApplications", listApplicationsDocument, ListApplicationsVariables{Filter: modelFilter, Limit: &limit, NextToken: nextToken}, "listApplications", &response)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, response.ListApplications.Items...)
+		return response.ListApplications.NextToken, len(response.ListApplications.Items), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createApplicationDocument = `mutation CreateApplication($input: CreateApplicationInput!) {
+  createApplication(input: $input) {
+    ...ApplicationFields
+  }
+}
+
+fragment ApplicationFields on Application {
+  id
+  challengeId
+  candidateId
+  transactionId
+  resumeURL
+  message
+  status
+  attribution
+  mailAuthentication
+  hiredAt
+  payoutPlan
+  createdAt
+  updatedAt
+}`
+
+// CreateApplicationVariables are the variables of the CreateApplication operation.
+type CreateApplicationVariables struct {
+	Input CreateApplicationInput `json:"input"`
+}
+
+// CreateApplicationResponse is the data returned by the CreateApplication operation.
+type CreateApplicationResponse struct {
+	CreateApplication *Application `json:"createApplication"`
+}
+
+// CreateApplication runs the CreateApplication operation without a deadline.
+func (r AppSyncResolver) CreateApplication(input CreateApplicationInput) (*Application, error) {
+	return r.CreateApplicationContext(context.Background(), input)
+}
+
+// CreateApplicationContext runs the CreateApplication operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) CreateApplicationContext(ctx context.Context, input CreateApplicationInput) (*Application, error) {
+	var response CreateApplicationResponse
+	err := r.do(ctx, "CreateApplication", createApplicationDocument, CreateApplicationVariables{Input: input}, "createApplication", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.CreateApplication, nil
+}
+
+const updateApplicationDocument = `mutation UpdateApplication($input: UpdateApplicationInput!) {
+  updateApplication(input: $input) {
+    ...ApplicationFields
+  }
+}
+
+fragment ApplicationFields on Application {
+  id
+  challengeId
+  candidateId
+  transactionId
+  resumeURL
+  message
+  status
+  attribution
+  mailAuthentication
+  hiredAt
+  payoutPlan
+  createdAt
+  updatedAt
+}`
+
+// UpdateApplicationVariables are the variables of the UpdateApplication operation.
+type UpdateApplicationVariables struct {
+	Input UpdateApplicationInput `json:"input"`
+}
+
+// UpdateApplicationResponse is the data returned by the UpdateApplication operation.
+type UpdateApplicationResponse struct {
+	UpdateApplication *Application `json:"updateApplication"`
+}
+
+// UpdateApplication runs the UpdateApplication operation without a deadline.
+func (r AppSyncResolver) UpdateApplication(input UpdateApplicationInput) (*Application, error) {
+	return r.UpdateApplicationContext(context.Background(), input)
+}
+
+// UpdateApplicationContext runs the UpdateApplication operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) UpdateApplicationContext(ctx context.Context, input UpdateApplicationInput) (*Application, error) {
+	var response UpdateApplicationResponse
+	err := r.do(ctx, "UpdateApplication", updateApplicationDocument, UpdateApplicationVariables{Input: input}, "updateApplication", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateApplication, nil
+}
+
+const getChallengeDocument = `query GetChallenge($id: ID!) {
+  getChallenge(id: $id) {
+    ...ChallengeFields
+  }
+}
+
+fragment ChallengeFields on Challenge {
+  id
+  name
+  description
+  imageUrl
+  sponsorName
+  sponsorEmail
+  expiration
+  shareExpiration
+  maxShares
+  maxRewards
+  offChain
+  maxDistributionFeeReward
+  maxSharesPerReceivedShare
+  maxDepth
+  maxNodes
+  publicKey
+  reward
+  active
+  status
+  closedAt
+  attributionModel
+  challengeTemplateId
+  challengeParentChallengeId
+  attachmentURL
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// GetChallengeVariables are the variables of the GetChallenge operation.
+type GetChallengeVariables struct {
+	ID string `json:"id"`
+}
+
+// GetChallengeResponse is the data returned by the GetChallenge operation.
+type GetChallengeResponse struct {
+	GetChallenge *Challenge `json:"getChallenge"`
+}
+
+// GetChallenge runs the GetChallenge operation without a deadline.
+func (r AppSyncResolver) GetChallenge(id string) (*Challenge, error) {
+	return r.GetChallengeContext(context.Background(), id)
+}
+
+// GetChallengeContext runs the GetChallenge operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) GetChallengeContext(ctx context.Context, id string) (*Challenge, error) {
+	var response GetChallengeResponse
+	err := r.do(ctx, "GetChallenge", getChallengeDocument, GetChallengeVariables{ID: id}, "getChallenge", &response)
+	if err != nil {
+		return nil, err
+	}
+	if !r.includeDeleted && response.GetChallenge.DeletedAt != nil {
+		return nil, newError(ErrNotFound, "GetChallenge", "getChallenge returned a deleted entity")
+	}
+	return response.GetChallenge, nil
+}
+
+const getChallengeSponsorTokenDocument = `query GetChallengeSponsorToken($id: ID!) {
+  getChallenge(id: $id) {
+    id
+    sponsorToken
+    deletedAt
+  }
+}`
+
+// GetChallengeSponsorTokenVariables are the variables of the GetChallengeSponsorToken operation.
+type GetChallengeSponsorTokenVariables struct {
+	ID string `json:"id"`
+}
+
+// GetChallengeSponsorTokenResponse is the data returned by the GetChallengeSponsorToken operation.
+type GetChallengeSponsorTokenResponse struct {
+	GetChallenge *Challenge `json:"getChallenge"`
+}
+
+// GetChallengeSponsorToken runs the GetChallengeSponsorToken operation without a deadline.
+func (r AppSyncResolver) GetChallengeSponsorToken(id string) (*Challenge, error) {
+	return r.GetChallengeSponsorTokenContext(context.Background(), id)
+}
+
+// GetChallengeSponsorTokenContext runs the GetChallengeSponsorToken operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) GetChallengeSponsorTokenContext(ctx context.Context, id string) (*Challenge, error) {
+	var response GetChallengeSponsorTokenResponse
+	err := r.do(ctx, "GetChallengeSponsorToken", getChallengeSponsorTokenDocument, GetChallengeSponsorTokenVariables{ID: id}, "getChallenge", &response)
+	if err != nil {
+		return nil, err
+	}
+	if !r.includeDeleted && response.GetChallenge.DeletedAt != nil {
+		return nil, newError(ErrNotFound, "GetChallengeSponsorToken", "getChallenge returned a deleted entity")
+	}
+	return response.GetChallenge, nil
+}
+
+const listChallengesDocument = `query ListChallenges($filter: ModelChallengeFilterInput, $limit: Int, $nextToken: String) {
+  listChallenges(filter: $filter, limit: $limit, nextToken: $nextToken) {
+    items {
+      ...ChallengeFields
+    }
+    nextToken
+  }
+}
+
+fragment ChallengeFields on Challenge {
+  id
+  name
+  description
+  imageUrl
+  sponsorName
+  sponsorEmail
+  expiration
+  shareExpiration
+  maxShares
+  maxRewards
+  offChain
+  maxDistributionFeeReward
+  maxSharesPerReceivedShare
+  maxDepth
+  maxNodes
+  publicKey
+  reward
+  active
+  status
+  closedAt
+  attributionModel
+  challengeTemplateId
+  challengeParentChallengeId
+  attachmentURL
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// ListChallengesVariables are the variables of the ListChallenges operation.
+type ListChallengesVariables struct {
+	Filter    *filter.Filter `json:"filter,omitempty"`
+	Limit     *int           `json:"limit,omitempty"`
+	NextToken *string        `json:"nextToken,omitempty"`
+}
+
+// ListChallengesResponse is the data returned by the ListChallenges operation.
+type ListChallengesResponse struct {
+	ListChallenges *ModelChallengeConnection `json:"listChallenges"`
+}
+
+// ListChallenges runs the ListChallenges operation without a deadline.
+func (r AppSyncResolver) ListChallenges(modelFilter *filter.Filter) ([]*Challenge, error) {
+	return r.ListChallengesContext(context.Background(), modelFilter)
+}
+
+// ListChallengesContext runs the ListChallenges operation, following nextToken across every page.
+func (r AppSyncResolver) ListChallengesContext(ctx context.Context, modelFilter *filter.Filter) ([]*Challenge, error) {
+	modelFilter = r.excludeDeleted(modelFilter)
+	var items []*Challenge
+	err := r.pagination.Each(func(limit int, nextToken *string) (*string, int, error) {
+		var response ListChallengesResponse
+		err := r.do(ctx, "ListChallenges", listChallengesDocument, ListChallengesVariables{Filter: modelFilter, Limit: &limit, NextToken: nextToken}, "listChallenges", &response)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, response.ListChallenges.Items...)
+		return response.ListChallenges.NextToken, len(response.ListChallenges.Items), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createChallengeDocument = `mutation CreateChallenge($input: CreateChallengeInput!) {
+  createChallenge(input: $input) {
+    ...ChallengeFields
+  }
+}
+
+fragment ChallengeFields on Challenge {
+  id
+  name
+  description
+  imageUrl
+  sponsorName
+  sponsorEmail
+  expiration
+  shareExpiration
+  maxShares
+  maxRewards
+  offChain
+  maxDistributionFeeReward
+  maxSharesPerReceivedShare
+  maxDepth
+  maxNodes
+  publicKey
+  reward
+  active
+  status
+  closedAt
+  attributionModel
+  challengeTemplateId
+  challengeParentChallengeId
+  attachmentURL
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// CreateChallengeVariables are the variables of the CreateChallenge operation.
+type CreateChallengeVariables struct {
+	Input CreateChallengeInput `json:"input"`
+}
+
+// CreateChallengeResponse is the data returned by the CreateChallenge operation.
+type CreateChallengeResponse struct {
+	CreateChallenge *Challenge `json:"createChallenge"`
+}
+
+// CreateChallenge runs the CreateChallenge operation without a deadline.
+func (r AppSyncResolver) CreateChallenge(input CreateChallengeInput) (*Challenge, error) {
+	return r.CreateChallengeContext(context.Background(), input)
+}
+
+// CreateChallengeContext runs the CreateChallenge operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) CreateChallengeContext(ctx context.Context, input CreateChallengeInput) (*Challenge, error) {
+	var response CreateChallengeResponse
+	err := r.do(ctx, "CreateChallenge", createChallengeDocument, CreateChallengeVariables{Input: input}, "createChallenge", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.CreateChallenge, nil
+}
+
+const updateChallengeDocument = `mutation UpdateChallenge($input: UpdateChallengeInput!) {
+  updateChallenge(input: $input) {
+    ...ChallengeFields
+  }
+}
+
+fragment ChallengeFields on Challenge {
+  id
+  name
+  description
+  imageUrl
+  sponsorName
+  sponsorEmail
+  expiration
+  shareExpiration
+  maxShares
+  maxRewards
+  offChain
+  maxDistributionFeeReward
+  maxSharesPerReceivedShare
+  maxDepth
+  maxNodes
+  publicKey
+  reward
+  active
+  status
+  closedAt
+  attributionModel
+  challengeTemplateId
+  challengeParentChallengeId
+  attachmentURL
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// UpdateChallengeVariables are the variables of the UpdateChallenge operation.
+type UpdateChallengeVariables struct {
+	Input UpdateChallengeInput `json:"input"`
+}
+
+// UpdateChallengeResponse is the data returned by the UpdateChallenge operation.
+type UpdateChallengeResponse struct {
+	UpdateChallenge *Challenge `json:"updateChallenge"`
+}
+
+// UpdateChallenge runs the UpdateChallenge operation without a deadline.
+func (r AppSyncResolver) UpdateChallenge(input UpdateChallengeInput) (*Challenge, error) {
+	return r.UpdateChallengeContext(context.Background(), input)
+}
+
+// UpdateChallengeContext runs the UpdateChallenge operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) UpdateChallengeContext(ctx context.Context, input UpdateChallengeInput) (*Challenge, error) {
+	var response UpdateChallengeResponse
+	err := r.do(ctx, "UpdateChallenge", updateChallengeDocument, UpdateChallengeVariables{Input: input}, "updateChallenge", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateChallenge, nil
+}
+
+const softDeleteChallengeDocument = `mutation SoftDeleteChallenge($id: ID!, $deletedAt: AWSDateTime!) {
+  updateChallenge(input: {id:$id,deletedAt:$deletedAt}) {
+    ...ChallengeFields
+  }
+}
+
+fragment ChallengeFields on Challenge {
+  id
+  name
+  description
+  imageUrl
+  sponsorName
+  sponsorEmail
+  expiration
+  shareExpiration
+  maxShares
+  maxRewards
+  offChain
+  maxDistributionFeeReward
+  maxSharesPerReceivedShare
+  maxDepth
+  maxNodes
+  publicKey
+  reward
+  active
+  status
+  closedAt
+  attributionModel
+  challengeTemplateId
+  challengeParentChallengeId
+  attachmentURL
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// SoftDeleteChallengeVariables are the variables of the SoftDeleteChallenge operation.
+type SoftDeleteChallengeVariables struct {
+	ID        string `json:"id"`
+	DeletedAt string `json:"deletedAt"`
+}
+
+// SoftDeleteChallengeResponse is the data returned by the SoftDeleteChallenge operation.
+type SoftDeleteChallengeResponse struct {
+	UpdateChallenge *Challenge `json:"updateChallenge"`
+}
+
+// SoftDeleteChallenge runs the SoftDeleteChallenge operation without a deadline.
+func (r AppSyncResolver) SoftDeleteChallenge(id string, deletedAt string) (*Challenge, error) {
+	return r.SoftDeleteChallengeContext(context.Background(), id, deletedAt)
+}
+
+// SoftDeleteChallengeContext runs the SoftDeleteChallenge operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) SoftDeleteChallengeContext(ctx context.Context, id string, deletedAt string) (*Challenge, error) {
+	var response SoftDeleteChallengeResponse
+	err := r.do(ctx, "SoftDeleteChallenge", softDeleteChallengeDocument, SoftDeleteChallengeVariables{ID: id, DeletedAt: deletedAt}, "updateChallenge", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateChallenge, nil
+}
+
+const restoreChallengeDocument = `mutation RestoreChallenge($id: ID!) {
+  updateChallenge(input: {id:$id,deletedAt:null}) {
+    ...ChallengeFields
+  }
+}
+
+fragment ChallengeFields on Challenge {
+  id
+  name
+  description
+  imageUrl
+  sponsorName
+  sponsorEmail
+  expiration
+  shareExpiration
+  maxShares
+  maxRewards
+  offChain
+  maxDistributionFeeReward
+  maxSharesPerReceivedShare
+  maxDepth
+  maxNodes
+  publicKey
+  reward
+  active
+  status
+  closedAt
+  attributionModel
+  challengeTemplateId
+  challengeParentChallengeId
+  attachmentURL
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// RestoreChallengeVariables are the variables of the RestoreChallenge operation.
+type RestoreChallengeVariables struct {
+	ID string `json:"id"`
+}
+
+// RestoreChallengeResponse is the data returned by the RestoreChallenge operation.
+type RestoreChallengeResponse struct {
+	UpdateChallenge *Challenge `json:"updateChallenge"`
+}
+
+// RestoreChallenge runs the RestoreChallenge operation without a deadline.
+func (r AppSyncResolver) RestoreChallenge(id string) (*Challenge, error) {
+	return r.RestoreChallengeContext(context.Background(), id)
+}
+
+// RestoreChallengeContext runs the RestoreChallenge operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) RestoreChallengeContext(ctx context.Context, id string) (*Challenge, error) {
+	var response RestoreChallengeResponse
+	err := r.do(ctx, "RestoreChallenge", restoreChallengeDocument, RestoreChallengeVariables{ID: id}, "updateChallenge", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateChallenge, nil
+}
+
+const getShareActionDocument = `query GetShareAction($id: ID!) {
+  getShareAction(id: $id) {
+    ...ShareActionFields
+  }
+}
+
+fragment ShareActionFields on ShareAction {
+  id
+  challengeId
+  userId
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// GetShareActionVariables are the variables of the GetShareAction operation.
+type GetShareActionVariables struct {
+	ID string `json:"id"`
+}
+
+// GetShareActionResponse is the data returned by the GetShareAction operation.
+type GetShareActionResponse struct {
+	GetShareAction *ShareAction `json:"getShareAction"`
+}
+
+// GetShareAction runs the GetShareAction operation without a deadline.
+func (r AppSyncResolver) GetShareAction(id string) (*ShareAction, error) {
+	return r.GetShareActionContext(context.Background(), id)
+}
+
+// GetShareActionContext runs the GetShareAction operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) GetShareActionContext(ctx context.Context, id string) (*ShareAction, error) {
+	var response GetShareActionResponse
+	err := r.do(ctx, "GetShareAction", getShareActionDocument, GetShareActionVariables{ID: id}, "getShareAction", &response)
+	if err != nil {
+		return nil, err
+	}
+	if !r.includeDeleted && response.GetShareAction.DeletedAt != nil {
+		return nil, newError(ErrNotFound, "GetShareAction", "getShareAction returned a deleted entity")
+	}
+	return response.GetShareAction, nil
+}
+
+const listShareActionsDocument = `query ListShareActions($filter: ModelShareActionFilterInput, $limit: Int, $nextToken: String) {
+  listShareActions(filter: $filter, limit: $limit, nextToken: $nextToken) {
+    items {
+      ...ShareActionFields
+    }
+    nextToken
+  }
+}
+
+fragment ShareActionFields on ShareAction {
+  id
+  challengeId
+  userId
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// ListShareActionsVariables are the variables of the ListShareActions operation.
+type ListShareActionsVariables struct {
+	Filter    *filter.Filter `json:"filter,omitempty"`
+	Limit     *int           `json:"limit,omitempty"`
+	NextToken *string        `json:"nextToken,omitempty"`
+}
+
+// ListShareActionsResponse is the data returned by the ListShareActions operation.
+type ListShareActionsResponse struct {
+	ListShareActions *ModelShareActionConnection `json:"listShareActions"`
+}
+
+// ListShareActions runs the ListShareActions operation without a deadline.
+func (r AppSyncResolver) ListShareActions(modelFilter *filter.Filter) ([]*ShareAction, error) {
+	return r.ListShareActionsContext(context.Background(), modelFilter)
+}
+
+// ListShareActionsContext runs the ListShareActions operation, following nextToken across every page.
+func (r AppSyncResolver) ListShareActionsContext(ctx context.Context, modelFilter *filter.Filter) ([]*ShareAction, error) {
+	modelFilter = r.excludeDeleted(modelFilter)
+	var items []*ShareAction
+	err := r.pagination.Each(func(limit int, nextToken *string) (*string, int, error) {
+		var response ListShareActionsResponse
+		err := r.do(ctx, "ListShareActions", listShareActionsDocument, ListShareActionsVariables{Filter: modelFilter, Limit: &limit, NextToken: nextToken}, "listShareActions", &response)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, response.ListShareActions.Items...)
+		return response.ListShareActions.NextToken, len(response.ListShareActions.Items), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createShareActionDocument = `mutation CreateShareAction($input: CreateShareActionInput!) {
+  createShareAction(input: $input) {
+    ...ShareActionFields
+  }
+}
+
+fragment ShareActionFields on ShareAction {
+  id
+  challengeId
+  userId
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// CreateShareActionVariables are the variables of the CreateShareAction operation.
+type CreateShareActionVariables struct {
+	Input CreateShareActionInput `json:"input"`
+}
+
+// CreateShareActionResponse is the data returned by the CreateShareAction operation.
+type CreateShareActionResponse struct {
+	CreateShareAction *ShareAction `json:"createShareAction"`
+}
+
+// CreateShareAction runs the CreateShareAction operation without a deadline.
+func (r AppSyncResolver) CreateShareAction(input CreateShareActionInput) (*ShareAction, error) {
+	return r.CreateShareActionContext(context.Background(), input)
+}
+
+// CreateShareActionContext runs the CreateShareAction operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) CreateShareActionContext(ctx context.Context, input CreateShareActionInput) (*ShareAction, error) {
+	var response CreateShareActionResponse
+	err := r.do(ctx, "CreateShareAction", createShareActionDocument, CreateShareActionVariables{Input: input}, "createShareAction", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.CreateShareAction, nil
+}
+
+const updateShareActionDocument = `mutation UpdateShareAction($input: UpdateShareActionInput!) {
+  updateShareAction(input: $input) {
+    ...ShareActionFields
+  }
+}
+
+fragment ShareActionFields on ShareAction {
+  id
+  challengeId
+  userId
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// UpdateShareActionVariables are the variables of the UpdateShareAction operation.
+type UpdateShareActionVariables struct {
+	Input UpdateShareActionInput `json:"input"`
+}
+
+// UpdateShareActionResponse is the data returned by the UpdateShareAction operation.
+type UpdateShareActionResponse struct {
+	UpdateShareAction *ShareAction `json:"updateShareAction"`
+}
+
+// UpdateShareAction runs the UpdateShareAction operation without a deadline.
+func (r AppSyncResolver) UpdateShareAction(input UpdateShareActionInput) (*ShareAction, error) {
+	return r.UpdateShareActionContext(context.Background(), input)
+}
+
+// UpdateShareActionContext runs the UpdateShareAction operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) UpdateShareActionContext(ctx context.Context, input UpdateShareActionInput) (*ShareAction, error) {
+	var response UpdateShareActionResponse
+	err := r.do(ctx, "UpdateShareAction", updateShareActionDocument, UpdateShareActionVariables{Input: input}, "updateShareAction", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateShareAction, nil
+}
+
+const createShareActionContactDocument = `mutation CreateShareActionContact($input: CreateShareActionContactInput!) {
+  createShareActionContact(input: $input) {
+    id
+    shareAction {
+      ...ShareActionFields
+    }
+    contact {
+      ...UserFields
+    }
+  }
+}
+
+fragment ShareActionFields on ShareAction {
+  id
+  challengeId
+  userId
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}
+
+fragment UserFields on User {
+  id
+  names
+  emails
+  canonicalEmails
+  phoneNumbers
+  pictures
+  identity
+  token
+  etag
+  version
+  mergedInto
+  mergeProposals
+  unsubscribedAt
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// CreateShareActionContactVariables are the variables of the CreateShareActionContact operation.
+type CreateShareActionContactVariables struct {
+	Input CreateShareActionContactInput `json:"input"`
+}
+
+// CreateShareActionContactResponse is the data returned by the CreateShareActionContact operation.
+type CreateShareActionContactResponse struct {
+	CreateShareActionContact *ShareActionContact `json:"createShareActionContact"`
+}
+
+// CreateShareActionContact runs the CreateShareActionContact operation without a deadline.
+func (r AppSyncResolver) CreateShareActionContact(input CreateShareActionContactInput) (*ShareActionContact, error) {
+	return r.CreateShareActionContactContext(context.Background(), input)
+}
+
+// CreateShareActionContactContext runs the CreateShareActionContact operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) CreateShareActionContactContext(ctx context.Context, input CreateShareActionContactInput) (*ShareActionContact, error) {
+	var response CreateShareActionContactResponse
+	err := r.do(ctx, "CreateShareActionContact", createShareActionContactDocument, CreateShareActionContactVariables{Input: input}, "createShareActionContact", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.CreateShareActionContact, nil
+}
+
+const updateShareActionContactDocument = `mutation UpdateShareActionContact($input: UpdateShareActionContactInput!) {
+  updateShareActionContact(input: $input) {
+    id
+    shareActionContactShareActionId
+    shareActionContactContactId
+    createdAt
+  }
+}`
+
+// UpdateShareActionContactVariables are the variables of the UpdateShareActionContact operation.
+type UpdateShareActionContactVariables struct {
+	Input UpdateShareActionContactInput `json:"input"`
+}
+
+// UpdateShareActionContactResponse is the data returned by the UpdateShareActionContact operation.
+type UpdateShareActionContactResponse struct {
+	UpdateShareActionContact *ShareActionContact `json:"updateShareActionContact"`
+}
+
+// UpdateShareActionContact runs the UpdateShareActionContact operation without a deadline.
+func (r AppSyncResolver) UpdateShareActionContact(input UpdateShareActionContactInput) (*ShareActionContact, error) {
+	return r.UpdateShareActionContactContext(context.Background(), input)
+}
+
+// UpdateShareActionContactContext runs the UpdateShareActionContact operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) UpdateShareActionContactContext(ctx context.Context, input UpdateShareActionContactInput) (*ShareActionContact, error) {
+	var response UpdateShareActionContactResponse
+	err := r.do(ctx, "UpdateShareActionContact", updateShareActionContactDocument, UpdateShareActionContactVariables{Input: input}, "updateShareActionContact", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateShareActionContact, nil
+}
+
+const listShareActionContactsDocument = `query ListShareActionContacts($filter: ModelShareActionContactFilterInput, $limit: Int, $nextToken: String) {
+  listShareActionContacts(filter: $filter, limit: $limit, nextToken: $nextToken) {
+    items {
+      id
+      shareActionContactShareActionId
+      shareActionContactContactId
+      shareAction {
+        ...ShareActionFields
+      }
+      contact {
+        ...UserFields
+      }
+      createdAt
+    }
+    nextToken
+  }
+}
+
+fragment ShareActionFields on ShareAction {
+  id
+  challengeId
+  userId
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}
+
+fragment UserFields on User {
+  id
+  names
+  emails
+  canonicalEmails
+  phoneNumbers
+  pictures
+  identity
+  token
+  etag
+  version
+  mergedInto
+  mergeProposals
+  unsubscribedAt
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// ListShareActionContactsVariables are the variables of the ListShareActionContacts operation.
+type ListShareActionContactsVariables struct {
+	Filter    *filter.Filter `json:"filter,omitempty"`
+	Limit     *int           `json:"limit,omitempty"`
+	NextToken *string        `json:"nextToken,omitempty"`
+}
+
+// ListShareActionContactsResponse is the data returned by the ListShareActionContacts operation.
+type ListShareActionContactsResponse struct {
+	ListShareActionContacts *ModelShareActionContactConnection `json:"listShareActionContacts"`
+}
+
+// ListShareActionContacts runs the ListShareActionContacts operation without a deadline.
+func (r AppSyncResolver) ListShareActionContacts(modelFilter *filter.Filter) ([]*ShareActionContact, error) {
+	return r.ListShareActionContactsContext(context.Background(), modelFilter)
+}
+
+// ListShareActionContactsContext runs the ListShareActionContacts operation, following nextToken across every page.
+func (r AppSyncResolver) ListShareActionContactsContext(ctx context.Context, modelFilter *filter.Filter) ([]*ShareActionContact, error) {
+	var items []*ShareActionContact
+	err := r.pagination.Each(func(limit int, nextToken *string) (*string, int, error) {
+		var response ListShareActionContactsResponse
+		err := r.do(ctx, "ListShareActionContacts", listShareActionContactsDocument, ListShareActionContactsVariables{Filter: modelFilter, Limit: &limit, NextToken: nextToken}, "listShareActionContacts", &response)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, response.ListShareActionContacts.Items...)
+		return response.ListShareActionContacts.NextToken, len(response.ListShareActionContacts.Items), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const softDeleteShareActionDocument = `mutation SoftDeleteShareAction($id: ID!, $deletedAt: AWSDateTime!) {
+  updateShareAction(input: {id:$id,deletedAt:$deletedAt}) {
+    ...ShareActionFields
+  }
+}
+
+fragment ShareActionFields on ShareAction {
+  id
+  challengeId
+  userId
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// SoftDeleteShareActionVariables are the variables of the SoftDeleteShareAction operation.
+type SoftDeleteShareActionVariables struct {
+	ID        string `json:"id"`
+	DeletedAt string `json:"deletedAt"`
+}
+
+// SoftDeleteShareActionResponse is the data returned by the SoftDeleteShareAction operation.
+type SoftDeleteShareActionResponse struct {
+	UpdateShareAction *ShareAction `json:"updateShareAction"`
+}
+
+// SoftDeleteShareAction runs the SoftDeleteShareAction operation without a deadline.
+func (r AppSyncResolver) SoftDeleteShareAction(id string, deletedAt string) (*ShareAction, error) {
+	return r.SoftDeleteShareActionContext(context.Background(), id, deletedAt)
+}
+
+// SoftDeleteShareActionContext runs the SoftDeleteShareAction operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) SoftDeleteShareActionContext(ctx context.Context, id string, deletedAt string) (*ShareAction, error) {
+	var response SoftDeleteShareActionResponse
+	err := r.do(ctx, "SoftDeleteShareAction", softDeleteShareActionDocument, SoftDeleteShareActionVariables{ID: id, DeletedAt: deletedAt}, "updateShareAction", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateShareAction, nil
+}
+
+const restoreShareActionDocument = `mutation RestoreShareAction($id: ID!) {
+  updateShareAction(input: {id:$id,deletedAt:null}) {
+    ...ShareActionFields
+  }
+}
+
+fragment ShareActionFields on ShareAction {
+  id
+  challengeId
+  userId
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// RestoreShareActionVariables are the variables of the RestoreShareAction operation.
+type RestoreShareActionVariables struct {
+	ID string `json:"id"`
+}
+
+// RestoreShareActionResponse is the data returned by the RestoreShareAction operation.
+type RestoreShareActionResponse struct {
+	UpdateShareAction *ShareAction `json:"updateShareAction"`
+}
+
+// RestoreShareAction runs the RestoreShareAction operation without a deadline.
+func (r AppSyncResolver) RestoreShareAction(id string) (*ShareAction, error) {
+	return r.RestoreShareActionContext(context.Background(), id)
+}
+
+// RestoreShareActionContext runs the RestoreShareAction operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) RestoreShareActionContext(ctx context.Context, id string) (*ShareAction, error) {
+	var response RestoreShareActionResponse
+	err := r.do(ctx, "RestoreShareAction", restoreShareActionDocument, RestoreShareActionVariables{ID: id}, "updateShareAction", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateShareAction, nil
+}
+
+const getTransactionDocument = `query GetTransaction($id: ID!) {
+  getTransaction(id: $id) {
+    ...TransactionFields
+    action {
+      ...ShareActionFields
+    }
+    parentTransaction {
+      ...TransactionFields
+    }
+  }
+}
+
+fragment ShareActionFields on ShareAction {
+  id
+  challengeId
+  userId
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}
+
+fragment TransactionFields on Transaction {
+  id
+  parentTransactionId
+  transactionActionId
+  status
+  attemptCounter
+  transitionErrors
+  fraudFlags
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// GetTransactionVariables are the variables of the GetTransaction operation.
+type GetTransactionVariables struct {
+	ID string `json:"id"`
+}
+
+// GetTransactionResponse is the data returned by the GetTransaction operation.
+type GetTransactionResponse struct {
+	GetTransaction *Transaction `json:"getTransaction"`
+}
+
+// GetTransaction runs the GetTransaction operation without a deadline.
+func (r AppSyncResolver) GetTransaction(id string) (*Transaction, error) {
+	return r.GetTransactionContext(context.Background(), id)
+}
+
+// GetTransactionContext runs the GetTransaction operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) GetTransactionContext(ctx context.Context, id string) (*Transaction, error) {
+	var response GetTransactionResponse
+	err := r.do(ctx, "GetTransaction", getTransactionDocument, GetTransactionVariables{ID: id}, "getTransaction", &response)
+	if err != nil {
+		return nil, err
+	}
+	if !r.includeDeleted && response.GetTransaction.DeletedAt != nil {
+		return nil, newError(ErrNotFound, "GetTransaction", "getTransaction returned a deleted entity")
+	}
+	return response.GetTransaction, nil
+}
+
+const listTransactionsDocument = `query ListTransactions($filter: ModelTransactionFilterInput, $limit: Int, $nextToken: String) {
+  listTransactions(filter: $filter, limit: $limit, nextToken: $nextToken) {
+    items {
+      ...TransactionFields
+      action {
+        ...ShareActionFields
+      }
+    }
+    nextToken
+  }
+}
+
+fragment ShareActionFields on ShareAction {
+  id
+  challengeId
+  userId
+  mailAuthentication
+  createdAt
+  updatedAt
+  deletedAt
+}
+
+fragment TransactionFields on Transaction {
+  id
+  parentTransactionId
+  transactionActionId
+  status
+  attemptCounter
+  transitionErrors
+  fraudFlags
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// ListTransactionsVariables are the variables of the ListTransactions operation.
+type ListTransactionsVariables struct {
+	Filter    *filter.Filter `json:"filter,omitempty"`
+	Limit     *int           `json:"limit,omitempty"`
+	NextToken *string        `json:"nextToken,omitempty"`
+}
+
+// ListTransactionsResponse is the data returned by the ListTransactions operation.
+type ListTransactionsResponse struct {
+	ListTransactions *ModelTransactionConnection `json:"listTransactions"`
+}
+
+// ListTransactions runs the ListTransactions operation without a deadline.
+func (r AppSyncResolver) ListTransactions(modelFilter *filter.Filter) ([]*Transaction, error) {
+	return r.ListTransactionsContext(context.Background(), modelFilter)
+}
+
+// ListTransactionsContext runs the ListTransactions operation, following nextToken across every page.
+func (r AppSyncResolver) ListTransactionsContext(ctx context.Context, modelFilter *filter.Filter) ([]*Transaction, error) {
+	modelFilter = r.excludeDeleted(modelFilter)
+	var items []*Transaction
+	err := r.pagination.Each(func(limit int, nextToken *string) (*string, int, error) {
+		var response ListTransactionsResponse
+		err := r.do(ctx, "ListTransactions", listTransactionsDocument, ListTransactionsVariables{Filter: modelFilter, Limit: &limit, NextToken: nextToken}, "listTransactions", &response)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, response.ListTransactions.Items...)
+		return response.ListTransactions.NextToken, len(response.ListTransactions.Items), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createTransactionDocument = `mutation CreateTransaction($input: CreateTransactionInput!) {
+  createTransaction(input: $input) {
+    ...TransactionFields
+  }
+}
+
+fragment TransactionFields on Transaction {
+  id
+  parentTransactionId
+  transactionActionId
+  status
+  attemptCounter
+  transitionErrors
+  fraudFlags
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// CreateTransactionVariables are the variables of the CreateTransaction operation.
+type CreateTransactionVariables struct {
+	Input CreateTransactionInput `json:"input"`
+}
+
+// CreateTransactionResponse is the data returned by the CreateTransaction operation.
+type CreateTransactionResponse struct {
+	CreateTransaction *Transaction `json:"createTransaction"`
+}
+
+// CreateTransaction runs the CreateTransaction operation without a deadline.
+func (r AppSyncResolver) CreateTransaction(input CreateTransactionInput) (*Transaction, error) {
+	return r.CreateTransactionContext(context.Background(), input)
+}
+
+// CreateTransactionContext runs the CreateTransaction operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) CreateTransactionContext(ctx context.Context, input CreateTransactionInput) (*Transaction, error) {
+	var response CreateTransactionResponse
+	err := r.do(ctx, "CreateTransaction", createTransactionDocument, CreateTransactionVariables{Input: input}, "createTransaction", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.CreateTransaction, nil
+}
+
+const updateTransactionDocument = `mutation UpdateTransaction($input: UpdateTransactionInput!) {
+  updateTransaction(input: $input) {
+    ...TransactionFields
+  }
+}
+
+fragment TransactionFields on Transaction {
+  id
+  parentTransactionId
+  transactionActionId
+  status
+  attemptCounter
+  transitionErrors
+  fraudFlags
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// UpdateTransactionVariables are the variables of the UpdateTransaction operation.
+type UpdateTransactionVariables struct {
+	Input UpdateTransactionInput `json:"input"`
+}
+
+// UpdateTransactionResponse is the data returned by the UpdateTransaction operation.
+type UpdateTransactionResponse struct {
+	UpdateTransaction *Transaction `json:"updateTransaction"`
+}
+
+// UpdateTransaction runs the UpdateTransaction operation without a deadline.
+func (r AppSyncResolver) UpdateTransaction(input UpdateTransactionInput) (*Transaction, error) {
+	return r.UpdateTransactionContext(context.Background(), input)
+}
+
+// UpdateTransactionContext runs the UpdateTransaction operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) UpdateTransactionContext(ctx context.Context, input UpdateTransactionInput) (*Transaction, error) {
+	var response UpdateTransactionResponse
+	err := r.do(ctx, "UpdateTransaction", updateTransactionDocument, UpdateTransactionVariables{Input: input}, "updateTransaction", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateTransaction, nil
+}
+
+const softDeleteTransactionDocument = `mutation SoftDeleteTransaction($id: ID!, $deletedAt: AWSDateTime!) {
+  updateTransaction(input: {id:$id,deletedAt:$deletedAt}) {
+    ...TransactionFields
+  }
+}
+
+fragment TransactionFields on Transaction {
+  id
+  parentTransactionId
+  transactionActionId
+  status
+  attemptCounter
+  transitionErrors
+  fraudFlags
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// SoftDeleteTransactionVariables are the variables of the SoftDeleteTransaction operation.
+type SoftDeleteTransactionVariables struct {
+	ID        string `json:"id"`
+	DeletedAt string `json:"deletedAt"`
+}
+
+// SoftDeleteTransactionResponse is the data returned by the SoftDeleteTransaction operation.
+type SoftDeleteTransactionResponse struct {
+	UpdateTransaction *Transaction `json:"updateTransaction"`
+}
+
+// SoftDeleteTransaction runs the SoftDeleteTransaction operation without a deadline.
+func (r AppSyncResolver) SoftDeleteTransaction(id string, deletedAt string) (*Transaction, error) {
+	return r.SoftDeleteTransactionContext(context.Background(), id, deletedAt)
+}
+
+// SoftDeleteTransactionContext runs the SoftDeleteTransaction operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) SoftDeleteTransactionContext(ctx context.Context, id string, deletedAt string) (*Transaction, error) {
+	var response SoftDeleteTransactionResponse
+	err := r.do(ctx, "SoftDeleteTransaction", softDeleteTransactionDocument, SoftDeleteTransactionVariables{ID: id, DeletedAt: deletedAt}, "updateTransaction", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateTransaction, nil
+}
+
+const restoreTransactionDocument = `mutation RestoreTransaction($id: ID!) {
+  updateTransaction(input: {id:$id,deletedAt:null}) {
+    ...TransactionFields
+  }
+}
+
+fragment TransactionFields on Transaction {
+  id
+  parentTransactionId
+  transactionActionId
+  status
+  attemptCounter
+  transitionErrors
+  fraudFlags
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// RestoreTransactionVariables are the variables of the RestoreTransaction operation.
+type RestoreTransactionVariables struct {
+	ID string `json:"id"`
+}
+
+// RestoreTransactionResponse is the data returned by the RestoreTransaction operation.
+type RestoreTransactionResponse struct {
+	UpdateTransaction *Transaction `json:"updateTransaction"`
+}
+
+// RestoreTransaction runs the RestoreTransaction operation without a deadline.
+func (r AppSyncResolver) RestoreTransaction(id string) (*Transaction, error) {
+	return r.RestoreTransactionContext(context.Background(), id)
+}
+
+// RestoreTransactionContext runs the RestoreTransaction operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) RestoreTransactionContext(ctx context.Context, id string) (*Transaction, error) {
+	var response RestoreTransactionResponse
+	err := r.do(ctx, "RestoreTransaction", restoreTransactionDocument, RestoreTransactionVariables{ID: id}, "updateTransaction", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateTransaction, nil
+}
+
+const getUserDocument = `query GetUser($id: ID!) {
+  getUser(id: $id) {
+    ...UserFields
+  }
+}
+
+fragment UserFields on User {
+  id
+  names
+  emails
+  canonicalEmails
+  phoneNumbers
+  pictures
+  identity
+  token
+  etag
+  version
+  mergedInto
+  mergeProposals
+  unsubscribedAt
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// GetUserVariables are the variables of the GetUser operation.
+type GetUserVariables struct {
+	ID string `json:"id"`
+}
+
+// GetUserResponse is the data returned by the GetUser operation.
+type GetUserResponse struct {
+	GetUser *User `json:"getUser"`
+}
+
+// GetUser runs the GetUser operation without a deadline.
+func (r AppSyncResolver) GetUser(id string) (*User, error) {
+	return r.GetUserContext(context.Background(), id)
+}
+
+// GetUserContext runs the GetUser operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) GetUserContext(ctx context.Context, id string) (*User, error) {
+	var response GetUserResponse
+	err := r.do(ctx, "GetUser", getUserDocument, GetUserVariables{ID: id}, "getUser", &response)
+	if err != nil {
+		return nil, err
+	}
+	if !r.includeDeleted && response.GetUser.DeletedAt != nil {
+		return nil, newError(ErrNotFound, "GetUser", "getUser returned a deleted entity")
+	}
+	return response.GetUser, nil
+}
+
+const listUsersDocument = `query ListUsers($filter: ModelUserFilterInput, $limit: Int, $nextToken: String) {
+  listUsers(filter: $filter, limit: $limit, nextToken: $nextToken) {
+    items {
+      ...UserFields
+    }
+    nextToken
+  }
+}
+
+fragment UserFields on User {
+  id
+  names
+  emails
+  canonicalEmails
+  phoneNumbers
+  pictures
+  identity
+  token
+  etag
+  version
+  mergedInto
+  mergeProposals
+  unsubscribedAt
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// ListUsersVariables are the variables of the ListUsers operation.
+type ListUsersVariables struct {
+	Filter    *filter.Filter `json:"filter,omitempty"`
+	Limit     *int           `json:"limit,omitempty"`
+	NextToken *string        `json:"nextToken,omitempty"`
+}
+
+// ListUsersResponse is the data returned by the ListUsers operation.
+type ListUsersResponse struct {
+	ListUsers *ModelUserConnection `json:"listUsers"`
+}
+
+// ListUsers runs the ListUsers operation without a deadline.
+func (r AppSyncResolver) ListUsers(modelFilter *filter.Filter) ([]*User, error) {
+	return r.ListUsersContext(context.Background(), modelFilter)
+}
+
+// ListUsersContext runs the ListUsers operation, following nextToken across every page.
+func (r AppSyncResolver) ListUsersContext(ctx context.Context, modelFilter *filter.Filter) ([]*User, error) {
+	modelFilter = r.excludeDeleted(modelFilter)
+	var items []*User
+	err := r.pagination.Each(func(limit int, nextToken *string) (*string, int, error) {
+		var response ListUsersResponse
+		err := r.do(ctx, "ListUsers", listUsersDocument, ListUsersVariables{Filter: modelFilter, Limit: &limit, NextToken: nextToken}, "listUsers", &response)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, response.ListUsers.Items...)
+		return response.ListUsers.NextToken, len(response.ListUsers.Items), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createUserDocument = `mutation CreateUser($input: CreateUserInput!) {
+  createUser(input: $input) {
+    ...UserFields
+  }
+}
+
+fragment UserFields on User {
+  id
+  names
+  emails
+  canonicalEmails
+  phoneNumbers
+  pictures
+  identity
+  token
+  etag
+  version
+  mergedInto
+  mergeProposals
+  unsubscribedAt
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// CreateUserVariables are the variables of the CreateUser operation.
+type CreateUserVariables struct {
+	Input CreateUserInput `json:"input"`
+}
+
+// CreateUserResponse is the data returned by the CreateUser operation.
+type CreateUserResponse struct {
+	CreateUser *User `json:"createUser"`
+}
+
+// CreateUser runs the CreateUser operation without a deadline.
+func (r AppSyncResolver) CreateUser(input CreateUserInput) (*User, error) {
+	return r.CreateUserContext(context.Background(), input)
+}
+
+// CreateUserContext runs the CreateUser operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) CreateUserContext(ctx context.Context, input CreateUserInput) (*User, error) {
+	var response CreateUserResponse
+	err := r.do(ctx, "CreateUser", createUserDocument, CreateUserVariables{Input: input}, "createUser", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.CreateUser, nil
+}
+
+const updateUserDocument = `mutation UpdateUser($input: UpdateUserInput!) {
+  updateUser(input: $input) {
+    ...UserFields
+  }
+}
+
+fragment UserFields on User {
+  id
+  names
+  emails
+  canonicalEmails
+  phoneNumbers
+  pictures
+  identity
+  token
+  etag
+  version
+  mergedInto
+  mergeProposals
+  unsubscribedAt
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// UpdateUserVariables are the variables of the UpdateUser operation.
+type UpdateUserVariables struct {
+	Input UpdateUserInput `json:"input"`
+}
+
+// UpdateUserResponse is the data returned by the UpdateUser operation.
+type UpdateUserResponse struct {
+	UpdateUser *User `json:"updateUser"`
+}
+
+// UpdateUser runs the UpdateUser operation without a deadline.
+func (r AppSyncResolver) UpdateUser(input UpdateUserInput) (*User, error) {
+	return r.UpdateUserContext(context.Background(), input)
+}
+
+// UpdateUserContext runs the UpdateUser operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) UpdateUserContext(ctx context.Context, input UpdateUserInput) (*User, error) {
+	var response UpdateUserResponse
+	err := r.do(ctx, "UpdateUser", updateUserDocument, UpdateUserVariables{Input: input}, "updateUser", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateUser, nil
+}
+
+const createUserContactDocument = `mutation CreateUserContact($input: CreateUserContactInput!) {
+  createUserContact(input: $input) {
+    id
+    user {
+      ...UserFields
+    }
+    contact {
+      ...UserFields
+    }
+  }
+}
+
+fragment UserFields on User {
+  id
+  names
+  emails
+  canonicalEmails
+  phoneNumbers
+  pictures
+  identity
+  token
+  etag
+  version
+  mergedInto
+  mergeProposals
+  unsubscribedAt
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// CreateUserContactVariables are the variables of the CreateUserContact operation.
+type CreateUserContactVariables struct {
+	Input CreateUserContactInput `json:"input"`
+}
+
+// CreateUserContactResponse is the data returned by the CreateUserContact operation.
+type CreateUserContactResponse struct {
+	CreateUserContact *UserContact `json:"createUserContact"`
+}
+
+// CreateUserContact runs the CreateUserContact operation without a deadline.
+func (r AppSyncResolver) CreateUserContact(input CreateUserContactInput) (*UserContact, error) {
+	return r.CreateUserContactContext(context.Background(), input)
+}
+
+// CreateUserContactContext runs the CreateUserContact operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) CreateUserContactContext(ctx context.Context, input CreateUserContactInput) (*UserContact, error) {
+	var response CreateUserContactResponse
+	err := r.do(ctx, "CreateUserContact", createUserContactDocument, CreateUserContactVariables{Input: input}, "createUserContact", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.CreateUserContact, nil
+}
+
+const listUserContactsDocument = `query ListUserContacts($filter: ModelUserContactFilterInput, $limit: Int, $nextToken: String) {
+  listUserContacts(filter: $filter, limit: $limit, nextToken: $nextToken) {
+    items {
+      id
+      userContactUserId
+      userContactContactId
+      createdAt
+      updatedAt
+    }
+    nextToken
+  }
+}`
+
+// ListUserContactsVariables are the variables of the ListUserContacts operation.
+type ListUserContactsVariables struct {
+	Filter    *filter.Filter `json:"filter,omitempty"`
+	Limit     *int           `json:"limit,omitempty"`
+	NextToken *string        `json:"nextToken,omitempty"`
+}
+
+// ListUserContactsResponse is the data returned by the ListUserContacts operation.
+type ListUserContactsResponse struct {
+	ListUserContacts *ModelUserContactConnection `json:"listUserContacts"`
+}
+
+// ListUserContacts runs the ListUserContacts operation without a deadline.
+func (r AppSyncResolver) ListUserContacts(modelFilter *filter.Filter) ([]*UserContact, error) {
+	return r.ListUserContactsContext(context.Background(), modelFilter)
+}
+
+// ListUserContactsContext runs the ListUserContacts operation, following nextToken across every page.
+func (r AppSyncResolver) ListUserContactsContext(ctx context.Context, modelFilter *filter.Filter) ([]*UserContact, error) {
+	var items []*UserContact
+	err := r.pagination.Each(func(limit int, nextToken *string) (*string, int, error) {
+		var response ListUserContactsResponse
+		err := r.do(ctx, "ListUserContacts", listUserContactsDocument, ListUserContactsVariables{Filter: modelFilter, Limit: &limit, NextToken: nextToken}, "listUserContacts", &response)
+		if err != nil {
+			return nil, 0, err
+		}
+		items = append(items, response.ListUserContacts.Items...)
+		return response.ListUserContacts.NextToken, len(response.ListUserContacts.Items), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateUserContactDocument = `mutation UpdateUserContact($input: UpdateUserContactInput!) {
+  updateUserContact(input: $input) {
+    id
+    userContactUserId
+    userContactContactId
+    createdAt
+    updatedAt
+  }
+}`
+
+// UpdateUserContactVariables are the variables of the UpdateUserContact operation.
+type UpdateUserContactVariables struct {
+	Input UpdateUserContactInput `json:"input"`
+}
+
+// UpdateUserContactResponse is the data returned by the UpdateUserContact operation.
+type UpdateUserContactResponse struct {
+	UpdateUserContact *UserContact `json:"updateUserContact"`
+}
+
+// UpdateUserContact runs the UpdateUserContact operation without a deadline.
+func (r AppSyncResolver) UpdateUserContact(input UpdateUserContactInput) (*UserContact, error) {
+	return r.UpdateUserContactContext(context.Background(), input)
+}
+
+// UpdateUserContactContext runs the UpdateUserContact operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) UpdateUserContactContext(ctx context.Context, input UpdateUserContactInput) (*UserContact, error) {
+	var response UpdateUserContactResponse
+	err := r.do(ctx, "UpdateUserContact", updateUserContactDocument, UpdateUserContactVariables{Input: input}, "updateUserContact", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateUserContact, nil
+}
+
+const deleteUserContactDocument = `mutation DeleteUserContact($input: DeleteUserContactInput!) {
+  deleteUserContact(input: $input) {
+    id
+    userContactUserId
+    userContactContactId
+  }
+}`
+
+// DeleteUserContactVariables are the variables of the DeleteUserContact operation.
+type DeleteUserContactVariables struct {
+	Input DeleteUserContactInput `json:"input"`
+}
+
+// DeleteUserContactResponse is the data returned by the DeleteUserContact operation.
+type DeleteUserContactResponse struct {
+	DeleteUserContact *UserContact `json:"deleteUserContact"`
+}
+
+// DeleteUserContact runs the DeleteUserContact operation without a deadline.
+func (r AppSyncResolver) DeleteUserContact(input DeleteUserContactInput) (*UserContact, error) {
+	return r.DeleteUserContactContext(context.Background(), input)
+}
+
+// DeleteUserContactContext runs the DeleteUserContact operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) DeleteUserContactContext(ctx context.Context, input DeleteUserContactInput) (*UserContact, error) {
+	var response DeleteUserContactResponse
+	err := r.do(ctx, "DeleteUserContact", deleteUserContactDocument, DeleteUserContactVariables{Input: input}, "deleteUserContact", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.DeleteUserContact, nil
+}
+
+const softDeleteUserDocument = `mutation SoftDeleteUser($id: ID!, $deletedAt: AWSDateTime!) {
+  updateUser(input: {id:$id,deletedAt:$deletedAt}) {
+    ...UserFields
+  }
+}
+
+fragment UserFields on User {
+  id
+  names
+  emails
+  canonicalEmails
+  phoneNumbers
+  pictures
+  identity
+  token
+  etag
+  version
+  mergedInto
+  mergeProposals
+  unsubscribedAt
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// SoftDeleteUserVariables are the variables of the SoftDeleteUser operation.
+type SoftDeleteUserVariables struct {
+	ID        string `json:"id"`
+	DeletedAt string `json:"deletedAt"`
+}
+
+// SoftDeleteUserResponse is the data returned by the SoftDeleteUser operation.
+type SoftDeleteUserResponse struct {
+	UpdateUser *User `json:"updateUser"`
+}
+
+// SoftDeleteUser runs the SoftDeleteUser operation without a deadline.
+func (r AppSyncResolver) SoftDeleteUser(id string, deletedAt string) (*User, error) {
+	return r.SoftDeleteUserContext(context.Background(), id, deletedAt)
+}
+
+// SoftDeleteUserContext runs the SoftDeleteUser operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) SoftDeleteUserContext(ctx context.Context, id string, deletedAt string) (*User, error) {
+	var response SoftDeleteUserResponse
+	err := r.do(ctx, "SoftDeleteUser", softDeleteUserDocument, SoftDeleteUserVariables{ID: id, DeletedAt: deletedAt}, "updateUser", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateUser, nil
+}
+
+const restoreUserDocument = `mutation RestoreUser($id: ID!) {
+  updateUser(input: {id:$id,deletedAt:null}) {
+    ...UserFields
+  }
+}
+
+fragment UserFields on User {
+  id
+  names
+  emails
+  canonicalEmails
+  phoneNumbers
+  pictures
+  identity
+  token
+  etag
+  version
+  mergedInto
+  mergeProposals
+  unsubscribedAt
+  createdAt
+  updatedAt
+  deletedAt
+}`
+
+// RestoreUserVariables are the variables of the RestoreUser operation.
+type RestoreUserVariables struct {
+	ID string `json:"id"`
+}
+
+// RestoreUserResponse is the data returned by the RestoreUser operation.
+type RestoreUserResponse struct {
+	UpdateUser *User `json:"updateUser"`
+}
+
+// RestoreUser runs the RestoreUser operation without a deadline.
+func (r AppSyncResolver) RestoreUser(id string) (*User, error) {
+	return r.RestoreUserContext(context.Background(), id)
+}
+
+// RestoreUserContext runs the RestoreUser operation, retrying transient failures until ctx is done.
+func (r AppSyncResolver) RestoreUserContext(ctx context.Context, id string) (*User, error) {
+	var response RestoreUserResponse
+	err := r.do(ctx, "RestoreUser", restoreUserDocument, RestoreUserVariables{ID: id}, "updateUser", &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.UpdateUser, nil
+}
@@ -0,0 +1,85 @@
+package appsync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const (
+	// DefaultPageSize is the limit sent with every list query page.
+	DefaultPageSize = 100
+	// DefaultMaxItems is the hard cap on items gathered across all pages.
+	DefaultMaxItems = 10000
+)
+
+var ErrMaxItemsExceeded = errors.New("appsync list exceeded the maximum number of items")
+
+// Pagination controls how list queries follow nextToken. PageSize is sent as
+// the query limit and MaxItems stops runaway scans.
+type Pagination struct {
+	PageSize int
+	MaxItems int
+}
+
+// DefaultPagination reads AWS_APP_SYNC_PAGE_SIZE and AWS_APP_SYNC_MAX_ITEMS,
+// falling back to DefaultPageSize and DefaultMaxItems.
+func DefaultPagination() Pagination {
+	return Pagination{
+		PageSize: lookupIntEnv("AWS_APP_SYNC_PAGE_SIZE", DefaultPageSize),
+		MaxItems: lookupIntEnv("AWS_APP_SYNC_MAX_ITEMS", DefaultMaxItems),
+	}
+}
+
+func lookupIntEnv(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		return fallback
+	}
+	return parsed
+}
+
+// pageFetcher runs one list query page with the given limit and token and
+// reports the next token and how many items the page held.
+type pageFetcher func(limit int, nextToken *string) (next *string, count int, err error)
+
+// Each calls fetch once per page, following nextToken until AppSync stops
+// returning one. DynamoDB applies filters after the limit, so empty pages with
+// a token are expected and followed.
+func (p Pagination) Each(fetch pageFetcher) error {
+	pageSize := p.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	maxItems := p.MaxItems
+	if maxItems <= 0 {
+		maxItems = DefaultMaxItems
+	}
+
+	var nextToken *string
+	total := 0
+	for {
+		token, count, err := fetch(pageSize, nextToken)
+		if err != nil {
+			return err
+		}
+
+		total += count
+		if total > maxItems {
+			return fmt.Errorf("%w: got more than %d", ErrMaxItemsExceeded, maxItems)
+		}
+
+		if token == nil || *token == "" {
+			return nil
+		}
+		if nextToken != nil && *token == *nextToken {
+			return fmt.Errorf("appsync list returned the same nextToken twice: %v", *token)
+		}
+		nextToken = token
+	}
+}
@@ -0,0 +1,84 @@
+package appsync
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+type page struct {
+	limit     int
+	nextToken *string
+}
+
+// pages returns a fetcher that serves count items split into pages of the
+// requested limit, recording the arguments of every call.
+func pages(count int, calls *[]page) pageFetcher {
+	return func(limit int, nextToken *string) (*string, int, error) {
+		*calls = append(*calls, page{limit, nextToken})
+		offset := 0
+		if nextToken != nil {
+			fmt.Sscanf(*nextToken, "offset-%d", &offset)
+		}
+		size := count - offset
+		if size > limit {
+			size = limit
+		}
+		if offset+size >= count {
+			return nil, size, nil
+		}
+		next := fmt.Sprintf("offset-%d", offset+size)
+		return &next, size, nil
+	}
+}
+
+func TestPagination(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Pagination", func() {
+		g.It("Should follow nextToken until exhausted", func() {
+			var calls []page
+			err := Pagination{PageSize: 10, MaxItems: 100}.Each(pages(25, &calls))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(calls).Should(HaveLen(3))
+			Expect(calls[0].nextToken).Should(BeNil())
+			Expect(*calls[2].nextToken).Should(Equal("offset-20"))
+			Expect(calls[2].limit).Should(Equal(10))
+		})
+
+		g.It("Should fail once the hard cap is passed", func() {
+			var calls []page
+			err := Pagination{PageSize: 10, MaxItems: 15}.Each(pages(25, &calls))
+			Expect(errors.Is(err, ErrMaxItemsExceeded)).Should(BeTrue())
+			Expect(calls).Should(HaveLen(2))
+		})
+
+		g.It("Should keep going past empty filtered pages", func() {
+			calls := 0
+			err := Pagination{PageSize: 10, MaxItems: 15}.Each(func(limit int, nextToken *string) (*string, int, error) {
+				calls++
+				if calls < 3 {
+					next := fmt.Sprintf("page-%d", calls)
+					return &next, 0, nil
+				}
+				return nil, 1, nil
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(calls).Should(Equal(3))
+		})
+
+		g.It("Should stop on a repeated nextToken", func() {
+			err := Pagination{}.Each(func(limit int, nextToken *string) (*string, int, error) {
+				next := "same"
+				return &next, 0, nil
+			})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+}
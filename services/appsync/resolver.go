@@ -1,694 +1,657 @@
 package appsync
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/mitchellh/mapstructure"
 	appsync "github.com/rodrigopavezi/appsync-client-go"
 	"github.com/rodrigopavezi/appsync-client-go/graphql"
+	"gitlab.com/ncent/arber/api/services/appsync/filter"
 )
 
 var serverURL, _ = os.LookupEnv("AWS_APP_SYNC_URL")
 
-type Resolver struct {
-	awsConfig *aws.Config
+// Resolver is the set of AppSync operations used by the arber services.
+// AppSyncResolver talks to the live GraphQL API, MemoryResolver keeps
+// everything in process for tests.
+//
+// The single-operation methods of AppSyncResolver are generated from
+// schema.graphql and operations/*.graphql; see generate.go.
+type Resolver interface {
+	CreateUser(input CreateUserInput) (*User, error)
+	UpdateUser(input UpdateUserInput) (*User, error)
+	GetUser(id string) (*User, error)
+	MapUsersByEmails(emails []*string) (map[string]User, error)
+	ListUsersByEmails(emails []*string) ([]User, error)
+	ListUsersByCanonicalEmails(emails []*string) ([]User, error)
+	ListUsersByPhoneNumbers(phoneNumbers []*string) ([]User, error)
+	CreateUserContact(input CreateUserContactInput) (*UserContact, error)
+	UpdateUserContact(input UpdateUserContactInput) (*UserContact, error)
+	DeleteUserContact(input DeleteUserContactInput) (*UserContact, error)
+	GetUserContactsByUser(userID string) ([]*UserContact, error)
+	GetUserContactsByContact(contactID string) ([]*UserContact, error)
+	CreateChallenge(input CreateChallengeInput) (*Challenge, error)
+	GetChallenge(id string) (*Challenge, error)
+	GetChallengeSponsorToken(id string) (*Challenge, error)
+	UpdateChallenge(input UpdateChallengeInput) (*Challenge, error)
+	GetChallengesExpiringBefore(at string) ([]*Challenge, error)
+	CreateShareAction(input CreateShareActionInput) (*ShareAction, error)
+	UpdateShareAction(input UpdateShareActionInput) (*ShareAction, error)
+	CreateShareActionContact(input CreateShareActionContactInput) (*ShareActionContact, error)
+	UpdateShareActionContact(input UpdateShareActionContactInput) (*ShareActionContact, error)
+	CreateTransaction(input CreateTransactionInput) (*Transaction, error)
+	UpdateTransaction(input UpdateTransactionInput) (*Transaction, error)
+	GetTransaction(id string) (*Transaction, error)
+	GetShareActionsByChallengeAndUser(challengeID string, userID string) ([]*ShareAction, error)
+	GetShareActionsByChallenge(challengeID string) ([]*ShareAction, error)
+	GetShareActionsByUser(userID string) ([]*ShareAction, error)
+	GetTransactionsByShareAction(actionID string) ([]*Transaction, error)
+	GetTransactionsByParentTransaction(parentTransactionID string) ([]*Transaction, error)
+	GetShareActionContactsByShareAction(shareActionID string) ([]*ShareActionContact, error)
+	GetShareActionContactsByContact(contactID string) ([]*ShareActionContact, error)
+	CreateApplication(input CreateApplicationInput) (*Application, error)
+	UpdateApplication(input UpdateApplicationInput) (*Application, error)
+	GetApplication(id string) (*Application, error)
+	GetApplicationsByChallenge(challengeID string) ([]*Application, error)
+	GetApplicationsByChallengeAndCandidate(challengeID string, candidateID string) ([]*Application, error)
+	GetApplicationsByCandidate(candidateID string) ([]*Application, error)
+
+	// Deletes are soft: they stamp deletedAt, and Restore clears it again.
+	DeleteUser(id string) (*User, error)
+	RestoreUser(id string) (*User, error)
+	DeleteChallenge(id string) (*Challenge, error)
+	RestoreChallenge(id string) (*Challenge, error)
+	DeleteShareAction(id string) (*ShareAction, error)
+	RestoreShareAction(id string) (*ShareAction, error)
+	DeleteTransaction(id string) (*Transaction, error)
+	RestoreTransaction(id string) (*Transaction, error)
+
+	// BatchUpsertUsers writes many users and contact edges in chunked
+	// requests and reports the outcome of every item.
+	BatchUpsertUsers(batch UserBatch) (*UserBatchResult, error)
+
+	// IncludeDeleted returns a view of the same data whose gets and lists
+	// also return soft-deleted rows, for admin tooling.
+	IncludeDeleted() Resolver
+
+	// The Context variants stop retrying and abandon in-flight calls once
+	// ctx is done. The methods above run them with context.Background().
+	CreateUserContext(ctx context.Context, input CreateUserInput) (*User, error)
+	UpdateUserContext(ctx context.Context, input UpdateUserInput) (*User, error)
+	GetUserContext(ctx context.Context, id string) (*User, error)
+	MapUsersByEmailsContext(ctx context.Context, emails []*string) (map[string]User, error)
+	ListUsersByEmailsContext(ctx context.Context, emails []*string) ([]User, error)
+	ListUsersByCanonicalEmailsContext(ctx context.Context, emails []*string) ([]User, error)
+	ListUsersByPhoneNumbersContext(ctx context.Context, phoneNumbers []*string) ([]User, error)
+	CreateUserContactContext(ctx context.Context, input CreateUserContactInput) (*UserContact, error)
+	UpdateUserContactContext(ctx context.Context, input UpdateUserContactInput) (*UserContact, error)
+	DeleteUserContactContext(ctx context.Context, input DeleteUserContactInput) (*UserContact, error)
+	GetUserContactsByUserContext(ctx context.Context, userID string) ([]*UserContact, error)
+	GetUserContactsByContactContext(ctx context.Context, contactID string) ([]*UserContact, error)
+	CreateChallengeContext(ctx context.Context, input CreateChallengeInput) (*Challenge, error)
+	GetChallengeContext(ctx context.Context, id string) (*Challenge, error)
+	GetChallengeSponsorTokenContext(ctx context.Context, id string) (*Challenge, error)
+	UpdateChallengeContext(ctx context.Context, input UpdateChallengeInput) (*Challenge, error)
+	GetChallengesExpiringBeforeContext(ctx context.Context, at string) ([]*Challenge, error)
+	CreateShareActionContext(ctx context.Context, input CreateShareActionInput) (*ShareAction, error)
+	UpdateShareActionContext(ctx context.Context, input UpdateShareActionInput) (*ShareAction, error)
+	CreateShareActionContactContext(ctx context.Context, input CreateShareActionContactInput) (*ShareActionContact, error)
+	UpdateShareActionContactContext(ctx context.Context, input UpdateShareActionContactInput) (*ShareActionContact, error)
+	CreateTransactionContext(ctx context.Context, input CreateTransactionInput) (*Transaction, error)
+	UpdateTransactionContext(ctx context.Context, input UpdateTransactionInput) (*Transaction, error)
+	GetTransactionContext(ctx context.Context, id string) (*Transaction, error)
+	GetShareActionsByChallengeAndUserContext(ctx context.Context, challengeID string, userID string) ([]*ShareAction, error)
+	GetShareActionsByChallengeContext(ctx context.Context, challengeID string) ([]*ShareAction, error)
+	GetShareActionsByUserContext(ctx context.Context, userID string) ([]*ShareAction, error)
+	GetTransactionsByShareActionContext(ctx context.Context, actionID string) ([]*Transaction, error)
+	GetTransactionsByParentTransactionContext(ctx context.Context, parentTransactionID string) ([]*Transaction, error)
+	GetShareActionContactsByShareActionContext(ctx context.Context, shareActionID string) ([]*ShareActionContact, error)
+	GetShareActionContactsByContactContext(ctx context.Context, contactID string) ([]*ShareActionContact, error)
+	CreateApplicationContext(ctx context.Context, input CreateApplicationInput) (*Application, error)
+	UpdateApplicationContext(ctx context.Context, input UpdateApplicationInput) (*Application, error)
+	GetApplicationContext(ctx context.Context, id string) (*Application, error)
+	GetApplicationsByChallengeContext(ctx context.Context, challengeID string) ([]*Application, error)
+	GetApplicationsByChallengeAndCandidateContext(ctx context.Context, challengeID string, candidateID string) ([]*Application, error)
+	GetApplicationsByCandidateContext(ctx context.Context, candidateID string) ([]*Application, error)
+	DeleteUserContext(ctx context.Context, id string) (*User, error)
+	RestoreUserContext(ctx context.Context, id string) (*User, error)
+	DeleteChallengeContext(ctx context.Context, id string) (*Challenge, error)
+	RestoreChallengeContext(ctx context.Context, id string) (*Challenge, error)
+	DeleteShareActionContext(ctx context.Context, id string) (*ShareAction, error)
+	RestoreShareActionContext(ctx context.Context, id string) (*ShareAction, error)
+	DeleteTransactionContext(ctx context.Context, id string) (*Transaction, error)
+	RestoreTransactionContext(ctx context.Context, id string) (*Transaction, error)
+	BatchUpsertUsersContext(ctx context.Context, batch UserBatch) (*UserBatchResult, error)
+}
+
+type AppSyncResolver struct {
+	awsConfig      *aws.Config
+	pagination     Pagination
+	retry          Retry
+	includeDeleted bool
 }
 
 func New() Resolver {
+	return NewWithPagination(DefaultPagination())
+}
+
+// NewWithPagination builds a resolver whose list queries page with the given
+// page size and stop at the given hard cap.
+func NewWithPagination(pagination Pagination) Resolver {
+	return NewWithConfig(pagination, DefaultRetry())
+}
+
+// NewWithConfig builds a resolver with explicit pagination and retry
+// policies.
+func NewWithConfig(pagination Pagination, retry Retry) Resolver {
 	// get aws credential
 	config := aws.Config{
 		Region: aws.String(os.Getenv("AWS_REGION")),
 	}
 	sess := session.Must(session.NewSession(&config))
 
-	r := Resolver{sess.Config}
+	r := AppSyncResolver{awsConfig: sess.Config, pagination: pagination, retry: retry}
 	return r
 }
 
-func (r Resolver) CreateUser(input CreateUserInput) (*User, error) {
-	mutation := `mutation CreateUser($input: CreateUserInput!) {
-		createUser(input: $input) {
-			id
-			names
-			emails
-			phoneNumbers
-			pictures
-			identity
-			token
-			etag
-			sharedActions {
-				items {
-					id
-				}
-				nextToken
-			}
-			contacts {
-				items {
-					id
-				}
-				nextToken
-			}
-			usersImContactOf {
-				items {
-					id
-				}
-				nextToken
-			}
-			createdAt
-			updatedAt
-			deletedAt
-		}
-	}
-	`
-	inputNewUser := &CreateInput{
-		Input: input,
-	}
-	jsonInputNewUser, err := json.Marshal(inputNewUser)
-	variables := json.RawMessage(jsonInputNewUser)
-	log.Printf("jsonInputNewUser: %+v", string(jsonInputNewUser))
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	appsyncResponse, err := client.Post(graphql.PostRequest{
-		Query:     mutation,
-		Variables: &variables,
-	})
-	log.Printf("CreateUser Appsync response status code: %+v", appsyncResponse.StatusCode)
-	log.Printf("CreateUser Appsync response Errors: %+v", appsyncResponse.Errors)
+func (r AppSyncResolver) MapUsersByEmails(emails []*string) (map[string]User, error) {
+	return r.MapUsersByEmailsContext(context.Background(), emails)
+}
 
+func (r AppSyncResolver) MapUsersByEmailsContext(ctx context.Context, emails []*string) (map[string]User, error) {
+	users, err := r.ListUsersByEmailsContext(ctx, emails)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %v", err)
 		return nil, err
 	}
 
-	var result CreateUserResponse
-	err = mapstructure.Decode(appsyncResponse.Data, &result)
-
-	log.Println("CreateUser data: %+v", result.CreateUser)
-	return &result.CreateUser, nil
-}
-
-func (r Resolver) UpdateUser(input UpdateUserInput) (*User, error) {
-	mutation := `mutation UpdateUser($input: UpdateUserInput!) {
-		updateUser(input: $input) {
-			id
-			names
-			emails
-			phoneNumbers
-			pictures
-			identity
-			token
-			etag
-			sharedActions {
-				items {
-					id
-				}
-				nextToken
-			}
-			contacts {
-				items {
-					id
-				}
-				nextToken
-			}
-			usersImContactOf {
-				items {
-					id
-				}
-				nextToken
-			}
-			createdAt
-			updatedAt
-			deletedAt
+	return mapUsersByEmails(users), nil
+}
+
+func mapUsersByEmails(users []User) map[string]User {
+	emailsToUserMap := make(map[string]User)
+	for _, user := range users {
+		for _, email := range user.Emails {
+			emailsToUserMap[*email] = user
 		}
 	}
-	`
-	inputNewUser := &UpdateInput{
-		Input: input,
-	}
-	jsonInputNewUser, err := json.Marshal(inputNewUser)
-	variables := json.RawMessage(jsonInputNewUser)
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	appsyncResponse, err := client.Post(graphql.PostRequest{
-		Query:     mutation,
-		Variables: &variables,
-	})
+	return emailsToUserMap
+}
 
-	log.Printf("UpdateUser Appsync response: %v", appsyncResponse)
+func (r AppSyncResolver) ListUsersByEmails(emails []*string) ([]User, error) {
+	return r.ListUsersByEmailsContext(context.Background(), emails)
+}
 
-	if err != nil {
-		log.Printf("Failed to post to appsync: %v", err)
-		return nil, err
+func (r AppSyncResolver) ListUsersByEmailsContext(ctx context.Context, emails []*string) ([]User, error) {
+	var filters []filter.Filter
+	for _, email := range emails {
+		filters = append(filters, filter.Contains("emails", *email))
 	}
-
-	var result UpdateUserResponse
-	err = mapstructure.Decode(appsyncResponse.Data, &result)
-
-	log.Println("UpdateUser data: %+v", result.UpdateUser)
-	return &result.UpdateUser, nil
-}
-
-func (r Resolver) GetUser(id string) (*User, error) {
-	query := `query GetUser($id: ID!) {
-		getUser(id: $id) {
-			id
-			names
-			emails
-			phoneNumbers
-			pictures
-			identity
-			token
-			etag
-			sharedActions {
-				items {
-					id
-				}
-				nextToken
-			}
-			contacts {
-				items {
-					id
-				}
-				nextToken
-			}
-			usersImContactOf {
-				items {
-					id
-				}
-				nextToken
-			}
-			createdAt
-			updatedAt
-			deletedAt
-		}
+	if len(filters) == 0 {
+		return nil, nil
 	}
-	`
-	log.Printf("ID %v", id)
-	log.Printf("serverURL %v", serverURL)
-	variables := json.RawMessage(fmt.Sprintf(`{ "id": "%s"	}`, id))
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	response, err := client.Post(graphql.PostRequest{
-		Query:     query,
-		Variables: &variables,
-	})
+
+	modelFilter := filter.Or(filters...)
+	items, err := r.ListUsersContext(ctx, &modelFilter)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %+v", err)
+		log.Printf("Failed to list users by emails: %+v", err)
 		return nil, err
 	}
 
-	log.Printf("Graph QL Response: %v", response)
-	log.Printf("Graph QL Response status code: %v", response.StatusCode)
-	log.Printf("Graph QL Response data: %v", response.Data)
-	log.Printf("Graph QL Response errors: %v", response.Errors)
-
-	var result GetUserResponse
-	err = mapstructure.Decode(response.Data, &result)
+	var users []User
+	for _, item := range items {
+		if item != nil {
+			users = append(users, *item)
+		}
+	}
+	log.Printf("ListUsersByEmails data: %+v", users)
+	return users, nil
+}
 
-	log.Println("GetUser data: %+v", result.GetUser)
-	return &result.GetUser, nil
+func (r AppSyncResolver) ListUsersByCanonicalEmails(emails []*string) ([]User, error) {
+	return r.ListUsersByCanonicalEmailsContext(context.Background(), emails)
 }
 
-func (r Resolver) MapUsersByEmails(emails []*string) (map[string]User, error) {
-	users, err := r.ListUsersByEmails(emails)
+// ListUsersByCanonicalEmailsContext looks users up by the canonical form of
+// their addresses, which callers canonicalize before asking. It scans the
+// table, so new users are read by the ID keyed on their address first.
+func (r AppSyncResolver) ListUsersByCanonicalEmailsContext(ctx context.Context, emails []*string) ([]User, error) {
+	var filters []filter.Filter
+	for _, email := range emails {
+		filters = append(filters, filter.Contains("canonicalEmails", *email))
+	}
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	modelFilter := filter.Or(filters...)
+	items, err := r.ListUsersContext(ctx, &modelFilter)
 	if err != nil {
+		log.Printf("Failed to list users by canonical emails: %+v", err)
 		return nil, err
 	}
 
-	emailsToUserMap := make(map[string]User)
-	for _, user := range users {
-		for _, email := range user.Emails {
-			emailsToUserMap[*email] = user
-		}
-	}
-	return emailsToUserMap, nil
-}
-
-func (r Resolver) ListUsersByEmails(emails []*string) ([]User, error) {
-	query := `query ListUsers(
-		$filter: ModelUserFilterInput
-		$limit: Int
-		$nextToken: String
-	) {
-		listUsers(filter: $filter, limit: $limit, nextToken: $nextToken) {
-			items {
-				id
-				names
-				emails
-				phoneNumbers
-				pictures
-				identity
-				token
-				etag
-				sharedActions {
-					nextToken
-				}
-				contacts {
-					nextToken
-				}
-				usersImContactOf {
-					nextToken
-				}
-				createdAt
-				updatedAt
-				deletedAt
-			}
-			nextToken
+	var users []User
+	for _, item := range items {
+		if item != nil {
+			users = append(users, *item)
 		}
 	}
-	`
-	var strs []string
+	log.Printf("ListUsersByCanonicalEmails data: %+v", users)
+	return users, nil
+}
 
-	for _, email := range emails {
-		strs = append(strs, fmt.Sprintf(`{"emails": { "contains": "%s" } }`, *email))
+func (r AppSyncResolver) ListUsersByPhoneNumbers(phoneNumbers []*string) ([]User, error) {
+	return r.ListUsersByPhoneNumbersContext(context.Background(), phoneNumbers)
+}
+
+// ListUsersByPhoneNumbersContext matches the numbers as given; numbers are
+// stored the way the address book had them.
+func (r AppSyncResolver) ListUsersByPhoneNumbersContext(ctx context.Context, phoneNumbers []*string) ([]User, error) {
+	var filters []filter.Filter
+	for _, phoneNumber := range phoneNumbers {
+		filters = append(filters, filter.Contains("phoneNumbers", *phoneNumber))
+	}
+	if len(filters) == 0 {
+		return nil, nil
 	}
-	filterJson := fmt.Sprintf(`{"filter": %s, "limit": 1000 }`, strings.Join(strs, `, "or": `))
-	log.Printf("filterJson: %v", filterJson)
 
-	variables := json.RawMessage(filterJson)
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	response, err := client.Post(graphql.PostRequest{
-		Query:     query,
-		Variables: &variables,
-	})
+	modelFilter := filter.Or(filters...)
+	items, err := r.ListUsersContext(ctx, &modelFilter)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %+v", err)
+		log.Printf("Failed to list users by phone numbers: %+v", err)
 		return nil, err
 	}
 
-	log.Printf("Graph QL Response: %v", response)
-	log.Printf("Graph QL Response status code: %v", response.StatusCode)
-	log.Printf("Graph QL Response data: %v", response.Data)
-	log.Printf("Graph QL Response errors: %v", response.Errors)
-
-	var result ListUsersByEmailsResponse
-	err = mapstructure.Decode(response.Data, &result)
-
-	log.Println("ListUsersByEmails data: %+v", result.ListUsers.Items)
-	return result.ListUsers.Items, nil
-}
-
-func (r Resolver) CreateUserContact(input CreateUserContactInput) (*UserContact, error) {
-	query := `mutation CreateUserContact($input: CreateUserContactInput!) {
-		createUserContact(input: $input) {
-			id
-			user {
-				id
-				names
-				emails
-				phoneNumbers
-				pictures
-				identity
-				token
-				etag
-				sharedActions {
-					nextToken
-				}
-				contacts {
-					nextToken
-				}
-				usersImContactOf {
-					nextToken
-				}
-				createdAt
-				updatedAt
-				deletedAt
-			}
-			contact {
-				id
-				names
-				emails
-				phoneNumbers
-				pictures
-				identity
-				token
-				etag
-				sharedActions {
-					nextToken
-				}
-				contacts {
-					nextToken
-				}
-				usersImContactOf {
-					nextToken
-				}
-				createdAt
-				updatedAt
-				deletedAt
-			}
+	var users []User
+	for _, item := range items {
+		if item != nil {
+			users = append(users, *item)
 		}
 	}
-	`
-	inputNewUserContact := &CreateUserContactInputWrapper{
-		Input: input,
-	}
-	jsonInputNewUserContact, err := json.Marshal(inputNewUserContact)
-	variables := json.RawMessage(jsonInputNewUserContact)
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	response, err := client.Post(graphql.PostRequest{
-		Query:     query,
-		Variables: &variables,
-	})
+	log.Printf("ListUsersByPhoneNumbers data: %+v", users)
+	return users, nil
+}
+
+func (r AppSyncResolver) GetUserContactsByUser(userID string) ([]*UserContact, error) {
+	return r.GetUserContactsByUserContext(context.Background(), userID)
+}
+
+func (r AppSyncResolver) GetUserContactsByUserContext(ctx context.Context, userID string) ([]*UserContact, error) {
+	modelFilter := filter.Eq("userContactUserId", userID)
+	contacts, err := r.ListUserContactsContext(ctx, &modelFilter)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %+v", err)
+		log.Printf("Failed to list user contacts: %+v", err)
 		return nil, err
 	}
 
-	log.Printf("Graph QL Response: %v", response)
-	log.Printf("Graph QL Response status code: %v", response.StatusCode)
-	log.Printf("Graph QL Response data: %v", response.Data)
-	log.Printf("Graph QL Response errors: %v", response.Errors)
-
-	var result CreateUserContactResponse
-	err = mapstructure.Decode(response.Data, &result)
-
-	log.Println("CreateUserContact data: %+v", result.CreateUserContact)
-	return &result.CreateUserContact, nil
+	log.Printf("GetUserContactsByUser data: %+v", contacts)
+	return contacts, nil
 }
 
-func (r Resolver) CreateChallenge(input CreateChallenge) (*Challenge, error) {
-	mutation := `mutation CreateChallenge($input: CreateChallengeInput!) {
-		createChallenge(input: $input) {
-			id
-			name
-			sponsorName
-		}
-	}
-	`
-	inputNewChallenge := &CreateChallengeInput{
-		Input: input,
-	}
-	jsonInputNewChallenge, err := json.Marshal(inputNewChallenge)
-	variables := json.RawMessage(jsonInputNewChallenge)
-	log.Printf("jsonInputNewChallenge: %+v", string(jsonInputNewChallenge))
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	appsyncResponse, err := client.Post(graphql.PostRequest{
-		Query:     mutation,
-		Variables: &variables,
-	})
-	log.Printf("CreateChallenge Appsync response status code: %+v", appsyncResponse.StatusCode)
-	log.Printf("CreateChallenge Appsync response Errors: %+v", appsyncResponse.Errors)
+func (r AppSyncResolver) GetUserContactsByContact(contactID string) ([]*UserContact, error) {
+	return r.GetUserContactsByContactContext(context.Background(), contactID)
+}
 
+func (r AppSyncResolver) GetUserContactsByContactContext(ctx context.Context, contactID string) ([]*UserContact, error) {
+	modelFilter := filter.Eq("userContactContactId", contactID)
+	contacts, err := r.ListUserContactsContext(ctx, &modelFilter)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %v", err)
+		log.Printf("Failed to list user contacts: %+v", err)
 		return nil, err
 	}
 
-	var result CreateChallengeResponse
-	err = mapstructure.Decode(appsyncResponse.Data, &result)
+	log.Printf("GetUserContactsByContact data: %+v", contacts)
+	return contacts, nil
+}
 
-	log.Println("CreateChallenge data: %+v", result.CreateChallenge)
-	return &result.CreateChallenge, nil
+func (r AppSyncResolver) GetShareActionsByChallengeAndUser(challengeID string, userID string) ([]*ShareAction, error) {
+	return r.GetShareActionsByChallengeAndUserContext(context.Background(), challengeID, userID)
 }
 
-func (r Resolver) GetChallenge(id string) (*Challenge, error) {
-	query := `query GetChallenge($id: ID!) {
-		getChallenge(id: $id) {
-			id
-			name
-			description
-			sponsorName
-		}
-	}
-	`
-	log.Printf("ID %v", id)
-	log.Printf("serverURL %v", serverURL)
-	variables := json.RawMessage(fmt.Sprintf(`{ "id": "%s"	}`, id))
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	response, err := client.Post(graphql.PostRequest{
-		Query:     query,
-		Variables: &variables,
-	})
+func (r AppSyncResolver) GetShareActionsByChallengeAndUserContext(ctx context.Context, challengeID string, userID string) ([]*ShareAction, error) {
+	modelFilter := filter.And(filter.Eq("challengeId", challengeID), filter.Eq("userId", userID))
+	shareActions, err := r.ListShareActionsContext(ctx, &modelFilter)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %+v", err)
+		log.Printf("Failed to list share actions: %+v", err)
 		return nil, err
 	}
 
-	log.Printf("Graph QL Response: %v", response)
-	log.Printf("Graph QL Response status code: %v", response.StatusCode)
-	log.Printf("Graph QL Response data: %v", response.Data)
-	log.Printf("Graph QL Response errors: %v", response.Errors)
-
-	var result GetChallengeResponse
-	err = mapstructure.Decode(response.Data, &result)
+	log.Printf("GetShareActionsByChallengeAndUser data: %+v", shareActions)
+	return shareActions, nil
+}
 
-	log.Println("GetChallenge data: %+v", result.GetChallenge)
-	return &result.GetChallenge, nil
+func (r AppSyncResolver) GetShareActionsByChallenge(challengeID string) ([]*ShareAction, error) {
+	return r.GetShareActionsByChallengeContext(context.Background(), challengeID)
 }
 
-func (r Resolver) CreateShareAction(input CreateShareAction) (*ShareAction, error) {
-	mutation := `mutation CreateShareAction($input: CreateShareActionInput!) {
-		createShareAction(input: $input) {
-			id
-		}
+func (r AppSyncResolver) GetShareActionsByChallengeContext(ctx context.Context, challengeID string) ([]*ShareAction, error) {
+	modelFilter := filter.Eq("challengeId", challengeID)
+	shareActions, err := r.ListShareActionsContext(ctx, &modelFilter)
+	if err != nil {
+		log.Printf("Failed to list share actions: %+v", err)
+		return nil, err
 	}
-	`
-	inputNewShareAction := &CreateShareActionInput{
-		Input: input,
-	}
-	jsonInputNewShareAction, err := json.Marshal(inputNewShareAction)
-	variables := json.RawMessage(jsonInputNewShareAction)
-	log.Printf("jsonInputNewShareAction: %+v", string(jsonInputNewShareAction))
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	appsyncResponse, err := client.Post(graphql.PostRequest{
-		Query:     mutation,
-		Variables: &variables,
-	})
-	log.Printf("CreateShareAction Appsync response status code: %+v", appsyncResponse.StatusCode)
-	log.Printf("CreateShareAction Appsync response Errors: %+v", appsyncResponse.Errors)
 
+	log.Printf("GetShareActionsByChallenge data: %+v", shareActions)
+	return shareActions, nil
+}
+
+func (r AppSyncResolver) GetShareActionsByUser(userID string) ([]*ShareAction, error) {
+	return r.GetShareActionsByUserContext(context.Background(), userID)
+}
+
+func (r AppSyncResolver) GetShareActionsByUserContext(ctx context.Context, userID string) ([]*ShareAction, error) {
+	modelFilter := filter.Eq("userId", userID)
+	shareActions, err := r.ListShareActionsContext(ctx, &modelFilter)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %v", err)
+		log.Printf("Failed to list share actions: %+v", err)
 		return nil, err
 	}
 
-	var result CreateShareActionResponse
-	err = mapstructure.Decode(appsyncResponse.Data, &result)
+	log.Printf("GetShareActionsByUser data: %+v", shareActions)
+	return shareActions, nil
+}
 
-	log.Println("CreateShareAction data: %+v", result.CreateShareAction)
-	return &result.CreateShareAction, nil
+// GetChallengesExpiringBefore lists the challenges whose expiration is
+// earlier than at, an RFC3339 UTC time. Expirations are compared as strings,
+// so they must be stored in the same format.
+func (r AppSyncResolver) GetChallengesExpiringBefore(at string) ([]*Challenge, error) {
+	return r.GetChallengesExpiringBeforeContext(context.Background(), at)
 }
 
-func (r Resolver) UpdateShareAction(input UpdateShareAction) (*ShareAction, error) {
-	mutation := `mutation UpdateShareAction($input: UpdateShareActionInput!) {
-		updateShareAction(input: $input) {
-			id
-		}
+func (r AppSyncResolver) GetChallengesExpiringBeforeContext(ctx context.Context, at string) ([]*Challenge, error) {
+	modelFilter := filter.Lt("expiration", at)
+	challenges, err := r.ListChallengesContext(ctx, &modelFilter)
+	if err != nil {
+		log.Printf("Failed to list expiring challenges: %+v", err)
+		return nil, err
 	}
-	`
-	inputNewShareAction := &UpdateShareActionInput{
-		Input: input,
-	}
-	jsonInputNewShareAction, err := json.Marshal(inputNewShareAction)
-	variables := json.RawMessage(jsonInputNewShareAction)
-	log.Printf("jsonInputNewShareAction: %+v", string(jsonInputNewShareAction))
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	appsyncResponse, err := client.Post(graphql.PostRequest{
-		Query:     mutation,
-		Variables: &variables,
-	})
-	log.Printf("UpdateShareAction Appsync response status code: %+v", appsyncResponse.StatusCode)
-	log.Printf("UpdateShareAction Appsync response Errors: %+v", appsyncResponse.Errors)
 
+	log.Printf("GetChallengesExpiringBefore data: %+v", challenges)
+	return challenges, nil
+}
+
+func (r AppSyncResolver) GetTransactionsByShareAction(actionID string) ([]*Transaction, error) {
+	return r.GetTransactionsByShareActionContext(context.Background(), actionID)
+}
+
+func (r AppSyncResolver) GetTransactionsByShareActionContext(ctx context.Context, actionID string) ([]*Transaction, error) {
+	modelFilter := filter.Eq("transactionActionId", actionID)
+	transactions, err := r.ListTransactionsContext(ctx, &modelFilter)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %v", err)
+		log.Printf("Failed to list transactions: %+v", err)
 		return nil, err
 	}
 
-	var result UpdateShareActionResponse
-	err = mapstructure.Decode(appsyncResponse.Data, &result)
+	log.Printf("GetTransactionsByShareAction data: %+v", transactions)
+	return transactions, nil
+}
 
-	log.Println("UpdateShareAction data: %+v", result.UpdateShareAction)
-	return &result.UpdateShareAction, nil
+func (r AppSyncResolver) GetTransactionsByParentTransaction(parentTransactionID string) ([]*Transaction, error) {
+	return r.GetTransactionsByParentTransactionContext(context.Background(), parentTransactionID)
 }
 
-func (r Resolver) CreateShareActionContact(input CreateShareActionContact) (*ShareActionContact, error) {
-	mutation := `mutation CreateShareActionContact($input: CreateShareActionContactInput!) {
-		createShareActionContact(input: $input) {
-			id
-		}
+func (r AppSyncResolver) GetTransactionsByParentTransactionContext(ctx context.Context, parentTransactionID string) ([]*Transaction, error) {
+	modelFilter := filter.Eq("parentTransactionId", parentTransactionID)
+	transactions, err := r.ListTransactionsContext(ctx, &modelFilter)
+	if err != nil {
+		log.Printf("Failed to list child transactions: %+v", err)
+		return nil, err
 	}
-	`
-	inputNewShareActionContact := &CreateShareActionContactInput{
-		Input: input,
-	}
-	jsonInputNewShareActionContact, err := json.Marshal(inputNewShareActionContact)
-	variables := json.RawMessage(jsonInputNewShareActionContact)
-	log.Printf("jsonInputNewShareActionContact: %+v", string(jsonInputNewShareActionContact))
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	appsyncResponse, err := client.Post(graphql.PostRequest{
-		Query:     mutation,
-		Variables: &variables,
-	})
-	log.Printf("CreateShareActionContact Appsync response status code: %+v", appsyncResponse.StatusCode)
-	log.Printf("CreateShareActionContact Appsync response Errors: %+v", appsyncResponse.Errors)
 
+	log.Printf("GetTransactionsByParentTransaction data: %+v", transactions)
+	return transactions, nil
+}
+
+func (r AppSyncResolver) GetShareActionContactsByShareAction(shareActionID string) ([]*ShareActionContact, error) {
+	return r.GetShareActionContactsByShareActionContext(context.Background(), shareActionID)
+}
+
+func (r AppSyncResolver) GetShareActionContactsByShareActionContext(ctx context.Context, shareActionID string) ([]*ShareActionContact, error) {
+	modelFilter := filter.Eq("shareActionContactShareActionId", shareActionID)
+	contacts, err := r.ListShareActionContactsContext(ctx, &modelFilter)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %v", err)
+		log.Printf("Failed to list share action contacts: %+v", err)
 		return nil, err
 	}
 
-	var result CreateShareActionContactResponse
-	err = mapstructure.Decode(appsyncResponse.Data, &result)
+	log.Printf("GetShareActionContactsByShareAction data: %+v", contacts)
+	return contacts, nil
+}
 
-	log.Println("CreateShareActionContact data: %+v", result.CreateShareActionContact)
-	return &result.CreateShareActionContact, nil
+func (r AppSyncResolver) GetShareActionContactsByContact(contactID string) ([]*ShareActionContact, error) {
+	return r.GetShareActionContactsByContactContext(context.Background(), contactID)
 }
 
-func (r Resolver) CreateTransaction(input CreateTransaction) (*Transaction, error) {
-	mutation := `mutation CreateTransaction($input: CreateTransactionInput!) {
-		createTransaction(input: $input) {
-			id
-		}
+func (r AppSyncResolver) GetShareActionContactsByContactContext(ctx context.Context, contactID string) ([]*ShareActionContact, error) {
+	modelFilter := filter.Eq("shareActionContactContactId", contactID)
+	contacts, err := r.ListShareActionContactsContext(ctx, &modelFilter)
+	if err != nil {
+		log.Printf("Failed to list share action contacts: %+v", err)
+		return nil, err
 	}
-	`
-	inputNewTransaction := &CreateTransactionInput{
-		Input: input,
-	}
-	jsonInputNewTransaction, err := json.Marshal(inputNewTransaction)
-	variables := json.RawMessage(jsonInputNewTransaction)
-	log.Printf("jsonInputNewTransaction: %+v", string(jsonInputNewTransaction))
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	appsyncResponse, err := client.Post(graphql.PostRequest{
-		Query:     mutation,
-		Variables: &variables,
-	})
-	log.Printf("CreateTransaction Appsync response status code: %v", *appsyncResponse.StatusCode)
-	log.Printf("CreateTransaction Appsync response Errors: %+v", appsyncResponse.Errors)
 
+	log.Printf("GetShareActionContactsByContact data: %+v", contacts)
+	return contacts, nil
+}
+
+func (r AppSyncResolver) GetApplicationsByChallenge(challengeID string) ([]*Application, error) {
+	return r.GetApplicationsByChallengeContext(context.Background(), challengeID)
+}
+
+func (r AppSyncResolver) GetApplicationsByChallengeContext(ctx context.Context, challengeID string) ([]*Application, error) {
+	modelFilter := filter.Eq("challengeId", challengeID)
+	applications, err := r.ListApplicationsContext(ctx, &modelFilter)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %v", err)
+		log.Printf("Failed to list applications: %+v", err)
 		return nil, err
 	}
 
-	var result CreateTransactionResponse
-	err = mapstructure.Decode(appsyncResponse.Data, &result)
+	log.Printf("GetApplicationsByChallenge data: %+v", applications)
+	return applications, nil
+}
 
-	log.Println("CreateTransaction data: %+v", result.CreateTransaction)
-	return &result.CreateTransaction, nil
+func (r AppSyncResolver) GetApplicationsByCandidate(candidateID string) ([]*Application, error) {
+	return r.GetApplicationsByCandidateContext(context.Background(), candidateID)
 }
 
-func (r Resolver) GetTransaction(id string) (*Transaction, error) {
-	query := `query GetTransaction($id: ID!) {
-		getTransaction(id: $id) {
-			id
-			action {
-				id
-				challengeId
-			}
-			parentTransaction {
-        id
-        parentTransactionId
-			}
-			parentTransactionId
-		}
-	}
-	`
-	log.Printf("ID %v", id)
-	variables := json.RawMessage(fmt.Sprintf(`{ "id": "%s"	}`, id))
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	response, err := client.Post(graphql.PostRequest{
-		Query:     query,
-		Variables: &variables,
-	})
+func (r AppSyncResolver) GetApplicationsByCandidateContext(ctx context.Context, candidateID string) ([]*Application, error) {
+	modelFilter := filter.Eq("candidateId", candidateID)
+	applications, err := r.ListApplicationsContext(ctx, &modelFilter)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %+v", err)
+		log.Printf("Failed to list applications: %+v", err)
 		return nil, err
 	}
 
-	log.Printf("Graph QL Response: %v", response)
-	log.Printf("Graph QL Response status code: %v", response.StatusCode)
-	log.Printf("Graph QL Response data: %v", response.Data)
-	log.Printf("Graph QL Response errors: %v", response.Errors)
+	log.Printf("GetApplicationsByCandidate data: %+v", applications)
+	return applications, nil
+}
+
+func (r AppSyncResolver) GetApplicationsByChallengeAndCandidate(challengeID string, candidateID string) ([]*Application, error) {
+	return r.GetApplicationsByChallengeAndCandidateContext(context.Background(), challengeID, candidateID)
+}
 
-	var result GetTransactionResponse
-	err = mapstructure.Decode(response.Data, &result)
+func (r AppSyncResolver) GetApplicationsByChallengeAndCandidateContext(ctx context.Context, challengeID string, candidateID string) ([]*Application, error) {
+	modelFilter := filter.And(filter.Eq("challengeId", challengeID), filter.Eq("candidateId", candidateID))
+	applications, err := r.ListApplicationsContext(ctx, &modelFilter)
+	if err != nil {
+		log.Printf("Failed to list applications: %+v", err)
+		return nil, err
+	}
 
-	log.Println("GetTransaction data: %+v", result.GetTransaction)
-	return &result.GetTransaction, nil
+	log.Printf("GetApplicationsByChallengeAndCandidate data: %+v", applications)
+	return applications, nil
 }
 
-func (r Resolver) GetShareActionsByChallengeAndUser(challengeID string, userID string) ([]*ShareAction, error) {
-	query := `query ListShareActions(
-		$filter: ModelShareActionFilterInput
-		$limit: Int
-		$nextToken: String
-	) {
-		listShareActions(filter: $filter, limit: $limit, nextToken: $nextToken) {
-			items {
-				id
-			}
-			nextToken
-		}
+// post sends one GraphQL operation, retrying transient failures under the
+// resolver's Retry policy. GraphQL errors and non-200 statuses come back as
+// an *Error, transport failures are wrapped as they are. Whatever data the
+// last attempt returned comes back with its GraphQL errors, since a request
+// of several aliased mutations can partly succeed.
+func (r AppSyncResolver) post(ctx context.Context, operation string, query string, input interface{}) (interface{}, error) {
+	jsonVariables, err := json.Marshal(input)
+	if err != nil {
+		return nil, newError(ErrValidation, operation, err.Error())
 	}
-	`
-	filterJson := fmt.Sprintf(`{"filter": { "challengeId": { "eq": "%s" } }, "and": { "userId" : { "eq": "%s" } }, "limit": 1000 }`, challengeID, userID)
-	log.Printf("filterJson: %v", filterJson)
+	variables := json.RawMessage(jsonVariables)
+	log.Printf("%s variables: %s", operation, string(jsonVariables))
+
+	// Mutations with an explicit id hit a conditional check when replayed,
+	// so only those are safe to retry after an ambiguous failure.
+	idempotent := !strings.HasPrefix(query, "mutation") || hasInputID(jsonVariables)
 
-	variables := json.RawMessage(filterJson)
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	response, err := client.Post(graphql.PostRequest{
-		Query:     query,
-		Variables: &variables,
+	var data interface{}
+	err = r.retry.Do(ctx, operation, idempotent, func(ctx context.Context) error {
+		var err error
+		data, err = r.postOnce(ctx, operation, query, &variables)
+		return err
 	})
+	if err != nil && hasExpectedVersion(jsonVariables) {
+		err = asConflict(err)
+	}
 	if err != nil {
-		log.Printf("Failed to post to appsync: %+v", err)
-		return nil, err
+		log.Printf("%s failed: %v", operation, err)
+		return data, err
 	}
+	return data, nil
+}
 
-	log.Printf("Graph QL Response: %v", response)
-	log.Printf("Graph QL Response status code: %v", response.StatusCode)
-	log.Printf("Graph QL Response data: %v", response.Data)
-	log.Printf("Graph QL Response errors: %v", response.Errors)
-
-	var result ListShareActionsByChallengeAndUserResponse
-	err = mapstructure.Decode(response.Data, &result)
+// postAttempt sends one GraphQL operation once, for callers that retry parts
+// of it themselves.
+func (r AppSyncResolver) postAttempt(ctx context.Context, operation string, query string, input interface{}) (interface{}, error) {
+	jsonVariables, err := json.Marshal(input)
+	if err != nil {
+		return nil, newError(ErrValidation, operation, err.Error())
+	}
+	variables := json.RawMessage(jsonVariables)
+	log.Printf("%s variables: %s", operation, string(jsonVariables))
 
-	log.Println("GetShareActionsByChallengeAndUser data: %+v", result.ListShareActions.Items)
+	callCtx, cancel := context.WithTimeout(ctx, r.retry.timeout())
+	defer cancel()
+	data, err := r.postOnce(callCtx, operation, query, &variables)
+	if err != nil {
+		log.Printf("%s failed: %v", operation, err)
+	}
+	return data, err
+}
 
-	return result.ListShareActions.Items, nil
+func hasExpectedVersion(jsonVariables []byte) bool {
+	var variables struct {
+		Input struct {
+			ExpectedVersion *int `json:"expectedVersion"`
+		} `json:"input"`
+	}
+	if err := json.Unmarshal(jsonVariables, &variables); err != nil {
+		return false
+	}
+	return variables.Input.ExpectedVersion != nil
 }
 
-func (r Resolver) GetTransactionsByShareAction(actionID string) ([]*Transaction, error) {
-	query := `query ListTransactions(
-		$filter: ModelTransactionFilterInput
-		$limit: Int
-		$nextToken: String
-	) {
-		listTransactions(filter: $filter, limit: $limit, nextToken: $nextToken) {
-			items {
-				id
-			}
-			nextToken
-		}
+func hasInputID(jsonVariables []byte) bool {
+	var variables struct {
+		ID    *string `json:"id"`
+		Input struct {
+			ID *string `json:"id"`
+		} `json:"input"`
+	}
+	if err := json.Unmarshal(jsonVariables, &variables); err != nil {
+		return false
+	}
+	if variables.ID != nil && *variables.ID != "" {
+		return true
 	}
-	`
-	filterJson := fmt.Sprintf(`{"filter": { "transactionActionId": { "eq": "%s"} }, "limit": 1000 }`, actionID)
-	log.Printf("filterJson: %v", filterJson)
+	return variables.Input.ID != nil && *variables.Input.ID != ""
+}
 
-	variables := json.RawMessage(filterJson)
-	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(serverURL, *r.awsConfig)))
-	response, err := client.Post(graphql.PostRequest{
-		Query:     query,
-		Variables: &variables,
-	})
+// postOnce makes a single HTTP call, giving up when ctx is done. The client
+// library's own retries are disabled so Retry alone decides.
+func (r AppSyncResolver) postOnce(ctx context.Context, operation string, query string, variables *json.RawMessage) (interface{}, error) {
+	timeout := r.retry.Timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("%s: %w", operation, context.DeadlineExceeded)
+	}
+
+	type result struct {
+		response *graphql.Response
+		err      error
+	}
+	results := make(chan result, 1)
+
+	client := appsync.NewClient(appsync.NewGraphQLClient(graphql.NewClient(
+		serverURL,
+		*r.awsConfig,
+		graphql.WithTimeout(timeout),
+		graphql.WithMaxElapsedTime(time.Nanosecond),
+	)))
+	cancel, err := client.PostAsync(
+		graphql.PostRequest{
+			Query:     query,
+			Variables: variables,
+		},
+		func(response *graphql.Response, err error) { results <- result{response, err} },
+	)
 	if err != nil {
-		log.Printf("Failed to post to appsync: %+v", err)
-		return nil, err
+		return nil, fmt.Errorf("%s: failed to post to appsync: %w", operation, err)
+	}
+
+	var response *graphql.Response
+	select {
+	case <-ctx.Done():
+		cancel()
+		return nil, fmt.Errorf("%s: %w", operation, ctx.Err())
+	case posted := <-results:
+		if posted.err != nil {
+			log.Printf("Failed to post to appsync: %+v", posted.err)
+			return nil, fmt.Errorf("%s: failed to post to appsync: %w", operation, posted.err)
+		}
+		response = posted.response
 	}
 
-	log.Printf("Graph QL Response: %v", response)
-	log.Printf("Graph QL Response status code: %v", response.StatusCode)
-	log.Printf("Graph QL Response data: %v", response.Data)
-	log.Printf("Graph QL Response errors: %v", response.Errors)
+	log.Printf("%s Appsync response status code: %v", operation, response.StatusCode)
+	log.Printf("%s Appsync response errors: %v", operation, response.Errors)
 
-	var result ListTransactionByShareActionResponse
-	err = mapstructure.Decode(response.Data, &result)
+	if err := errorFromResponse(operation, response.StatusCode, response.Errors); err != nil {
+		return response.Data, err
+	}
+	return response.Data, nil
+}
 
-	log.Println("GetTransactionsByShareAction data: %+v", result.ListTransaction.Items)
+// do posts the operation and decodes the response data into result.
+func (r AppSyncResolver) do(ctx context.Context, operation string, query string, variables interface{}, field string, result interface{}) error {
+	data, err := r.post(ctx, operation, query, variables)
+	if err != nil {
+		return err
+	}
+	if err := decode(operation, data, field, result); err != nil {
+		return err
+	}
+	log.Printf("%s data: %+v", operation, result)
+	return nil
+}
 
-	return result.ListTransaction.Items, nil
+// decode copies the response data into result through its json tags. A null
+// top-level field means the entity does not exist and yields ErrNotFound.
+func decode(operation string, data interface{}, field string, result interface{}) error {
+	fields, _ := data.(map[string]interface{})
+	if fields == nil || fields[field] == nil {
+		return newError(ErrNotFound, operation, fmt.Sprintf("%s returned null", field))
+	}
+	encoded, err := json.Marshal(data)
+	if err == nil {
+		err = json.Unmarshal(encoded, result)
+	}
+	if err != nil {
+		return newError(ErrUnknown, operation, fmt.Sprintf("failed to decode %s: %v", field, err))
+	}
+	return nil
 }
@@ -0,0 +1,139 @@
+package appsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+const (
+	// DefaultTimeout bounds a single AppSync HTTP call.
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxRetries is how many times a transient failure is retried.
+	DefaultMaxRetries = 4
+	// DefaultInitialInterval is the wait before the first retry.
+	DefaultInitialInterval = 200 * time.Millisecond
+	// DefaultMaxInterval caps the wait between two retries.
+	DefaultMaxInterval = 5 * time.Second
+	// DefaultJitter is the randomization factor applied to every wait.
+	DefaultJitter = 0.5
+)
+
+// Retry controls how long one AppSync call may take and how transient
+// failures are retried. Waits grow exponentially from InitialInterval up to
+// MaxInterval, each randomized by +/- Jitter.
+type Retry struct {
+	Timeout         time.Duration
+	MaxRetries      int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Jitter          float64
+}
+
+// DefaultRetry reads AWS_APP_SYNC_TIMEOUT (a duration such as "5s") and
+// AWS_APP_SYNC_MAX_RETRIES, falling back to the defaults above.
+func DefaultRetry() Retry {
+	return Retry{
+		Timeout:         lookupDurationEnv("AWS_APP_SYNC_TIMEOUT", DefaultTimeout),
+		MaxRetries:      lookupIntEnv("AWS_APP_SYNC_MAX_RETRIES", DefaultMaxRetries),
+		InitialInterval: DefaultInitialInterval,
+		MaxInterval:     DefaultMaxInterval,
+		Jitter:          DefaultJitter,
+	}
+}
+
+func lookupDurationEnv(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		return fallback
+	}
+	return parsed
+}
+
+// IsRetryable reports whether err is worth another attempt: throttling,
+// AppSync or DynamoDB being unavailable, and network failures including a
+// single call running past its timeout.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrThrottled) || errors.Is(err, ErrUnavailable) {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Do runs call until it succeeds, fails with an error that is not retryable,
+// runs out of retries or ctx is done. Every attempt gets its own context
+// bounded by Timeout. Calls that are not idempotent, such as mutations that
+// let AppSync pick the id, are only retried when they were throttled, since
+// any other failure may have happened after the write.
+func (r Retry) Do(ctx context.Context, operation string, idempotent bool, call func(ctx context.Context) error) error {
+	timeout := r.timeout()
+
+	attempt := func() error {
+		if err := ctx.Err(); err != nil {
+			return backoff.Permanent(fmt.Errorf("%s: %w", operation, err))
+		}
+		callCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		err := call(callCtx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || !IsRetryable(err) || (!idempotent && !errors.Is(err, ErrThrottled)) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+	notify := func(err error, wait time.Duration) {
+		log.Printf("%s failed, retrying in %v: %v", operation, wait, err)
+	}
+
+	return backoff.RetryNotify(attempt, r.backOff(ctx), notify)
+}
+
+func (r Retry) timeout() time.Duration {
+	if r.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return r.Timeout
+}
+
+// backOff returns the waits between the retries of one call, ending after
+// MaxRetries or once ctx is done.
+func (r Retry) backOff(ctx context.Context) backoff.BackOff {
+	policy := backoff.NewExponentialBackOff()
+	policy.InitialInterval = r.InitialInterval
+	if policy.InitialInterval <= 0 {
+		policy.InitialInterval = DefaultInitialInterval
+	}
+	policy.MaxInterval = r.MaxInterval
+	if policy.MaxInterval <= 0 {
+		policy.MaxInterval = DefaultMaxInterval
+	}
+	policy.RandomizationFactor = r.Jitter
+	// The context and MaxRetries bound the retries, not the elapsed time.
+	policy.MaxElapsedTime = 0
+
+	maxRetries := r.MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return backoff.WithContext(backoff.WithMaxRetries(policy, uint64(maxRetries)), ctx)
+}
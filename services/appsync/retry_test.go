@@ -0,0 +1,104 @@
+package appsync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func TestRetry(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	retry := Retry{
+		Timeout:         50 * time.Millisecond,
+		MaxRetries:      3,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     2 * time.Millisecond,
+		Jitter:          DefaultJitter,
+	}
+
+	g.Describe("Retry", func() {
+		g.It("Should retry throttling until it succeeds", func() {
+			calls := 0
+			err := retry.Do(context.Background(), "GetUser", true, func(ctx context.Context) error {
+				calls++
+				if calls < 3 {
+					return newError(ErrThrottled, "GetUser", "slow down")
+				}
+				return nil
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(calls).Should(Equal(3))
+		})
+
+		g.It("Should fail fast on errors that are not retryable", func() {
+			calls := 0
+			err := retry.Do(context.Background(), "CreateUser", true, func(ctx context.Context) error {
+				calls++
+				return newError(ErrValidation, "CreateUser", "bad input")
+			})
+			Expect(errors.Is(err, ErrValidation)).Should(BeTrue())
+			Expect(calls).Should(Equal(1))
+		})
+
+		g.It("Should give up after MaxRetries", func() {
+			calls := 0
+			err := retry.Do(context.Background(), "GetUser", true, func(ctx context.Context) error {
+				calls++
+				return newError(ErrUnavailable, "GetUser", "down")
+			})
+			Expect(errors.Is(err, ErrUnavailable)).Should(BeTrue())
+			Expect(calls).Should(Equal(4))
+		})
+
+		g.It("Should only retry throttling for calls that are not idempotent", func() {
+			calls := 0
+			err := retry.Do(context.Background(), "CreateUser", false, func(ctx context.Context) error {
+				calls++
+				return newError(ErrUnavailable, "CreateUser", "down")
+			})
+			Expect(errors.Is(err, ErrUnavailable)).Should(BeTrue())
+			Expect(calls).Should(Equal(1))
+		})
+
+		g.It("Should time out a single attempt and retry it", func() {
+			calls := 0
+			err := retry.Do(context.Background(), "GetUser", true, func(ctx context.Context) error {
+				calls++
+				if calls == 1 {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				return nil
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(calls).Should(Equal(2))
+		})
+
+		g.It("Should stop once the caller's context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			calls := 0
+			err := retry.Do(ctx, "GetUser", true, func(ctx context.Context) error {
+				calls++
+				cancel()
+				return newError(ErrThrottled, "GetUser", "slow down")
+			})
+			Expect(err).Should(HaveOccurred())
+			Expect(calls).Should(Equal(1))
+
+			err = retry.Do(ctx, "GetUser", true, func(ctx context.Context) error {
+				calls++
+				return nil
+			})
+			Expect(errors.Is(err, context.Canceled)).Should(BeTrue())
+			Expect(calls).Should(Equal(1))
+		})
+	})
+}
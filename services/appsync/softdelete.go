@@ -0,0 +1,74 @@
+package appsync
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gitlab.com/ncent/arber/api/services/appsync/filter"
+)
+
+// Rows are never removed from AppSync: a delete stamps deletedAt and a
+// restore clears it. Generated gets treat a stamped row as not found and
+// generated lists filter stamped rows out, unless the resolver was built with
+// IncludeDeleted.
+
+// IncludeDeleted returns a copy of the resolver that also returns
+// soft-deleted rows.
+func (r AppSyncResolver) IncludeDeleted() Resolver {
+	r.includeDeleted = true
+	return r
+}
+
+// excludeDeleted adds a deletedAt-does-not-exist condition to a list filter.
+func (r AppSyncResolver) excludeDeleted(modelFilter *filter.Filter) *filter.Filter {
+	if r.includeDeleted {
+		return modelFilter
+	}
+	notDeleted := filter.Exists("deletedAt", false)
+	if modelFilter == nil || modelFilter.IsEmpty() {
+		return &notDeleted
+	}
+	combined := filter.And(*modelFilter, notDeleted)
+	return &combined
+}
+
+func deletedAtNow() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+func (r AppSyncResolver) DeleteUser(id string) (*User, error) {
+	return r.DeleteUserContext(context.Background(), id)
+}
+
+func (r AppSyncResolver) DeleteUserContext(ctx context.Context, id string) (*User, error) {
+	log.Printf("Soft deleting user %v", id)
+	return r.SoftDeleteUserContext(ctx, id, deletedAtNow())
+}
+
+func (r AppSyncResolver) DeleteChallenge(id string) (*Challenge, error) {
+	return r.DeleteChallengeContext(context.Background(), id)
+}
+
+func (r AppSyncResolver) DeleteChallengeContext(ctx context.Context, id string) (*Challenge, error) {
+	log.Printf("Soft deleting challenge %v", id)
+	return r.SoftDeleteChallengeContext(ctx, id, deletedAtNow())
+}
+
+func (r AppSyncResolver) DeleteShareAction(id string) (*ShareAction, error) {
+	return r.DeleteShareActionContext(context.Background(), id)
+}
+
+func (r AppSyncResolver) DeleteShareActionContext(ctx context.Context, id string) (*ShareAction, error) {
+	log.Printf("Soft deleting share action %v", id)
+	return r.SoftDeleteShareActionContext(ctx, id, deletedAtNow())
+}
+
+func (r AppSyncResolver) DeleteTransaction(id string) (*Transaction, error) {
+	return r.DeleteTransactionContext(context.Background(), id)
+}
+
+func (r AppSyncResolver) DeleteTransactionContext(ctx context.Context, id string) (*Transaction, error) {
+	log.Printf("Soft deleting transaction %v", id)
+	return r.SoftDeleteTransactionContext(ctx, id, deletedAtNow())
+}
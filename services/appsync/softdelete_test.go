@@ -0,0 +1,119 @@
+package appsync
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	"gitlab.com/ncent/arber/api/services/appsync/filter"
+)
+
+func TestSoftDelete(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("excludeDeleted", func() {
+		g.It("Should only match rows without deletedAt", func() {
+			encoded, err := json.Marshal(AppSyncResolver{}.excludeDeleted(nil))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(encoded)).Should(MatchJSON(`{"deletedAt":{"attributeExists":false}}`))
+		})
+
+		g.It("Should keep the caller's filter", func() {
+			modelFilter := filter.Eq("userId", "u1")
+			encoded, err := json.Marshal(AppSyncResolver{}.excludeDeleted(&modelFilter))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(encoded)).Should(MatchJSON(`{"and":[{"userId":{"eq":"u1"}},{"deletedAt":{"attributeExists":false}}]}`))
+		})
+
+		g.It("Should leave the filter alone when including deleted rows", func() {
+			modelFilter := filter.Eq("userId", "u1")
+			resolver := AppSyncResolver{}.IncludeDeleted().(AppSyncResolver)
+			Expect(resolver.excludeDeleted(&modelFilter)).Should(Equal(&modelFilter))
+		})
+	})
+
+	g.Describe("MemoryResolver", func() {
+		var resolver *MemoryResolver
+		email := "ada@example.com"
+
+		g.BeforeEach(func() {
+			resolver = NewMemoryResolver()
+		})
+
+		g.It("Should hide a deleted user until it is restored", func() {
+			user, err := resolver.CreateUser(CreateUserInput{Emails: []*string{&email}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			deleted, err := resolver.DeleteUser(*user.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(deleted.DeletedAt).ShouldNot(BeNil())
+
+			_, err = resolver.GetUser(*user.ID)
+			Expect(errors.Is(err, ErrNotFound)).Should(BeTrue())
+			users, err := resolver.ListUsersByEmails([]*string{&email})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(BeEmpty())
+
+			admin := resolver.IncludeDeleted()
+			found, err := admin.GetUser(*user.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(found.DeletedAt).ShouldNot(BeNil())
+			users, err = admin.ListUsersByEmails([]*string{&email})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(HaveLen(1))
+
+			restored, err := resolver.RestoreUser(*user.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(restored.DeletedAt).Should(BeNil())
+			_, err = resolver.GetUser(*user.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		g.It("Should exclude deleted share actions and transactions from lists", func() {
+			challengeID := "c1"
+			userID := "u1"
+			shareAction, err := resolver.CreateShareAction(CreateShareActionInput{ChallengeID: &challengeID, UserID: &userID})
+			Expect(err).ShouldNot(HaveOccurred())
+			transaction, err := resolver.CreateTransaction(CreateTransactionInput{TransactionActionID: shareAction.ID})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = resolver.DeleteTransaction(*transaction.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			transactions, err := resolver.GetTransactionsByShareAction(*shareAction.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(transactions).Should(BeEmpty())
+
+			_, err = resolver.DeleteShareAction(*shareAction.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			shareActions, err := resolver.GetShareActionsByChallengeAndUser(challengeID, userID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(shareActions).Should(BeEmpty())
+
+			shareActions, err = resolver.IncludeDeleted().GetShareActionsByChallengeAndUser(challengeID, userID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(shareActions).Should(HaveLen(1))
+		})
+
+		g.It("Should not serve a deleted challenge from the cache", func() {
+			name := "Engineer"
+			challenge, err := resolver.CreateChallenge(CreateChallengeInput{Name: &name})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			cached := NewCachedResolver(resolver, 0, nil)
+			_, err = cached.GetChallenge(*challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = cached.DeleteChallenge(*challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = cached.GetChallenge(*challenge.ID)
+			Expect(errors.Is(err, ErrNotFound)).Should(BeTrue())
+			_, err = cached.IncludeDeleted().GetChallenge(*challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+}
@@ -0,0 +1,159 @@
+package address
+
+import (
+	"os"
+	"strings"
+)
+
+// Rules say how the mailbox behind an address is named at each domain.
+// Addresses that differ only in what a domain ignores reach the same
+// mailbox and share a canonical form.
+type Rules struct {
+	// Domains maps a domain to the one it is another name of.
+	Domains map[string]string
+	// IgnoreDots lists the domains whose mailboxes ignore dots in the
+	// local part.
+	IgnoreDots map[string]bool
+	// Subaddressing maps a domain to the characters that start a tag in
+	// the local part, like the + of jane+jobs@gmail.com.
+	Subaddressing map[string]string
+	// AnySubaddress, when set, starts a tag at these characters on every
+	// domain, not only the ones in Subaddressing.
+	AnySubaddress string
+}
+
+// DefaultRules are the rules of the large providers. Other domains keep
+// their addresses whole, as a tag there may well be part of the mailbox.
+func DefaultRules() Rules {
+	return Rules{
+		Domains: map[string]string{
+			"googlemail.com": "gmail.com",
+		},
+		IgnoreDots: map[string]bool{
+			"gmail.com": true,
+		},
+		Subaddressing: map[string]string{
+			"gmail.com":      "+",
+			"outlook.com":    "+",
+			"hotmail.com":    "+",
+			"live.com":       "+",
+			"icloud.com":     "+",
+			"me.com":         "+",
+			"mac.com":        "+",
+			"fastmail.com":   "+",
+			"protonmail.com": "+",
+		},
+	}
+}
+
+// Default are the DefaultRules plus the domains configured in
+// SUBADDRESS_DOMAINS.
+var Default = DefaultRules().WithSubaddressing(os.Getenv("SUBADDRESS_DOMAINS"))
+
+// WithSubaddressing returns a copy of the rules that also strips tags on
+// the domains of config, a comma separated list of domains each optionally
+// followed by a colon and its tag characters, as in "redb.ai,example.com:-".
+// The tag character defaults to +.
+func (r Rules) WithSubaddressing(config string) Rules {
+	copied := r.copy()
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		domain, separators := entry, "+"
+		if colon := strings.Index(entry, ":"); colon >= 0 {
+			domain, separators = entry[:colon], entry[colon+1:]
+		}
+		if domain != "" && separators != "" {
+			copied.Subaddressing[domain] = separators
+		}
+	}
+	return copied
+}
+
+// Loose returns a copy of the rules that strips + tags on every domain.
+// It over-matches, which suits checks that look for one person behind
+// several addresses but not the identity of users.
+func (r Rules) Loose() Rules {
+	copied := r.copy()
+	copied.AnySubaddress = "+"
+	return copied
+}
+
+// Canonical is the canonical form of address: lowercased, with the domain
+// resolved to the one it names and whatever the domain ignores dropped.
+// Strings that are not addresses are only trimmed and lowercased.
+func (r Rules) Canonical(address string) string {
+	address = strings.ToLower(strings.TrimSpace(address))
+	at := strings.LastIndex(address, "@")
+	if at <= 0 || at == len(address)-1 {
+		return address
+	}
+	local, domain := address[:at], address[at+1:]
+	if alias, ok := r.Domains[domain]; ok {
+		domain = alias
+	}
+	if tag := strings.IndexAny(local, r.Subaddressing[domain]+r.AnySubaddress); tag > 0 {
+		local = local[:tag]
+	}
+	if r.IgnoreDots[domain] {
+		if undotted := strings.Replace(local, ".", "", -1); undotted != "" {
+			local = undotted
+		}
+	}
+	return local + "@" + domain
+}
+
+// Same reports whether a and b reach the same mailbox under the rules.
+func (r Rules) Same(a string, b string) bool {
+	return r.Canonical(a) == r.Canonical(b)
+}
+
+func (r Rules) copy() Rules {
+	copied := Rules{
+		Domains:       map[string]string{},
+		IgnoreDots:    map[string]bool{},
+		Subaddressing: map[string]string{},
+		AnySubaddress: r.AnySubaddress,
+	}
+	for domain, alias := range r.Domains {
+		copied.Domains[domain] = alias
+	}
+	for domain, ignore := range r.IgnoreDots {
+		copied.IgnoreDots[domain] = ignore
+	}
+	for domain, separators := range r.Subaddressing {
+		copied.Subaddressing[domain] = separators
+	}
+	return copied
+}
+
+// Canonical is the canonical form of address under the Default rules.
+func Canonical(address string) string {
+	return Default.Canonical(address)
+}
+
+// Same reports whether a and b reach the same mailbox under the Default
+// rules.
+func Same(a string, b string) bool {
+	return Default.Same(a, b)
+}
+
+// CanonicalAll canonicalizes addresses, dropping empty ones and repeats.
+func CanonicalAll(addresses []*string) []*string {
+	var canonical []*string
+	seen := map[string]bool{}
+	for _, address := range addresses {
+		if address == nil || strings.TrimSpace(*address) == "" {
+			continue
+		}
+		value := Canonical(*address)
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		canonical = append(canonical, &value)
+	}
+	return canonical
+}
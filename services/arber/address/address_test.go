@@ -0,0 +1,58 @@
+package address
+
+import (
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+func Test(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Canonical", func() {
+		rules := DefaultRules()
+
+		g.It("Should fold Gmail dots, tags and googlemail", func() {
+			Expect(rules.Canonical("jane.doe+jobs@gmail.com")).Should(Equal("janedoe@gmail.com"))
+			Expect(rules.Canonical(" JaneDoe@GoogleMail.com ")).Should(Equal("janedoe@gmail.com"))
+			Expect(rules.Same("janedoe@gmail.com", "j.a.n.e.doe@googlemail.com")).Should(BeTrue())
+		})
+
+		g.It("Should keep tags and dots on other domains", func() {
+			Expect(rules.Canonical("jane.doe+jobs@example.com")).Should(Equal("jane.doe+jobs@example.com"))
+			Expect(rules.Canonical("jane.doe+jobs@outlook.com")).Should(Equal("jane.doe@outlook.com"))
+		})
+
+		g.It("Should strip tags on configured domains", func() {
+			configured := rules.WithSubaddressing("Example.com, redb.ai:-+, ")
+			Expect(configured.Canonical("jane+jobs@example.com")).Should(Equal("jane@example.com"))
+			Expect(configured.Canonical("jane-jobs@redb.ai")).Should(Equal("jane@redb.ai"))
+			Expect(rules.Canonical("jane+jobs@example.com")).Should(Equal("jane+jobs@example.com"))
+		})
+
+		g.It("Should strip + tags everywhere when loose", func() {
+			Expect(rules.Loose().Canonical("jane+jobs@example.com")).Should(Equal("jane@example.com"))
+		})
+
+		g.It("Should leave what is not an address alone", func() {
+			Expect(rules.Canonical("+jobs@gmail.com")).Should(Equal("+jobs@gmail.com"))
+			Expect(rules.Canonical("...@gmail.com")).Should(Equal("...@gmail.com"))
+			Expect(rules.Canonical("Jane")).Should(Equal("jane"))
+			Expect(rules.Canonical("jane@")).Should(Equal("jane@"))
+		})
+	})
+
+	g.Describe("CanonicalAll", func() {
+		g.It("Should drop empty and repeated addresses", func() {
+			a, b, c, empty := "jane.doe@gmail.com", "janedoe+x@googlemail.com", "bob@example.com", " "
+			canonical := CanonicalAll([]*string{&a, nil, &b, &empty, &c})
+			Expect(canonical).Should(HaveLen(2))
+			Expect(*canonical[0]).Should(Equal("janedoe@gmail.com"))
+			Expect(*canonical[1]).Should(Equal("bob@example.com"))
+		})
+	})
+}
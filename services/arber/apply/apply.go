@@ -0,0 +1,291 @@
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/DusanKasan/parsemail"
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	AttachmentController "gitlab.com/ncent/arber/api/services/arber/attachment"
+	AttributionController "gitlab.com/ncent/arber/api/services/arber/attribution"
+	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
+	UserController "gitlab.com/ncent/arber/api/services/arber/user"
+	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
+)
+
+// Submitted is the status of an application nobody has acted on yet.
+const Submitted = "SUBMITTED"
+
+var (
+	// ErrInvalidRequest is returned for an application missing the
+	// transaction or the candidate's address.
+	ErrInvalidRequest = errors.New("invalid application")
+	// ErrAlreadyApplied is returned with the existing application when the
+	// candidate applies to the same challenge again, through any share.
+	ErrAlreadyApplied = errors.New("candidate has already applied to this challenge")
+)
+
+// Request is a candidate applying through the apply link of a share.
+// TransactionID is the transaction the link was generated for; Resume is
+// optional. An application without a link, as by mail, gives ChallengeID
+// instead and is credited to the chains that reached the candidate only.
+// MailAuthentication is the authentication decision, as JSON, of an
+// application made by mail.
+type Request struct {
+	TransactionID      string
+	ChallengeID        string
+	Candidate          *mail.Address
+	Message            string
+	Resume             *parsemail.Attachment
+	MailAuthentication *string
+}
+
+// now, saveResume and notify are replaced in tests.
+var (
+	now        = time.Now
+	saveResume = AttachmentController.SaveAttachments
+	notify     = func(request clients.EmailRequest) error {
+		return clients.SESClient.SendEmail(request)
+	}
+)
+
+// ApplicationID is the ID of the application of candidateID to challengeID.
+// Deriving it from the pair makes the create conditional, so a candidate is
+// attributed to exactly one referral chain even when two applications race.
+func ApplicationID(challengeID string, candidateID string) string {
+	return challengeID + ":" + candidateID
+}
+
+// Apply records the candidate's application to the challenge of the
+// transaction they applied through, uploads their resume and tells the
+// sponsor and the users who shared the credited transactions. Which of the
+// chains that reached the candidate is credited is up to the attribution
+// model of the challenge. The first application wins: a later one returns
+// the existing application with ErrAlreadyApplied.
+func Apply(ctx context.Context, resolver Resolver.Resolver, request Request) (*appsync.Application, error) {
+	if request.TransactionID == "" && request.ChallengeID == "" {
+		return nil, fmt.Errorf("%w: missing transaction", ErrInvalidRequest)
+	}
+	if request.Candidate == nil || request.Candidate.Address == "" {
+		return nil, fmt.Errorf("%w: missing candidate email", ErrInvalidRequest)
+	}
+
+	challengeID := request.ChallengeID
+	if request.TransactionID != "" {
+		transaction, err := resolver.GetTransactionContext(ctx, request.TransactionID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get transaction: %w", err)
+		}
+		if transaction.Action == nil || transaction.Action.ChallengeID == nil {
+			return nil, fmt.Errorf("%w: transaction %v has no challenge", ErrInvalidRequest, request.TransactionID)
+		}
+		challengeID = *transaction.Action.ChallengeID
+	}
+	challenge, err := ChallengeController.GetChallenge(ctx, resolver, challengeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ChallengeController.CheckOpen(challenge, now()); err != nil {
+		return nil, err
+	}
+
+	candidate, err := UserController.CreateSparseUser(ctx, resolver, request.Candidate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checked before the upload so a repeat application stores nothing.
+	existing, err := FindApplication(ctx, resolver, *challenge.ID, *candidate.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, alreadyApplied(existing)
+	}
+
+	var resumeURL *string
+	if request.Resume != nil {
+		resumeURLs, err := saveResume([]parsemail.Attachment{*request.Resume})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to save resume: %w", err)
+		}
+		if len(resumeURLs) > 0 {
+			resumeURL = resumeURLs[0]
+		}
+	}
+
+	attribution, err := AttributionController.AttributeCandidate(ctx, resolver, challenge, *candidate.ID, request.TransactionID, now())
+	if err != nil {
+		return nil, err
+	}
+	attributionJSON, err := json.Marshal(attribution)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal attribution: %w", err)
+	}
+
+	id := ApplicationID(*challenge.ID, *candidate.ID)
+	status := Submitted
+	credited := attribution.Primary()
+	attributionValue := string(attributionJSON)
+	input := appsync.CreateApplicationInput{
+		ID:                 &id,
+		ChallengeID:        challenge.ID,
+		CandidateID:        candidate.ID,
+		TransactionID:      &credited,
+		ResumeURL:          resumeURL,
+		Status:             &status,
+		Attribution:        &attributionValue,
+		MailAuthentication: request.MailAuthentication,
+	}
+	if request.Message != "" {
+		input.Message = &request.Message
+	}
+	application, err := resolver.CreateApplicationContext(ctx, input)
+	if errors.Is(err, appsync.ErrConditionalCheckFailed) {
+		// Another application of the same candidate got in first
+		log.Printf("Application %v already created, fetching it: %v", id, err)
+		existing, err := resolver.GetApplicationContext(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get application: %w", err)
+		}
+		return existing, alreadyApplied(existing)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create application: %w", err)
+	}
+	log.Printf("Created application: %+v", application)
+
+	credits, _ := attribution.Weights()
+	notifyApplication(ctx, resolver, challenge, credits, request, application)
+	return application, nil
+}
+
+// FindApplication returns the application of the candidate to the
+// challenge, or nil when they have not applied.
+func FindApplication(ctx context.Context, resolver Resolver.Resolver, challengeID string, candidateID string) (*appsync.Application, error) {
+	applications, err := resolver.GetApplicationsByChallengeAndCandidateContext(ctx, challengeID, candidateID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get applications: %w", err)
+	}
+	if len(applications) == 0 {
+		return nil, nil
+	}
+	return applications[0], nil
+}
+
+func alreadyApplied(application *appsync.Application) error {
+	return fmt.Errorf("%w: application %v through transaction %v", ErrAlreadyApplied, stringValue(application.ID), stringValue(application.TransactionID))
+}
+
+// notifyApplication emails the sponsor and the sharers of the credited
+// transactions. The application is already recorded, so a failed email is
+// logged and not returned.
+func notifyApplication(ctx context.Context, resolver Resolver.Resolver, challenge *appsync.Challenge, credited []string, request Request, application *appsync.Application) {
+	role := stringValue(challenge.Name)
+	candidate := candidateName(request.Candidate)
+
+	if challenge.SponsorEmail == nil || *challenge.SponsorEmail == "" {
+		log.Printf("Challenge %v has no sponsor email, not notifying the sponsor", stringValue(challenge.ID))
+	} else {
+		lines := []string{fmt.Sprintf("%s applied to %s.", candidate, role)}
+		if request.Message != "" {
+			lines = append(lines, request.Message)
+		}
+		if application.ResumeURL != nil {
+			lines = append(lines, "Resume: "+*application.ResumeURL)
+		}
+		send(clients.EmailRequest{
+			Recipient: *challenge.SponsorEmail,
+			Sender:    "no-reply@redb.ai",
+			Subject:   fmt.Sprintf("New applicant for %s: %s", role, candidate),
+			Html:      htmlParagraphs(lines),
+			Body:      strings.Join(lines, "\n\n"),
+		})
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s applied to %s through your referral.", candidate, role),
+		"You will hear from us if they are hired.",
+	}
+	notified := map[string]bool{}
+	for _, transactionID := range credited {
+		sharerEmail := sharerEmail(ctx, resolver, transactionID)
+		if sharerEmail == "" {
+			log.Printf("Transaction %v has no sharer email, not notifying the sharer", transactionID)
+			continue
+		}
+		if notified[sharerEmail] {
+			continue
+		}
+		notified[sharerEmail] = true
+		send(clients.EmailRequest{
+			Recipient: sharerEmail,
+			Sender:    "no-reply@redb.ai",
+			Subject:   fmt.Sprintf("Your referral applied to %s", role),
+			Html:      htmlParagraphs(lines),
+			Body:      strings.Join(lines, "\n\n"),
+		})
+	}
+}
+
+func send(request clients.EmailRequest) {
+	if err := notify(request); err != nil {
+		log.Printf("Failed to send %q to %v: %v", request.Subject, request.Recipient, err)
+	}
+}
+
+func sharerEmail(ctx context.Context, resolver Resolver.Resolver, transactionID string) string {
+	transaction, err := resolver.GetTransactionContext(ctx, transactionID)
+	if err != nil {
+		log.Printf("Failed to get transaction %v: %v", transactionID, err)
+		return ""
+	}
+	if transaction.Action == nil || transaction.Action.UserID == nil {
+		return ""
+	}
+	sharer, err := resolver.GetUserContext(ctx, *transaction.Action.UserID)
+	if err != nil {
+		log.Printf("Failed to get sharer %v: %v", *transaction.Action.UserID, err)
+		return ""
+	}
+	if UserController.Unsubscribed(sharer) {
+		log.Printf("Sharer %v unsubscribed, not notifying them", *sharer.ID)
+		return ""
+	}
+	for _, email := range sharer.Emails {
+		if email != nil && *email != "" {
+			return *email
+		}
+	}
+	return ""
+}
+
+func candidateName(candidate *mail.Address) string {
+	if name := strings.TrimSpace(candidate.Name); name != "" {
+		return fmt.Sprintf("%s (%s)", name, candidate.Address)
+	}
+	return candidate.Address
+}
+
+func htmlParagraphs(lines []string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString("<p>" + html.EscapeString(line) + "</p>")
+	}
+	return b.String()
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
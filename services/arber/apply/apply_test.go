@@ -0,0 +1,140 @@
+package apply
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/mail"
+	"testing"
+	"time"
+
+	"github.com/DusanKasan/parsemail"
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
+	"gitlab.com/ncent/arber/api/services/arber/share/sharetest"
+	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
+)
+
+func Test(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Apply", func() {
+		var resolver *Resolver.MemoryResolver
+		var challenge *Resolver.Challenge
+		var root *Resolver.Transaction
+		var forwarded *Resolver.Transaction
+		var sent []clients.EmailRequest
+		var saved []parsemail.Attachment
+		ctx := context.Background()
+
+		candidate := &mail.Address{Name: "Casey Candidate", Address: "Casey@example.com"}
+
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+			sent = nil
+			saved = nil
+			now = func() time.Time { return time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC) }
+			notify = func(request clients.EmailRequest) error {
+				sent = append(sent, request)
+				return nil
+			}
+			saveResume = func(attachments []parsemail.Attachment) ([]*string, error) {
+				saved = append(saved, attachments...)
+				url := "https://attachments.example.com/" + attachments[0].Filename
+				return []*string{&url}, nil
+			}
+
+			var err error
+			challenge, err = ChallengeController.CreateChallenge(ctx, resolver, "Engineer", &mail.Address{Address: "Sponsor@example.com"}, "Acme", "Engineer", "Build things", "")
+			Expect(err).ShouldNot(HaveOccurred())
+			root = sharetest.Share(ctx, resolver, *challenge.ID, "", "sharer@example.com", "friend@example.com")
+			forwarded = sharetest.Share(ctx, resolver, *challenge.ID, *root.ID, "friend@example.com", "casey@example.com")
+		})
+
+		g.After(func() {
+			now = time.Now
+		})
+
+		g.It("Should record the application against the transaction it came through", func() {
+			application, err := Apply(ctx, resolver, Request{
+				TransactionID: *forwarded.ID,
+				Candidate:     candidate,
+				Message:       "I would love to help",
+				Resume:        &parsemail.Attachment{Filename: "resume.pdf", Data: bytes.NewReader([]byte("pdf"))},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*application.ChallengeID).Should(Equal(*challenge.ID))
+			Expect(*application.TransactionID).Should(Equal(*forwarded.ID))
+			Expect(*application.Status).Should(Equal(Submitted))
+			Expect(*application.Message).Should(Equal("I would love to help"))
+			Expect(*application.ResumeURL).Should(Equal("https://attachments.example.com/resume.pdf"))
+			Expect(saved).Should(HaveLen(1))
+
+			// The candidate was already a sparse user from the share.
+			email := "casey@example.com"
+			users, err := resolver.ListUsersByEmails([]*string{&email})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(HaveLen(1))
+			Expect(*application.CandidateID).Should(Equal(*users[0].ID))
+		})
+
+		g.It("Should notify the sponsor and the user who shared the transaction", func() {
+			_, err := Apply(ctx, resolver, Request{TransactionID: *forwarded.ID, Candidate: candidate})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(sent).Should(HaveLen(2))
+			Expect(sent[0].Recipient).Should(Equal("sponsor@example.com"))
+			Expect(sent[0].Subject).Should(Equal("New applicant for Engineer: Casey Candidate (Casey@example.com)"))
+			Expect(sent[1].Recipient).Should(Equal("friend@example.com"))
+			Expect(sent[1].Subject).Should(Equal("Your referral applied to Engineer"))
+		})
+
+		g.It("Should keep the application when an email fails", func() {
+			notify = func(request clients.EmailRequest) error { return errors.New("ses is down") }
+			application, err := Apply(ctx, resolver, Request{TransactionID: *forwarded.ID, Candidate: candidate})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(application.ID).ShouldNot(BeNil())
+		})
+
+		g.It("Should attribute the candidate to the first chain only", func() {
+			first, err := Apply(ctx, resolver, Request{TransactionID: *forwarded.ID, Candidate: candidate})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			again, err := Apply(ctx, resolver, Request{
+				TransactionID: *root.ID,
+				Candidate:     &mail.Address{Address: "casey@example.com"},
+				Resume:        &parsemail.Attachment{Filename: "resume.pdf", Data: bytes.NewReader(nil)},
+			})
+			Expect(errors.Is(err, ErrAlreadyApplied)).Should(BeTrue())
+			Expect(*again.ID).Should(Equal(*first.ID))
+			Expect(*again.TransactionID).Should(Equal(*forwarded.ID))
+			Expect(saved).Should(BeEmpty())
+
+			applications, err := resolver.GetApplicationsByChallenge(*challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(applications).Should(HaveLen(1))
+		})
+
+		g.It("Should refuse applications to a closed challenge", func() {
+			_, err := ChallengeController.Close(ctx, resolver, *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = Apply(ctx, resolver, Request{TransactionID: *forwarded.ID, Candidate: candidate})
+			Expect(errors.Is(err, ChallengeController.ErrNotOpen)).Should(BeTrue())
+			Expect(sent).Should(BeEmpty())
+		})
+
+		g.It("Should reject unknown transactions and missing candidates", func() {
+			_, err := Apply(ctx, resolver, Request{TransactionID: "missing", Candidate: candidate})
+			Expect(errors.Is(err, Resolver.ErrNotFound)).Should(BeTrue())
+
+			_, err = Apply(ctx, resolver, Request{TransactionID: *forwarded.ID})
+			Expect(errors.Is(err, ErrInvalidRequest)).Should(BeTrue())
+		})
+	})
+}
@@ -0,0 +1,286 @@
+package attribution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	FraudController "gitlab.com/ncent/arber/api/services/arber/fraud"
+	ShareController "gitlab.com/ncent/arber/api/services/arber/share"
+)
+
+// Model decides which of the chains that reached a candidate is credited
+// with them.
+type Model string
+
+const (
+	// FirstTouch credits the chain that reached the candidate first.
+	FirstTouch Model = "FIRST_TOUCH"
+	// LastTouch credits the chain that reached the candidate last.
+	LastTouch Model = "LAST_TOUCH"
+	// ShortestChain credits the chain with the fewest hops from the
+	// challenge, the earliest of them on a tie.
+	ShortestChain Model = "SHORTEST_CHAIN"
+	// Split credits every chain that reached the candidate equally.
+	Split Model = "SPLIT"
+)
+
+// DefaultModel is used for challenges that do not choose one.
+const DefaultModel = FirstTouch
+
+var (
+	// ErrUnknownModel is returned by ParseModel for names it does not know.
+	ErrUnknownModel = errors.New("unknown attribution model")
+	// ErrNoTouches is returned when nothing reached the candidate.
+	ErrNoTouches = errors.New("no transaction reached the candidate")
+)
+
+// ParseModel reads a model name case-insensitively. An empty name is the
+// DefaultModel.
+func ParseModel(name string) (Model, error) {
+	switch model := Model(strings.ToUpper(strings.TrimSpace(name))); model {
+	case "":
+		return DefaultModel, nil
+	case FirstTouch, LastTouch, ShortestChain, Split:
+		return model, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownModel, name)
+	}
+}
+
+// ModelFor is the model configured on the challenge. A model the challenge
+// names but this code does not know falls back to the DefaultModel.
+func ModelFor(challenge *appsync.Challenge) Model {
+	if challenge.AttributionModel == nil {
+		return DefaultModel
+	}
+	model, err := ParseModel(*challenge.AttributionModel)
+	if err != nil {
+		log.Printf("Challenge %v: %v, using %s", stringValue(challenge.ID), err, DefaultModel)
+		return DefaultModel
+	}
+	return model
+}
+
+// Touch is one transaction that reached the candidate: ReachedAt is when
+// its share was addressed to them and Hops how many transactions the chain
+// has from the challenge down to it.
+type Touch struct {
+	TransactionID string    `json:"transactionId"`
+	SenderID      string    `json:"senderId,omitempty"`
+	ReachedAt     time.Time `json:"reachedAt"`
+	Hops          int       `json:"hops"`
+	Flagged       bool      `json:"flagged,omitempty"`
+}
+
+// Credit is the part of the candidate credited to one chain.
+type Credit struct {
+	TransactionID string   `json:"transactionId"`
+	Weight        *big.Rat `json:"weight"`
+}
+
+// Attribution is the outcome of a model: the credited chains, the touches
+// they were chosen from and why.
+type Attribution struct {
+	Model       Model    `json:"model"`
+	Credits     []Credit `json:"credits"`
+	Touches     []Touch  `json:"touches"`
+	Explanation string   `json:"explanation"`
+}
+
+// Primary is the transaction with the largest credit, the first of them on a
+// tie.
+func (a *Attribution) Primary() string {
+	var primary *Credit
+	for i := range a.Credits {
+		if primary == nil || a.Credits[i].Weight.Cmp(primary.Weight) > 0 {
+			primary = &a.Credits[i]
+		}
+	}
+	if primary == nil {
+		return ""
+	}
+	return primary.TransactionID
+}
+
+// Weights returns the credited transactions and their weights in order, as
+// the payout splits them.
+func (a *Attribution) Weights() ([]string, []*big.Rat) {
+	var transactionIDs []string
+	var weights []*big.Rat
+	for _, credit := range a.Credits {
+		transactionIDs = append(transactionIDs, credit.TransactionID)
+		weights = append(weights, credit.Weight)
+	}
+	return transactionIDs, weights
+}
+
+// Parse reads an attribution stored as JSON on an application.
+func Parse(data string) (*Attribution, error) {
+	var attribution Attribution
+	if err := json.Unmarshal([]byte(data), &attribution); err != nil {
+		return nil, fmt.Errorf("Failed to read attribution: %w", err)
+	}
+	return &attribution, nil
+}
+
+// Attribute credits the touches under model. Touches flagged for fraud are
+// only credited when nothing else reached the candidate.
+func Attribute(model Model, touches []Touch) (*Attribution, error) {
+	if len(touches) == 0 {
+		return nil, ErrNoTouches
+	}
+	attribution := &Attribution{Model: model, Touches: touches}
+
+	candidates := make([]Touch, 0, len(touches))
+	for _, touch := range touches {
+		if !touch.Flagged {
+			candidates = append(candidates, touch)
+		}
+	}
+	var reasons []string
+	if len(candidates) == 0 {
+		candidates = append(candidates, touches...)
+		reasons = append(reasons, "every chain is flagged for fraud")
+	} else if dropped := len(touches) - len(candidates); dropped > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d flagged chains were not considered", dropped))
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].ReachedAt.Before(candidates[j].ReachedAt)
+	})
+
+	credit := func(touch Touch, why string) {
+		attribution.Credits = []Credit{{TransactionID: touch.TransactionID, Weight: big.NewRat(1, 1)}}
+		reasons = append([]string{why}, reasons...)
+	}
+	switch model {
+	case FirstTouch:
+		first := candidates[0]
+		credit(first, fmt.Sprintf("transaction %v reached the candidate first, on %s, of %d", first.TransactionID, first.ReachedAt.Format(time.RFC3339), len(touches)))
+	case LastTouch:
+		last := candidates[len(candidates)-1]
+		credit(last, fmt.Sprintf("transaction %v reached the candidate last, on %s, of %d", last.TransactionID, last.ReachedAt.Format(time.RFC3339), len(touches)))
+	case ShortestChain:
+		shortest := candidates[0]
+		for _, touch := range candidates[1:] {
+			if touch.Hops < shortest.Hops {
+				shortest = touch
+			}
+		}
+		credit(shortest, fmt.Sprintf("transaction %v has the shortest chain, %d hops, of %d", shortest.TransactionID, shortest.Hops, len(touches)))
+	case Split:
+		for _, touch := range candidates {
+			attribution.Credits = append(attribution.Credits, Credit{TransactionID: touch.TransactionID, Weight: big.NewRat(1, int64(len(candidates)))})
+		}
+		reasons = append([]string{fmt.Sprintf("credit is split evenly between %d chains", len(candidates))}, reasons...)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownModel, model)
+	}
+
+	attribution.Explanation = fmt.Sprintf("%s: %s", model, strings.Join(reasons, "; "))
+	return attribution, nil
+}
+
+// Touches returns every transaction of the challenge whose share was
+// addressed to the candidate, in the order they reached them.
+func Touches(ctx context.Context, resolver Resolver.Resolver, challengeID string, candidateID string) ([]Touch, error) {
+	contacts, err := resolver.GetShareActionContactsByContactContext(ctx, candidateID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get shares addressed to %v: %w", candidateID, err)
+	}
+
+	var touches []Touch
+	seen := map[string]bool{}
+	for _, contact := range contacts {
+		if contact.ShareAction == nil || stringValue(contact.ShareAction.ChallengeID) != challengeID {
+			continue
+		}
+		reachedAt := time.Time{}
+		if contact.CreatedAt != nil {
+			if reachedAt, err = time.Parse(time.RFC3339Nano, *contact.CreatedAt); err != nil {
+				log.Printf("Share action contact %v has an invalid createdAt %q: %v", stringValue(contact.ID), *contact.CreatedAt, err)
+			}
+		}
+
+		transactions, err := resolver.GetTransactionsByShareActionContext(ctx, *contact.ShareAction.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get transactions of share action %v: %w", *contact.ShareAction.ID, err)
+		}
+		for _, transaction := range transactions {
+			if seen[*transaction.ID] {
+				continue
+			}
+			seen[*transaction.ID] = true
+			touch, err := newTouch(ctx, resolver, *transaction.ID, reachedAt)
+			if err != nil {
+				return nil, err
+			}
+			touches = append(touches, touch)
+		}
+	}
+	sort.SliceStable(touches, func(i, j int) bool {
+		return touches[i].ReachedAt.Before(touches[j].ReachedAt)
+	})
+	return touches, nil
+}
+
+func newTouch(ctx context.Context, resolver Resolver.Resolver, transactionID string, reachedAt time.Time) (Touch, error) {
+	ancestry, err := ShareController.GetAncestry(ctx, resolver, transactionID, 0)
+	if err != nil {
+		return Touch{}, err
+	}
+	touch := Touch{
+		TransactionID: transactionID,
+		ReachedAt:     reachedAt,
+		Hops:          len(ancestry),
+		Flagged:       FraudController.Flagged(ancestry[0].Transaction),
+	}
+	if ancestry[0].User != nil {
+		touch.SenderID = stringValue(ancestry[0].User.ID)
+	}
+	return touch, nil
+}
+
+// AttributeCandidate credits the candidate's application to the challenge
+// under the challenge's model. appliedThrough, the transaction of the apply
+// link they used, counts as a touch at reachedAt even when its share was
+// not addressed to them, as when it was forwarded outside of redb.
+func AttributeCandidate(ctx context.Context, resolver Resolver.Resolver, challenge *appsync.Challenge, candidateID string, appliedThrough string, reachedAt time.Time) (*Attribution, error) {
+	touches, err := Touches(ctx, resolver, *challenge.ID, candidateID)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, touch := range touches {
+		found = found || touch.TransactionID == appliedThrough
+	}
+	if !found && appliedThrough != "" {
+		touch, err := newTouch(ctx, resolver, appliedThrough, reachedAt)
+		if err != nil {
+			return nil, err
+		}
+		touches = append(touches, touch)
+	}
+
+	attribution, err := Attribute(ModelFor(challenge), touches)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Attributed candidate %v on challenge %v: %s", candidateID, *challenge.ID, attribution.Explanation)
+	return attribution, nil
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
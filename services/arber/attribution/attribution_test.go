@@ -0,0 +1,162 @@
+package attribution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/mail"
+	"testing"
+	"time"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	"gitlab.com/ncent/arber/api/services/arber/share/sharetest"
+	UserController "gitlab.com/ncent/arber/api/services/arber/user"
+)
+
+func Test(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	start := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	touches := []Touch{
+		{TransactionID: "long", ReachedAt: start, Hops: 3},
+		{TransactionID: "short", ReachedAt: start.Add(time.Hour), Hops: 1},
+		{TransactionID: "late", ReachedAt: start.Add(2 * time.Hour), Hops: 2},
+	}
+
+	g.Describe("Attribute", func() {
+		g.It("Should credit the first touch", func() {
+			attribution, err := Attribute(FirstTouch, touches)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(attribution.Primary()).Should(Equal("long"))
+			Expect(attribution.Credits).Should(HaveLen(1))
+			Expect(attribution.Explanation).Should(HavePrefix("FIRST_TOUCH: transaction long reached the candidate first"))
+		})
+
+		g.It("Should credit the last touch", func() {
+			attribution, err := Attribute(LastTouch, touches)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(attribution.Primary()).Should(Equal("late"))
+		})
+
+		g.It("Should credit the shortest chain", func() {
+			attribution, err := Attribute(ShortestChain, touches)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(attribution.Primary()).Should(Equal("short"))
+			Expect(attribution.Explanation).Should(ContainSubstring("1 hops"))
+		})
+
+		g.It("Should split credit evenly", func() {
+			attribution, err := Attribute(Split, touches)
+			Expect(err).ShouldNot(HaveOccurred())
+			transactionIDs, weights := attribution.Weights()
+			Expect(transactionIDs).Should(Equal([]string{"long", "short", "late"}))
+			for _, weight := range weights {
+				Expect(weight.Cmp(big.NewRat(1, 3))).Should(Equal(0))
+			}
+			Expect(attribution.Primary()).Should(Equal("long"))
+		})
+
+		g.It("Should pass over flagged chains unless nothing else is left", func() {
+			flagged := append([]Touch{}, touches...)
+			flagged[0].Flagged = true
+			attribution, err := Attribute(FirstTouch, flagged)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(attribution.Primary()).Should(Equal("short"))
+			Expect(attribution.Explanation).Should(ContainSubstring("1 flagged chains were not considered"))
+
+			attribution, err = Attribute(FirstTouch, []Touch{flagged[0]})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(attribution.Primary()).Should(Equal("long"))
+			Expect(attribution.Explanation).Should(ContainSubstring("every chain is flagged"))
+		})
+
+		g.It("Should refuse unknown models and missing touches", func() {
+			_, err := Attribute(Model("BIGGEST_BONUS"), touches)
+			Expect(errors.Is(err, ErrUnknownModel)).Should(BeTrue())
+			_, err = Attribute(FirstTouch, nil)
+			Expect(errors.Is(err, ErrNoTouches)).Should(BeTrue())
+		})
+
+		g.It("Should survive being stored as JSON", func() {
+			attribution, err := Attribute(Split, touches[:2])
+			Expect(err).ShouldNot(HaveOccurred())
+			data, err := json.Marshal(attribution)
+			Expect(err).ShouldNot(HaveOccurred())
+			parsed, err := Parse(string(data))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(parsed.Model).Should(Equal(Split))
+			_, weights := parsed.Weights()
+			Expect(weights[1].Cmp(big.NewRat(1, 2))).Should(Equal(0))
+		})
+	})
+
+	g.Describe("ParseModel", func() {
+		g.It("Should read model names and default the rest", func() {
+			Expect(ParseModel(" last_touch ")).Should(Equal(LastTouch))
+			Expect(ParseModel("")).Should(Equal(DefaultModel))
+			_, err := ParseModel("most likes")
+			Expect(errors.Is(err, ErrUnknownModel)).Should(BeTrue())
+
+			unknown := "most likes"
+			Expect(ModelFor(&Resolver.Challenge{AttributionModel: &unknown})).Should(Equal(DefaultModel))
+			Expect(ModelFor(&Resolver.Challenge{})).Should(Equal(DefaultModel))
+		})
+	})
+
+	g.Describe("AttributeCandidate", func() {
+		var resolver *Resolver.MemoryResolver
+		var challenge *Resolver.Challenge
+		var candidate *Resolver.User
+		ctx := context.Background()
+
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+			var err error
+			challenge, err = resolver.CreateChallenge(Resolver.CreateChallengeInput{})
+			Expect(err).ShouldNot(HaveOccurred())
+			candidate, err = UserController.CreateSparseUser(ctx, resolver, &mail.Address{Address: "carol@example.com"})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		g.It("Should find every chain that reached the candidate", func() {
+			root := *sharetest.Share(ctx, resolver, *challenge.ID, "", "alice@example.com", "bob@example.com").ID
+			long := *sharetest.Share(ctx, resolver, *challenge.ID, root, "bob@example.com", "carol@example.com").ID
+			short := *sharetest.Share(ctx, resolver, *challenge.ID, "", "dave@example.com", "carol@example.com").ID
+			sharetest.Share(ctx, resolver, *challenge.ID, "", "erin@example.com", "frank@example.com")
+
+			found, err := Touches(ctx, resolver, *challenge.ID, *candidate.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(found).Should(HaveLen(2))
+			Expect(found[0].TransactionID).Should(Equal(long))
+			Expect(found[0].Hops).Should(Equal(2))
+			Expect(found[1].TransactionID).Should(Equal(short))
+			Expect(found[1].Hops).Should(Equal(1))
+
+			attribution, err := AttributeCandidate(ctx, resolver, challenge, *candidate.ID, short, time.Now())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(attribution.Primary()).Should(Equal(long))
+
+			model := string(ShortestChain)
+			challenge, err = resolver.UpdateChallenge(Resolver.UpdateChallengeInput{ID: *challenge.ID, AttributionModel: &model})
+			Expect(err).ShouldNot(HaveOccurred())
+			attribution, err = AttributeCandidate(ctx, resolver, challenge, *candidate.ID, long, time.Now())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(attribution.Primary()).Should(Equal(short))
+		})
+
+		g.It("Should count the link the candidate applied through", func() {
+			forwarded := *sharetest.Share(ctx, resolver, *challenge.ID, "", "alice@example.com", "bob@example.com").ID
+
+			attribution, err := AttributeCandidate(ctx, resolver, challenge, *candidate.ID, forwarded, time.Now())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(attribution.Primary()).Should(Equal(forwarded))
+			Expect(attribution.Touches).Should(HaveLen(1))
+		})
+	})
+}
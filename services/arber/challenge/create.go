@@ -1,37 +1,49 @@
 package user
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/mail"
+	"strings"
 
+	"github.com/dchest/uniuri"
 	"gitlab.com/ncent/arber/api/services/appsync"
 	Resolver "gitlab.com/ncent/arber/api/services/appsync"
 )
 
-func CreateChallenge(resolver Resolver.Resolver, subject string, from *mail.Address, sponsor string, name string, description string, attachmentURL string) (*appsync.Challenge, error) {
+func CreateChallenge(ctx context.Context, resolver Resolver.Resolver, subject string, from *mail.Address, sponsor string, name string, description string, attachmentURL string) (*appsync.Challenge, error) {
 	log.Printf("Creating a challenge")
-	challenge, err := resolver.CreateChallenge(
-		appsync.CreateChallenge{
-			Name:          &name,
-			Description:   &description,
-			SponsorName:   &sponsor,
-			AttachmentURL: &attachmentURL,
-		},
-	)
+	input := appsync.CreateChallengeInput{
+		Name:          &name,
+		Description:   &description,
+		SponsorName:   &sponsor,
+		AttachmentURL: &attachmentURL,
+	}
+	// The sponsor is whoever mailed the challenge in; applications are
+	// sent to them, and the token lets them confirm the hire.
+	sponsorToken := uniuri.NewLen(32)
+	input.SponsorToken = &sponsorToken
+	if from != nil && from.Address != "" {
+		sponsorEmail := strings.ToLower(from.Address)
+		input.SponsorEmail = &sponsorEmail
+	}
+	challenge, err := resolver.CreateChallengeContext(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create challenge: %v", err)
+		return nil, fmt.Errorf("Failed to create challenge: %w", err)
 	}
 	log.Printf("Created challenge: %+v", challenge)
+	// Challenge reads leave the token out; the sponsor gets it from here.
+	challenge.SponsorToken = &sponsorToken
 
 	return challenge, nil
 }
 
-func GetChallenge(resolver Resolver.Resolver, id string) (*appsync.Challenge, error) {
+func GetChallenge(ctx context.Context, resolver Resolver.Resolver, id string) (*appsync.Challenge, error) {
 	log.Printf("Get a challenge")
-	challenge, err := resolver.GetChallenge(id)
+	challenge, err := resolver.GetChallengeContext(ctx, id)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to get challenge: %v", err)
+		return nil, fmt.Errorf("Failed to get challenge: %w", err)
 	}
 	log.Printf("Get challenge: %+v", challenge)
 
@@ -0,0 +1,220 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/looplab/fsm"
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+)
+
+// Status is where a challenge is in its lifecycle. Only ACTIVE challenges
+// accept shares; CLOSED means the role was filled.
+type Status string
+
+const (
+	Active  Status = "ACTIVE"
+	Paused  Status = "PAUSED"
+	Closed  Status = "CLOSED"
+	Expired Status = "EXPIRED"
+)
+
+var (
+	// ErrNotOpen is wrapped by every ClosedError.
+	ErrNotOpen = errors.New("challenge is not accepting shares")
+	// ErrIllegalTransition is returned for a lifecycle operation the
+	// challenge's status does not allow, such as activating a closed one.
+	ErrIllegalTransition = errors.New("illegal challenge transition")
+)
+
+// ClosedError refuses a share of a challenge that is not ACTIVE, with a
+// reason fit to show the sender.
+type ClosedError struct {
+	ChallengeID string
+	Status      Status
+	Reason      string
+}
+
+func (e *ClosedError) Error() string {
+	return fmt.Sprintf("challenge %v is %s: %s", e.ChallengeID, e.Status, e.Reason)
+}
+
+func (e *ClosedError) Unwrap() error {
+	return ErrNotOpen
+}
+
+// now is replaced in tests.
+var now = time.Now
+
+// The lifecycle events. CLOSED is final; an EXPIRED challenge can still be
+// closed once the role is filled.
+func lifecycleEvents() fsm.Events {
+	return fsm.Events{
+		{Name: "activate", Src: []string{string(Paused)}, Dst: string(Active)},
+		{Name: "pause", Src: []string{string(Active)}, Dst: string(Paused)},
+		{Name: "close", Src: []string{string(Active), string(Paused), string(Expired)}, Dst: string(Closed)},
+		{Name: "expire", Src: []string{string(Active), string(Paused)}, Dst: string(Expired)},
+	}
+}
+
+// StoredStatus is the status written on the challenge. Challenges from before
+// statuses were written count as ACTIVE unless Active was switched off.
+func StoredStatus(challenge *appsync.Challenge) Status {
+	if challenge.Status != nil && *challenge.Status != "" {
+		return Status(*challenge.Status)
+	}
+	if challenge.Active != nil && !*challenge.Active {
+		return Paused
+	}
+	return Active
+}
+
+// StatusAt is the status of the challenge at t. A challenge whose expiration
+// has passed is EXPIRED even before the sweeper has written it.
+func StatusAt(challenge *appsync.Challenge, t time.Time) Status {
+	status := StoredStatus(challenge)
+	if (status == Active || status == Paused) && expired(challenge, t) {
+		return Expired
+	}
+	return status
+}
+
+func expired(challenge *appsync.Challenge, t time.Time) bool {
+	if challenge.Expiration == nil || *challenge.Expiration == "" {
+		return false
+	}
+	expiration, err := time.Parse(time.RFC3339, *challenge.Expiration)
+	if err != nil {
+		log.Printf("Challenge %v has an invalid Expiration %q: %v", stringValue(challenge.ID), *challenge.Expiration, err)
+		return false
+	}
+	return !t.Before(expiration)
+}
+
+// CheckOpen returns a ClosedError unless the challenge accepts shares at t.
+func CheckOpen(challenge *appsync.Challenge, t time.Time) error {
+	status := StatusAt(challenge, t)
+	name := stringValue(challenge.Name)
+	if name == "" {
+		name = "This role"
+	}
+
+	var reason string
+	switch status {
+	case Active:
+		return nil
+	case Closed:
+		reason = fmt.Sprintf("%s has been filled", name)
+	case Expired:
+		reason = fmt.Sprintf("%s is closed", name)
+		if challenge.Expiration != nil {
+			if expiration, err := time.Parse(time.RFC3339, *challenge.Expiration); err == nil {
+				reason = fmt.Sprintf("%s closed on %s", name, expiration.Format("January 2, 2006"))
+			}
+		}
+	case Paused:
+		reason = fmt.Sprintf("%s is paused and not taking new referrals right now", name)
+	default:
+		reason = fmt.Sprintf("%s is not taking new referrals", name)
+	}
+
+	err := &ClosedError{ChallengeID: stringValue(challenge.ID), Status: status, Reason: reason}
+	log.Printf("Share refused: %v", err)
+	return err
+}
+
+// Activate reopens a paused challenge for shares.
+func Activate(ctx context.Context, resolver Resolver.Resolver, id string) (*appsync.Challenge, error) {
+	return transition(ctx, resolver, id, "activate")
+}
+
+// Pause stops new shares of an active challenge until it is activated again.
+func Pause(ctx context.Context, resolver Resolver.Resolver, id string) (*appsync.Challenge, error) {
+	return transition(ctx, resolver, id, "pause")
+}
+
+// Close marks the role filled. It is final.
+func Close(ctx context.Context, resolver Resolver.Resolver, id string) (*appsync.Challenge, error) {
+	return transition(ctx, resolver, id, "close")
+}
+
+// Expire closes a challenge for shares because its time ran out.
+func Expire(ctx context.Context, resolver Resolver.Resolver, id string) (*appsync.Challenge, error) {
+	return transition(ctx, resolver, id, "expire")
+}
+
+func transition(ctx context.Context, resolver Resolver.Resolver, id string, event string) (*appsync.Challenge, error) {
+	challenge, err := GetChallenge(ctx, resolver, id)
+	if err != nil {
+		return nil, err
+	}
+	from := StoredStatus(challenge)
+	if event == "activate" && expired(challenge, now()) {
+		return nil, fmt.Errorf("%w: cannot activate challenge %v after its expiration", ErrIllegalTransition, id)
+	}
+
+	machine := fsm.NewFSM(string(from), lifecycleEvents(), nil)
+	if err := machine.Event(event); err != nil {
+		return nil, fmt.Errorf("%w: cannot %s challenge %v while it is %s: %v", ErrIllegalTransition, event, id, from, err)
+	}
+
+	// The write only lands while the stored status is still the one read
+	// above, so of two racing transitions only the first moves it.
+	status := machine.Current()
+	active := Status(status) == Active
+	expectedStatus := stringValue(challenge.Status)
+	input := appsync.UpdateChallengeInput{ID: id, Status: &status, Active: &active, ExpectedStatus: &expectedStatus}
+	if Status(status) == Closed || Status(status) == Expired {
+		closedAt := now().UTC().Format(time.RFC3339)
+		input.ClosedAt = &closedAt
+	}
+	updated, err := resolver.UpdateChallengeContext(ctx, input)
+	if errors.Is(err, appsync.ErrConditionalCheckFailed) {
+		return nil, fmt.Errorf("%w: challenge %v changed from %s before it could %s: %v", ErrIllegalTransition, id, from, event, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to %s challenge %v: %w", event, id, err)
+	}
+	log.Printf("Challenge %v moved from %s to %s", id, from, status)
+	return updated, nil
+}
+
+// SweepExpired expires every active or paused challenge whose expiration is
+// before at and returns the ones it expired. A challenge that fails to update
+// does not stop the sweep; the first failure is returned with the rest.
+func SweepExpired(ctx context.Context, resolver Resolver.Resolver, at time.Time) ([]*appsync.Challenge, error) {
+	challenges, err := resolver.GetChallengesExpiringBeforeContext(ctx, at.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list expiring challenges: %w", err)
+	}
+
+	var swept []*appsync.Challenge
+	var firstErr error
+	for _, challenge := range challenges {
+		if status := StoredStatus(challenge); status != Active && status != Paused {
+			continue
+		}
+		expiredChallenge, err := Expire(ctx, resolver, *challenge.ID)
+		if err != nil {
+			log.Printf("Failed to expire challenge %v: %v", *challenge.ID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		swept = append(swept, expiredChallenge)
+	}
+	log.Printf("Expired %d of %d challenges past their expiration", len(swept), len(challenges))
+	return swept, firstErr
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,126 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+)
+
+func TestLifecycle(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Challenge lifecycle", func() {
+		var resolver *Resolver.MemoryResolver
+		var challenge *Resolver.Challenge
+		ctx := context.Background()
+		today := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+			now = func() time.Time { return today }
+			name := "Engineer"
+			var err error
+			challenge, err = resolver.CreateChallenge(Resolver.CreateChallengeInput{Name: &name})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		g.After(func() {
+			now = time.Now
+		})
+
+		g.It("Should treat a challenge without a status as active", func() {
+			Expect(StoredStatus(challenge)).Should(Equal(Active))
+			Expect(CheckOpen(challenge, today)).Should(Succeed())
+
+			inactive := false
+			challenge.Active = &inactive
+			Expect(StoredStatus(challenge)).Should(Equal(Paused))
+		})
+
+		g.It("Should pause and activate again", func() {
+			paused, err := Pause(ctx, resolver, *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*paused.Status).Should(Equal("PAUSED"))
+			Expect(*paused.Active).Should(BeFalse())
+
+			err = CheckOpen(paused, today)
+			Expect(errors.Is(err, ErrNotOpen)).Should(BeTrue())
+			Expect(err.(*ClosedError).Reason).Should(ContainSubstring("paused"))
+
+			active, err := Activate(ctx, resolver, *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*active.Status).Should(Equal("ACTIVE"))
+			Expect(*active.Active).Should(BeTrue())
+		})
+
+		g.It("Should close for good", func() {
+			closed, err := Close(ctx, resolver, *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*closed.Status).Should(Equal("CLOSED"))
+			Expect(*closed.ClosedAt).Should(Equal("2020-03-01T12:00:00Z"))
+
+			var closedErr *ClosedError
+			Expect(errors.As(CheckOpen(closed, today), &closedErr)).Should(BeTrue())
+			Expect(closedErr.Reason).Should(Equal("Engineer has been filled"))
+
+			_, err = Activate(ctx, resolver, *challenge.ID)
+			Expect(errors.Is(err, ErrIllegalTransition)).Should(BeTrue())
+			_, err = Expire(ctx, resolver, *challenge.ID)
+			Expect(errors.Is(err, ErrIllegalTransition)).Should(BeTrue())
+		})
+
+		g.It("Should refuse shares once the expiration has passed, before any sweep", func() {
+			expiration := "2020-02-15T00:00:00Z"
+			challenge.Expiration = &expiration
+			var closedErr *ClosedError
+			Expect(errors.As(CheckOpen(challenge, today), &closedErr)).Should(BeTrue())
+			Expect(closedErr.Status).Should(Equal(Expired))
+			Expect(closedErr.Reason).Should(Equal("Engineer closed on February 15, 2020"))
+		})
+
+		g.It("Should not activate a challenge past its expiration", func() {
+			expiration := "2020-02-15T00:00:00Z"
+			_, err := resolver.UpdateChallenge(Resolver.UpdateChallengeInput{ID: *challenge.ID, Expiration: &expiration})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = Pause(ctx, resolver, *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = Activate(ctx, resolver, *challenge.ID)
+			Expect(errors.Is(err, ErrIllegalTransition)).Should(BeTrue())
+		})
+
+		g.It("Should sweep open challenges past their expiration", func() {
+			past := "2020-02-15T00:00:00Z"
+			future := "2020-04-01T00:00:00Z"
+			_, err := resolver.UpdateChallenge(Resolver.UpdateChallengeInput{ID: *challenge.ID, Expiration: &past})
+			Expect(err).ShouldNot(HaveOccurred())
+			later, err := resolver.CreateChallenge(Resolver.CreateChallengeInput{Expiration: &future})
+			Expect(err).ShouldNot(HaveOccurred())
+			filled, err := resolver.CreateChallenge(Resolver.CreateChallengeInput{Expiration: &past})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = Close(ctx, resolver, *filled.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			swept, err := SweepExpired(ctx, resolver, today)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(swept).Should(HaveLen(1))
+			Expect(*swept[0].ID).Should(Equal(*challenge.ID))
+			Expect(*swept[0].Status).Should(Equal("EXPIRED"))
+
+			stored, err := resolver.GetChallenge(*later.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.Status).Should(BeNil())
+			stored, err = resolver.GetChallenge(*filled.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*stored.Status).Should(Equal("CLOSED"))
+		})
+	})
+}
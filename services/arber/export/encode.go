@@ -0,0 +1,251 @@
+package export
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownFormat is returned by ParseFormat for formats it cannot write.
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Format is a serialization of a Tree.
+type Format string
+
+const (
+	// JSON nests children inside their parent, for the web client.
+	JSON Format = "json"
+	// DOT is a Graphviz digraph, for quick diagrams.
+	DOT Format = "dot"
+	// GraphML is a flat node and edge list with typed attributes, for
+	// analysis tools.
+	GraphML Format = "graphml"
+)
+
+// ParseFormat reads a format name case-insensitively. An empty name is JSON.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case "":
+		return JSON, nil
+	case JSON, DOT, GraphML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+	}
+}
+
+// ContentType is the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case DOT:
+		return "text/vnd.graphviz"
+	case GraphML:
+		return "application/graphml+xml"
+	default:
+		return "application/json"
+	}
+}
+
+// Write serializes tree to w in format.
+func Write(w io.Writer, tree *Tree, format Format) error {
+	switch format {
+	case JSON:
+		return WriteJSON(w, tree)
+	case DOT:
+		return WriteDOT(w, tree)
+	case GraphML:
+		return WriteGraphML(w, tree)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+func WriteJSON(w io.Writer, tree *Tree) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(tree)
+}
+
+// The challenge is the source of every root; transactions and recipients are
+// told apart by the prefix of their node IDs.
+const challengeNodeID = "challenge"
+
+func transactionNodeID(node *Node) string {
+	return "transaction:" + node.TransactionID
+}
+
+func recipientNodeID(recipient Recipient) string {
+	return "contact:" + recipient.ContactID
+}
+
+// WriteDOT draws transactions as ellipses labelled with the sender, depth and
+// status, and recipients as boxes on dashed edges.
+func WriteDOT(w io.Writer, tree *Tree) error {
+	var b strings.Builder
+	b.WriteString("digraph referrals {\n")
+	fmt.Fprintf(&b, "  label=%s;\n", dotQuote(tree.ChallengeName))
+	fmt.Fprintf(&b, "  %s [shape=doublecircle, label=%s];\n", dotQuote(challengeNodeID), dotQuote(tree.ChallengeName))
+
+	tree.Walk(func(parent *Node, node *Node) {
+		label := []string{node.UserName, "depth " + strconv.Itoa(node.Depth)}
+		if node.Status != "" {
+			label = append(label, node.Status)
+		}
+		if node.CreatedAt != "" {
+			label = append(label, node.CreatedAt)
+		}
+		fmt.Fprintf(&b, "  %s [label=%s];\n", dotQuote(transactionNodeID(node)), dotQuote(strings.Join(label, "\n")))
+
+		source := challengeNodeID
+		if parent != nil {
+			source = transactionNodeID(parent)
+		}
+		fmt.Fprintf(&b, "  %s -> %s;\n", dotQuote(source), dotQuote(transactionNodeID(node)))
+
+		for _, recipient := range node.Recipients {
+			fmt.Fprintf(&b, "  %s [shape=box, label=%s];\n", dotQuote(recipientNodeID(recipient)), dotQuote(recipient.UserName))
+			fmt.Fprintf(&b, "  %s -> %s [style=dashed];\n", dotQuote(transactionNodeID(node)), dotQuote(recipientNodeID(recipient)))
+		}
+	})
+
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotQuote(value string) string {
+	return `"` + dotEscaper.Replace(value) + `"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+var graphMLKeys = []graphMLKey{
+	{ID: "kind", For: "node", AttrName: "kind", AttrType: "string"},
+	{ID: "name", For: "node", AttrName: "name", AttrType: "string"},
+	{ID: "userId", For: "node", AttrName: "userId", AttrType: "string"},
+	{ID: "shareActionId", For: "node", AttrName: "shareActionId", AttrType: "string"},
+	{ID: "depth", For: "node", AttrName: "depth", AttrType: "int"},
+	{ID: "status", For: "node", AttrName: "status", AttrType: "string"},
+	{ID: "createdAt", For: "node", AttrName: "createdAt", AttrType: "string"},
+	{ID: "relation", For: "edge", AttrName: "relation", AttrType: "string"},
+}
+
+// data drops empty attributes, which GraphML readers treat as missing.
+func data(pairs ...string) []graphMLData {
+	var result []graphMLData
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			result = append(result, graphMLData{Key: pairs[i], Value: pairs[i+1]})
+		}
+	}
+	return result
+}
+
+// WriteGraphML lists the challenge, every transaction and every recipient as
+// nodes, with "shared" edges between transactions and "addressed" edges to
+// recipients.
+func WriteGraphML(w io.Writer, tree *Tree) error {
+	graph := graphMLGraph{ID: tree.ChallengeID, EdgeDefault: "directed"}
+	graph.Nodes = append(graph.Nodes, graphMLNode{
+		ID:   challengeNodeID,
+		Data: data("kind", "challenge", "name", tree.ChallengeName),
+	})
+
+	tree.Walk(func(parent *Node, node *Node) {
+		graph.Nodes = append(graph.Nodes, graphMLNode{
+			ID: transactionNodeID(node),
+			Data: data(
+				"kind", "transaction",
+				"name", node.UserName,
+				"userId", node.UserID,
+				"shareActionId", node.ShareActionID,
+				"depth", strconv.Itoa(node.Depth),
+				"status", node.Status,
+				"createdAt", node.CreatedAt,
+			),
+		})
+		source := challengeNodeID
+		if parent != nil {
+			source = transactionNodeID(parent)
+		}
+		graph.Edges = append(graph.Edges, graphMLEdge{
+			Source: source,
+			Target: transactionNodeID(node),
+			Data:   data("relation", "shared"),
+		})
+
+		for _, recipient := range node.Recipients {
+			graph.Nodes = append(graph.Nodes, graphMLNode{
+				ID: recipientNodeID(recipient),
+				Data: data(
+					"kind", "recipient",
+					"name", recipient.UserName,
+					"userId", recipient.UserID,
+					"createdAt", recipient.CreatedAt,
+				),
+			})
+			graph.Edges = append(graph.Edges, graphMLEdge{
+				Source: transactionNodeID(node),
+				Target: recipientNodeID(recipient),
+				Data:   data("relation", "addressed"),
+			})
+		}
+	})
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err := encoder.Encode(graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  graphMLKeys,
+		Graph: graph,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
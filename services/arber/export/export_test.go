@@ -0,0 +1,137 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	"gitlab.com/ncent/arber/api/services/arber/share/sharetest"
+)
+
+func Test(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Referral tree export", func() {
+		var resolver *Resolver.MemoryResolver
+		var challenge *Resolver.Challenge
+		var tree *Tree
+		ctx := context.Background()
+
+		// The tree is root -> first -> second, plus a second root; another
+		// challenge's share must not show up.
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+			name := `Engineer "Backend"`
+			var err error
+			challenge, err = resolver.CreateChallenge(Resolver.CreateChallengeInput{Name: &name})
+			Expect(err).ShouldNot(HaveOccurred())
+			other, err := resolver.CreateChallenge(Resolver.CreateChallengeInput{})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			root := *sharetest.Share(ctx, resolver, *challenge.ID, "", "root@example.com", "first@example.com").ID
+			first := *sharetest.Share(ctx, resolver, *challenge.ID, root, "first@example.com", "second@example.com").ID
+			sharetest.Share(ctx, resolver, *challenge.ID, first, "second@example.com")
+			sharetest.Share(ctx, resolver, *challenge.ID, "", "other-root@example.com")
+			sharetest.Share(ctx, resolver, *other.ID, "", "elsewhere@example.com")
+
+			tree, err = BuildTree(ctx, resolver, *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		g.It("Should build every root with its children", func() {
+			Expect(tree.ChallengeName).Should(Equal(`Engineer "Backend"`))
+			Expect(tree.Roots).Should(HaveLen(2))
+			Expect(tree.Size()).Should(Equal(4))
+
+			root := tree.Roots[0]
+			Expect(root.UserName).Should(Equal(root.UserID))
+			Expect(root.Depth).Should(Equal(0))
+			Expect(root.Status).Should(Equal("CREATED"))
+			Expect(root.Recipients).Should(HaveLen(1))
+			Expect(root.Recipients[0].UserID).Should(Equal(root.Children[0].UserID))
+
+			second := root.Children[0].Children[0]
+			Expect(second.UserName).Should(Equal(second.UserID))
+			Expect(second.Depth).Should(Equal(2))
+			Expect(tree.Roots[1].UserID).ShouldNot(Equal(root.UserID))
+		})
+
+		g.It("Should name users by their names and never by their emails", func() {
+			name := "Root"
+			_, err := resolver.UpdateUser(Resolver.UpdateUserInput{ID: tree.Roots[0].UserID, Names: []*string{&name}})
+			Expect(err).ShouldNot(HaveOccurred())
+			tree, err = BuildTree(ctx, resolver, *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tree.Roots[0].UserName).Should(Equal("Root"))
+
+			for _, format := range []Format{JSON, DOT, GraphML} {
+				var b bytes.Buffer
+				Expect(Write(&b, tree, format)).Should(Succeed())
+				Expect(b.String()).ShouldNot(ContainSubstring("@example.com"))
+			}
+		})
+
+		g.It("Should fail for an unknown challenge", func() {
+			_, err := BuildTree(ctx, resolver, "missing")
+			Expect(errors.Is(err, Resolver.ErrNotFound)).Should(BeTrue())
+		})
+
+		g.It("Should write nested JSON", func() {
+			var b bytes.Buffer
+			Expect(Write(&b, tree, JSON)).Should(Succeed())
+
+			var decoded Tree
+			Expect(json.Unmarshal(b.Bytes(), &decoded)).Should(Succeed())
+			Expect(decoded.Roots[0].Children[0].UserName).Should(Equal(tree.Roots[0].Children[0].UserID))
+		})
+
+		g.It("Should write a DOT digraph with escaped labels", func() {
+			var b bytes.Buffer
+			Expect(Write(&b, tree, DOT)).Should(Succeed())
+
+			dot := b.String()
+			Expect(dot).Should(HavePrefix("digraph referrals {\n"))
+			Expect(dot).Should(ContainSubstring(`label="Engineer \"Backend\""`))
+			Expect(dot).Should(ContainSubstring(`"transaction:` + tree.Roots[0].TransactionID + `" -> "transaction:` + tree.Roots[0].Children[0].TransactionID + `";`))
+			Expect(dot).Should(ContainSubstring(`[label="` + tree.Roots[0].Children[0].Children[0].UserID + `\ndepth 2\nCREATED"]`))
+			Expect(strings.Count(dot, "[style=dashed]")).Should(Equal(2))
+		})
+
+		g.It("Should write GraphML nodes and edges", func() {
+			var b bytes.Buffer
+			Expect(Write(&b, tree, GraphML)).Should(Succeed())
+
+			var decoded graphML
+			Expect(xml.Unmarshal(b.Bytes(), &decoded)).Should(Succeed())
+			// The challenge, four transactions and two recipients.
+			Expect(decoded.Graph.Nodes).Should(HaveLen(7))
+			Expect(decoded.Graph.Edges).Should(HaveLen(6))
+			Expect(decoded.Graph.Nodes[1].Data).Should(ContainElement(graphMLData{Key: "depth", Value: "0"}))
+		})
+	})
+
+	g.Describe("ParseFormat", func() {
+		g.It("Should default to JSON and reject unknown formats", func() {
+			format, err := ParseFormat("")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(format).Should(Equal(JSON))
+
+			format, err = ParseFormat("GraphML")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(format.ContentType()).Should(Equal("application/graphml+xml"))
+
+			_, err = ParseFormat("png")
+			Expect(errors.Is(err, ErrUnknownFormat)).Should(BeTrue())
+		})
+	})
+}
@@ -0,0 +1,160 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ShareController "gitlab.com/ncent/arber/api/services/arber/share"
+)
+
+// Tree is the referral tree of one challenge. Every root is a transaction
+// shared straight from the challenge; the rest hang below the transaction
+// they were forwarded from.
+type Tree struct {
+	ChallengeID   string  `json:"challengeId"`
+	ChallengeName string  `json:"challengeName,omitempty"`
+	Roots         []*Node `json:"roots"`
+}
+
+// Node is one transaction with its share action, the user who shared it and
+// the people it was addressed to. Depth is 0 at the roots.
+type Node struct {
+	TransactionID string      `json:"transactionId"`
+	ShareActionID string      `json:"shareActionId,omitempty"`
+	UserID        string      `json:"userId,omitempty"`
+	UserName      string      `json:"userName,omitempty"`
+	Depth         int         `json:"depth"`
+	Status        string      `json:"status,omitempty"`
+	CreatedAt     string      `json:"createdAt,omitempty"`
+	Recipients    []Recipient `json:"recipients,omitempty"`
+	Children      []*Node     `json:"children,omitempty"`
+}
+
+// Recipient is a share action contact: someone a share was addressed to.
+type Recipient struct {
+	ContactID string `json:"contactId"`
+	UserID    string `json:"userId,omitempty"`
+	UserName  string `json:"userName,omitempty"`
+	CreatedAt string `json:"createdAt,omitempty"`
+}
+
+// Size returns the number of transactions in the tree.
+func (t *Tree) Size() int {
+	size := 0
+	t.Walk(func(parent *Node, node *Node) { size++ })
+	return size
+}
+
+// Walk calls visit for every node of the tree, parents before children.
+// parent is nil for the roots.
+func (t *Tree) Walk(visit func(parent *Node, node *Node)) {
+	var walk func(parent *Node, nodes []*Node)
+	walk = func(parent *Node, nodes []*Node) {
+		for _, node := range nodes {
+			visit(parent, node)
+			walk(node, node.Children)
+		}
+	}
+	walk(nil, t.Roots)
+}
+
+// BuildTree reads every share action of the challenge, starts a root at each
+// transaction without a parent and follows the children down from there.
+func BuildTree(ctx context.Context, resolver Resolver.Resolver, challengeID string) (*Tree, error) {
+	challenge, err := resolver.GetChallengeContext(ctx, challengeID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get challenge: %w", err)
+	}
+	tree := &Tree{ChallengeID: challengeID, ChallengeName: stringValue(challenge.Name)}
+
+	shareActions, err := resolver.GetShareActionsByChallengeContext(ctx, challengeID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get share actions of challenge %v: %w", challengeID, err)
+	}
+	for _, shareAction := range shareActions {
+		transactions, err := resolver.GetTransactionsByShareActionContext(ctx, *shareAction.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get transactions of share action %v: %w", *shareAction.ID, err)
+		}
+		for _, transaction := range transactions {
+			if transaction.ParentTransactionID != nil && *transaction.ParentTransactionID != "" {
+				continue
+			}
+			subtree, err := ShareController.GetSubtree(ctx, resolver, *transaction.ID, 0)
+			if err != nil {
+				return nil, err
+			}
+			root, err := newNode(ctx, resolver, subtree)
+			if err != nil {
+				return nil, err
+			}
+			tree.Roots = append(tree.Roots, root)
+		}
+	}
+
+	log.Printf("Referral tree of challenge %v has %d transactions", challengeID, tree.Size())
+	return tree, nil
+}
+
+func newNode(ctx context.Context, resolver Resolver.Resolver, subtree *ShareController.Node) (*Node, error) {
+	node := &Node{
+		TransactionID: stringValue(subtree.Transaction.ID),
+		Depth:         subtree.Depth,
+		Status:        stringValue(subtree.Transaction.Status),
+		CreatedAt:     stringValue(subtree.Transaction.CreatedAt),
+	}
+	if subtree.User != nil {
+		node.UserID = stringValue(subtree.User.ID)
+		node.UserName = displayName(subtree.User)
+	}
+
+	if subtree.ShareAction != nil && subtree.ShareAction.ID != nil {
+		node.ShareActionID = *subtree.ShareAction.ID
+		contacts, err := resolver.GetShareActionContactsByShareActionContext(ctx, node.ShareActionID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get contacts of share action %v: %w", node.ShareActionID, err)
+		}
+		for _, contact := range contacts {
+			recipient := Recipient{
+				ContactID: stringValue(contact.ID),
+				CreatedAt: stringValue(contact.CreatedAt),
+			}
+			if contact.Contact != nil {
+				recipient.UserID = stringValue(contact.Contact.ID)
+				recipient.UserName = displayName(contact.Contact)
+			}
+			node.Recipients = append(node.Recipients, recipient)
+		}
+	}
+
+	for _, child := range subtree.Children {
+		childNode, err := newNode(ctx, resolver, child)
+		if err != nil {
+			return nil, err
+		}
+		node.Children = append(node.Children, childNode)
+	}
+	return node, nil
+}
+
+// displayName is the first name of the user, or the user ID for sparse users
+// created from an address only. The export never carries email addresses.
+func displayName(user *appsync.User) string {
+	for _, name := range user.Names {
+		if name != nil && strings.TrimSpace(*name) != "" {
+			return strings.TrimSpace(*name)
+		}
+	}
+	return stringValue(user.ID)
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
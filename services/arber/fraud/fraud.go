@@ -0,0 +1,280 @@
+package fraud
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	AddressController "gitlab.com/ncent/arber/api/services/arber/address"
+)
+
+// Kind is the sort of abuse a Finding points at.
+type Kind string
+
+const (
+	// SelfReferral is a user being credited for referring themselves into
+	// the hire. Sharing with yourself is not one; the copy is just skipped.
+	SelfReferral Kind = "SELF_REFERRAL"
+	// Cycle is a user appearing twice in the same chain, such as two
+	// accounts forwarding the challenge back and forth to farm depth.
+	Cycle Kind = "CYCLE"
+	// Alias is two users of a chain whose addresses belong to one person.
+	Alias Kind = "ALIAS"
+	// FanOut is a sender addressing more recipients in a short window than
+	// a person plausibly knows to be a fit.
+	FanOut Kind = "FAN_OUT"
+	// Spoofed is a share recorded from mail that failed authentication, so
+	// its sender may not have sent it.
+	Spoofed Kind = "SPOOFED"
+)
+
+// Finding is one suspicious hop. TransactionID is the transaction that is
+// flagged and UserID the user it concerns.
+type Finding struct {
+	Kind          Kind
+	TransactionID string
+	UserID        string
+	Detail        string
+}
+
+// String is the form recorded in the fraudFlags of the transaction.
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s", f.Kind, f.Detail)
+}
+
+// Link is one sender of a referral chain: the transaction they shared and
+// who they are. User is nil while the share has no sender.
+type Link struct {
+	TransactionID string
+	User          *appsync.User
+}
+
+// Burst is how many recipients one sender may address across the shares of
+// a challenge within Window before the share counts as a fan-out.
+type Burst struct {
+	MaxRecipients int
+	Window        time.Duration
+}
+
+// DefaultBurst is the fan-out limit applied to every share.
+var DefaultBurst = Burst{MaxRecipients: 50, Window: time.Hour}
+
+// CheckShare looks at a share about to be sent from transactionID by sender
+// to recipients. chain is the senders above the transaction, nearest first,
+// and recent the recipients sender already addressed on this challenge
+// within the burst window. The sender in their own To or Cc is left out, not
+// flagged: it only matters once it would be credited, which CheckChain
+// catches at the hire.
+func CheckShare(transactionID string, sender *appsync.User, chain []Link, recipients []*appsync.User, recent int, burst Burst) []Finding {
+	var findings []Finding
+	flag := func(kind Kind, user *appsync.User, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Kind:          kind,
+			TransactionID: transactionID,
+			UserID:        userID(user),
+			Detail:        fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, link := range chain {
+		switch {
+		case sameUser(sender, link.User):
+			flag(Cycle, sender, "%s already shared this challenge further up the chain", name(sender))
+		case aliases(sender, link.User):
+			flag(Alias, sender, "%s and %s up the chain are the same person", name(sender), name(link.User))
+		}
+	}
+
+	for _, recipient := range recipients {
+		switch {
+		case sameUser(sender, recipient):
+			continue
+		case aliases(sender, recipient):
+			flag(Alias, recipient, "%s shared with their own address %s", name(sender), name(recipient))
+			continue
+		}
+		for _, link := range chain {
+			switch {
+			case sameUser(recipient, link.User):
+				flag(Cycle, recipient, "%s was sent back to %s, who is already in the chain", name(sender), name(recipient))
+			case aliases(recipient, link.User):
+				flag(Alias, recipient, "%s was sent to %s, an address of %s up the chain", name(sender), name(recipient), name(link.User))
+			}
+		}
+	}
+
+	if burst.MaxRecipients > 0 && recent+len(recipients) > burst.MaxRecipients {
+		flag(FanOut, sender, "%s addressed %d recipients within %v, more than %d", name(sender), recent+len(recipients), burst.Window, burst.MaxRecipients)
+	}
+	return findings
+}
+
+// CheckChain looks at the chain a hired candidate came through, nearest the
+// hire first. The nearer of two hops by the same person is flagged, so the
+// first time they shared is still paid, and any hop by the candidate
+// themselves is flagged.
+func CheckChain(chain []Link, candidate *appsync.User) []Finding {
+	var findings []Finding
+	for i, link := range chain {
+		if link.User == nil {
+			continue
+		}
+		flag := func(kind Kind, format string, args ...interface{}) {
+			findings = append(findings, Finding{
+				Kind:          kind,
+				TransactionID: link.TransactionID,
+				UserID:        userID(link.User),
+				Detail:        fmt.Sprintf(format, args...),
+			})
+		}
+
+		switch {
+		case sameUser(link.User, candidate):
+			flag(SelfReferral, "%s referred themselves", name(link.User))
+			continue
+		case aliases(link.User, candidate):
+			flag(SelfReferral, "%s referred their own address %s", name(link.User), name(candidate))
+			continue
+		}
+		for j := i + 1; j < len(chain); j++ {
+			above := chain[j].User
+			if sameUser(link.User, above) {
+				if j == i+1 {
+					flag(SelfReferral, "%s forwarded their own share", name(link.User))
+				} else {
+					flag(Cycle, "%s appears %d hops apart in the chain", name(link.User), j-i)
+				}
+				break
+			}
+			if aliases(link.User, above) {
+				flag(Alias, "%s and %s in the chain are the same person", name(link.User), name(above))
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// Record appends the findings to the fraudFlags of their transactions,
+// skipping flags a transaction already has.
+func Record(ctx context.Context, resolver Resolver.Resolver, findings []Finding) error {
+	var order []string
+	byTransaction := map[string][]Finding{}
+	for _, finding := range findings {
+		if finding.TransactionID == "" {
+			continue
+		}
+		if _, seen := byTransaction[finding.TransactionID]; !seen {
+			order = append(order, finding.TransactionID)
+		}
+		byTransaction[finding.TransactionID] = append(byTransaction[finding.TransactionID], finding)
+	}
+
+	for _, transactionID := range order {
+		transaction, err := resolver.GetTransactionContext(ctx, transactionID)
+		if err != nil {
+			return fmt.Errorf("Failed to get transaction %v: %w", transactionID, err)
+		}
+		flags := transaction.FraudFlags
+		existing := map[string]bool{}
+		for _, flag := range flags {
+			if flag != nil {
+				existing[*flag] = true
+			}
+		}
+		added := 0
+		for _, finding := range byTransaction[transactionID] {
+			flag := finding.String()
+			if existing[flag] {
+				continue
+			}
+			existing[flag] = true
+			flags = append(flags, &flag)
+			added++
+		}
+		if added == 0 {
+			continue
+		}
+
+		_, err = resolver.UpdateTransactionContext(ctx, appsync.UpdateTransactionInput{ID: transactionID, FraudFlags: flags})
+		if err != nil {
+			return fmt.Errorf("Failed to flag transaction %v: %w", transactionID, err)
+		}
+		log.Printf("Flagged transaction %v: %d new findings", transactionID, added)
+	}
+	return nil
+}
+
+// Flags returns the fraud flags of the transaction.
+func Flags(transaction *appsync.Transaction) []string {
+	if transaction == nil {
+		return nil
+	}
+	var flags []string
+	for _, flag := range transaction.FraudFlags {
+		if flag != nil && *flag != "" {
+			flags = append(flags, *flag)
+		}
+	}
+	return flags
+}
+
+// Flagged reports whether the transaction has any fraud findings.
+func Flagged(transaction *appsync.Transaction) bool {
+	return len(Flags(transaction)) > 0
+}
+
+func sameUser(a *appsync.User, b *appsync.User) bool {
+	return a != nil && b != nil && a.ID != nil && b.ID != nil && *a.ID == *b.ID
+}
+
+// aliases reports whether two different users share an address once
+// subaddresses and Gmail dots are dropped.
+func aliases(a *appsync.User, b *appsync.User) bool {
+	if a == nil || b == nil || sameUser(a, b) {
+		return false
+	}
+	keys := map[string]bool{}
+	for _, email := range a.Emails {
+		if email != nil && *email != "" {
+			keys[aliasKey(*email)] = true
+		}
+	}
+	for _, email := range b.Emails {
+		if email != nil && *email != "" && keys[aliasKey(*email)] {
+			return true
+		}
+	}
+	return false
+}
+
+// aliasRules over-match on purpose: a + tag on any domain counts as an
+// alias here, even where it would not merge two users.
+var aliasRules = AddressController.Default.Loose()
+
+func aliasKey(email string) string {
+	return aliasRules.Canonical(email)
+}
+
+func userID(user *appsync.User) string {
+	if user == nil || user.ID == nil {
+		return ""
+	}
+	return *user.ID
+}
+
+// name is the first address of the user, which is what sparse users have.
+func name(user *appsync.User) string {
+	if user == nil {
+		return "unknown user"
+	}
+	for _, email := range user.Emails {
+		if email != nil && *email != "" {
+			return *email
+		}
+	}
+	return userID(user)
+}
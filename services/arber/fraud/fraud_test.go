@@ -0,0 +1,120 @@
+package fraud
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+)
+
+func Test(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	user := func(id string, emails ...string) *Resolver.User {
+		var addresses []*string
+		for i := range emails {
+			addresses = append(addresses, &emails[i])
+		}
+		return &Resolver.User{ID: &id, Emails: addresses}
+	}
+	kinds := func(findings []Finding) []Kind {
+		var result []Kind
+		for _, finding := range findings {
+			result = append(result, finding.Kind)
+		}
+		return result
+	}
+
+	alice := user("alice", "alice@example.com")
+	aliceAlias := user("alice-2", "alice+jobs@example.com")
+	bob := user("bob", "bob@example.com")
+	carol := user("carol", "carol@example.com")
+	burst := Burst{MaxRecipients: 3, Window: time.Hour}
+
+	g.Describe("CheckShare", func() {
+		g.It("Should pass an ordinary share", func() {
+			chain := []Link{{TransactionID: "t1", User: alice}}
+			Expect(CheckShare("t2", bob, chain, []*Resolver.User{carol}, 0, burst)).Should(BeEmpty())
+		})
+
+		g.It("Should skip sharing with yourself and flag your own alias", func() {
+			findings := CheckShare("t1", alice, nil, []*Resolver.User{alice, aliceAlias}, 0, burst)
+			Expect(kinds(findings)).Should(Equal([]Kind{Alias}))
+			Expect(findings[0].TransactionID).Should(Equal("t1"))
+		})
+
+		g.It("Should flag forwarding back up the chain", func() {
+			chain := []Link{{TransactionID: "t2", User: bob}, {TransactionID: "t1", User: alice}}
+			findings := CheckShare("t3", carol, chain, []*Resolver.User{alice}, 0, burst)
+			Expect(kinds(findings)).Should(Equal([]Kind{Cycle}))
+
+			findings = CheckShare("t3", alice, chain, []*Resolver.User{carol}, 0, burst)
+			Expect(kinds(findings)).Should(Equal([]Kind{Cycle}))
+
+			findings = CheckShare("t3", aliceAlias, chain, []*Resolver.User{carol}, 0, burst)
+			Expect(kinds(findings)).Should(Equal([]Kind{Alias}))
+		})
+
+		g.It("Should flag a fan-out burst", func() {
+			Expect(CheckShare("t1", alice, nil, []*Resolver.User{bob, carol}, 1, burst)).Should(BeEmpty())
+			findings := CheckShare("t1", alice, nil, []*Resolver.User{bob, carol}, 2, burst)
+			Expect(kinds(findings)).Should(Equal([]Kind{FanOut}))
+		})
+	})
+
+	g.Describe("CheckChain", func() {
+		g.It("Should flag the nearer of two hops by one person", func() {
+			chain := []Link{
+				{TransactionID: "t4", User: alice},
+				{TransactionID: "t3", User: bob},
+				{TransactionID: "t2", User: alice},
+				{TransactionID: "t1", User: carol},
+			}
+			findings := CheckChain(chain, user("dave", "dave@example.com"))
+			Expect(kinds(findings)).Should(Equal([]Kind{Cycle}))
+			Expect(findings[0].TransactionID).Should(Equal("t4"))
+		})
+
+		g.It("Should flag consecutive hops and aliases", func() {
+			chain := []Link{
+				{TransactionID: "t3", User: bob},
+				{TransactionID: "t2", User: bob},
+				{TransactionID: "t1", User: aliceAlias},
+				{TransactionID: "t0", User: alice},
+			}
+			findings := CheckChain(chain, carol)
+			Expect(kinds(findings)).Should(Equal([]Kind{SelfReferral, Alias}))
+			Expect(findings[1].TransactionID).Should(Equal("t1"))
+		})
+
+		g.It("Should flag a candidate who referred themselves", func() {
+			chain := []Link{{TransactionID: "t2", User: bob}, {TransactionID: "t1", User: user("gmail", "Car.Ol@gmail.com")}}
+			findings := CheckChain(chain, user("googlemail", "carol+apply@googlemail.com"))
+			Expect(kinds(findings)).Should(Equal([]Kind{SelfReferral}))
+			Expect(findings[0].TransactionID).Should(Equal("t1"))
+		})
+	})
+
+	g.Describe("Record", func() {
+		g.It("Should append findings to the transaction once", func() {
+			resolver := Resolver.NewMemoryResolver()
+			transaction, err := resolver.CreateTransaction(Resolver.CreateTransactionInput{})
+			Expect(err).ShouldNot(HaveOccurred())
+			findings := CheckShare(*transaction.ID, alice, nil, []*Resolver.User{aliceAlias}, 0, burst)
+
+			Expect(Record(context.Background(), resolver, findings)).Should(Succeed())
+			Expect(Record(context.Background(), resolver, findings)).Should(Succeed())
+
+			stored, err := resolver.GetTransaction(*transaction.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(Flagged(stored)).Should(BeTrue())
+			Expect(Flags(stored)).Should(Equal([]string{"ALIAS: alice@example.com shared with their own address alice+jobs@example.com"}))
+		})
+	})
+}
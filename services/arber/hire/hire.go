@@ -0,0 +1,335 @@
+package hire
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ApplyService "gitlab.com/ncent/arber/api/services/arber/apply"
+	AttributionController "gitlab.com/ncent/arber/api/services/arber/attribution"
+	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
+	FraudController "gitlab.com/ncent/arber/api/services/arber/fraud"
+	MergeController "gitlab.com/ncent/arber/api/services/arber/merge"
+	PayoutController "gitlab.com/ncent/arber/api/services/arber/payout"
+	ShareController "gitlab.com/ncent/arber/api/services/arber/share"
+	UserController "gitlab.com/ncent/arber/api/services/arber/user"
+	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
+)
+
+// Hired is the status of the application that filled the challenge.
+const Hired = "HIRED"
+
+var (
+	// ErrUnauthorized is returned when the sponsor token does not match the
+	// challenge of the application.
+	ErrUnauthorized = errors.New("not the sponsor of this challenge")
+	// ErrAlreadyFilled is returned when the challenge was already filled,
+	// by this application or another one.
+	ErrAlreadyFilled = errors.New("challenge has already been filled")
+	// ErrRetired is returned for an application a merge of users retired in
+	// favour of another application of the same candidate.
+	ErrRetired = errors.New("application was retired by a merge of users")
+)
+
+// Outcome is what a confirmed hire did: the hired application, the closed
+// challenge, the payout plan of the winning chain (nil for a challenge
+// without a reward) and the addresses that were emailed.
+type Outcome struct {
+	Application *appsync.Application   `json:"application"`
+	Challenge   *appsync.Challenge     `json:"challenge"`
+	Plan        *PayoutController.Plan `json:"plan,omitempty"`
+	Notified    []string               `json:"notified,omitempty"`
+}
+
+// now and notify are replaced in tests.
+var (
+	now    = time.Now
+	notify = func(request clients.EmailRequest) error {
+		return clients.SESClient.SendEmail(request)
+	}
+)
+
+// ConfirmHire marks the application hired on behalf of the sponsor holding
+// sponsorToken. It runs the fraud checks on the chain the candidate applied
+// through, computes its payout plan without the flagged referrers and
+// stores it on the application, then closes the challenge, which freezes
+// its referral tree: no more shares or applications are accepted.
+// Referrers on the chain are told their outcome and everyone else who
+// shared the challenge that the role was filled.
+func ConfirmHire(ctx context.Context, resolver Resolver.Resolver, applicationID string, sponsorToken string) (*Outcome, error) {
+	application, err := resolver.GetApplicationContext(ctx, applicationID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get application: %w", err)
+	}
+	if application.ChallengeID == nil || application.TransactionID == nil {
+		return nil, fmt.Errorf("Application %v has no challenge or transaction", applicationID)
+	}
+	authorized, err := Authorized(ctx, resolver, *application.ChallengeID, sponsorToken)
+	if err != nil {
+		return nil, err
+	}
+	if !authorized {
+		return nil, fmt.Errorf("%w: application %v", ErrUnauthorized, applicationID)
+	}
+	if stringValue(application.Status) == MergeController.Retired {
+		return nil, fmt.Errorf("%w: application %v, hire the candidate's other application to challenge %v", ErrRetired, applicationID, *application.ChallengeID)
+	}
+	challenge, err := ChallengeController.GetChallenge(ctx, resolver, *application.ChallengeID)
+	if err != nil {
+		return nil, err
+	}
+	if ChallengeController.StoredStatus(challenge) == ChallengeController.Closed {
+		return nil, fmt.Errorf("%w: challenge %v", ErrAlreadyFilled, *challenge.ID)
+	}
+
+	transactionIDs, weights := credited(application)
+	for _, transactionID := range transactionIDs {
+		if err := checkChain(ctx, resolver, application, transactionID); err != nil {
+			return nil, err
+		}
+	}
+	plan, err := PayoutController.CalculateSplit(ctx, resolver, transactionIDs, weights, nil)
+	if errors.Is(err, PayoutController.ErrNoReward) {
+		log.Printf("Challenge %v has no reward, hiring without a payout: %v", *challenge.ID, err)
+		plan = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The application is written before the challenge is closed so a
+	// failed close can be retried with the same request. The close only
+	// lands while the challenge is still open, so of two hires racing past
+	// the check above one loses and puts its application back.
+	previousStatus := application.Status
+	if stringValue(application.Status) != Hired {
+		status := Hired
+		hiredAt := now().UTC().Format(time.RFC3339)
+		input := appsync.UpdateApplicationInput{ID: applicationID, Status: &status, HiredAt: &hiredAt}
+		if plan != nil {
+			planJSON, err := json.Marshal(plan)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to marshal payout plan: %w", err)
+			}
+			payoutPlan := string(planJSON)
+			input.PayoutPlan = &payoutPlan
+		}
+		application, err = resolver.UpdateApplicationContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to mark application hired: %w", err)
+		}
+	}
+	challenge, err = ChallengeController.Close(ctx, resolver, *challenge.ID)
+	if errors.Is(err, ChallengeController.ErrIllegalTransition) {
+		if stringValue(previousStatus) != Hired {
+			revert(ctx, resolver, applicationID, previousStatus)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrAlreadyFilled, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Application %v filled challenge %v", applicationID, *challenge.ID)
+
+	outcome := &Outcome{Application: application, Challenge: challenge, Plan: plan}
+	outcome.Notified, err = notifyReferrers(ctx, resolver, challenge, plan)
+	if err != nil {
+		// The hire stands; only the emails are missing.
+		log.Printf("Failed to notify referrers of challenge %v: %v", *challenge.ID, err)
+	}
+	return outcome, nil
+}
+
+// revert puts back the status of an application whose hire lost the race to
+// close the challenge; HiredAt stays as the time of the attempt. A failure is
+// logged; the sponsor sees the challenge filled either way.
+func revert(ctx context.Context, resolver Resolver.Resolver, applicationID string, status *string) {
+	input := appsync.UpdateApplicationInput{ID: applicationID, Status: status}
+	if status == nil {
+		submitted := ApplyService.Submitted
+		input.Status = &submitted
+	}
+	if _, err := resolver.UpdateApplicationContext(ctx, input); err != nil {
+		log.Printf("Failed to revert application %v after losing the hire: %v", applicationID, err)
+	}
+}
+
+// credited returns the chains the application is credited to and their
+// shares of the payout, as attributed when the candidate applied.
+// Applications without an attribution are credited to their transaction.
+func credited(application *appsync.Application) ([]string, []*big.Rat) {
+	if application.Attribution != nil && *application.Attribution != "" {
+		attribution, err := AttributionController.Parse(*application.Attribution)
+		if err != nil {
+			log.Printf("Application %v: %v, crediting transaction %v", stringValue(application.ID), err, *application.TransactionID)
+		} else if transactionIDs, weights := attribution.Weights(); len(transactionIDs) > 0 {
+			return transactionIDs, weights
+		}
+	}
+	return []string{*application.TransactionID}, []*big.Rat{big.NewRat(1, 1)}
+}
+
+// checkChain runs the fraud checks on a chain the application is credited
+// to, so the payout below leaves flagged referrers out.
+func checkChain(ctx context.Context, resolver Resolver.Resolver, application *appsync.Application, transactionID string) error {
+	ancestry, err := ShareController.GetAncestry(ctx, resolver, transactionID, 0)
+	if err != nil {
+		return err
+	}
+	var candidate *appsync.User
+	if application.CandidateID != nil {
+		candidate, err = resolver.GetUserContext(ctx, *application.CandidateID)
+		if err != nil {
+			return fmt.Errorf("Failed to get candidate: %w", err)
+		}
+	}
+	findings := FraudController.CheckChain(ShareController.ChainLinks(ancestry), candidate)
+	for _, finding := range findings {
+		log.Printf("Fraud finding on hire of application %v: %v", stringValue(application.ID), finding)
+	}
+	return FraudController.Record(ctx, resolver, findings)
+}
+
+// Authorized reports whether token is the sponsor token of the challenge.
+// The token is read only here, through its own query, so no other challenge
+// read carries it. Challenges without a token cannot be hired through the
+// API.
+func Authorized(ctx context.Context, resolver Resolver.Resolver, challengeID string, token string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+	challenge, err := resolver.GetChallengeSponsorTokenContext(ctx, challengeID)
+	if err != nil {
+		return false, fmt.Errorf("Failed to get sponsor token of challenge %v: %w", challengeID, err)
+	}
+	if challenge.SponsorToken == nil || *challenge.SponsorToken == "" {
+		return false, nil
+	}
+	return subtle.ConstantTimeCompare([]byte(*challenge.SponsorToken), []byte(token)) == 1, nil
+}
+
+// BearerToken reads the token of the Authorization header of a request,
+// whatever the case of the header name and of the Bearer scheme. A bare
+// token without a scheme is taken as is; one of another scheme is not a
+// bearer token.
+func BearerToken(headers map[string]string) string {
+	for name, value := range headers {
+		if !strings.EqualFold(name, "Authorization") {
+			continue
+		}
+		fields := strings.Fields(value)
+		switch {
+		case len(fields) == 1:
+			return fields[0]
+		case len(fields) == 2 && strings.EqualFold(fields[0], "Bearer"):
+			return fields[1]
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// notifyReferrers emails every referrer on the winning chain their outcome
+// and every other user who shared the challenge that the role was filled.
+// Each user gets one email. Failed emails are logged and skipped.
+func notifyReferrers(ctx context.Context, resolver Resolver.Resolver, challenge *appsync.Challenge, plan *PayoutController.Plan) ([]string, error) {
+	role := stringValue(challenge.Name)
+	if role == "" {
+		role = "the role"
+	}
+	var notified []string
+	emailed := map[string]bool{}
+	email := func(userID string, subject string, lines ...string) {
+		if userID == "" || emailed[userID] {
+			return
+		}
+		emailed[userID] = true
+		address, err := userEmail(ctx, resolver, userID)
+		if err != nil || address == "" {
+			log.Printf("Not notifying user %v, no email: %v", userID, err)
+			return
+		}
+		err = notify(clients.EmailRequest{
+			Recipient: address,
+			Sender:    "no-reply@redb.ai",
+			Subject:   subject,
+			Html:      htmlParagraphs(lines),
+			Body:      strings.Join(lines, "\n\n"),
+		})
+		if err != nil {
+			log.Printf("Failed to send %q to %v: %v", subject, address, err)
+			return
+		}
+		notified = append(notified, address)
+	}
+
+	if plan != nil {
+		for _, payout := range plan.Payouts {
+			subject := fmt.Sprintf("Your referral was hired for %s", role)
+			opening := fmt.Sprintf("Good news: %s was filled by someone you helped refer.", role)
+			switch {
+			case payout.Amount > 0:
+				email(payout.UserID, subject, opening, fmt.Sprintf("Your reward is %s.", PayoutController.FormatCents(payout.Amount)))
+			case payout.Skipped != "":
+				email(payout.UserID, subject, opening, fmt.Sprintf("Your place in the chain is not rewarded (%s).", payout.Skipped))
+			default:
+				email(payout.UserID, subject, opening, "Your place in the chain is not rewarded.")
+			}
+		}
+	}
+
+	shareActions, err := resolver.GetShareActionsByChallengeContext(ctx, *challenge.ID)
+	if err != nil {
+		return notified, fmt.Errorf("Failed to get share actions: %w", err)
+	}
+	for _, shareAction := range shareActions {
+		email(
+			stringValue(shareAction.UserID),
+			fmt.Sprintf("%s has been filled", role),
+			fmt.Sprintf("%s has been filled. Thank you for sharing it.", role),
+			"The hire came through another chain of referrals, so this one is not rewarded.",
+		)
+	}
+	return notified, nil
+}
+
+func userEmail(ctx context.Context, resolver Resolver.Resolver, userID string) (string, error) {
+	user, err := resolver.GetUserContext(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if UserController.Unsubscribed(user) {
+		log.Printf("User %v unsubscribed", userID)
+		return "", nil
+	}
+	for _, email := range user.Emails {
+		if email != nil && *email != "" {
+			return *email, nil
+		}
+	}
+	return "", nil
+}
+
+func htmlParagraphs(lines []string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString("<p>" + html.EscapeString(line) + "</p>")
+	}
+	return b.String()
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
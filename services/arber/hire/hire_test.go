@@ -0,0 +1,252 @@
+package hire
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/mail"
+	"testing"
+	"time"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
+	MergeController "gitlab.com/ncent/arber/api/services/arber/merge"
+	PayoutController "gitlab.com/ncent/arber/api/services/arber/payout"
+	ShareController "gitlab.com/ncent/arber/api/services/arber/share"
+	"gitlab.com/ncent/arber/api/services/arber/share/sharetest"
+	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
+)
+
+func Test(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("ConfirmHire", func() {
+		var resolver *Resolver.MemoryResolver
+		var challenge *Resolver.Challenge
+		var sponsorToken string
+		var forwarded *Resolver.Transaction
+		var application *Resolver.Application
+		var sent []clients.EmailRequest
+		ctx := context.Background()
+
+		recipients := func() []string {
+			var addresses []string
+			for _, request := range sent {
+				addresses = append(addresses, request.Recipient)
+			}
+			return addresses
+		}
+
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+			sent = nil
+			now = func() time.Time { return time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC) }
+			notify = func(request clients.EmailRequest) error {
+				sent = append(sent, request)
+				return nil
+			}
+
+			var err error
+			challenge, err = ChallengeController.CreateChallenge(ctx, resolver, "Engineer", &mail.Address{Address: "sponsor@example.com"}, "Acme", "Engineer", "Build things", "")
+			Expect(err).ShouldNot(HaveOccurred())
+			sponsorToken = *challenge.SponsorToken
+			reward := "$1,000"
+			challenge, err = resolver.UpdateChallenge(Resolver.UpdateChallengeInput{ID: *challenge.ID, Reward: &reward})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			root := sharetest.Share(ctx, resolver, *challenge.ID, "", "sharer@example.com", "friend@example.com")
+			forwarded = sharetest.Share(ctx, resolver, *challenge.ID, *root.ID, "friend@example.com", "casey@example.com")
+			sharetest.Share(ctx, resolver, *challenge.ID, "", "bystander@example.com", "someone@example.com")
+
+			candidateEmail := "casey@example.com"
+			candidate, err := resolver.ListUsersByEmails([]*string{&candidateEmail})
+			Expect(err).ShouldNot(HaveOccurred())
+			status := "SUBMITTED"
+			application, err = resolver.CreateApplication(Resolver.CreateApplicationInput{
+				ChallengeID:   challenge.ID,
+				CandidateID:   candidate[0].ID,
+				TransactionID: forwarded.ID,
+				Status:        &status,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		g.After(func() {
+			now = time.Now
+		})
+
+		g.It("Should refuse anyone but the sponsor", func() {
+			_, err := ConfirmHire(ctx, resolver, *application.ID, "guess")
+			Expect(errors.Is(err, ErrUnauthorized)).Should(BeTrue())
+			_, err = ConfirmHire(ctx, resolver, *application.ID, "")
+			Expect(errors.Is(err, ErrUnauthorized)).Should(BeTrue())
+			Expect(sent).Should(BeEmpty())
+		})
+
+		g.It("Should mark the application hired with the payout plan of its chain", func() {
+			outcome, err := ConfirmHire(ctx, resolver, *application.ID, sponsorToken)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*outcome.Application.Status).Should(Equal(Hired))
+			Expect(*outcome.Application.HiredAt).Should(Equal("2020-03-01T12:00:00Z"))
+			Expect(outcome.Plan.TransactionID).Should(Equal(*forwarded.ID))
+			Expect(outcome.Plan.Distributed).Should(Equal(int64(100000)))
+
+			Expect(*outcome.Application.PayoutPlan).Should(ContainSubstring(`"transactionId":"` + *forwarded.ID + `"`))
+			var stored PayoutController.Plan
+			Expect(json.Unmarshal([]byte(*outcome.Application.PayoutPlan), &stored)).Should(Succeed())
+			Expect(stored.Payouts).Should(HaveLen(2))
+		})
+
+		g.It("Should close the challenge and freeze its referral tree", func() {
+			outcome, err := ConfirmHire(ctx, resolver, *application.ID, sponsorToken)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*outcome.Challenge.Status).Should(Equal("CLOSED"))
+
+			_, err = ShareController.CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, *forwarded.ID, *challenge.ID)
+			Expect(errors.Is(err, ChallengeController.ErrNotOpen)).Should(BeTrue())
+
+			_, err = ConfirmHire(ctx, resolver, *application.ID, sponsorToken)
+			Expect(errors.Is(err, ErrAlreadyFilled)).Should(BeTrue())
+		})
+
+		g.It("Should let only one of two racing hires close the challenge", func() {
+			candidateEmail := "friend@example.com"
+			candidate, err := resolver.ListUsersByEmails([]*string{&candidateEmail})
+			Expect(err).ShouldNot(HaveOccurred())
+			status := "SUBMITTED"
+			rival, err := resolver.CreateApplication(Resolver.CreateApplicationInput{
+				ChallengeID:   challenge.ID,
+				CandidateID:   candidate[0].ID,
+				TransactionID: forwarded.ID,
+				Status:        &status,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// The rival hire closes the challenge between this hire's check
+			// and its close.
+			racing := &closingResolver{MemoryResolver: resolver, closeFirst: func() {
+				_, err := ConfirmHire(ctx, resolver, *rival.ID, sponsorToken)
+				Expect(err).ShouldNot(HaveOccurred())
+			}}
+			_, err = ConfirmHire(ctx, racing, *application.ID, sponsorToken)
+			Expect(errors.Is(err, ErrAlreadyFilled)).Should(BeTrue())
+
+			lost, err := resolver.GetApplication(*application.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*lost.Status).Should(Equal("SUBMITTED"))
+			won, err := resolver.GetApplication(*rival.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*won.Status).Should(Equal(Hired))
+		})
+
+		g.It("Should refuse an application a merge retired", func() {
+			email := "casey@work.example.com"
+			duplicate, err := resolver.CreateUser(Resolver.CreateUserInput{Emails: []*string{&email}})
+			Expect(err).ShouldNot(HaveOccurred())
+			id := *challenge.ID + ":" + *duplicate.ID
+			status := "SUBMITTED"
+			later, err := resolver.CreateApplication(Resolver.CreateApplicationInput{
+				ID:            &id,
+				ChallengeID:   challenge.ID,
+				CandidateID:   duplicate.ID,
+				TransactionID: forwarded.ID,
+				Status:        &status,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			result, err := MergeController.Merge(ctx, resolver, *application.CandidateID, *duplicate.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.RetiredApplications).Should(Equal([]string{*later.ID}))
+
+			_, err = ConfirmHire(ctx, resolver, *later.ID, sponsorToken)
+			Expect(errors.Is(err, ErrRetired)).Should(BeTrue())
+			open, err := resolver.GetChallenge(*challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ChallengeController.StoredStatus(open)).Should(Equal(ChallengeController.Active))
+			Expect(sent).Should(BeEmpty())
+		})
+
+		g.It("Should leave the sponsor token out of challenge reads", func() {
+			read, err := resolver.GetChallenge(*challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(read.SponsorToken).Should(BeNil())
+
+			authorized, err := Authorized(ctx, resolver, *challenge.ID, sponsorToken)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(authorized).Should(BeTrue())
+		})
+
+		g.It("Should tell the chain their outcome and everyone else the role was filled", func() {
+			outcome, err := ConfirmHire(ctx, resolver, *application.ID, sponsorToken)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(recipients()).Should(Equal([]string{"friend@example.com", "sharer@example.com", "bystander@example.com"}))
+			Expect(outcome.Notified).Should(Equal(recipients()))
+
+			Expect(sent[0].Subject).Should(Equal("Your referral was hired for Engineer"))
+			Expect(sent[0].Body).Should(ContainSubstring("Your reward is $"))
+			Expect(sent[2].Subject).Should(Equal("Engineer has been filled"))
+		})
+
+		g.It("Should not pay a referrer who referred the candidate's own account", func() {
+			sharerEmail := "sharer@example.com"
+			sharer, err := resolver.ListUsersByEmails([]*string{&sharerEmail})
+			Expect(err).ShouldNot(HaveOccurred())
+			selfApplied, err := resolver.CreateApplication(Resolver.CreateApplicationInput{
+				ChallengeID:   challenge.ID,
+				CandidateID:   sharer[0].ID,
+				TransactionID: forwarded.ID,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			outcome, err := ConfirmHire(ctx, resolver, *selfApplied.ID, sponsorToken)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(outcome.Plan.Payouts[1].Skipped).Should(HavePrefix("flagged: SELF_REFERRAL"))
+			Expect(outcome.Plan.Payouts[0].Amount).Should(Equal(int64(100000)))
+		})
+
+		g.It("Should hire without a payout when the challenge has no reward", func() {
+			plain, err := ChallengeController.CreateChallenge(ctx, resolver, "Designer", &mail.Address{Address: "sponsor@example.com"}, "Acme", "Designer", "", "")
+			Expect(err).ShouldNot(HaveOccurred())
+			transaction, err := ShareController.CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *plain.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			applied, err := resolver.CreateApplication(Resolver.CreateApplicationInput{ChallengeID: plain.ID, TransactionID: transaction.ID})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			outcome, err := ConfirmHire(ctx, resolver, *applied.ID, *plain.SponsorToken)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(outcome.Plan).Should(BeNil())
+			Expect(outcome.Application.PayoutPlan).Should(BeNil())
+		})
+	})
+
+	g.Describe("BearerToken", func() {
+		g.It("Should read the bearer token whatever the case of the header and scheme", func() {
+			Expect(BearerToken(map[string]string{"authorization": "Bearer abc "})).Should(Equal("abc"))
+			Expect(BearerToken(map[string]string{"Authorization": "bearer abc"})).Should(Equal("abc"))
+			Expect(BearerToken(map[string]string{"Authorization": "BEARER  abc"})).Should(Equal("abc"))
+			Expect(BearerToken(map[string]string{"Authorization": "abc"})).Should(Equal("abc"))
+			Expect(BearerToken(map[string]string{"Authorization": "Basic abc"})).Should(BeEmpty())
+			Expect(BearerToken(map[string]string{"Content-Type": "text/plain"})).Should(BeEmpty())
+		})
+	})
+}
+
+// closingResolver runs closeFirst before the first challenge update it sees,
+// the close of a hire that raced the one under test.
+type closingResolver struct {
+	*Resolver.MemoryResolver
+	closeFirst func()
+}
+
+func (r *closingResolver) UpdateChallengeContext(ctx context.Context, input Resolver.UpdateChallengeInput) (*Resolver.Challenge, error) {
+	if r.closeFirst != nil {
+		closeFirst := r.closeFirst
+		r.closeFirst = nil
+		closeFirst()
+	}
+	return r.MemoryResolver.UpdateChallengeContext(ctx, input)
+}
@@ -0,0 +1,122 @@
+package mail
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
+)
+
+// Authentication is whether mail provably comes from the address its From
+// header names.
+type Authentication string
+
+const (
+	// Authenticated mail passed DMARC, which ties SPF or DKIM to the From
+	// domain.
+	Authenticated Authentication = "PASS"
+	// Spoofed mail failed the checks the From domain asks for.
+	Spoofed Authentication = "FAIL"
+	// Unverified mail could not be checked either way: SES gave no
+	// verdicts, or the domain publishes no DMARC policy and SPF and DKIM
+	// were inconclusive.
+	Unverified Authentication = "NONE"
+)
+
+// Authenticate judges the From header of mail by the SES verdicts. DMARC
+// decides where the domain has a policy, unless that policy is none. Without
+// one, SPF or DKIM failing while neither passed is taken as spoofing; a
+// pass alone proves nothing, as it need not be for the From domain.
+func Authenticate(verdicts *clients.Verdicts) (Authentication, string) {
+	if verdicts == nil {
+		return Unverified, "no SES verdicts"
+	}
+	checks := fmt.Sprintf("DMARC %s, SPF %s, DKIM %s", status(verdicts.DMARC), status(verdicts.SPF), status(verdicts.DKIM))
+	switch {
+	case verdicts.DMARC == clients.VerdictPass:
+		return Authenticated, checks
+	case verdicts.DMARC == clients.VerdictFail && !strings.EqualFold(verdicts.DMARCPolicy, "NONE"):
+		return Spoofed, fmt.Sprintf("%s under a DMARC policy of %s", checks, status(verdicts.DMARCPolicy))
+	case (verdicts.SPF == clients.VerdictFail || verdicts.DKIM == clients.VerdictFail) &&
+		verdicts.SPF != clients.VerdictPass && verdicts.DKIM != clients.VerdictPass:
+		return Spoofed, checks
+	default:
+		return Unverified, checks
+	}
+}
+
+func status(verdict string) string {
+	if verdict == "" {
+		return "missing"
+	}
+	return verdict
+}
+
+// Action is what a route does with mail of a given Authentication.
+type Action string
+
+const (
+	// Accept handles the mail as usual.
+	Accept Action = "ACCEPT"
+	// Flag handles the mail and marks what it creates as suspect.
+	Flag Action = "FLAG"
+	// Quarantine keeps the mail for someone to look at, unhandled.
+	Quarantine Action = "QUARANTINE"
+	// Reject drops the mail. The sender is not told, as they are likely
+	// not who the mail claims.
+	Reject Action = "REJECT"
+)
+
+// Policy says what a route does with mail that is Spoofed and with mail
+// that is Unverified. Authenticated mail is always accepted, as is
+// everything under the zero Policy.
+type Policy struct {
+	Spoofed    Action
+	Unverified Action
+}
+
+// Decision is the outcome of a Policy for one message, recorded on what the
+// message creates.
+type Decision struct {
+	Authentication Authentication    `json:"authentication"`
+	Action         Action            `json:"action"`
+	Reason         string            `json:"reason"`
+	Verdicts       *clients.Verdicts `json:"verdicts,omitempty"`
+}
+
+// Decide applies the policy to mail with the verdicts.
+func (p Policy) Decide(verdicts *clients.Verdicts) Decision {
+	authentication, reason := Authenticate(verdicts)
+	decision := Decision{Authentication: authentication, Action: Accept, Reason: reason, Verdicts: verdicts}
+	switch authentication {
+	case Spoofed:
+		decision.Action = p.Spoofed
+	case Unverified:
+		decision.Action = p.Unverified
+	}
+	if decision.Action == "" {
+		decision.Action = Accept
+	}
+	return decision
+}
+
+// Flagged reports whether what the mail creates should be marked suspect.
+func (d *Decision) Flagged() bool {
+	return d != nil && d.Action == Flag
+}
+
+// JSON is the decision as stored in the mailAuthentication of records, or
+// nil without a decision.
+func (d *Decision) JSON() *string {
+	if d == nil {
+		return nil
+	}
+	decisionJSON, err := json.Marshal(d)
+	if err != nil {
+		// A Decision holds only strings.
+		panic(err)
+	}
+	value := string(decisionJSON)
+	return &value
+}
@@ -0,0 +1,154 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/dchest/uniuri"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
+)
+
+// FallbackFromEnv returns the fallback named by INBOUND_FALLBACK: "reply"
+// for AutoReply, "dead-letter" for DeadLetter into DEAD_LETTER_BUCKET, or
+// none, which only logs unrouted mail.
+func FallbackFromEnv() Handler {
+	switch fallback := strings.ToLower(strings.TrimSpace(os.Getenv("INBOUND_FALLBACK"))); fallback {
+	case "", "log":
+		return nil
+	case "reply":
+		return AutoReply
+	case "dead-letter":
+		bucket := os.Getenv("DEAD_LETTER_BUCKET")
+		if bucket == "" {
+			log.Printf("INBOUND_FALLBACK is dead-letter without a DEAD_LETTER_BUCKET, only logging unrouted mail")
+			return nil
+		}
+		return DeadLetter(bucket)
+	default:
+		log.Printf("Unknown INBOUND_FALLBACK %q, only logging unrouted mail", fallback)
+		return nil
+	}
+}
+
+// AutoReply tells the sender of unrouted mail that nobody reads the address
+// they wrote to. Mail from our own domains and from mailer daemons is not
+// answered, so two auto-responders cannot keep each other busy.
+func AutoReply(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error {
+	from := request.Message.From
+	if from == nil || from.Address == "" {
+		return nil
+	}
+	address := strings.ToLower(from.Address)
+	for _, prefix := range []string{"mailer-daemon@", "postmaster@", "no-reply@", "noreply@"} {
+		if strings.HasPrefix(address, prefix) {
+			log.Printf("Not auto-replying to %v", address)
+			return nil
+		}
+	}
+	if NewRouter(inboundDomains()...).Routes(address) {
+		log.Printf("Not auto-replying to %v", address)
+		return nil
+	}
+
+	lines := []string{
+		"Nobody reads this address, so your message was not delivered.",
+		"To share a role, keep the share+ address we gave you in Bcc. To post a role, write to start@redb.ai.",
+	}
+	return bounce(sess, clients.EmailRequest{
+		Recipient: from.Address,
+		Sender:    "no-reply@redb.ai",
+		Subject:   "Your message was not delivered: " + request.Message.Subject,
+		Html:      "<p>" + strings.Join(lines, "</p><p>") + "</p>",
+		Body:      strings.Join(lines, "\n\n"),
+	})
+}
+
+// deadLetter is one message as stored by DeadLetter.
+type deadLetter struct {
+	ReceivedAt string            `json:"receivedAt"`
+	From       string            `json:"from"`
+	To         []string          `json:"to"`
+	Cc         []string          `json:"cc,omitempty"`
+	Bcc        []string          `json:"bcc,omitempty"`
+	Recipients []string          `json:"recipients,omitempty"`
+	Verdicts   *clients.Verdicts `json:"verdicts,omitempty"`
+	Decision   *Decision         `json:"decision,omitempty"`
+	Subject    string            `json:"subject"`
+	Body       string            `json:"body"`
+}
+
+// DeadLetter returns a handler that stores mail in bucket, as JSON, for
+// someone to look at. It serves as the fallback for unrouted mail and as
+// the quarantine.
+func DeadLetter(bucket string) Handler {
+	return func(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error {
+		message := request.Message
+		letter := deadLetter{
+			ReceivedAt: time.Now().UTC().Format(time.RFC3339),
+			To:         addresses(message.To),
+			Cc:         addresses(message.Cc),
+			Bcc:        addresses(message.Bcc),
+			Recipients: addresses(message.Recipients),
+			Verdicts:   message.Verdicts,
+			Decision:   request.Decision,
+			Subject:    message.Subject,
+			Body:       message.Body,
+		}
+		if message.From != nil {
+			letter.From = message.From.Address
+		}
+		letterJSON, err := json.Marshal(letter)
+		if err != nil {
+			return fmt.Errorf("Failed to marshal dead letter: %w", err)
+		}
+		key := fmt.Sprintf("%s-%s.json", letter.ReceivedAt, uniuri.New())
+		if err := storeDeadLetter(bucket, key, letterJSON); err != nil {
+			return fmt.Errorf("Failed to store dead letter: %w", err)
+		}
+		log.Printf("Stored mail from %v as %v/%v", letter.From, bucket, key)
+		return nil
+	}
+}
+
+// QuarantineFromEnv returns a DeadLetter into QUARANTINE_BUCKET, or none
+// when it is not set.
+func QuarantineFromEnv() Handler {
+	bucket := os.Getenv("QUARANTINE_BUCKET")
+	if bucket == "" {
+		return nil
+	}
+	return DeadLetter(bucket)
+}
+
+// storeDeadLetter is replaced in tests, which have no S3.
+var storeDeadLetter = func(bucket string, key string, data []byte) error {
+	uploader := s3manager.NewUploader(session.Must(session.NewSession()))
+	_, err := uploader.Upload(&s3manager.UploadInput{
+		Body:        bytes.NewReader(data),
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+func addresses(list []*mail.Address) []string {
+	var result []string
+	for _, address := range list {
+		if address != nil {
+			result = append(result, address.Address)
+		}
+	}
+	return result
+}
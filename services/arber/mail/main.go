@@ -2,21 +2,22 @@ package mail
 
 import (
 	"bufio"
+	"context"
+	"fmt"
+	"html"
 	"log"
 	"net/mail"
 	"strings"
 
 	"github.com/DusanKasan/parsemail"
 	Resolver "gitlab.com/ncent/arber/api/services/appsync"
-	AttachmentController "gitlab.com/ncent/arber/api/services/arber/attachment"
-	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
-	ShareActionController "gitlab.com/ncent/arber/api/services/arber/share"
-	UserController "gitlab.com/ncent/arber/api/services/arber/user"
 	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
-	helpers "gitlab.com/ncent/arber/api/services/google/helper"
 )
 
+// ProcessInbound routes mail known only by its headers through the
+// DefaultRouter.
 func ProcessInbound(
+	ctx context.Context,
 	resolver Resolver.Resolver,
 	sess clients.SESService,
 	tos []*mail.Address,
@@ -25,56 +26,53 @@ func ProcessInbound(
 	body string,
 	subject string,
 	attachments []parsemail.Attachment) error {
-	toAddress := strings.ToLower(tos[0].Address)
-	if strings.HasPrefix(toAddress, "start") {
-		var firstAttachmentURL string
-		if len(attachments) > 0 {
-			attachmentURLs, err := AttachmentController.SaveAttachments(attachments)
-			if err != nil {
-				log.Printf("Failed to save attachments: %+v", err)
-			}
-			firstAttachmentURL = *attachmentURLs[0]
-		}
+	return ProcessReceived(ctx, resolver, sess, clients.InboundEmail{
+		To:          tos,
+		From:        from,
+		Bcc:         bcc,
+		Body:        body,
+		Subject:     subject,
+		Attachments: attachments,
+	})
+}
 
-		user, err := UserController.CreateSparseUser(resolver, from)
-		if err != nil {
-			return err
-		}
-		bodyLines, _ := StringToLines(body)
-		challenge, err := ChallengeController.CreateChallenge(
-			resolver,
-			subject,
-			from,
-			subject,
-			bodyLines[0],
-			body,
-			firstAttachmentURL,
-		)
-		if err != nil {
-			return err
+// ProcessReceived routes received mail through the DefaultRouter, on its
+// envelope recipients when it came with an SES receipt. Mail SES found a
+// virus or spam in is dropped.
+func ProcessReceived(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, email clients.InboundEmail) error {
+	if verdicts := email.Verdicts; verdicts != nil {
+		if verdicts.Virus == clients.VerdictFail || verdicts.Spam == clients.VerdictFail {
+			log.Printf("Dropping mail from %v, virus verdict %v, spam verdict %v", email.From, verdicts.Virus, verdicts.Spam)
+			return nil
 		}
+	}
+	return DefaultRouter.Route(ctx, resolver, sess, Message{
+		To:          email.To,
+		Cc:          email.Cc,
+		From:        email.From,
+		Bcc:         email.Bcc,
+		Body:        email.Body,
+		Subject:     email.Subject,
+		Attachments: email.Attachments,
+		Recipients:  email.Recipients,
+		Verdicts:    email.Verdicts,
+	})
+}
 
-		helpers.SendStartEmail(*user, *challenge)
-	} else {
-		var bccAddress string
-		if len(bcc) > 0 && len(bcc[0].Address) > 0 {
-			bccAddress = strings.ToLower(bcc[0].Address)
-		}
-		if strings.HasPrefix(bccAddress, "share") {
-			// bbcAddress will contain txId as such share+txId@redb.ai
-			partsArray := strings.Split(strings.Split(bccAddress, "+")[1], "@")
-			transactionID := partsArray[0]
+func bounceShare(sess clients.SESService, from *mail.Address, subject string, reason string) error {
+	return bounce(sess, clients.EmailRequest{
+		Recipient: from.Address,
+		Sender:    "no-reply@redb.ai",
+		Subject:   "Your share was not delivered: " + subject,
+		Html:      fmt.Sprintf("<p>Your share was not sent to anyone.</p><p>%s</p>", html.EscapeString(reason)),
+		Body:      fmt.Sprintf("Your share was not sent to anyone.\n\n%s", reason),
+	})
+}
 
-			err := ShareActionController.CreateShareActionContacts(resolver, transactionID, from, tos)
-			if err != nil {
-				return err
-			}
-		} else {
-			log.Printf("Failed to find a proper route for: %v", bccAddress)
-			log.Printf("Failed to find a proper route for: %v", toAddress)
-		}
-	}
-	return nil
+// bounce tells a sender their message was refused. It is replaced in tests,
+// which have no SES client.
+var bounce = func(sess clients.SESService, request clients.EmailRequest) error {
+	return sess.SendEmail(request)
 }
 
 func StringToLines(s string) (lines []string, err error) {
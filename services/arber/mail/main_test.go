@@ -0,0 +1,315 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/mail"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ApplyService "gitlab.com/ncent/arber/api/services/arber/apply"
+	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
+	FraudController "gitlab.com/ncent/arber/api/services/arber/fraud"
+	ShareActionController "gitlab.com/ncent/arber/api/services/arber/share"
+	UserController "gitlab.com/ncent/arber/api/services/arber/user"
+	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
+)
+
+func Test(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("ProcessInbound", func() {
+		var resolver *Resolver.MemoryResolver
+		var challenge *Resolver.Challenge
+		var transaction *Resolver.Transaction
+
+		from := &mail.Address{Name: "Sharer", Address: "Sharer@example.com"}
+		tos := []*mail.Address{
+			{Address: "friend.one@example.com"},
+			{Address: "friend.two@example.com"},
+		}
+
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+			name := "Engineer"
+			var err error
+			challenge, err = resolver.CreateChallenge(Resolver.CreateChallengeInput{Name: &name})
+			Expect(err).ShouldNot(HaveOccurred())
+			transaction, err = ShareActionController.CreateShareActionAndTransactionWithParentTransaction(context.Background(), resolver, "", *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		g.Describe("share route", func() {
+			g.It("Should attach the sender and recipients to the share action", func() {
+				bcc := []*mail.Address{{Address: "share+" + *transaction.ID + "@redb.ai"}}
+				err := ProcessInbound(context.Background(), resolver, clients.SESService{}, tos, from, bcc, "body", "subject", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				stored, err := resolver.GetTransaction(*transaction.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(stored.Action.UserID).ShouldNot(BeNil())
+
+				sender, err := resolver.GetUser(*stored.Action.UserID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*sender.Emails[0]).Should(Equal("sharer@example.com"))
+
+				contacts := resolver.ShareActionContacts(*stored.Action.ID)
+				Expect(contacts).Should(HaveLen(2))
+				Expect(*contacts[0].Contact.Emails[0]).Should(Equal("friend.one@example.com"))
+				Expect(*contacts[1].Contact.Emails[0]).Should(Equal("friend.two@example.com"))
+			})
+
+			g.It("Should reuse existing users instead of creating duplicates", func() {
+				bcc := []*mail.Address{{Address: "share+" + *transaction.ID + "@redb.ai"}}
+				err := ProcessInbound(context.Background(), resolver, clients.SESService{}, tos, from, bcc, "body", "subject", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				err = ProcessInbound(context.Background(), resolver, clients.SESService{}, tos, from, bcc, "body", "subject", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				email := "friend.one@example.com"
+				users, err := resolver.ListUsersByEmails([]*string{&email})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(users).Should(HaveLen(1))
+			})
+
+			g.It("Should bounce a share the challenge rules refuse", func() {
+				maxShares := 1
+				limited, err := resolver.CreateChallenge(Resolver.CreateChallengeInput{MaxShares: &maxShares})
+				Expect(err).ShouldNot(HaveOccurred())
+				limitedTransaction, err := ShareActionController.CreateShareActionAndTransactionWithParentTransaction(context.Background(), resolver, "", *limited.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				var bounced []clients.EmailRequest
+				defer func(original func(clients.SESService, clients.EmailRequest) error) { bounce = original }(bounce)
+				bounce = func(sess clients.SESService, request clients.EmailRequest) error {
+					bounced = append(bounced, request)
+					return nil
+				}
+				bcc := []*mail.Address{{Address: "share+" + *limitedTransaction.ID + "@redb.ai"}}
+				err = ProcessInbound(context.Background(), resolver, clients.SESService{}, tos, from, bcc, "body", "subject", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(bounced).Should(HaveLen(1))
+				Expect(bounced[0].Recipient).Should(Equal(from.Address))
+				Expect(bounced[0].Body).Should(ContainSubstring("can go to 1 people at most"))
+				stored, err := resolver.GetTransaction(*limitedTransaction.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resolver.ShareActionContacts(*stored.Action.ID)).Should(BeEmpty())
+			})
+
+			g.It("Should bounce a share of a filled role", func() {
+				_, err := ChallengeController.Close(context.Background(), resolver, *challenge.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				var bounced []clients.EmailRequest
+				defer func(original func(clients.SESService, clients.EmailRequest) error) { bounce = original }(bounce)
+				bounce = func(sess clients.SESService, request clients.EmailRequest) error {
+					bounced = append(bounced, request)
+					return nil
+				}
+				bcc := []*mail.Address{{Address: "share+" + *transaction.ID + "@redb.ai"}}
+				err = ProcessInbound(context.Background(), resolver, clients.SESService{}, tos, from, bcc, "body", "subject", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				Expect(bounced).Should(HaveLen(1))
+				Expect(bounced[0].Body).Should(ContainSubstring("Engineer has been filled"))
+			})
+
+			g.It("Should record a share Bcc'd only in the envelope", func() {
+				email := clients.InboundEmail{
+					To:         tos,
+					From:       from,
+					Body:       "body",
+					Subject:    "subject",
+					Recipients: []*mail.Address{{Address: "share+" + *transaction.ID + "@redb.ai"}},
+					Verdicts:   &clients.Verdicts{Spam: clients.VerdictPass, Virus: clients.VerdictPass},
+				}
+				Expect(ProcessReceived(context.Background(), resolver, clients.SESService{}, email)).Should(Succeed())
+
+				stored, err := resolver.GetTransaction(*transaction.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resolver.ShareActionContacts(*stored.Action.ID)).Should(HaveLen(2))
+			})
+
+			g.It("Should drop mail with a virus", func() {
+				email := clients.InboundEmail{
+					To:         tos,
+					From:       from,
+					Recipients: []*mail.Address{{Address: "share+" + *transaction.ID + "@redb.ai"}},
+					Verdicts:   &clients.Verdicts{Spam: clients.VerdictPass, Virus: clients.VerdictFail},
+				}
+				Expect(ProcessReceived(context.Background(), resolver, clients.SESService{}, email)).Should(Succeed())
+
+				stored, err := resolver.GetTransaction(*transaction.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resolver.ShareActionContacts(*stored.Action.ID)).Should(BeEmpty())
+			})
+
+			g.It("Should flag a share of spoofed mail", func() {
+				email := clients.InboundEmail{
+					To:         tos,
+					From:       from,
+					Recipients: []*mail.Address{{Address: "share+" + *transaction.ID + "@redb.ai"}},
+					Verdicts:   &clients.Verdicts{DMARC: clients.VerdictFail, DMARCPolicy: "REJECT"},
+				}
+				Expect(ProcessReceived(context.Background(), resolver, clients.SESService{}, email)).Should(Succeed())
+
+				stored, err := resolver.GetTransaction(*transaction.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resolver.ShareActionContacts(*stored.Action.ID)).Should(HaveLen(2))
+				Expect(FraudController.Flags(stored)).Should(HaveLen(1))
+				Expect(FraudController.Flags(stored)[0]).Should(HavePrefix(string(FraudController.Spoofed)))
+
+				Expect(stored.Action.MailAuthentication).ShouldNot(BeNil())
+				var decision Decision
+				Expect(json.Unmarshal([]byte(*stored.Action.MailAuthentication), &decision)).Should(Succeed())
+				Expect(decision.Authentication).Should(Equal(Spoofed))
+				Expect(decision.Action).Should(Equal(Flag))
+			})
+
+			g.It("Should fail for an unknown transaction", func() {
+				bcc := []*mail.Address{{Address: "share+missing@redb.ai"}}
+				err := ProcessInbound(context.Background(), resolver, clients.SESService{}, tos, from, bcc, "body", "subject", nil)
+				Expect(errors.Is(err, Resolver.ErrNotFound)).Should(BeTrue())
+			})
+		})
+
+		g.Describe("apply route", func() {
+			g.It("Should apply the sender to the challenge", func() {
+				to := []*mail.Address{{Address: "apply+" + *challenge.ID + "@redb.ai"}}
+				err := ProcessInbound(context.Background(), resolver, clients.SESService{}, to, from, nil, "I would love to.\n", "Engineer", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				email := "sharer@example.com"
+				users, err := resolver.ListUsersByEmails([]*string{&email})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(users).Should(HaveLen(1))
+				application, err := resolver.GetApplication(ApplyService.ApplicationID(*challenge.ID, *users[0].ID))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*application.Message).Should(Equal("I would love to."))
+
+				err = ProcessInbound(context.Background(), resolver, clients.SESService{}, to, from, nil, "Again", "Engineer", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				// One root for the first application, none for the repeat.
+				shareActions, err := resolver.GetShareActionsByChallenge(*challenge.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(shareActions).Should(HaveLen(2))
+			})
+
+			g.It("Should credit the share that reached the sender without a new transaction", func() {
+				ctx := context.Background()
+				Expect(ShareActionController.CreateShareActionContacts(ctx, resolver, *transaction.ID, &mail.Address{Address: "referrer@example.com"}, []*mail.Address{from})).Should(Succeed())
+
+				to := []*mail.Address{{Address: "apply+" + *challenge.ID + "@redb.ai"}}
+				Expect(ProcessInbound(ctx, resolver, clients.SESService{}, to, from, nil, "Hello", "Engineer", nil)).Should(Succeed())
+				Expect(ProcessInbound(ctx, resolver, clients.SESService{}, to, from, nil, "Again", "Engineer", nil)).Should(Succeed())
+
+				candidate, err := UserController.FindUser(ctx, resolver, from.Address)
+				Expect(err).ShouldNot(HaveOccurred())
+				application, err := resolver.GetApplication(ApplyService.ApplicationID(*challenge.ID, *candidate.ID))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*application.TransactionID).Should(Equal(*transaction.ID))
+				shareActions, err := resolver.GetShareActionsByChallenge(*challenge.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(shareActions).Should(HaveLen(1))
+			})
+
+			g.It("Should record how the application authenticated", func() {
+				email := clients.InboundEmail{
+					From:     from,
+					To:       []*mail.Address{{Address: "apply+" + *challenge.ID + "@redb.ai"}},
+					Body:     "Hello",
+					Verdicts: &clients.Verdicts{DMARC: clients.VerdictPass, SPF: clients.VerdictPass, DKIM: clients.VerdictPass},
+				}
+				Expect(ProcessReceived(context.Background(), resolver, clients.SESService{}, email)).Should(Succeed())
+
+				address := "sharer@example.com"
+				users, err := resolver.ListUsersByEmails([]*string{&address})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(users).Should(HaveLen(1))
+				application, err := resolver.GetApplication(ApplyService.ApplicationID(*challenge.ID, *users[0].ID))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(application.MailAuthentication).ShouldNot(BeNil())
+				Expect(*application.MailAuthentication).Should(ContainSubstring(`"authentication":"PASS"`))
+			})
+
+			g.It("Should not apply from spoofed mail", func() {
+				email := clients.InboundEmail{
+					From:     from,
+					To:       []*mail.Address{{Address: "apply+" + *challenge.ID + "@redb.ai"}},
+					Verdicts: &clients.Verdicts{DMARC: clients.VerdictFail, DMARCPolicy: "REJECT"},
+				}
+				Expect(ProcessReceived(context.Background(), resolver, clients.SESService{}, email)).Should(Succeed())
+
+				address := "sharer@example.com"
+				users, err := resolver.ListUsersByEmails([]*string{&address})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(users).Should(BeEmpty())
+			})
+
+			g.It("Should bounce an application to a filled role", func() {
+				_, err := ChallengeController.Close(context.Background(), resolver, *challenge.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				var bounced []clients.EmailRequest
+				defer func(original func(clients.SESService, clients.EmailRequest) error) { bounce = original }(bounce)
+				bounce = func(sess clients.SESService, request clients.EmailRequest) error {
+					bounced = append(bounced, request)
+					return nil
+				}
+				to := []*mail.Address{{Address: "apply+" + *challenge.ID + "@redb.ai"}}
+				err = ProcessInbound(context.Background(), resolver, clients.SESService{}, to, from, nil, "body", "Engineer", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(bounced).Should(HaveLen(1))
+				Expect(bounced[0].Subject).Should(Equal("Your application was not delivered: Engineer"))
+			})
+		})
+
+		g.Describe("stop route", func() {
+			g.It("Should unsubscribe the sender", func() {
+				to := []*mail.Address{{Address: "stop@redb.ai"}}
+				err := ProcessInbound(context.Background(), resolver, clients.SESService{}, to, from, nil, "", "stop", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				email := "sharer@example.com"
+				users, err := resolver.ListUsersByEmails([]*string{&email})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(users).Should(HaveLen(1))
+				Expect(UserController.Unsubscribed(&users[0])).Should(BeTrue())
+			})
+
+			g.It("Should not unsubscribe someone else", func() {
+				email := clients.InboundEmail{
+					From:     from,
+					To:       []*mail.Address{{Address: "stop@redb.ai"}},
+					Verdicts: &clients.Verdicts{SPF: clients.VerdictFail, DKIM: clients.VerdictFail},
+				}
+				Expect(ProcessReceived(context.Background(), resolver, clients.SESService{}, email)).Should(Succeed())
+
+				address := "sharer@example.com"
+				users, err := resolver.ListUsersByEmails([]*string{&address})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(users).Should(BeEmpty())
+			})
+		})
+
+		g.Describe("unknown route", func() {
+			g.It("Should not record anything", func() {
+				to := []*mail.Address{{Address: "hello@redb.ai"}}
+				err := ProcessInbound(context.Background(), resolver, clients.SESService{}, to, from, nil, "body", "subject", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				email := "sharer@example.com"
+				users, err := resolver.ListUsersByEmails([]*string{&email})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(users).Should(BeEmpty())
+			})
+		})
+	})
+}
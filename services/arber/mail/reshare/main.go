@@ -1,7 +1,9 @@
 package mail
 
 import (
+	"context"
 	"fmt"
+	"html"
 	"log"
 	"net/url"
 	"strings"
@@ -12,18 +14,18 @@ import (
 	helpers "gitlab.com/ncent/arber/api/services/google/helper"
 )
 
-func GenerateReshareBodyByChallenge(resolver Resolver.Resolver, transactionId string, challengeId string) (*string, error) {
+func GenerateReshareBodyByChallenge(ctx context.Context, resolver Resolver.Resolver, transactionId string, challengeId string) (*string, error) {
 	challenge, err := ChallengeController.GetChallenge(
-		resolver, challengeId,
+		ctx, resolver, challengeId,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("There was a problem in GenerateReshareBodyByChallenge getting challenge: %v", err.Error())
+		return nil, fmt.Errorf("There was a problem in GenerateReshareBodyByChallenge getting challenge: %w", err)
 	}
 	log.Printf("Found challenge: %+v -- Generating mail body", *challenge)
 
-	transaction, err := ShareActionController.CreateShareActionAndTransactionWithParentTransaction(resolver, transactionId, challengeId)
+	transaction, err := ShareActionController.CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, transactionId, challengeId)
 	if err != nil {
-		return nil, fmt.Errorf("There was a problem in Creating new Share Action And Trasaction: %v", err.Error())
+		return nil, fmt.Errorf("There was a problem in Creating new Share Action And Trasaction: %w", err)
 	}
 
 	subject := fmt.Sprintf("Love this startup- Can you help us find an %s?", *challenge.Name)
@@ -132,3 +134,58 @@ func GenerateReshareBodyByChallenge(resolver Resolver.Resolver, transactionId st
 	log.Printf("htmlBody: %v", htmlBody)
 	return &htmlBody, nil
 }
+
+// GenerateRejectionBody renders the page shown instead of the reshare page
+// when the challenge rules refuse another share.
+func GenerateRejectionBody(reason string) string {
+	return generateStatusPage("This challenge can't be shared any further", reason)
+}
+
+// GenerateClosedBody renders the page shown instead of the reshare page once
+// the challenge stopped taking shares.
+func GenerateClosedBody(closedErr *ChallengeController.ClosedError) string {
+	heading := "This role is closed"
+	if closedErr.Status == ChallengeController.Closed {
+		heading = "This role has been filled"
+	}
+	return generateStatusPage(heading, closedErr.Reason)
+}
+
+func generateStatusPage(heading string, reason string) string {
+	return fmt.Sprintf(`
+		<html>
+			<head>
+				<style>
+					body {
+						margin: 0;
+					}
+					.background {
+						width: 100%%;
+						height: 100vh;
+						display: flex;
+						background-color: #18191B;
+						flex-direction: column;
+						justify-content: center;
+						align-items: center;
+					}
+					.text {
+						font-size: 50px;
+						color: #FFFFFF;
+						text-align: center;
+					}
+					.reason {
+						font-size: 30px;
+						color: #FFFFFF;
+						text-align: center;
+					}
+				</style>
+			</head>
+			<body>
+				<div class="background">
+					<h1 class="text">%s</h1>
+					<p class="reason">%s</p>
+				</div>
+			</body>
+		</html>
+	`, html.EscapeString(heading), html.EscapeString(reason))
+}
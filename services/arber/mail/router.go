@@ -0,0 +1,357 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/DusanKasan/parsemail"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
+)
+
+// Message is an inbound email.
+type Message struct {
+	To          []*mail.Address
+	Cc          []*mail.Address
+	From        *mail.Address
+	Bcc         []*mail.Address
+	Body        string
+	Subject     string
+	Attachments []parsemail.Attachment
+	// Recipients are the envelope recipients of an SES receipt, nil for
+	// mail that came without one.
+	Recipients []*mail.Address
+	// Verdicts are the checks SES ran on the message, nil when unknown.
+	Verdicts *clients.Verdicts
+}
+
+// Routed returns the addresses the message is routed on: its envelope
+// recipients, or without those, To, Cc and Bcc. A Bcc header is usually
+// stripped before delivery, so an address only Bcc'd survives in the
+// envelope alone.
+func (m Message) Routed() []*mail.Address {
+	if len(m.Recipients) > 0 {
+		return m.Recipients
+	}
+	return m.headerRecipients()
+}
+
+func (m Message) headerRecipients() []*mail.Address {
+	var recipients []*mail.Address
+	recipients = append(recipients, m.To...)
+	recipients = append(recipients, m.Cc...)
+	return append(recipients, m.Bcc...)
+}
+
+// Request is a message routed to a handler through one of its recipients.
+type Request struct {
+	Message Message
+	// Recipient is the address that matched the route, nil for the
+	// fallback.
+	Recipient *mail.Address
+	// Route is the pattern that matched, empty for the fallback.
+	Route string
+	// Params are the values the pattern captured, by name.
+	Params map[string]string
+	// External are the To and Cc recipients the router does not route,
+	// the people the sender wrote to.
+	External []*mail.Address
+	// Decision is how the route's policy judged the sender; handlers
+	// record it on what they create.
+	Decision *Decision
+}
+
+// Handler handles a routed message.
+type Handler func(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error
+
+// Pattern matches the local part of an address, like share+{txId}. Text
+// outside braces matches itself, ignoring case; each {name} captures one or
+// more characters into the parameter name.
+type Pattern struct {
+	source string
+	names  []string
+	regexp *regexp.Regexp
+}
+
+var paramName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParsePattern parses a local-part pattern. Two parameters must be
+// separated by some text, or where one ends would be ambiguous.
+func ParsePattern(source string) (*Pattern, error) {
+	if source == "" {
+		return nil, fmt.Errorf("Invalid pattern: empty")
+	}
+	pattern := &Pattern{source: source}
+	var expression strings.Builder
+	expression.WriteString("^")
+	lastWasParam := false
+	for i := 0; i < len(source); {
+		open := strings.IndexAny(source[i:], "{}")
+		if open < 0 {
+			expression.WriteString(regexp.QuoteMeta(strings.ToLower(source[i:])))
+			break
+		}
+		open += i
+		if source[open] == '}' {
+			return nil, fmt.Errorf("Invalid pattern %q: unmatched }", source)
+		}
+		if open > i {
+			expression.WriteString(regexp.QuoteMeta(strings.ToLower(source[i:open])))
+			lastWasParam = false
+		}
+		closing := strings.IndexByte(source[open:], '}')
+		if closing < 0 {
+			return nil, fmt.Errorf("Invalid pattern %q: unmatched {", source)
+		}
+		closing += open
+		name := source[open+1 : closing]
+		if !paramName.MatchString(name) {
+			return nil, fmt.Errorf("Invalid pattern %q: bad parameter name %q", source, name)
+		}
+		for _, existing := range pattern.names {
+			if existing == name {
+				return nil, fmt.Errorf("Invalid pattern %q: parameter %q repeated", source, name)
+			}
+		}
+		if lastWasParam {
+			return nil, fmt.Errorf("Invalid pattern %q: parameters must be separated", source)
+		}
+		pattern.names = append(pattern.names, name)
+		expression.WriteString("(.+?)")
+		lastWasParam = true
+		i = closing + 1
+	}
+	expression.WriteString("$")
+	pattern.regexp = regexp.MustCompile(expression.String())
+	return pattern, nil
+}
+
+// Match returns the parameters captured from localPart, and whether it
+// matched at all. localPart is lowercased first, as the routes always did.
+func (p *Pattern) Match(localPart string) (map[string]string, bool) {
+	matches := p.regexp.FindStringSubmatch(strings.ToLower(localPart))
+	if matches == nil {
+		return nil, false
+	}
+	params := make(map[string]string, len(p.names))
+	for i, name := range p.names {
+		params[name] = matches[i+1]
+	}
+	return params, true
+}
+
+func (p *Pattern) String() string {
+	return p.source
+}
+
+type route struct {
+	pattern *Pattern
+	policy  *Policy
+	handler Handler
+}
+
+// Router sends each recipient of inbound mail to the first route whose
+// pattern matches its local part. Mail none of whose recipients matched
+// goes to the Fallback.
+type Router struct {
+	// Domains are the domains whose recipients are routed. The people a
+	// message was written to are elsewhere and never routed, even when
+	// their address looks like a route. Without Domains every recipient is.
+	Domains []string
+	// Fallback handles mail that matched no route. Without one it is only
+	// logged.
+	Fallback Handler
+	// DefaultPolicy applies to the Fallback and to routes added without a
+	// policy of their own.
+	DefaultPolicy Policy
+	// Quarantine keeps mail a policy quarantined. Without one it is only
+	// logged.
+	Quarantine Handler
+	routes     []route
+}
+
+// NewRouter returns a router without routes for the domains.
+func NewRouter(domains ...string) *Router {
+	router := &Router{}
+	for _, domain := range domains {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			router.Domains = append(router.Domains, domain)
+		}
+	}
+	return router
+}
+
+// Handle adds a route for pattern under the DefaultPolicy. Routes are tried
+// in the order they were added. Like http.Handle, it panics on an invalid
+// pattern, which is a programming error.
+func (r *Router) Handle(pattern string, handler Handler) {
+	r.handle(pattern, nil, handler)
+}
+
+// HandleWithPolicy adds a route for pattern that judges its senders by
+// policy.
+func (r *Router) HandleWithPolicy(pattern string, policy Policy, handler Handler) {
+	r.handle(pattern, &policy, handler)
+}
+
+func (r *Router) handle(pattern string, policy *Policy, handler Handler) {
+	parsed, err := ParsePattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	r.routes = append(r.routes, route{pattern: parsed, policy: policy, handler: handler})
+}
+
+// Route hands the message to the route of every Routed address that
+// matches one, each address once, or to the Fallback when none did. The
+// policy of the route decides on the sender first: rejected mail is
+// dropped and quarantined mail goes to the Quarantine instead. Every
+// matched route runs even when an earlier one failed; the first error is
+// returned and the others are logged.
+func (r *Router) Route(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, message Message) error {
+	external := r.external(append(append([]*mail.Address{}, message.To...), message.Cc...))
+	seen := map[string]bool{}
+	matched := false
+	var firstErr error
+	for _, recipient := range message.Routed() {
+		if recipient == nil {
+			continue
+		}
+		address := strings.ToLower(strings.TrimSpace(recipient.Address))
+		if address == "" || seen[address] {
+			continue
+		}
+		seen[address] = true
+		route, params, ok := r.match(address)
+		if !ok {
+			continue
+		}
+		matched = true
+		log.Printf("Routing mail to %v through %v with %v", address, route.pattern, params)
+		policy := r.DefaultPolicy
+		if route.policy != nil {
+			policy = *route.policy
+		}
+		err := r.dispatch(ctx, resolver, sess, policy, route.handler, Request{
+			Message:   message,
+			Recipient: recipient,
+			Route:     route.pattern.String(),
+			Params:    params,
+			External:  external,
+		})
+		if err != nil {
+			log.Printf("Route %v failed for %v: %v", route.pattern, address, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if matched {
+		return firstErr
+	}
+
+	if r.Fallback == nil {
+		log.Printf("Failed to find a proper route for: %v", recipientsOf(message))
+		return nil
+	}
+	log.Printf("No route for %v, using the fallback", recipientsOf(message))
+	return r.dispatch(ctx, resolver, sess, r.DefaultPolicy, r.Fallback, Request{Message: message, External: external})
+}
+
+// dispatch decides on the sender of the request under policy and hands it
+// to handler, the Quarantine or nobody accordingly.
+func (r *Router) dispatch(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, policy Policy, handler Handler, request Request) error {
+	decision := policy.Decide(request.Message.Verdicts)
+	request.Decision = &decision
+	from := request.Message.From
+	switch decision.Action {
+	case Reject:
+		log.Printf("Rejected mail from %v to %v: %v", from, request.Route, decision.Reason)
+		return nil
+	case Quarantine:
+		if r.Quarantine == nil {
+			log.Printf("Quarantined mail from %v to %v, without a quarantine to keep it: %v", from, request.Route, decision.Reason)
+			return nil
+		}
+		log.Printf("Quarantining mail from %v to %v: %v", from, request.Route, decision.Reason)
+		return r.Quarantine(ctx, resolver, sess, request)
+	case Flag:
+		log.Printf("Flagging mail from %v to %v: %v", from, request.Route, decision.Reason)
+	}
+	return handler(ctx, resolver, sess, request)
+}
+
+func (r *Router) match(address string) (route, map[string]string, bool) {
+	localPart, ok := r.localPart(address)
+	if !ok {
+		return route{}, nil, false
+	}
+	for _, route := range r.routes {
+		if params, ok := route.pattern.Match(localPart); ok {
+			return route, params, true
+		}
+	}
+	return route{}, nil, false
+}
+
+// localPart returns the local part of address when the router routes its
+// domain.
+func (r *Router) localPart(address string) (string, bool) {
+	at := strings.LastIndex(address, "@")
+	if at <= 0 {
+		return "", false
+	}
+	if !r.Routes(address) {
+		return "", false
+	}
+	return address[:at], true
+}
+
+// Routes reports whether address is at one of the router's domains.
+func (r *Router) Routes(address string) bool {
+	if len(r.Domains) == 0 {
+		return true
+	}
+	domain := strings.ToLower(address[strings.LastIndex(address, "@")+1:])
+	for _, routed := range r.Domains {
+		if domain == routed {
+			return true
+		}
+	}
+	return false
+}
+
+// external returns the recipients the router does not route: those outside
+// its domains, or without domains, those matching no route.
+func (r *Router) external(recipients []*mail.Address) []*mail.Address {
+	var external []*mail.Address
+	for _, recipient := range recipients {
+		if recipient == nil {
+			continue
+		}
+		address := strings.ToLower(strings.TrimSpace(recipient.Address))
+		if len(r.Domains) > 0 {
+			if r.Routes(address) {
+				continue
+			}
+		} else if _, _, ok := r.match(address); ok {
+			continue
+		}
+		external = append(external, recipient)
+	}
+	return external
+}
+
+func recipientsOf(message Message) []string {
+	var recipients []string
+	for _, recipient := range message.Routed() {
+		if recipient != nil {
+			recipients = append(recipients, recipient.Address)
+		}
+	}
+	return recipients
+}
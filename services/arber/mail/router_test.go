@@ -0,0 +1,294 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/mail"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
+)
+
+func TestRouter(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("ParsePattern", func() {
+		g.It("Should capture parameters and ignore case", func() {
+			pattern, err := ParsePattern("share+{txId}")
+			Expect(err).ShouldNot(HaveOccurred())
+			params, ok := pattern.Match("Share+ABC-123")
+			Expect(ok).Should(BeTrue())
+			Expect(params).Should(Equal(map[string]string{"txId": "abc-123"}))
+
+			_, ok = pattern.Match("share+")
+			Expect(ok).Should(BeFalse())
+			_, ok = pattern.Match("reshare+abc")
+			Expect(ok).Should(BeFalse())
+		})
+
+		g.It("Should match literal patterns whole", func() {
+			pattern, err := ParsePattern("start")
+			Expect(err).ShouldNot(HaveOccurred())
+			params, ok := pattern.Match("START")
+			Expect(ok).Should(BeTrue())
+			Expect(params).Should(BeEmpty())
+			_, ok = pattern.Match("startup")
+			Expect(ok).Should(BeFalse())
+		})
+
+		g.It("Should capture several separated parameters", func() {
+			pattern, err := ParsePattern("{kind}.{id}+x")
+			Expect(err).ShouldNot(HaveOccurred())
+			params, ok := pattern.Match("apply.c1+x")
+			Expect(ok).Should(BeTrue())
+			Expect(params).Should(Equal(map[string]string{"kind": "apply", "id": "c1"}))
+		})
+
+		g.It("Should refuse invalid patterns", func() {
+			for _, source := range []string{"", "share+{", "share}", "{}", "{1d}", "{a}{b}", "{a}+{a}"} {
+				_, err := ParsePattern(source)
+				Expect(err).Should(HaveOccurred(), source)
+			}
+		})
+	})
+
+	g.Describe("Router", func() {
+		var router *Router
+		var routed []Request
+		ctx := context.Background()
+		resolver := Resolver.NewMemoryResolver()
+		from := &mail.Address{Address: "sender@example.com"}
+
+		record := func(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error {
+			routed = append(routed, request)
+			return nil
+		}
+
+		g.BeforeEach(func() {
+			routed = nil
+			router = NewRouter("redb.ai")
+			router.Handle("share+{txId}", record)
+			router.Handle("apply+{challengeId}", record)
+			router.Handle("stop", record)
+		})
+
+		g.It("Should route every recipient of our domains once", func() {
+			err := router.Route(ctx, resolver, clients.SESService{}, Message{
+				From: from,
+				To: []*mail.Address{
+					{Address: "friend@example.com"},
+					{Address: "Apply+C1@redb.ai"},
+					{Address: "stop@example.com"},
+				},
+				Bcc: []*mail.Address{
+					{Address: "share+t1@redb.ai"},
+					{Address: "apply+c1@redb.ai"},
+				},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(routed).Should(HaveLen(2))
+			Expect(routed[0].Route).Should(Equal("apply+{challengeId}"))
+			Expect(routed[0].Params).Should(Equal(map[string]string{"challengeId": "c1"}))
+			Expect(routed[1].Route).Should(Equal("share+{txId}"))
+			Expect(routed[1].Recipient.Address).Should(Equal("share+t1@redb.ai"))
+			Expect(routed[1].External).Should(Equal([]*mail.Address{{Address: "friend@example.com"}, {Address: "stop@example.com"}}))
+		})
+
+		g.It("Should run every route and return the first error", func() {
+			failed := errors.New("failed")
+			router.Handle("{name}", func(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error {
+				routed = append(routed, request)
+				return failed
+			})
+			err := router.Route(ctx, resolver, clients.SESService{}, Message{
+				From: from,
+				To:   []*mail.Address{{Address: "hello@redb.ai"}, {Address: "jobs@redb.ai"}, {Address: "stop@redb.ai"}},
+			})
+			Expect(err).Should(Equal(failed))
+			Expect(routed).Should(HaveLen(3))
+		})
+
+		g.It("Should hand unmatched mail to the fallback once", func() {
+			message := Message{From: from, To: []*mail.Address{{Address: "hello@redb.ai"}, {Address: "friend@example.com"}}}
+			Expect(router.Route(ctx, resolver, clients.SESService{}, message)).Should(Succeed())
+			Expect(routed).Should(BeEmpty())
+
+			router.Fallback = record
+			Expect(router.Route(ctx, resolver, clients.SESService{}, message)).Should(Succeed())
+			Expect(routed).Should(HaveLen(1))
+			Expect(routed[0].Recipient).Should(BeNil())
+			Expect(routed[0].External).Should(Equal([]*mail.Address{{Address: "friend@example.com"}}))
+		})
+
+		g.It("Should route every domain without domains", func() {
+			router.Domains = nil
+			err := router.Route(ctx, resolver, clients.SESService{}, Message{
+				From: from,
+				To:   []*mail.Address{{Address: "stop@example.com"}, {Address: "friend@example.com"}},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(routed).Should(HaveLen(1))
+			Expect(routed[0].External).Should(Equal([]*mail.Address{{Address: "friend@example.com"}}))
+		})
+	})
+
+	g.Describe("Authenticate", func() {
+		g.It("Should judge mail by DMARC, then SPF and DKIM", func() {
+			cases := []struct {
+				verdicts       *clients.Verdicts
+				authentication Authentication
+			}{
+				{nil, Unverified},
+				{&clients.Verdicts{DMARC: clients.VerdictPass, SPF: clients.VerdictFail}, Authenticated},
+				{&clients.Verdicts{DMARC: clients.VerdictFail, DMARCPolicy: "REJECT", DKIM: clients.VerdictPass}, Spoofed},
+				{&clients.Verdicts{DMARC: clients.VerdictFail, DMARCPolicy: "NONE", DKIM: clients.VerdictPass}, Unverified},
+				{&clients.Verdicts{DMARC: clients.VerdictGray, SPF: clients.VerdictFail, DKIM: clients.VerdictGray}, Spoofed},
+				{&clients.Verdicts{DMARC: clients.VerdictGray, SPF: clients.VerdictFail, DKIM: clients.VerdictPass}, Unverified},
+				{&clients.Verdicts{DMARC: clients.VerdictGray, SPF: clients.VerdictPass, DKIM: clients.VerdictGray}, Unverified},
+			}
+			for _, c := range cases {
+				authentication, reason := Authenticate(c.verdicts)
+				Expect(authentication).Should(Equal(c.authentication), reason)
+				Expect(reason).ShouldNot(BeEmpty())
+			}
+		})
+
+		g.It("Should decide by the policy and accept under the zero policy", func() {
+			spoofed := &clients.Verdicts{DMARC: clients.VerdictFail, DMARCPolicy: "QUARANTINE"}
+			policy := Policy{Spoofed: Reject, Unverified: Flag}
+			Expect(policy.Decide(spoofed).Action).Should(Equal(Reject))
+			Expect(policy.Decide(nil).Action).Should(Equal(Flag))
+			Expect(policy.Decide(&clients.Verdicts{DMARC: clients.VerdictPass}).Action).Should(Equal(Accept))
+			Expect(Policy{}.Decide(spoofed).Action).Should(Equal(Accept))
+
+			decision := policy.Decide(nil)
+			Expect(decision.Flagged()).Should(BeTrue())
+			var stored Decision
+			Expect(json.Unmarshal([]byte(*decision.JSON()), &stored)).Should(Succeed())
+			Expect(stored.Authentication).Should(Equal(Unverified))
+			Expect(stored.Action).Should(Equal(Flag))
+
+			var none *Decision
+			Expect(none.Flagged()).Should(BeFalse())
+			Expect(none.JSON()).Should(BeNil())
+		})
+
+		g.It("Should quarantine a start that could not be verified", func() {
+			Expect(StartPolicy.Decide(nil).Action).Should(Equal(Quarantine))
+		})
+	})
+
+	g.Describe("Router policies", func() {
+		var router *Router
+		var routed, quarantined []Request
+		ctx := context.Background()
+		resolver := Resolver.NewMemoryResolver()
+		spoofed := Message{
+			From:     &mail.Address{Address: "ceo@example.com"},
+			To:       []*mail.Address{{Address: "share+t1@redb.ai"}, {Address: "apply+c1@redb.ai"}, {Address: "stop@redb.ai"}},
+			Verdicts: &clients.Verdicts{DMARC: clients.VerdictFail, DMARCPolicy: "REJECT"},
+		}
+
+		g.BeforeEach(func() {
+			routed, quarantined = nil, nil
+			record := func(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error {
+				routed = append(routed, request)
+				return nil
+			}
+			router = NewRouter("redb.ai")
+			router.HandleWithPolicy("share+{txId}", Policy{Spoofed: Flag}, record)
+			router.HandleWithPolicy("apply+{challengeId}", Policy{Spoofed: Quarantine}, record)
+			router.HandleWithPolicy("stop", Policy{Spoofed: Reject}, record)
+			router.Quarantine = func(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error {
+				quarantined = append(quarantined, request)
+				return nil
+			}
+		})
+
+		g.It("Should flag, quarantine or reject spoofed mail per route", func() {
+			Expect(router.Route(ctx, resolver, clients.SESService{}, spoofed)).Should(Succeed())
+			Expect(routed).Should(HaveLen(1))
+			Expect(routed[0].Route).Should(Equal("share+{txId}"))
+			Expect(routed[0].Decision.Flagged()).Should(BeTrue())
+			Expect(routed[0].Decision.Authentication).Should(Equal(Spoofed))
+			Expect(quarantined).Should(HaveLen(1))
+			Expect(quarantined[0].Route).Should(Equal("apply+{challengeId}"))
+			Expect(quarantined[0].Decision.Action).Should(Equal(Quarantine))
+		})
+
+		g.It("Should accept authenticated mail on every route", func() {
+			message := spoofed
+			message.Verdicts = &clients.Verdicts{DMARC: clients.VerdictPass}
+			Expect(router.Route(ctx, resolver, clients.SESService{}, message)).Should(Succeed())
+			Expect(routed).Should(HaveLen(3))
+			Expect(quarantined).Should(BeEmpty())
+			for _, request := range routed {
+				Expect(request.Decision.Action).Should(Equal(Accept))
+			}
+		})
+
+		g.It("Should judge the fallback by the default policy", func() {
+			router.DefaultPolicy = Policy{Spoofed: Reject}
+			router.Fallback = func(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error {
+				routed = append(routed, request)
+				return nil
+			}
+			message := Message{From: spoofed.From, To: []*mail.Address{{Address: "hello@redb.ai"}}, Verdicts: spoofed.Verdicts}
+			Expect(router.Route(ctx, resolver, clients.SESService{}, message)).Should(Succeed())
+			Expect(routed).Should(BeEmpty())
+		})
+	})
+
+	g.Describe("Fallbacks", func() {
+		ctx := context.Background()
+		resolver := Resolver.NewMemoryResolver()
+
+		g.It("Should auto-reply to people but not to daemons or ourselves", func() {
+			var replies []clients.EmailRequest
+			defer func(original func(clients.SESService, clients.EmailRequest) error) { bounce = original }(bounce)
+			bounce = func(sess clients.SESService, request clients.EmailRequest) error {
+				replies = append(replies, request)
+				return nil
+			}
+			for _, sender := range []string{"jane@example.com", "MAILER-DAEMON@example.com", "no-reply@redb.ai", "team@redb.ai"} {
+				request := Request{Message: Message{From: &mail.Address{Address: sender}, Subject: "hi"}}
+				Expect(AutoReply(ctx, resolver, clients.SESService{}, request)).Should(Succeed())
+			}
+			Expect(replies).Should(HaveLen(1))
+			Expect(replies[0].Recipient).Should(Equal("jane@example.com"))
+			Expect(replies[0].Subject).Should(Equal("Your message was not delivered: hi"))
+		})
+
+		g.It("Should store dead letters in the bucket", func() {
+			var stored map[string][]byte
+			defer func(original func(string, string, []byte) error) { storeDeadLetter = original }(storeDeadLetter)
+			storeDeadLetter = func(bucket string, key string, data []byte) error {
+				stored = map[string][]byte{bucket + "/" + key: data}
+				return nil
+			}
+			request := Request{Message: Message{
+				From:    &mail.Address{Address: "jane@example.com"},
+				To:      []*mail.Address{{Address: "hello@redb.ai"}},
+				Subject: "hi",
+				Body:    "anyone there?",
+			}}
+			Expect(DeadLetter("redb-dead-letters")(ctx, resolver, clients.SESService{}, request)).Should(Succeed())
+			Expect(stored).Should(HaveLen(1))
+			for key, data := range stored {
+				Expect(key).Should(HavePrefix("redb-dead-letters/"))
+				var letter deadLetter
+				Expect(json.Unmarshal(data, &letter)).Should(Succeed())
+				Expect(letter.From).Should(Equal("jane@example.com"))
+				Expect(letter.To).Should(Equal([]string{"hello@redb.ai"}))
+				Expect(letter.Body).Should(Equal("anyone there?"))
+			}
+		})
+	})
+}
@@ -0,0 +1,278 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ApplyService "gitlab.com/ncent/arber/api/services/arber/apply"
+	AttachmentController "gitlab.com/ncent/arber/api/services/arber/attachment"
+	AttributionController "gitlab.com/ncent/arber/api/services/arber/attribution"
+	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
+	FraudController "gitlab.com/ncent/arber/api/services/arber/fraud"
+	ShareActionController "gitlab.com/ncent/arber/api/services/arber/share"
+	UserController "gitlab.com/ncent/arber/api/services/arber/user"
+	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
+	helpers "gitlab.com/ncent/arber/api/services/google/helper"
+)
+
+// DefaultDomains are the domains routed when INBOUND_DOMAINS, a comma
+// separated list, is not set.
+const DefaultDomains = "redb.ai"
+
+// DefaultRouter routes the inbound mail of ProcessInbound.
+var DefaultRouter = NewDefaultRouter()
+
+// Policies of the default routes. A challenge is started in the sender's
+// name, so a spoofed start is rejected and an unverified one, which includes
+// mail stored without the headers SES stamps, quarantined until someone has
+// looked at it. A spoofed share is recorded but flagged, which leaves its
+// chain out of payouts. A spoofed application is kept for the sponsor to look
+// at, and nobody is unsubscribed by someone else. Unrouted spoofed mail is
+// not answered.
+var (
+	StartPolicy   = Policy{Spoofed: Reject, Unverified: Quarantine}
+	SharePolicy   = Policy{Spoofed: Flag, Unverified: Accept}
+	ApplyPolicy   = Policy{Spoofed: Quarantine, Unverified: Accept}
+	StopPolicy    = Policy{Spoofed: Reject, Unverified: Accept}
+	DefaultPolicy = Policy{Spoofed: Reject, Unverified: Accept}
+)
+
+// NewDefaultRouter returns a router with the routes of redb, the fallback
+// configured by INBOUND_FALLBACK and the quarantine in QUARANTINE_BUCKET.
+func NewDefaultRouter() *Router {
+	router := NewRouter(inboundDomains()...)
+	router.DefaultPolicy = DefaultPolicy
+	router.HandleWithPolicy("start", StartPolicy, StartChallenge)
+	router.HandleWithPolicy("share+{txId}", SharePolicy, RecordShare)
+	router.HandleWithPolicy("apply+{challengeId}", ApplyPolicy, ApplyByMail)
+	router.HandleWithPolicy("stop", StopPolicy, Unsubscribe)
+	router.Fallback = FallbackFromEnv()
+	router.Quarantine = QuarantineFromEnv()
+	return router
+}
+
+func inboundDomains() []string {
+	domains := os.Getenv("INBOUND_DOMAINS")
+	if domains == "" {
+		domains = DefaultDomains
+	}
+	return strings.Split(domains, ",")
+}
+
+// StartChallenge creates a challenge from mail to start@: the subject names
+// it, the first line of the body sums it up and the first attachment is
+// its description.
+func StartChallenge(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error {
+	message := request.Message
+	var firstAttachmentURL string
+	if len(message.Attachments) > 0 {
+		attachmentURLs, err := AttachmentController.SaveAttachments(message.Attachments)
+		if err != nil {
+			log.Printf("Failed to save attachments: %+v", err)
+		} else if len(attachmentURLs) > 0 {
+			firstAttachmentURL = *attachmentURLs[0]
+		}
+	}
+
+	user, err := UserController.CreateSparseUser(ctx, resolver, message.From)
+	if err != nil {
+		return err
+	}
+	bodyLines, _ := StringToLines(message.Body)
+	var summary string
+	if len(bodyLines) > 0 {
+		summary = bodyLines[0]
+	}
+	challenge, err := ChallengeController.CreateChallenge(
+		ctx,
+		resolver,
+		message.Subject,
+		message.From,
+		message.Subject,
+		summary,
+		message.Body,
+		firstAttachmentURL,
+	)
+	if err != nil {
+		return err
+	}
+	// The start email carries the hire link, so it is sent with the
+	// created challenge: only that one holds the sponsor token.
+	if request.Decision != nil {
+		_, err = resolver.UpdateChallengeContext(ctx, appsync.UpdateChallengeInput{
+			ID:                 *challenge.ID,
+			MailAuthentication: request.Decision.JSON(),
+		})
+		if err != nil {
+			return fmt.Errorf("Failed to record mail authentication of challenge: %w", err)
+		}
+	}
+
+	helpers.SendStartEmail(*user, *challenge)
+	return nil
+}
+
+// RecordShare records the sender of mail Bcc'd to share+{txId} and the
+// people they wrote to on the share action of the transaction, along with
+// how the mail authenticated. A flagged share is also a fraud finding on
+// the transaction.
+func RecordShare(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error {
+	message := request.Message
+	transactionID := request.Params["txId"]
+	err := ShareActionController.CreateShareActionContacts(ctx, resolver, transactionID, message.From, request.External)
+	if err == nil && request.Decision != nil {
+		err = recordShareDecision(ctx, resolver, transactionID, message.From, request.Decision)
+	}
+	// A refused share is not a failure: tell the sender and drop the
+	// message.
+	var limitErr *ShareActionController.LimitError
+	if errors.As(err, &limitErr) {
+		return bounceShare(sess, message.From, message.Subject, limitErr.Reason)
+	}
+	var closedErr *ChallengeController.ClosedError
+	if errors.As(err, &closedErr) {
+		return bounceShare(sess, message.From, message.Subject, closedErr.Reason)
+	}
+	return err
+}
+
+func recordShareDecision(ctx context.Context, resolver Resolver.Resolver, transactionID string, from *mail.Address, decision *Decision) error {
+	transaction, err := resolver.GetTransactionContext(ctx, transactionID)
+	if err != nil {
+		return fmt.Errorf("Failed to get transaction: %w", err)
+	}
+	if transaction.Action == nil {
+		return fmt.Errorf("Transaction %v has no share action", transactionID)
+	}
+	_, err = resolver.UpdateShareActionContext(ctx, appsync.UpdateShareActionInput{
+		ID:                 *transaction.Action.ID,
+		MailAuthentication: decision.JSON(),
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to record mail authentication of share: %w", err)
+	}
+	if !decision.Flagged() {
+		return nil
+	}
+	finding := FraudController.Finding{
+		Kind:          FraudController.Spoofed,
+		TransactionID: transactionID,
+		Detail:        fmt.Sprintf("mail from %v did not authenticate: %v", from.Address, decision.Reason),
+	}
+	if transaction.Action.UserID != nil {
+		finding.UserID = *transaction.Action.UserID
+	}
+	return FraudController.Record(ctx, resolver, []FraudController.Finding{finding})
+}
+
+// ApplyByMail applies the sender to the challenge of apply+{challengeId},
+// with the body as their message and the first attachment as their resume.
+// Mail carries no apply link, so the chains that reached the sender are
+// credited under the challenge's attribution model. Only a sender no share
+// reached applies through a new root transaction of the challenge, which is
+// discarded again if the application is not recorded.
+func ApplyByMail(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error {
+	message := request.Message
+	challengeID := request.Params["challengeId"]
+	challenge, err := ChallengeController.GetChallenge(ctx, resolver, challengeID)
+	if err != nil {
+		return err
+	}
+	// Checked before anything is written, which Apply checks again.
+	var closedErr *ChallengeController.ClosedError
+	if err := ChallengeController.CheckOpen(challenge, time.Now()); errors.As(err, &closedErr) {
+		return bounceApplication(sess, message.From, message.Subject, closedErr.Reason)
+	} else if err != nil {
+		return err
+	}
+
+	candidate, err := UserController.CreateSparseUser(ctx, resolver, message.From)
+	if err != nil {
+		return err
+	}
+	existing, err := ApplyService.FindApplication(ctx, resolver, challengeID, *candidate.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		log.Printf("%v already applied to %v as %v", message.From.Address, challengeID, *existing.ID)
+		return nil
+	}
+
+	applyRequest := ApplyService.Request{
+		ChallengeID:        challengeID,
+		Candidate:          message.From,
+		Message:            strings.TrimSpace(message.Body),
+		MailAuthentication: request.Decision.JSON(),
+	}
+	if len(message.Attachments) > 0 {
+		applyRequest.Resume = &message.Attachments[0]
+	}
+	touches, err := AttributionController.Touches(ctx, resolver, challengeID, *candidate.ID)
+	if err != nil {
+		return err
+	}
+	var root *appsync.Transaction
+	if len(touches) == 0 {
+		root, err = ShareActionController.CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", challengeID)
+		if err != nil {
+			return err
+		}
+		applyRequest.TransactionID = *root.ID
+	}
+
+	application, err := ApplyService.Apply(ctx, resolver, applyRequest)
+	if err != nil && root != nil {
+		discardRoot(ctx, resolver, root)
+	}
+	if errors.Is(err, ApplyService.ErrAlreadyApplied) {
+		log.Printf("%v already applied to %v as %v", message.From.Address, challengeID, *application.ID)
+		return nil
+	}
+	if errors.As(err, &closedErr) {
+		return bounceApplication(sess, message.From, message.Subject, closedErr.Reason)
+	}
+	if errors.Is(err, ApplyService.ErrInvalidRequest) {
+		return bounceApplication(sess, message.From, message.Subject, err.Error())
+	}
+	return err
+}
+
+// discardRoot soft-deletes the root transaction of an application by mail
+// that was not recorded. A failure is only logged: the root is addressed to
+// nobody, so it is never a touch of another candidate.
+func discardRoot(ctx context.Context, resolver Resolver.Resolver, root *appsync.Transaction) {
+	if _, err := resolver.DeleteTransactionContext(ctx, *root.ID); err != nil {
+		log.Printf("Failed to discard root transaction %v: %v", *root.ID, err)
+	}
+	if root.TransactionActionID != nil {
+		if _, err := resolver.DeleteShareActionContext(ctx, *root.TransactionActionID); err != nil {
+			log.Printf("Failed to discard share action %v: %v", *root.TransactionActionID, err)
+		}
+	}
+}
+
+// Unsubscribe stops notifications to the sender of mail to stop@.
+func Unsubscribe(ctx context.Context, resolver Resolver.Resolver, sess clients.SESService, request Request) error {
+	_, err := UserController.Unsubscribe(ctx, resolver, request.Message.From)
+	return err
+}
+
+func bounceApplication(sess clients.SESService, from *mail.Address, subject string, reason string) error {
+	return bounce(sess, clients.EmailRequest{
+		Recipient: from.Address,
+		Sender:    "no-reply@redb.ai",
+		Subject:   "Your application was not delivered: " + subject,
+		Html:      fmt.Sprintf("<p>Your application was not sent.</p><p>%s</p>", html.EscapeString(reason)),
+		Body:      fmt.Sprintf("Your application was not sent.\n\n%s", reason),
+	})
+}
@@ -0,0 +1,328 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	AddressController "gitlab.com/ncent/arber/api/services/arber/address"
+)
+
+var (
+	// ErrSameUser is returned when asked to merge a user into themselves.
+	ErrSameUser = errors.New("cannot merge a user into themselves")
+	// ErrAlreadyMerged is returned when the merged user is already the
+	// tombstone of an earlier merge.
+	ErrAlreadyMerged = errors.New("user was already merged")
+)
+
+// Retired is the status of an application that lost to another application
+// of the survivor to the same challenge. It stays with the tombstone.
+const Retired = "MERGED"
+
+// hired is the status the hire package writes on the application that
+// filled a challenge.
+const hired = "HIRED"
+
+// now is replaced in tests.
+var now = time.Now
+
+// Result lists what a merge moved onto the surviving user. Transactions
+// hang off share actions, so they are the transactions of the moved share
+// actions. RemovedContacts are contact edges the survivor already had, or
+// that would have pointed the survivor at themselves. RetiredApplications
+// are applications to a challenge both users had applied to that were not
+// kept.
+type Result struct {
+	Survivor            *appsync.User `json:"survivor"`
+	Tombstone           *appsync.User `json:"tombstone"`
+	ShareActions        []string      `json:"shareActions,omitempty"`
+	Transactions        []string      `json:"transactions,omitempty"`
+	ShareActionContacts []string      `json:"shareActionContacts,omitempty"`
+	Contacts            []string      `json:"contacts,omitempty"`
+	RemovedContacts     []string      `json:"removedContacts,omitempty"`
+	Applications        []string      `json:"applications,omitempty"`
+	RetiredApplications []string      `json:"retiredApplications,omitempty"`
+}
+
+// Merge moves everything of mergedID onto survivorID: the share actions it
+// sent with their transactions, the shares addressed to it, its contact
+// edges, its applications and its addresses, numbers and pictures. The
+// merged user is then left as a soft-deleted tombstone naming the survivor.
+// The tombstone is written last, so a merge that failed halfway can be run
+// again.
+func Merge(ctx context.Context, resolver Resolver.Resolver, survivorID string, mergedID string) (*Result, error) {
+	if survivorID == mergedID {
+		return nil, fmt.Errorf("%w: %v", ErrSameUser, survivorID)
+	}
+	tombstone, err := resolver.IncludeDeleted().GetUserContext(ctx, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get merged user: %w", err)
+	}
+	if tombstone.MergedInto != nil {
+		return nil, fmt.Errorf("%w: %v into %v", ErrAlreadyMerged, mergedID, *tombstone.MergedInto)
+	}
+	merged, err := resolver.GetUserContext(ctx, mergedID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get merged user: %w", err)
+	}
+	survivor, err := resolver.GetUserContext(ctx, survivorID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get surviving user: %w", err)
+	}
+	log.Printf("Merging user %v into %v", mergedID, survivorID)
+
+	result := &Result{}
+	if err := moveShareActions(ctx, resolver, result, survivorID, mergedID); err != nil {
+		return nil, err
+	}
+	if err := moveShareActionContacts(ctx, resolver, result, survivorID, mergedID); err != nil {
+		return nil, err
+	}
+	if err := moveContacts(ctx, resolver, result, survivorID, mergedID); err != nil {
+		return nil, err
+	}
+	if err := moveApplications(ctx, resolver, result, survivorID, mergedID); err != nil {
+		return nil, err
+	}
+
+	result.Survivor, err = appsync.MergeUser(ctx, resolver, profileInput(survivor, merged))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to merge profile of %v into %v: %w", mergedID, survivorID, err)
+	}
+
+	deletedAt := now().UTC().Format(time.RFC3339)
+	result.Tombstone, err = resolver.UpdateUserContext(ctx, appsync.UpdateUserInput{
+		ID:             mergedID,
+		MergedInto:     &survivorID,
+		MergeProposals: []*string{},
+		DeletedAt:      &deletedAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to record tombstone of %v: %w", mergedID, err)
+	}
+
+	survivor, err = dropProposals(ctx, resolver, survivorID, mergedID)
+	if err != nil {
+		// The merge stands; only a stale proposal is left.
+		log.Printf("Failed to drop merge proposals of %v: %v", survivorID, err)
+	} else {
+		result.Survivor = survivor
+	}
+	log.Printf("Merged user %v into %v: %+v", mergedID, survivorID, result)
+	return result, nil
+}
+
+// moveShareActions hands the share actions the merged user sent, deleted
+// ones included, to the survivor.
+func moveShareActions(ctx context.Context, resolver Resolver.Resolver, result *Result, survivorID string, mergedID string) error {
+	all := resolver.IncludeDeleted()
+	shareActions, err := all.GetShareActionsByUserContext(ctx, mergedID)
+	if err != nil {
+		return fmt.Errorf("Failed to get share actions of %v: %w", mergedID, err)
+	}
+	for _, shareAction := range shareActions {
+		if _, err := all.UpdateShareActionContext(ctx, appsync.UpdateShareActionInput{ID: *shareAction.ID, UserID: &survivorID}); err != nil {
+			return fmt.Errorf("Failed to move share action %v: %w", *shareAction.ID, err)
+		}
+		result.ShareActions = append(result.ShareActions, *shareAction.ID)
+
+		transactions, err := all.GetTransactionsByShareActionContext(ctx, *shareAction.ID)
+		if err != nil {
+			return fmt.Errorf("Failed to get transactions of share action %v: %w", *shareAction.ID, err)
+		}
+		for _, transaction := range transactions {
+			result.Transactions = append(result.Transactions, *transaction.ID)
+		}
+	}
+	return nil
+}
+
+// moveShareActionContacts readdresses the shares the merged user received.
+func moveShareActionContacts(ctx context.Context, resolver Resolver.Resolver, result *Result, survivorID string, mergedID string) error {
+	contacts, err := resolver.GetShareActionContactsByContactContext(ctx, mergedID)
+	if err != nil {
+		return fmt.Errorf("Failed to get shares addressed to %v: %w", mergedID, err)
+	}
+	for _, contact := range contacts {
+		if _, err := resolver.UpdateShareActionContactContext(ctx, appsync.UpdateShareActionContactInput{ID: *contact.ID, ShareActionContactContactID: &survivorID}); err != nil {
+			return fmt.Errorf("Failed to move share action contact %v: %w", *contact.ID, err)
+		}
+		result.ShareActionContacts = append(result.ShareActionContacts, *contact.ID)
+	}
+	return nil
+}
+
+// moveContacts repoints the contact edges of the merged user, both ways, at
+// the survivor. Edges the survivor already has and edges that would join
+// the survivor to themselves are deleted instead.
+func moveContacts(ctx context.Context, resolver Resolver.Resolver, result *Result, survivorID string, mergedID string) error {
+	existing := map[string]bool{}
+	for _, userID := range []string{survivorID, mergedID} {
+		edges, err := contactEdges(ctx, resolver, userID)
+		if err != nil {
+			return err
+		}
+		for _, edge := range edges {
+			if userID == survivorID {
+				existing[edgeKey(*edge.UserContactUserID, *edge.UserContactContactID)] = true
+				continue
+			}
+
+			input := appsync.UpdateUserContactInput{ID: *edge.ID}
+			from, to := *edge.UserContactUserID, *edge.UserContactContactID
+			if from == mergedID {
+				from = survivorID
+				input.UserContactUserID = &survivorID
+			}
+			if to == mergedID {
+				to = survivorID
+				input.UserContactContactID = &survivorID
+			}
+			if from == to || existing[edgeKey(from, to)] {
+				if _, err := resolver.DeleteUserContactContext(ctx, appsync.DeleteUserContactInput{ID: *edge.ID}); err != nil {
+					return fmt.Errorf("Failed to delete contact edge %v: %w", *edge.ID, err)
+				}
+				result.RemovedContacts = append(result.RemovedContacts, *edge.ID)
+				continue
+			}
+			if _, err := resolver.UpdateUserContactContext(ctx, input); err != nil {
+				return fmt.Errorf("Failed to move contact edge %v: %w", *edge.ID, err)
+			}
+			existing[edgeKey(from, to)] = true
+			result.Contacts = append(result.Contacts, *edge.ID)
+		}
+	}
+	return nil
+}
+
+// contactEdges returns the contact edges from and to the user, each once.
+func contactEdges(ctx context.Context, resolver Resolver.Resolver, userID string) ([]*appsync.UserContact, error) {
+	from, err := resolver.GetUserContactsByUserContext(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get contacts of %v: %w", userID, err)
+	}
+	to, err := resolver.GetUserContactsByContactContext(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get users with %v as a contact: %w", userID, err)
+	}
+	edges := from
+	for _, edge := range to {
+		if *edge.UserContactUserID != userID {
+			edges = append(edges, edge)
+		}
+	}
+	return edges, nil
+}
+
+func edgeKey(userID string, contactID string) string {
+	return userID + "->" + contactID
+}
+
+// moveApplications credits the merged user's applications to the survivor.
+// A candidate has one application per challenge, so where both users
+// applied to the same challenge only one is kept: the hired one, otherwise
+// the earlier. The other is retired onto the tombstone, before the kept one
+// moves, so a merge run again finds it already retired.
+func moveApplications(ctx context.Context, resolver Resolver.Resolver, result *Result, survivorID string, mergedID string) error {
+	applications, err := resolver.GetApplicationsByCandidateContext(ctx, mergedID)
+	if err != nil {
+		return fmt.Errorf("Failed to get applications of %v: %w", mergedID, err)
+	}
+	for _, application := range applications {
+		if stringValue(application.Status) == Retired {
+			continue
+		}
+		existing, err := resolver.GetApplicationsByChallengeAndCandidateContext(ctx, stringValue(application.ChallengeID), survivorID)
+		if err != nil {
+			return fmt.Errorf("Failed to get applications of %v: %w", survivorID, err)
+		}
+		if len(existing) > 0 {
+			if keep(existing[0], application) == existing[0] {
+				if err := retireApplication(ctx, resolver, result, application, mergedID); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := retireApplication(ctx, resolver, result, existing[0], mergedID); err != nil {
+				return err
+			}
+		}
+		if _, err := resolver.UpdateApplicationContext(ctx, appsync.UpdateApplicationInput{ID: *application.ID, CandidateID: &survivorID}); err != nil {
+			return fmt.Errorf("Failed to move application %v: %w", *application.ID, err)
+		}
+		result.Applications = append(result.Applications, *application.ID)
+	}
+	return nil
+}
+
+// keep picks which of two applications to the same challenge survives a
+// merge: the hired one, otherwise the earlier, and the survivor's on a tie.
+func keep(survivors *appsync.Application, merged *appsync.Application) *appsync.Application {
+	if stringValue(survivors.Status) == hired {
+		return survivors
+	}
+	if stringValue(merged.Status) == hired {
+		return merged
+	}
+	survivorsAt, err := time.Parse(time.RFC3339Nano, stringValue(survivors.CreatedAt))
+	if err != nil {
+		return survivors
+	}
+	mergedAt, err := time.Parse(time.RFC3339Nano, stringValue(merged.CreatedAt))
+	if err != nil || !mergedAt.Before(survivorsAt) {
+		return survivors
+	}
+	return merged
+}
+
+// retireApplication marks an application that lost to another one of the
+// survivor and leaves it with the tombstone.
+func retireApplication(ctx context.Context, resolver Resolver.Resolver, result *Result, application *appsync.Application, mergedID string) error {
+	status := Retired
+	input := appsync.UpdateApplicationInput{ID: *application.ID, CandidateID: &mergedID, Status: &status}
+	if _, err := resolver.UpdateApplicationContext(ctx, input); err != nil {
+		return fmt.Errorf("Failed to retire application %v: %w", *application.ID, err)
+	}
+	result.RetiredApplications = append(result.RetiredApplications, *application.ID)
+	return nil
+}
+
+// profileInput adds the lists of the merged user to the survivor. The blank
+// name of a sparse user is left behind, the Google identity only moves when
+// the survivor has none, and an unsubscribe is kept.
+func profileInput(survivor *appsync.User, merged *appsync.User) appsync.UpdateUserInput {
+	input := appsync.UpdateUserInput{
+		ID:              *survivor.ID,
+		Emails:          merged.Emails,
+		CanonicalEmails: AddressController.CanonicalAll(append(append([]*string{}, merged.Emails...), merged.CanonicalEmails...)),
+		PhoneNumbers:    merged.PhoneNumbers,
+		Pictures:        merged.Pictures,
+	}
+	for _, name := range merged.Names {
+		if name != nil && !blank(*name) {
+			input.Names = append(input.Names, name)
+		}
+	}
+	if stringValue(survivor.Identity) == "" && stringValue(merged.Identity) != "" {
+		input.Identity = merged.Identity
+		input.Token = merged.Token
+		input.Etag = merged.Etag
+	}
+	if survivor.UnsubscribedAt == nil && merged.UnsubscribedAt != nil {
+		// Whoever asked to stop mail still wants none.
+		input.UnsubscribedAt = merged.UnsubscribedAt
+	}
+	return input
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,220 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ShareController "gitlab.com/ncent/arber/api/services/arber/share"
+	UserController "gitlab.com/ncent/arber/api/services/arber/user"
+)
+
+func Test(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Merging users", func() {
+		var resolver *Resolver.MemoryResolver
+		var full *Resolver.User
+		var sparse *Resolver.User
+		ctx := context.Background()
+
+		values := func(list []*string) []string {
+			var result []string
+			for _, value := range list {
+				result = append(result, *value)
+			}
+			return result
+		}
+		sparseUser := func(address string) *Resolver.User {
+			user, err := UserController.CreateSparseUser(ctx, resolver, &mail.Address{Address: address})
+			Expect(err).ShouldNot(HaveOccurred())
+			return user
+		}
+		edge := func(user *Resolver.User, contact *Resolver.User) {
+			_, err := resolver.CreateUserContact(Resolver.CreateUserContactInput{UserContactUserID: user.ID, UserContactContactID: contact.ID})
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+			email, name, identity, phone := "janedoe@gmail.com", "Jane Doe", "google-oauth2|jane", "+1 (555) 010-0000"
+			var err error
+			full, err = resolver.CreateUser(Resolver.CreateUserInput{
+				Emails:          []*string{&email},
+				CanonicalEmails: []*string{&email},
+				Names:           []*string{&name},
+				Identity:        &identity,
+				PhoneNumbers:    []*string{&phone},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			sparse = sparseUser("jane@work.example.com")
+		})
+
+		g.Describe("Merge", func() {
+			g.It("Should move everything of the sparse user onto the full account", func() {
+				bob := sparseUser("bob@example.com")
+				carol := sparseUser("carol@example.com")
+				challenge, err := resolver.CreateChallenge(Resolver.CreateChallengeInput{})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				// Jane shared from her work address, and Bob shared with it.
+				sent, err := ShareController.CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *challenge.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(ShareController.CreateShareActionContacts(ctx, resolver, *sent.ID, &mail.Address{Address: "jane@work.example.com"}, nil)).Should(Succeed())
+				received, err := ShareController.CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *challenge.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(ShareController.CreateShareActionContacts(ctx, resolver, *received.ID, &mail.Address{Address: "bob@example.com"}, []*mail.Address{{Address: "jane@work.example.com"}})).Should(Succeed())
+
+				edge(sparse, bob)
+				edge(full, bob)
+				edge(sparse, full)
+				edge(carol, sparse)
+
+				candidate := *sparse.ID
+				application, err := resolver.CreateApplication(Resolver.CreateApplicationInput{ChallengeID: challenge.ID, CandidateID: &candidate, TransactionID: received.ID})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				sent, err = resolver.GetTransaction(*sent.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				received, err = resolver.GetTransaction(*received.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				result, err := Merge(ctx, resolver, *full.ID, *sparse.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(result.ShareActions).Should(Equal([]string{*sent.TransactionActionID}))
+				Expect(result.Transactions).Should(Equal([]string{*sent.ID}))
+				Expect(result.ShareActionContacts).Should(HaveLen(1))
+				Expect(result.Contacts).Should(HaveLen(1))
+				Expect(result.RemovedContacts).Should(HaveLen(2))
+				Expect(result.Applications).Should(Equal([]string{*application.ID}))
+
+				shareActions, err := resolver.GetShareActionsByUser(*full.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(shareActions).Should(HaveLen(1))
+				addressed, err := resolver.GetShareActionContactsByContact(*full.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*addressed[0].ShareAction.ID).Should(Equal(*received.TransactionActionID))
+				contacts, err := resolver.GetUserContactsByUser(*full.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(contacts).Should(HaveLen(1))
+				contacts, err = resolver.GetUserContactsByContact(*full.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*contacts[0].UserContactUserID).Should(Equal(*carol.ID))
+				moved, err := resolver.GetApplication(*application.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*moved.CandidateID).Should(Equal(*full.ID))
+
+				Expect(values(result.Survivor.Names)).Should(Equal([]string{"Jane Doe"}))
+				Expect(values(result.Survivor.Emails)).Should(ConsistOf("janedoe@gmail.com", "jane@work.example.com"))
+				found, err := UserController.FindUser(ctx, resolver, "Jane@Work.example.com")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*found.ID).Should(Equal(*full.ID))
+			})
+
+			g.It("Should keep one application per challenge, the hired one or else the earlier", func() {
+				challenge, err := resolver.CreateChallenge(Resolver.CreateChallengeInput{})
+				Expect(err).ShouldNot(HaveOccurred())
+				other, err := resolver.CreateChallenge(Resolver.CreateChallengeInput{})
+				Expect(err).ShouldNot(HaveOccurred())
+				apply := func(challenge *Resolver.Challenge, candidate *Resolver.User, status string) *Resolver.Application {
+					id := *challenge.ID + ":" + *candidate.ID
+					application, err := resolver.CreateApplication(Resolver.CreateApplicationInput{ID: &id, ChallengeID: challenge.ID, CandidateID: candidate.ID, Status: &status})
+					Expect(err).ShouldNot(HaveOccurred())
+					return application
+				}
+				earlier := apply(challenge, sparse, "SUBMITTED")
+				later := apply(challenge, full, "SUBMITTED")
+				hiredLater := apply(other, sparse, "HIRED")
+				submitted := apply(other, full, "SUBMITTED")
+
+				result, err := Merge(ctx, resolver, *full.ID, *sparse.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(result.Applications).Should(ConsistOf(*earlier.ID, *hiredLater.ID))
+				Expect(result.RetiredApplications).Should(ConsistOf(*later.ID, *submitted.ID))
+
+				for _, challenge := range []*Resolver.Challenge{challenge, other} {
+					applications, err := resolver.GetApplicationsByChallengeAndCandidate(*challenge.ID, *full.ID)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(applications).Should(HaveLen(1))
+				}
+				retired, err := resolver.GetApplication(*later.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*retired.Status).Should(Equal(Retired))
+				Expect(*retired.CandidateID).Should(Equal(*sparse.ID))
+			})
+
+			g.It("Should leave a tombstone and refuse to merge it again", func() {
+				result, err := Merge(ctx, resolver, *full.ID, *sparse.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*result.Tombstone.MergedInto).Should(Equal(*full.ID))
+
+				_, err = resolver.GetUser(*sparse.ID)
+				Expect(errors.Is(err, Resolver.ErrNotFound)).Should(BeTrue())
+				tombstone, err := resolver.IncludeDeleted().GetUser(*sparse.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(*tombstone.MergedInto).Should(Equal(*full.ID))
+
+				_, err = Merge(ctx, resolver, *full.ID, *sparse.ID)
+				Expect(errors.Is(err, ErrAlreadyMerged)).Should(BeTrue())
+				_, err = Merge(ctx, resolver, *full.ID, *full.ID)
+				Expect(errors.Is(err, ErrSameUser)).Should(BeTrue())
+			})
+		})
+
+		g.Describe("Propose", func() {
+			g.It("Should propose duplicates by alias and phone number on both users", func() {
+				// Stored by an import that did not look for aliases.
+				aliasEmail, canonical := "jane.doe+jobs@gmail.com", "janedoe@gmail.com"
+				alias, err := resolver.CreateUser(Resolver.CreateUserInput{Emails: []*string{&aliasEmail}, CanonicalEmails: []*string{&canonical}})
+				Expect(err).ShouldNot(HaveOccurred())
+				phone, blankName := "+15550100000", " "
+				imported, err := resolver.CreateUser(Resolver.CreateUserInput{PhoneNumbers: []*string{&phone}, Names: []*string{&blankName}})
+				Expect(err).ShouldNot(HaveOccurred())
+
+				proposals, err := Propose(ctx, resolver, full)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(proposals).Should(Equal([]Proposal{
+					{SurvivorID: *full.ID, MergedID: *alias.ID, Reason: "shares email jane.doe+jobs@gmail.com"},
+					{SurvivorID: *full.ID, MergedID: *imported.ID, Reason: "shares phone number +15550100000"},
+				}))
+				_, err = Propose(ctx, resolver, full)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				stored, err := resolver.GetUser(*full.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(values(stored.MergeProposals)).Should(Equal([]string{
+					*alias.ID + ": shares email jane.doe+jobs@gmail.com",
+					*imported.ID + ": shares phone number +15550100000",
+				}))
+				stored, err = resolver.GetUser(*alias.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(values(stored.MergeProposals)).Should(Equal([]string{*full.ID + ": shares email jane.doe+jobs@gmail.com"}))
+
+				result, err := Merge(ctx, resolver, *full.ID, *alias.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(values(result.Survivor.MergeProposals)).Should(Equal([]string{*imported.ID + ": shares phone number +15550100000"}))
+			})
+
+			g.It("Should keep the account that signed up", func() {
+				// Created before addresses were canonicalized, so not linked.
+				email := "jane.doe@googlemail.com"
+				alias, err := resolver.CreateUser(Resolver.CreateUserInput{Emails: []*string{&email}})
+				Expect(err).ShouldNot(HaveOccurred())
+				proposals, err := Duplicates(ctx, resolver, alias)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(proposals).Should(HaveLen(1))
+				Expect(proposals[0].SurvivorID).Should(Equal(*full.ID))
+				Expect(proposals[0].MergedID).Should(Equal(*alias.ID))
+				Expect(Sparse(alias)).Should(BeTrue())
+				Expect(Sparse(full)).Should(BeFalse())
+			})
+		})
+	})
+}
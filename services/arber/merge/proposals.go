@@ -0,0 +1,226 @@
+package merge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	AddressController "gitlab.com/ncent/arber/api/services/arber/address"
+	UserController "gitlab.com/ncent/arber/api/services/arber/user"
+)
+
+// Proposal is a pair of users that look like one person, with the one that
+// should survive a merge.
+type Proposal struct {
+	SurvivorID string `json:"survivorId"`
+	MergedID   string `json:"mergedId"`
+	Reason     string `json:"reason"`
+}
+
+// Sparse reports whether the user was only created because someone mailed
+// or listed their address: they never signed up and have no name.
+func Sparse(user *appsync.User) bool {
+	if stringValue(user.Identity) != "" {
+		return false
+	}
+	for _, name := range user.Names {
+		if name != nil && !blank(*name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Duplicates returns a proposal for every other user sharing an address,
+// up to aliases, or a phone number with user. A user who signed up survives
+// a sparse one; between two alike, user survives.
+func Duplicates(ctx context.Context, resolver Resolver.Resolver, user *appsync.User) ([]Proposal, error) {
+	var proposals []Proposal
+	seen := map[string]bool{*user.ID: true}
+	propose := func(other appsync.User, reason string) {
+		if seen[*other.ID] || other.MergedInto != nil {
+			return
+		}
+		seen[*other.ID] = true
+		proposal := Proposal{SurvivorID: *user.ID, MergedID: *other.ID, Reason: reason}
+		if Sparse(user) && !Sparse(&other) {
+			proposal.SurvivorID, proposal.MergedID = *other.ID, *user.ID
+		}
+		proposals = append(proposals, proposal)
+	}
+
+	byEmail, err := UserController.FindUsers(ctx, resolver, user.Emails)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range byEmail {
+		propose(other, "shares email "+sharedEmail(user, &other))
+	}
+
+	phoneNumbers := phoneVariants(user.PhoneNumbers)
+	if len(phoneNumbers) > 0 {
+		byPhone, err := resolver.ListUsersByPhoneNumbersContext(ctx, phoneNumbers)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get users by phone number: %w", err)
+		}
+		for _, other := range byPhone {
+			propose(other, "shares phone number "+sharedPhone(user, &other))
+		}
+	}
+	return proposals, nil
+}
+
+// Propose records the Duplicates of user on both users of each pair as
+// "userId: reason", naming the other user, for an admin to merge.
+func Propose(ctx context.Context, resolver Resolver.Resolver, user *appsync.User) ([]Proposal, error) {
+	proposals, err := Duplicates(ctx, resolver, user)
+	if err != nil {
+		return nil, err
+	}
+	for _, proposal := range proposals {
+		log.Printf("Proposing to merge user %v into %v: %v", proposal.MergedID, proposal.SurvivorID, proposal.Reason)
+		pairs := [][2]string{{proposal.SurvivorID, proposal.MergedID}, {proposal.MergedID, proposal.SurvivorID}}
+		for _, pair := range pairs {
+			entry := pair[1] + ": " + proposal.Reason
+			_, err := updateProposals(ctx, resolver, pair[0], func(entries []string) []string {
+				if proposed(entries, pair[1]) {
+					return entries
+				}
+				return append(entries, entry)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return proposals, nil
+}
+
+// dropProposals removes the proposals naming otherID from the user.
+func dropProposals(ctx context.Context, resolver Resolver.Resolver, userID string, otherID string) (*appsync.User, error) {
+	return updateProposals(ctx, resolver, userID, func(entries []string) []string {
+		var kept []string
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry, otherID+":") {
+				kept = append(kept, entry)
+			}
+		}
+		return kept
+	})
+}
+
+// updateProposals rewrites the merge proposals of the user with change,
+// conditionally on the version read and again on a conflict, the way
+// appsync.MergeUser writes the other lists.
+func updateProposals(ctx context.Context, resolver Resolver.Resolver, userID string, change func([]string) []string) (*appsync.User, error) {
+	var err error
+	for attempt := 1; attempt <= appsync.DefaultMergeAttempts; attempt++ {
+		var user *appsync.User
+		user, err = resolver.GetUserContext(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get user %v: %w", userID, err)
+		}
+		var entries []string
+		for _, entry := range user.MergeProposals {
+			if entry != nil {
+				entries = append(entries, *entry)
+			}
+		}
+		changed := change(entries)
+		if strings.Join(changed, "\n") == strings.Join(entries, "\n") {
+			return user, nil
+		}
+
+		input := appsync.MergeUserInput(user, appsync.UpdateUserInput{ID: userID})
+		input.MergeProposals = []*string{}
+		for i := range changed {
+			input.MergeProposals = append(input.MergeProposals, &changed[i])
+		}
+		user, err = resolver.UpdateUserContext(ctx, input)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, appsync.ErrConflict) {
+			return nil, fmt.Errorf("Failed to update merge proposals of %v: %w", userID, err)
+		}
+		log.Printf("Merge proposals of %v changed underneath, retrying (attempt %d): %v", userID, attempt, err)
+	}
+	return nil, fmt.Errorf("Failed to update merge proposals of %v: %w", userID, err)
+}
+
+func proposed(entries []string, otherID string) bool {
+	for _, entry := range entries {
+		if strings.HasPrefix(entry, otherID+":") {
+			return true
+		}
+	}
+	return false
+}
+
+func sharedEmail(user *appsync.User, other *appsync.User) string {
+	for _, email := range user.Emails {
+		if email == nil {
+			continue
+		}
+		for _, otherEmail := range other.Emails {
+			if otherEmail != nil && AddressController.Same(*email, *otherEmail) {
+				return *otherEmail
+			}
+		}
+	}
+	return "address"
+}
+
+func sharedPhone(user *appsync.User, other *appsync.User) string {
+	for _, phoneNumber := range user.PhoneNumbers {
+		if phoneNumber == nil {
+			continue
+		}
+		for _, otherPhoneNumber := range other.PhoneNumbers {
+			if otherPhoneNumber != nil && NormalizePhone(*phoneNumber) == NormalizePhone(*otherPhoneNumber) {
+				return *otherPhoneNumber
+			}
+		}
+	}
+	return "number"
+}
+
+// NormalizePhone keeps the digits of a phone number and a leading +, so
+// "+1 (555) 010-0000" and "+15550100000" compare equal.
+func NormalizePhone(phoneNumber string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phoneNumber) {
+		if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// phoneVariants returns the numbers as stored and normalized, since stored
+// numbers are compared as they are.
+func phoneVariants(phoneNumbers []*string) []*string {
+	var variants []*string
+	seen := map[string]bool{}
+	for _, phoneNumber := range phoneNumbers {
+		if phoneNumber == nil {
+			continue
+		}
+		for _, variant := range []string{*phoneNumber, NormalizePhone(*phoneNumber)} {
+			if variant != "" && !seen[variant] {
+				seen[variant] = true
+				value := variant
+				variants = append(variants, &value)
+			}
+		}
+	}
+	return variants
+}
+
+func blank(name string) bool {
+	return strings.TrimSpace(name) == ""
+}
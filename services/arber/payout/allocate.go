@@ -0,0 +1,87 @@
+package payout
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var amountPattern = regexp.MustCompile(`^(\d+)(?:\.(\d{1,2}))?$`)
+
+// ParseAmount reads a challenge reward such as "7000", "$7,000" or
+// "7000.50" into cents.
+func ParseAmount(amount string) (int64, error) {
+	cleaned := strings.NewReplacer("$", "", ",", "", " ", "").Replace(amount)
+	match := amountPattern.FindStringSubmatch(cleaned)
+	if match == nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidReward, amount)
+	}
+	cents := new(big.Int)
+	if _, ok := cents.SetString(match[1]+(match[2] + "00")[:2], 10); !ok || !cents.IsInt64() {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidReward, amount)
+	}
+	return cents.Int64(), nil
+}
+
+// FormatCents prints cents as a dollar amount, such as "$1,000.00".
+func FormatCents(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	dollars := fmt.Sprintf("%d", cents/100)
+	var groups []string
+	for len(dollars) > 3 {
+		groups = append([]string{dollars[len(dollars)-3:]}, groups...)
+		dollars = dollars[:len(dollars)-3]
+	}
+	groups = append([]string{dollars}, groups...)
+	return fmt.Sprintf("%s$%s.%02d", sign, strings.Join(groups, ","), cents%100)
+}
+
+// Allocate splits pool cents in proportion to weights so the shares add up
+// to exactly pool whenever any weight is positive. Every share is first
+// rounded down; the cents left over go one each to the largest remainders,
+// ties going to the lower position.
+func Allocate(pool int64, weights []*big.Rat) []int64 {
+	shares := make([]int64, len(weights))
+	total := new(big.Rat)
+	for _, weight := range weights {
+		if weight.Sign() > 0 {
+			total.Add(total, weight)
+		}
+	}
+	if pool <= 0 || total.Sign() == 0 {
+		return shares
+	}
+
+	type remainder struct {
+		position int
+		value    *big.Rat
+	}
+	var remainders []remainder
+	allocated := int64(0)
+	for i, weight := range weights {
+		if weight.Sign() <= 0 {
+			continue
+		}
+		exact := new(big.Rat).Mul(big.NewRat(pool, 1), weight)
+		exact.Quo(exact, total)
+		floor := new(big.Int).Quo(exact.Num(), exact.Denom())
+		shares[i] = floor.Int64()
+		allocated += shares[i]
+		remainders = append(remainders, remainder{i, new(big.Rat).Sub(exact, new(big.Rat).SetInt(floor))})
+	}
+
+	sort.SliceStable(remainders, func(a, b int) bool {
+		return remainders[a].value.Cmp(remainders[b].value) > 0
+	})
+	for i := 0; allocated < pool; i++ {
+		shares[remainders[i%len(remainders)].position]++
+		allocated++
+	}
+	return shares
+}
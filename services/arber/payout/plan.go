@@ -0,0 +1,243 @@
+package payout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	FraudController "gitlab.com/ncent/arber/api/services/arber/fraud"
+	ShareController "gitlab.com/ncent/arber/api/services/arber/share"
+)
+
+var (
+	// ErrNoReward is returned for a challenge without a reward to pay out.
+	ErrNoReward = errors.New("challenge has no reward")
+	// ErrInvalidReward is returned when a reward is not a dollar amount.
+	ErrInvalidReward = errors.New("invalid reward amount")
+)
+
+// Payout is one referrer's line of a Plan. Amounts are in cents. Skipped
+// says why a hop is paid nothing, and is empty for paid hops.
+type Payout struct {
+	Position      int    `json:"position"`
+	Depth         int    `json:"depth"`
+	TransactionID string `json:"transactionId"`
+	ShareActionID string `json:"shareActionId"`
+	UserID        string `json:"userId"`
+	Weight        string `json:"weight"`
+	Amount        int64  `json:"amount"`
+	Skipped       string `json:"skipped,omitempty"`
+}
+
+// Plan is the auditable outcome of a payout: the challenge terms it was
+// computed from, every hop of the chain and the notes on caps that applied.
+// Distributed plus Undistributed always equals Pool.
+type Plan struct {
+	ChallengeID   string   `json:"challengeId"`
+	TransactionID string   `json:"transactionId"`
+	Schedule      string   `json:"schedule"`
+	Reward        int64    `json:"reward"`
+	Pool          int64    `json:"pool"`
+	Distributed   int64    `json:"distributed"`
+	Undistributed int64    `json:"undistributed"`
+	Payouts       []Payout `json:"payouts"`
+	Notes         []string `json:"notes,omitempty"`
+}
+
+func (p *Plan) note(format string, args ...interface{}) {
+	p.Notes = append(p.Notes, fmt.Sprintf(format, args...))
+}
+
+// Calculate walks the ancestry of the transaction through which the hired
+// candidate received the challenge and splits the challenge reward between
+// the senders along it under schedule, or DefaultSchedule when nil. Nothing
+// is written; the plan is only computed.
+func Calculate(ctx context.Context, resolver Resolver.Resolver, transactionID string, schedule Schedule) (*Plan, error) {
+	ancestry, err := ShareController.GetAncestry(ctx, resolver, transactionID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if ancestry[0].ShareAction == nil || ancestry[0].ShareAction.ChallengeID == nil {
+		return nil, fmt.Errorf("Transaction %v has no challenge", transactionID)
+	}
+	challenge, err := resolver.GetChallengeContext(ctx, *ancestry[0].ShareAction.ChallengeID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get challenge: %w", err)
+	}
+
+	plan, err := PlanChain(challenge, ancestry, schedule)
+	if err != nil {
+		return nil, err
+	}
+	plan.TransactionID = transactionID
+	log.Printf("Payout plan for transaction %v: %+v", transactionID, plan)
+	return plan, nil
+}
+
+// PlanChain splits the reward of challenge between the hops of ancestry, as
+// returned by GetAncestry: nearest the hire first.
+func PlanChain(challenge *appsync.Challenge, ancestry []ShareController.Hop, schedule Schedule) (*Plan, error) {
+	plan, err := newPlan(challenge, schedule)
+	if err != nil {
+		return nil, err
+	}
+	plan.addChain(challenge, ancestry, plan.Pool, schedule)
+	plan.finish()
+	return plan, nil
+}
+
+// CalculateSplit is Calculate for a hire credited to several chains. The
+// pool is first split between the transactions in proportion to weights,
+// then each part is split along its chain under schedule. MaxRewards
+// applies to each chain on its own.
+func CalculateSplit(ctx context.Context, resolver Resolver.Resolver, transactionIDs []string, weights []*big.Rat, schedule Schedule) (*Plan, error) {
+	if len(transactionIDs) == 0 || len(transactionIDs) != len(weights) {
+		return nil, fmt.Errorf("Cannot split a payout between %d transactions with %d weights", len(transactionIDs), len(weights))
+	}
+	if len(transactionIDs) == 1 {
+		return Calculate(ctx, resolver, transactionIDs[0], schedule)
+	}
+
+	ancestries := make([][]ShareController.Hop, len(transactionIDs))
+	for i, transactionID := range transactionIDs {
+		ancestry, err := ShareController.GetAncestry(ctx, resolver, transactionID, 0)
+		if err != nil {
+			return nil, err
+		}
+		if ancestry[0].ShareAction == nil || ancestry[0].ShareAction.ChallengeID == nil {
+			return nil, fmt.Errorf("Transaction %v has no challenge", transactionID)
+		}
+		ancestries[i] = ancestry
+	}
+	challenge, err := resolver.GetChallengeContext(ctx, *ancestries[0][0].ShareAction.ChallengeID)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get challenge: %w", err)
+	}
+
+	plan, err := newPlan(challenge, schedule)
+	if err != nil {
+		return nil, err
+	}
+	plan.TransactionID = transactionIDs[0]
+	parts := Allocate(plan.Pool, weights)
+	for i, ancestry := range ancestries {
+		plan.note("chain of transaction %v is credited %s of the pool, %s", transactionIDs[i], weights[i].RatString(), FormatCents(parts[i]))
+		plan.addChain(challenge, ancestry, parts[i], schedule)
+	}
+	plan.finish()
+	log.Printf("Split payout plan for transactions %v: %+v", transactionIDs, plan)
+	return plan, nil
+}
+
+// newPlan reads the reward of challenge and caps the pool.
+func newPlan(challenge *appsync.Challenge, schedule Schedule) (*Plan, error) {
+	if schedule == nil {
+		schedule = DefaultSchedule
+	}
+	if challenge.Reward == nil || *challenge.Reward == "" {
+		return nil, fmt.Errorf("%w: challenge %v", ErrNoReward, stringValue(challenge.ID))
+	}
+	reward, err := ParseAmount(*challenge.Reward)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		ChallengeID: stringValue(challenge.ID),
+		Schedule:    schedule.String(),
+		Reward:      reward,
+		Pool:        reward,
+	}
+	plan.note("reward %s split %s", FormatCents(reward), schedule)
+	if challenge.MaxDistributionFeeReward != nil {
+		limit := int64(*challenge.MaxDistributionFeeReward) * 100
+		if limit < plan.Pool {
+			plan.Pool = limit
+			plan.note("MaxDistributionFeeReward caps the pool at %s", FormatCents(limit))
+		}
+	}
+	return plan, nil
+}
+
+// addChain splits pool between the hops of ancestry and appends their
+// payouts to the plan.
+func (p *Plan) addChain(challenge *appsync.Challenge, ancestry []ShareController.Hop, pool int64, schedule Schedule) {
+	if schedule == nil {
+		schedule = DefaultSchedule
+	}
+
+	// Only hops with a sender and no fraud findings take a position; the
+	// rest are listed unpaid.
+	var payouts []Payout
+	paid := 0
+	flagged := 0
+	capped := false
+	for _, hop := range ancestry {
+		payout := Payout{Position: -1, Depth: hop.Depth}
+		if hop.Transaction != nil {
+			payout.TransactionID = stringValue(hop.Transaction.ID)
+		}
+		if hop.ShareAction != nil {
+			payout.ShareActionID = stringValue(hop.ShareAction.ID)
+		}
+		switch {
+		case hop.User == nil || hop.User.ID == nil:
+			payout.Skipped = "no sender"
+		case FraudController.Flagged(hop.Transaction):
+			payout.UserID = *hop.User.ID
+			payout.Skipped = "flagged: " + strings.Join(FraudController.Flags(hop.Transaction), "; ")
+			flagged++
+		case challenge.MaxRewards != nil && paid >= *challenge.MaxRewards:
+			payout.UserID = *hop.User.ID
+			payout.Skipped = fmt.Sprintf("beyond MaxRewards of %d", *challenge.MaxRewards)
+			capped = true
+		default:
+			payout.UserID = *hop.User.ID
+			payout.Position = paid
+			paid++
+		}
+		payouts = append(payouts, payout)
+	}
+	if flagged > 0 {
+		p.note("%d flagged referrers are not paid", flagged)
+	}
+	if capped {
+		p.note("MaxRewards pays the %d nearest referrers", *challenge.MaxRewards)
+	}
+
+	weights := schedule.Weights(paid)
+	shares := Allocate(pool, weights)
+	for i := range payouts {
+		payout := &payouts[i]
+		if payout.Position < 0 {
+			continue
+		}
+		payout.Weight = weights[payout.Position].RatString()
+		payout.Amount = shares[payout.Position]
+		if payout.Amount == 0 && weights[payout.Position].Sign() == 0 {
+			payout.Skipped = "zero weight"
+		}
+		p.Distributed += payout.Amount
+	}
+	p.Payouts = append(p.Payouts, payouts...)
+}
+
+// finish works out what the chains left undistributed.
+func (p *Plan) finish() {
+	p.Undistributed = p.Pool - p.Distributed
+	if p.Undistributed > 0 {
+		p.note("%s is not distributed", FormatCents(p.Undistributed))
+	}
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
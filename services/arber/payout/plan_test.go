@@ -0,0 +1,156 @@
+package payout
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/mail"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ShareController "gitlab.com/ncent/arber/api/services/arber/share"
+)
+
+func TestPayout(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Allocate", func() {
+		g.It("Should never lose a cent to rounding", func() {
+			shares := Allocate(100, []*big.Rat{big.NewRat(1, 1), big.NewRat(1, 1), big.NewRat(1, 1)})
+			Expect(shares).Should(Equal([]int64{34, 33, 33}))
+
+			shares = Allocate(700000, DefaultSchedule.Weights(3))
+			Expect(shares).Should(Equal([]int64{400000, 200000, 100000}))
+		})
+
+		g.It("Should give nothing when every weight is zero", func() {
+			Expect(Allocate(100, []*big.Rat{new(big.Rat)})).Should(Equal([]int64{0}))
+		})
+	})
+
+	g.Describe("ParseAmount", func() {
+		g.It("Should read dollar amounts into cents", func() {
+			Expect(ParseAmount("$7,000")).Should(Equal(int64(700000)))
+			Expect(ParseAmount("12.5")).Should(Equal(int64(1250)))
+			_, err := ParseAmount("seven thousand")
+			Expect(errors.Is(err, ErrInvalidReward)).Should(BeTrue())
+			Expect(FormatCents(123456789)).Should(Equal("$1,234,567.89"))
+		})
+	})
+
+	g.Describe("Calculate", func() {
+		var resolver *Resolver.MemoryResolver
+		var challenge *Resolver.Challenge
+		var hired string
+		ctx := context.Background()
+
+		newChallenge := func(input Resolver.CreateChallengeInput) {
+			var err error
+			challenge, err = resolver.CreateChallenge(input)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// you -> friend -> friend's friend, who shares with the hire.
+			parent := ""
+			for _, sender := range []string{"you@example.com", "friend@example.com", "fof@example.com"} {
+				transaction, err := ShareController.CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, parent, *challenge.ID)
+				Expect(err).ShouldNot(HaveOccurred())
+				err = ShareController.CreateShareActionContacts(ctx, resolver, *transaction.ID, &mail.Address{Address: sender}, nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				parent = *transaction.ID
+			}
+			hired = parent
+		}
+
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+		})
+
+		g.It("Should escalate the reward towards the hire", func() {
+			reward := "$7,000"
+			newChallenge(Resolver.CreateChallengeInput{Reward: &reward})
+
+			plan, err := Calculate(ctx, resolver, hired, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(plan.Payouts).Should(HaveLen(3))
+			Expect(plan.Payouts[0].Amount).Should(Equal(int64(400000)))
+			Expect(plan.Payouts[1].Amount).Should(Equal(int64(200000)))
+			Expect(plan.Payouts[2].Amount).Should(Equal(int64(100000)))
+			Expect(plan.Payouts[2].Depth).Should(Equal(0))
+			Expect(plan.Distributed).Should(Equal(plan.Pool))
+		})
+
+		g.It("Should apply the challenge caps", func() {
+			reward := "1000"
+			maxFee := 100
+			maxRewards := 2
+			newChallenge(Resolver.CreateChallengeInput{Reward: &reward, MaxDistributionFeeReward: &maxFee, MaxRewards: &maxRewards})
+
+			plan, err := Calculate(ctx, resolver, hired, Flat())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(plan.Pool).Should(Equal(int64(10000)))
+			Expect(plan.Payouts[0].Amount).Should(Equal(int64(5000)))
+			Expect(plan.Payouts[1].Amount).Should(Equal(int64(5000)))
+			Expect(plan.Payouts[2].Amount).Should(Equal(int64(0)))
+			Expect(plan.Payouts[2].Skipped).Should(ContainSubstring("MaxRewards"))
+			Expect(plan.Notes).Should(HaveLen(3))
+		})
+
+		g.It("Should follow a custom schedule and keep what it leaves", func() {
+			reward := "300"
+			newChallenge(Resolver.CreateChallengeInput{Reward: &reward})
+
+			plan, err := Calculate(ctx, resolver, hired, Custom(big.NewRat(2, 1), big.NewRat(1, 1)))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(plan.Payouts[0].Amount).Should(Equal(int64(20000)))
+			Expect(plan.Payouts[1].Amount).Should(Equal(int64(10000)))
+			Expect(plan.Payouts[2].Skipped).Should(Equal("zero weight"))
+			Expect(plan.Distributed + plan.Undistributed).Should(Equal(plan.Pool))
+		})
+
+		g.It("Should leave flagged referrers out of the split", func() {
+			reward := "$7,000"
+			newChallenge(Resolver.CreateChallengeInput{Reward: &reward})
+			ancestry, err := ShareController.GetAncestry(ctx, resolver, hired, 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			flag := "CYCLE: friend@example.com appears 2 hops apart in the chain"
+			_, err = resolver.UpdateTransaction(Resolver.UpdateTransactionInput{ID: *ancestry[1].Transaction.ID, FraudFlags: []*string{&flag}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			plan, err := Calculate(ctx, resolver, hired, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(plan.Payouts[1].Amount).Should(BeZero())
+			Expect(plan.Payouts[1].Skipped).Should(Equal("flagged: " + flag))
+			Expect(plan.Payouts[0].Amount + plan.Payouts[2].Amount).Should(Equal(int64(700000)))
+		})
+
+		g.It("Should split the reward between the credited chains", func() {
+			reward := "$7,000"
+			newChallenge(Resolver.CreateChallengeInput{Reward: &reward})
+			other, err := ShareController.CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			err = ShareController.CreateShareActionContacts(ctx, resolver, *other.ID, &mail.Address{Address: "other@example.com"}, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			plan, err := CalculateSplit(ctx, resolver, []string{hired, *other.ID}, []*big.Rat{big.NewRat(1, 2), big.NewRat(1, 2)}, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(plan.Payouts).Should(HaveLen(4))
+			Expect(plan.Payouts[3].TransactionID).Should(Equal(*other.ID))
+			Expect(plan.Payouts[3].Amount).Should(Equal(int64(350000)))
+			Expect(plan.Distributed).Should(Equal(plan.Pool))
+
+			_, err = CalculateSplit(ctx, resolver, []string{hired}, nil, nil)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		g.It("Should refuse a challenge without a reward", func() {
+			newChallenge(Resolver.CreateChallengeInput{})
+			_, err := Calculate(ctx, resolver, hired, nil)
+			Expect(errors.Is(err, ErrNoReward)).Should(BeTrue())
+		})
+	})
+}
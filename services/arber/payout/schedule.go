@@ -0,0 +1,97 @@
+package payout
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Schedule weighs the referrers of a chain against each other. Position 0 is
+// the referrer nearest the hire, the one who shared with the candidate;
+// higher positions are further up towards the root. Weights are relative:
+// the pool is split in proportion to them.
+type Schedule interface {
+	Weights(referrers int) []*big.Rat
+	String() string
+}
+
+type geometric struct {
+	ratio *big.Rat
+}
+
+// Geometric gives each referrer ratio times the weight of the one below it.
+// A ratio of 1/2 is the split of the welcome email: the referrer nearest the
+// hire gets twice what their own referrer gets, and so on up the chain.
+func Geometric(ratio *big.Rat) Schedule {
+	return geometric{ratio: new(big.Rat).Set(ratio)}
+}
+
+func (s geometric) Weights(referrers int) []*big.Rat {
+	weights := make([]*big.Rat, referrers)
+	weight := big.NewRat(1, 1)
+	for i := range weights {
+		weights[i] = new(big.Rat).Set(weight)
+		weight = new(big.Rat).Mul(weight, s.ratio)
+	}
+	return weights
+}
+
+func (s geometric) String() string {
+	return fmt.Sprintf("geometric(%v)", s.ratio.RatString())
+}
+
+type flat struct{}
+
+// Flat splits the pool evenly between the referrers.
+func Flat() Schedule {
+	return flat{}
+}
+
+func (flat) Weights(referrers int) []*big.Rat {
+	weights := make([]*big.Rat, referrers)
+	for i := range weights {
+		weights[i] = big.NewRat(1, 1)
+	}
+	return weights
+}
+
+func (flat) String() string {
+	return "flat"
+}
+
+type custom struct {
+	weights []*big.Rat
+}
+
+// Custom weighs referrers by position with the given weights. Referrers past
+// the end of the list get nothing.
+func Custom(weights ...*big.Rat) Schedule {
+	copied := make([]*big.Rat, len(weights))
+	for i, weight := range weights {
+		copied[i] = new(big.Rat).Set(weight)
+	}
+	return custom{weights: copied}
+}
+
+func (s custom) Weights(referrers int) []*big.Rat {
+	weights := make([]*big.Rat, referrers)
+	for i := range weights {
+		if i < len(s.weights) {
+			weights[i] = new(big.Rat).Set(s.weights[i])
+		} else {
+			weights[i] = new(big.Rat)
+		}
+	}
+	return weights
+}
+
+func (s custom) String() string {
+	var weights []string
+	for _, weight := range s.weights {
+		weights = append(weights, weight.RatString())
+	}
+	return fmt.Sprintf("custom(%s)", strings.Join(weights, ", "))
+}
+
+// DefaultSchedule halves the reward at every hop away from the hire.
+var DefaultSchedule = Geometric(big.NewRat(1, 2))
@@ -0,0 +1,157 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+)
+
+// DefaultMaxDepth bounds how many hops GetAncestry and GetSubtree follow
+// when the caller passes no limit of its own.
+const DefaultMaxDepth = 100
+
+var (
+	// ErrCycle is returned when a referral chain leads back to a
+	// transaction it has already visited.
+	ErrCycle = errors.New("referral chain has a cycle")
+	// ErrMaxDepth is returned when a referral chain is longer than the
+	// limit it is walked with.
+	ErrMaxDepth = errors.New("referral chain exceeds the max depth")
+)
+
+// Hop is one transaction of a referral chain with the share action it
+// records and the user who shared it. Depth counts hops from the root of
+// the chain, which is at depth 0. User is nil while the share action has
+// no sender yet.
+type Hop struct {
+	Transaction *appsync.Transaction
+	ShareAction *appsync.ShareAction
+	User        *appsync.User
+	Depth       int
+}
+
+// Node is a Hop with the transactions shared from it.
+type Node struct {
+	Hop
+	Children []*Node
+}
+
+func maxDepthOrDefault(maxDepth int) int {
+	if maxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+	return maxDepth
+}
+
+// GetAncestry walks from a transaction up through its parents and returns
+// the path in that order: the transaction itself first, the root last.
+// maxDepth limits the number of hops; zero means DefaultMaxDepth.
+func GetAncestry(ctx context.Context, resolver Resolver.Resolver, transactionID string, maxDepth int) ([]Hop, error) {
+	maxDepth = maxDepthOrDefault(maxDepth)
+
+	var path []Hop
+	visited := map[string]bool{}
+	nextID := transactionID
+	for nextID != "" {
+		if visited[nextID] {
+			return nil, fmt.Errorf("%w: transaction %v is its own ancestor", ErrCycle, nextID)
+		}
+		if len(path) > maxDepth {
+			return nil, fmt.Errorf("%w: more than %d hops above transaction %v", ErrMaxDepth, maxDepth, transactionID)
+		}
+		visited[nextID] = true
+
+		transaction, err := resolver.GetTransactionContext(ctx, nextID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get Transaction %v: %w", nextID, err)
+		}
+		hop, err := newHop(ctx, resolver, transaction)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, hop)
+
+		nextID = ""
+		if transaction.ParentTransactionID != nil {
+			nextID = *transaction.ParentTransactionID
+		}
+	}
+
+	for i := range path {
+		path[i].Depth = len(path) - 1 - i
+	}
+	log.Printf("Ancestry of transaction %v has %d hops", transactionID, len(path))
+	return path, nil
+}
+
+// GetSubtree returns the transaction and, recursively, every transaction
+// shared from it. Depths are counted from the root of the whole chain, so
+// the ancestry is walked first. maxDepth limits how many levels below the
+// transaction are followed; zero means DefaultMaxDepth.
+func GetSubtree(ctx context.Context, resolver Resolver.Resolver, transactionID string, maxDepth int) (*Node, error) {
+	maxDepth = maxDepthOrDefault(maxDepth)
+
+	ancestry, err := GetAncestry(ctx, resolver, transactionID, 0)
+	if err != nil {
+		return nil, err
+	}
+	root := &Node{Hop: ancestry[0]}
+
+	visited := map[string]bool{}
+	for _, hop := range ancestry {
+		visited[*hop.Transaction.ID] = true
+	}
+
+	if err := addChildren(ctx, resolver, root, root.Depth+maxDepth, visited); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func addChildren(ctx context.Context, resolver Resolver.Resolver, node *Node, maxDepth int, visited map[string]bool) error {
+	children, err := resolver.GetTransactionsByParentTransactionContext(ctx, *node.Transaction.ID)
+	if err != nil {
+		return fmt.Errorf("Failed to get child Transactions of %v: %w", *node.Transaction.ID, err)
+	}
+	if len(children) > 0 && node.Depth >= maxDepth {
+		return fmt.Errorf("%w: transaction %v has children below depth %d", ErrMaxDepth, *node.Transaction.ID, maxDepth)
+	}
+
+	for _, transaction := range children {
+		if visited[*transaction.ID] {
+			return fmt.Errorf("%w: transaction %v is reached twice", ErrCycle, *transaction.ID)
+		}
+		visited[*transaction.ID] = true
+
+		hop, err := newHop(ctx, resolver, transaction)
+		if err != nil {
+			return err
+		}
+		hop.Depth = node.Depth + 1
+		child := &Node{Hop: hop}
+		node.Children = append(node.Children, child)
+
+		if err := addChildren(ctx, resolver, child, maxDepth, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newHop resolves the sharing user of a transaction, whose share action
+// comes selected with it.
+func newHop(ctx context.Context, resolver Resolver.Resolver, transaction *appsync.Transaction) (Hop, error) {
+	hop := Hop{Transaction: transaction, ShareAction: transaction.Action}
+	if hop.ShareAction != nil && hop.ShareAction.UserID != nil {
+		user, err := resolver.GetUserContext(ctx, *hop.ShareAction.UserID)
+		if err != nil {
+			return Hop{}, fmt.Errorf("Failed to get sharing User of Transaction %v: %w", *transaction.ID, err)
+		}
+		hop.User = user
+	}
+	return hop, nil
+}
@@ -0,0 +1,95 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ShareController "gitlab.com/ncent/arber/api/services/arber/share"
+	"gitlab.com/ncent/arber/api/services/arber/share/sharetest"
+)
+
+// loopingResolver makes one transaction claim another as its parent, which
+// the memory store would otherwise refuse to record.
+type loopingResolver struct {
+	*Resolver.MemoryResolver
+	transactionID string
+	parentID      string
+}
+
+func (r *loopingResolver) GetTransactionContext(ctx context.Context, id string) (*Resolver.Transaction, error) {
+	transaction, err := r.MemoryResolver.GetTransactionContext(ctx, id)
+	if err == nil && id == r.transactionID {
+		transaction.ParentTransactionID = &r.parentID
+	}
+	return transaction, err
+}
+
+func TestChain(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Referral chain", func() {
+		var resolver *Resolver.MemoryResolver
+		var ids []string
+		ctx := context.Background()
+
+		// The chain is root -> first -> second, plus a sibling of first.
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+			name := "Engineer"
+			challenge, err := resolver.CreateChallenge(Resolver.CreateChallengeInput{Name: &name})
+			Expect(err).ShouldNot(HaveOccurred())
+			root := *sharetest.Share(ctx, resolver, *challenge.ID, "", "root@example.com").ID
+			first := *sharetest.Share(ctx, resolver, *challenge.ID, root, "first@example.com").ID
+			second := *sharetest.Share(ctx, resolver, *challenge.ID, first, "second@example.com").ID
+			sibling := *sharetest.Share(ctx, resolver, *challenge.ID, root, "sibling@example.com").ID
+			ids = []string{root, first, second, sibling}
+		})
+
+		g.It("Should return the path from a transaction up to the root", func() {
+			path, err := ShareController.GetAncestry(ctx, resolver, ids[2], 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(path).Should(HaveLen(3))
+			Expect(*path[0].Transaction.ID).Should(Equal(ids[2]))
+			Expect(*path[2].Transaction.ID).Should(Equal(ids[0]))
+			Expect(path[0].Depth).Should(Equal(2))
+			Expect(path[2].Depth).Should(Equal(0))
+			Expect(*path[0].User.Emails[0]).Should(Equal("second@example.com"))
+			Expect(*path[1].ShareAction.ID).Should(Equal(*path[1].Transaction.TransactionActionID))
+		})
+
+		g.It("Should return the whole subtree below a transaction", func() {
+			root, err := ShareController.GetSubtree(ctx, resolver, ids[0], 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(root.Children).Should(HaveLen(2))
+			Expect(*root.Children[0].Transaction.ID).Should(Equal(ids[1]))
+			Expect(root.Children[0].Children).Should(HaveLen(1))
+			Expect(root.Children[0].Children[0].Depth).Should(Equal(2))
+			Expect(*root.Children[1].User.Emails[0]).Should(Equal("sibling@example.com"))
+
+			first, err := ShareController.GetSubtree(ctx, resolver, ids[1], 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(first.Depth).Should(Equal(1))
+			Expect(first.Children).Should(HaveLen(1))
+		})
+
+		g.It("Should stop at the max depth", func() {
+			_, err := ShareController.GetAncestry(ctx, resolver, ids[2], 1)
+			Expect(errors.Is(err, ShareController.ErrMaxDepth)).Should(BeTrue())
+			_, err = ShareController.GetSubtree(ctx, resolver, ids[0], 1)
+			Expect(errors.Is(err, ShareController.ErrMaxDepth)).Should(BeTrue())
+		})
+
+		g.It("Should detect a cycle", func() {
+			looping := &loopingResolver{MemoryResolver: resolver, transactionID: ids[0], parentID: ids[2]}
+			_, err := ShareController.GetAncestry(ctx, looping, ids[2], 0)
+			Expect(errors.Is(err, ShareController.ErrCycle)).Should(BeTrue())
+		})
+	})
+}
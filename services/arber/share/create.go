@@ -1,87 +1,163 @@
 package user
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/mail"
 
 	"gitlab.com/ncent/arber/api/services/appsync"
 	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	AddressController "gitlab.com/ncent/arber/api/services/arber/address"
+	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
 	UserController "gitlab.com/ncent/arber/api/services/arber/user"
 )
 
-func CreateShareActionAndTransactionWithParentTransaction(resolver Resolver.Resolver, parentTransactionID string, challengeId string) (*appsync.Transaction, error) {
+func CreateShareActionAndTransactionWithParentTransaction(ctx context.Context, resolver Resolver.Resolver, parentTransactionID string, challengeId string) (*appsync.Transaction, error) {
 	log.Printf("Creating a Share Action with Transaction")
 
+	challenge, err := resolver.GetChallengeContext(ctx, challengeId)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get challenge: %w", err)
+	}
+	if err := ChallengeController.CheckOpen(challenge, now()); err != nil {
+		return nil, err
+	}
+	if err := CheckShareLimits(ctx, resolver, challenge, parentTransactionID); err != nil {
+		return nil, err
+	}
+
 	var transaction *appsync.Transaction
-	shareAction, err := resolver.CreateShareAction(
-		appsync.CreateShareAction{
+	shareAction, err := resolver.CreateShareActionContext(
+		ctx,
+		appsync.CreateShareActionInput{
 			ChallengeID: &challengeId,
 		},
 	)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create ShareAction: %v", err)
+		return nil, fmt.Errorf("Failed to create ShareAction: %w", err)
 	}
 	log.Printf("Created ShareAction: %+v", shareAction)
 
-	createTransaction := appsync.CreateTransaction{
+	status := appsync.CREATED.String()
+	createTransaction := appsync.CreateTransactionInput{
 		TransactionActionID: shareAction.ID,
+		Status:              &status,
 	}
 	if parentTransactionID != "" {
-		createTransaction = appsync.CreateTransaction{
+		createTransaction = appsync.CreateTransactionInput{
 			ParentTransactionID: &parentTransactionID,
 			TransactionActionID: shareAction.ID,
+			Status:              &status,
 		}
 	}
-	transaction, err = resolver.CreateTransaction(createTransaction)
+	transaction, err = resolver.CreateTransactionContext(ctx, createTransaction)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create Transaction: %v", err)
+		return nil, fmt.Errorf("Failed to create Transaction: %w", err)
 	}
 	return transaction, nil
 }
 
-func CreateShareActionContacts(resolver Resolver.Resolver, transactionID string, from *mail.Address, tos []*mail.Address) error {
+func CreateShareActionContacts(ctx context.Context, resolver Resolver.Resolver, transactionID string, from *mail.Address, tos []*mail.Address) error {
 	log.Printf("Creating a Share Action with Transaction")
 
 	if transactionID != "" {
-		fromUser, err := UserController.CreateSparseUser(resolver, from)
+		transaction, err := resolver.GetTransactionContext(ctx, transactionID)
 		if err != nil {
-			return err
+			return fmt.Errorf("Failed to get Parent Transaction: %w", err)
+		}
+		if transaction.Action == nil || transaction.Action.ID == nil {
+			return fmt.Errorf("Transaction %v has no ShareAction", transactionID)
 		}
 
-		transaction, err := resolver.GetTransaction(transactionID)
+		if transaction.Action.ChallengeID != nil {
+			challenge, err := resolver.GetChallengeContext(ctx, *transaction.Action.ChallengeID)
+			if err != nil {
+				return fmt.Errorf("Failed to get challenge: %w", err)
+			}
+			if err := ChallengeController.CheckOpen(challenge, now()); err != nil {
+				return err
+			}
+			recipients, err := countRecipients(ctx, resolver, from, tos)
+			if err != nil {
+				return err
+			}
+			if err := CheckRecipientLimits(challenge, recipients); err != nil {
+				return err
+			}
+		}
+
+		fromUser, err := UserController.CreateSparseUser(ctx, resolver, from)
 		if err != nil {
-			return fmt.Errorf("Failed to get Parent Transaction: %v", err)
+			return err
 		}
 
-		_, err = resolver.UpdateShareAction(
-			appsync.UpdateShareAction{
-				ID:     transaction.Action.ID,
+		_, err = resolver.UpdateShareActionContext(
+			ctx,
+			appsync.UpdateShareActionInput{
+				ID:     *transaction.Action.ID,
 				UserID: fromUser.ID,
 			},
 		)
 		if err != nil {
-			return fmt.Errorf("Failed to update ShareAction: %v", err)
+			return fmt.Errorf("Failed to update ShareAction: %w", err)
 		}
 
+		// A sender in their own To or Cc is skipped without a flag and
+		// never recorded as a recipient.
+		var toUsers []*appsync.User
 		for _, to := range tos {
-			toUser, _ := UserController.CreateSparseUser(resolver, to)
+			toUser, err := UserController.CreateSparseUser(ctx, resolver, to)
+			if err != nil {
+				return fmt.Errorf("Failed to create Share Action Contact: %w", err)
+			}
+			if *toUser.ID == *fromUser.ID {
+				continue
+			}
+			toUsers = append(toUsers, toUser)
+		}
+
+		if err := checkShareFraud(ctx, resolver, transaction, fromUser, toUsers); err != nil {
+			return fmt.Errorf("Failed to check share for fraud: %w", err)
+		}
 
-			_, err = resolver.CreateShareActionContact(
-				appsync.CreateShareActionContact{
+		for _, toUser := range toUsers {
+			_, err = resolver.CreateShareActionContactContext(
+				ctx,
+				appsync.CreateShareActionContactInput{
 					ShareActionContactShareActionID: transaction.Action.ID,
 					ShareActionContactContactID:     toUser.ID,
 				},
 			)
-
 			if err != nil {
-				break
+				return fmt.Errorf("Failed to create Share Action Contact: %w", err)
 			}
 		}
-		if err != nil {
-			return fmt.Errorf("Failed to create Share Action Contact: %v", err)
-		}
 	}
 
 	return nil
 }
+
+// countRecipients counts the recipients a share is recorded with: tos
+// without the sender, who may be in their own To or Cc under any of their
+// addresses. It only reads, so the limits are checked before anything is
+// written.
+func countRecipients(ctx context.Context, resolver Resolver.Resolver, from *mail.Address, tos []*mail.Address) (int, error) {
+	own := map[string]bool{AddressController.Canonical(from.Address): true}
+	sender, err := UserController.FindUser(ctx, resolver, from.Address)
+	if err != nil {
+		return 0, err
+	}
+	if sender != nil {
+		for _, canonical := range AddressController.CanonicalAll(append(append([]*string{}, sender.Emails...), sender.CanonicalEmails...)) {
+			own[*canonical] = true
+		}
+	}
+	recipients := 0
+	for _, to := range tos {
+		if !own[AddressController.Canonical(to.Address)] {
+			recipients++
+		}
+	}
+	return recipients, nil
+}
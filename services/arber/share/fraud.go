@@ -0,0 +1,84 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	FraudController "gitlab.com/ncent/arber/api/services/arber/fraud"
+)
+
+// ChainLinks turns hops, as returned by GetAncestry, into the links the
+// fraud checks read, keeping their order.
+func ChainLinks(hops []Hop) []FraudController.Link {
+	links := make([]FraudController.Link, 0, len(hops))
+	for _, hop := range hops {
+		links = append(links, FraudController.Link{
+			TransactionID: stringValue(hop.Transaction.ID),
+			User:          hop.User,
+		})
+	}
+	return links
+}
+
+// checkShareFraud runs the fraud checks on a share of transaction by sender
+// and records what they find on the transaction. Flagged shares still go
+// out; they are only left out of the payout.
+func checkShareFraud(ctx context.Context, resolver Resolver.Resolver, transaction *appsync.Transaction, sender *appsync.User, recipients []*appsync.User) error {
+	var chain []FraudController.Link
+	if transaction.ParentTransactionID != nil && *transaction.ParentTransactionID != "" {
+		ancestry, err := GetAncestry(ctx, resolver, *transaction.ParentTransactionID, 0)
+		if err != nil {
+			return err
+		}
+		chain = ChainLinks(ancestry)
+	}
+
+	burst := FraudController.DefaultBurst
+	recent, err := recentRecipients(ctx, resolver, stringValue(transaction.Action.ChallengeID), sender, now().Add(-burst.Window))
+	if err != nil {
+		return err
+	}
+
+	findings := FraudController.CheckShare(*transaction.ID, sender, chain, recipients, recent, burst)
+	for _, finding := range findings {
+		log.Printf("Fraud finding on transaction %v: %v", *transaction.ID, finding)
+	}
+	return FraudController.Record(ctx, resolver, findings)
+}
+
+// recentRecipients counts the contacts sender addressed on the challenge
+// since the given time, across all their shares of it.
+func recentRecipients(ctx context.Context, resolver Resolver.Resolver, challengeID string, sender *appsync.User, since time.Time) (int, error) {
+	if challengeID == "" || sender == nil || sender.ID == nil {
+		return 0, nil
+	}
+	shareActions, err := resolver.GetShareActionsByChallengeAndUserContext(ctx, challengeID, *sender.ID)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get share actions: %w", err)
+	}
+	recent := 0
+	for _, shareAction := range shareActions {
+		contacts, err := resolver.GetShareActionContactsByShareActionContext(ctx, *shareAction.ID)
+		if err != nil {
+			return 0, fmt.Errorf("Failed to get share action contacts: %w", err)
+		}
+		for _, contact := range contacts {
+			if contact.CreatedAt == nil {
+				continue
+			}
+			createdAt, err := time.Parse(time.RFC3339Nano, *contact.CreatedAt)
+			if err != nil {
+				log.Printf("Share action contact %v has an invalid createdAt %q: %v", stringValue(contact.ID), *contact.CreatedAt, err)
+				continue
+			}
+			if !createdAt.Before(since) {
+				recent++
+			}
+		}
+	}
+	return recent, nil
+}
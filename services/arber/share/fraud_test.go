@@ -0,0 +1,55 @@
+package user_test
+
+import (
+	"context"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	FraudController "gitlab.com/ncent/arber/api/services/arber/fraud"
+	"gitlab.com/ncent/arber/api/services/arber/share/sharetest"
+)
+
+func TestFraud(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Fraud checks on share", func() {
+		var resolver *Resolver.MemoryResolver
+		var challenge *Resolver.Challenge
+		ctx := context.Background()
+
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+			var err error
+			challenge, err = resolver.CreateChallenge(Resolver.CreateChallengeInput{})
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		g.It("Should skip the sender in their own recipients without a flag", func() {
+			transaction := sharetest.Share(ctx, resolver, *challenge.ID, "", "alice@example.com", "Alice@example.com", "bob@example.com")
+			Expect(FraudController.Flagged(transaction)).Should(BeFalse())
+			Expect(resolver.ShareActionContacts(*transaction.Action.ID)).Should(HaveLen(1))
+		})
+
+		g.It("Should flag two accounts forwarding to each other", func() {
+			first := sharetest.Share(ctx, resolver, *challenge.ID, "", "alice@example.com", "bob@example.com")
+			second := sharetest.Share(ctx, resolver, *challenge.ID, *first.ID, "bob@example.com", "alice@example.com")
+			third := sharetest.Share(ctx, resolver, *challenge.ID, *second.ID, "alice@example.com", "bob@example.com")
+
+			Expect(FraudController.Flagged(first)).Should(BeFalse())
+			Expect(FraudController.Flags(second)).Should(ConsistOf(ContainSubstring("CYCLE")))
+			Expect(FraudController.Flags(third)).Should(HaveLen(2))
+		})
+
+		g.It("Should leave ordinary shares alone", func() {
+			first := sharetest.Share(ctx, resolver, *challenge.ID, "", "alice@example.com", "bob@example.com")
+			second := sharetest.Share(ctx, resolver, *challenge.ID, *first.ID, "bob@example.com", "carol@example.com")
+			Expect(FraudController.Flagged(first)).Should(BeFalse())
+			Expect(FraudController.Flagged(second)).Should(BeFalse())
+		})
+	})
+}
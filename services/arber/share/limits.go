@@ -0,0 +1,128 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+)
+
+// ErrLimitExceeded is wrapped by every LimitError, so callers can tell a
+// share the challenge rules refuse from a failure to record it.
+var ErrLimitExceeded = errors.New("challenge sharing limit reached")
+
+// LimitError says which rule of a challenge refused a share and why, in
+// words fit to show the sender.
+type LimitError struct {
+	ChallengeID string
+	Limit       string
+	Reason      string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("challenge %v: %s: %s", e.ChallengeID, e.Limit, e.Reason)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// now is replaced in tests.
+var now = time.Now
+
+func limitError(challenge *appsync.Challenge, limit string, format string, args ...interface{}) error {
+	err := &LimitError{
+		ChallengeID: stringValue(challenge.ID),
+		Limit:       limit,
+		Reason:      fmt.Sprintf(format, args...),
+	}
+	log.Printf("Share refused: %v", err)
+	return err
+}
+
+func checkShareExpiration(challenge *appsync.Challenge) error {
+	if challenge.ShareExpiration == nil || *challenge.ShareExpiration == "" {
+		return nil
+	}
+	expiration, err := time.Parse(time.RFC3339, *challenge.ShareExpiration)
+	if err != nil {
+		return fmt.Errorf("Challenge %v has an invalid ShareExpiration %q: %w", stringValue(challenge.ID), *challenge.ShareExpiration, err)
+	}
+	if !now().Before(expiration) {
+		return limitError(challenge, "ShareExpiration", "sharing for %s closed on %s", stringValue(challenge.Name), expiration.Format("January 2, 2006"))
+	}
+	return nil
+}
+
+// CheckShareLimits decides whether a new share of challenge may be recorded
+// under parentTransactionID, which is empty for a share from the challenge
+// itself. It checks, in order:
+//
+//	ShareExpiration           no shares once it has passed
+//	MaxNodes                  share actions recorded for the challenge
+//	MaxDepth                  hops from the root to the new share
+//	MaxSharesPerReceivedShare reshares made from the parent share
+func CheckShareLimits(ctx context.Context, resolver Resolver.Resolver, challenge *appsync.Challenge, parentTransactionID string) error {
+	if err := checkShareExpiration(challenge); err != nil {
+		return err
+	}
+
+	if challenge.MaxNodes != nil {
+		shareActions, err := resolver.GetShareActionsByChallengeContext(ctx, stringValue(challenge.ID))
+		if err != nil {
+			return fmt.Errorf("Failed to count share actions: %w", err)
+		}
+		if len(shareActions) >= *challenge.MaxNodes {
+			return limitError(challenge, "MaxNodes", "this challenge has already been shared the maximum of %d times", *challenge.MaxNodes)
+		}
+	}
+
+	if parentTransactionID == "" {
+		return nil
+	}
+
+	if challenge.MaxDepth != nil {
+		ancestry, err := GetAncestry(ctx, resolver, parentTransactionID, 0)
+		if err != nil {
+			return err
+		}
+		if depth := len(ancestry); depth > *challenge.MaxDepth {
+			return limitError(challenge, "MaxDepth", "this share is %d steps from the original and the challenge allows %d", depth, *challenge.MaxDepth)
+		}
+	}
+
+	if challenge.MaxSharesPerReceivedShare != nil {
+		children, err := resolver.GetTransactionsByParentTransactionContext(ctx, parentTransactionID)
+		if err != nil {
+			return fmt.Errorf("Failed to count reshares: %w", err)
+		}
+		if len(children) >= *challenge.MaxSharesPerReceivedShare {
+			return limitError(challenge, "MaxSharesPerReceivedShare", "the share you received can be passed on at most %d times", *challenge.MaxSharesPerReceivedShare)
+		}
+	}
+	return nil
+}
+
+// CheckRecipientLimits decides whether one share email may go to recipients
+// people: ShareExpiration must not have passed and MaxShares caps the
+// recipients of a single share.
+func CheckRecipientLimits(challenge *appsync.Challenge, recipients int) error {
+	if err := checkShareExpiration(challenge); err != nil {
+		return err
+	}
+	if challenge.MaxShares != nil && recipients > *challenge.MaxShares {
+		return limitError(challenge, "MaxShares", "a share can go to %d people at most and this one is addressed to %d", *challenge.MaxShares, recipients)
+	}
+	return nil
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,143 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"testing"
+	"time"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ChallengeController "gitlab.com/ncent/arber/api/services/arber/challenge"
+)
+
+func TestLimits(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("Sharing limits", func() {
+		var resolver *Resolver.MemoryResolver
+		ctx := context.Background()
+
+		newChallenge := func(input Resolver.CreateChallengeInput) *Resolver.Challenge {
+			challenge, err := resolver.CreateChallenge(input)
+			Expect(err).ShouldNot(HaveOccurred())
+			return challenge
+		}
+		refusedBy := func(err error, limit string) {
+			var limitErr *LimitError
+			Expect(errors.As(err, &limitErr)).Should(BeTrue())
+			Expect(limitErr.Limit).Should(Equal(limit))
+			Expect(errors.Is(err, ErrLimitExceeded)).Should(BeTrue())
+		}
+
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+			now = time.Now
+		})
+
+		g.After(func() {
+			now = time.Now
+		})
+
+		g.It("Should refuse shares once ShareExpiration has passed", func() {
+			expiration := "2019-06-01T00:00:00Z"
+			challenge := newChallenge(Resolver.CreateChallengeInput{ShareExpiration: &expiration})
+			now = func() time.Time { return time.Date(2019, 5, 31, 0, 0, 0, 0, time.UTC) }
+			_, err := CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			now = func() time.Time { return time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC) }
+			_, err = CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *challenge.ID)
+			refusedBy(err, "ShareExpiration")
+		})
+
+		g.It("Should cap the share actions of a challenge with MaxNodes", func() {
+			maxNodes := 2
+			challenge := newChallenge(Resolver.CreateChallengeInput{MaxNodes: &maxNodes})
+			root, err := CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, *root.ID, *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, *root.ID, *challenge.ID)
+			refusedBy(err, "MaxNodes")
+			shareActions, err := resolver.GetShareActionsByChallenge(*challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(shareActions).Should(HaveLen(2))
+		})
+
+		g.It("Should cap the depth of the chain with MaxDepth", func() {
+			maxDepth := 1
+			challenge := newChallenge(Resolver.CreateChallengeInput{MaxDepth: &maxDepth})
+			root, err := CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			first, err := CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, *root.ID, *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, *first.ID, *challenge.ID)
+			refusedBy(err, "MaxDepth")
+		})
+
+		g.It("Should cap reshares of one received share with MaxSharesPerReceivedShare", func() {
+			perShare := 1
+			challenge := newChallenge(Resolver.CreateChallengeInput{MaxSharesPerReceivedShare: &perShare})
+			root, err := CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, *root.ID, *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, *root.ID, *challenge.ID)
+			refusedBy(err, "MaxSharesPerReceivedShare")
+		})
+
+		g.It("Should cap the recipients of one share with MaxShares before recording anything", func() {
+			maxShares := 1
+			challenge := newChallenge(Resolver.CreateChallengeInput{MaxShares: &maxShares})
+			transaction, err := CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			from := &mail.Address{Address: "sharer@example.com"}
+			tos := []*mail.Address{{Address: "one@example.com"}, {Address: "two@example.com"}}
+			err = CreateShareActionContacts(ctx, resolver, *transaction.ID, from, tos)
+			refusedBy(err, "MaxShares")
+
+			users, err := resolver.ListUsersByEmails([]*string{&from.Address})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(BeEmpty())
+		})
+
+		g.It("Should not count a sender who copied themselves against MaxShares", func() {
+			maxShares := 1
+			challenge := newChallenge(Resolver.CreateChallengeInput{MaxShares: &maxShares})
+			transaction, err := CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			from := &mail.Address{Address: "sharer@example.com"}
+			tos := []*mail.Address{{Address: "Sharer@example.com"}, {Address: "one@example.com"}}
+			Expect(CreateShareActionContacts(ctx, resolver, *transaction.ID, from, tos)).Should(Succeed())
+			stored, err := resolver.GetTransaction(*transaction.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(resolver.ShareActionContacts(*stored.Action.ID)).Should(HaveLen(1))
+		})
+
+		g.It("Should refuse new shares and recipients of a closed challenge", func() {
+			challenge := newChallenge(Resolver.CreateChallengeInput{})
+			transaction, err := CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, "", *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = ChallengeController.Close(ctx, resolver, *challenge.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, *transaction.ID, *challenge.ID)
+			Expect(errors.Is(err, ChallengeController.ErrNotOpen)).Should(BeTrue())
+
+			from := &mail.Address{Address: "sharer@example.com"}
+			err = CreateShareActionContacts(ctx, resolver, *transaction.ID, from, []*mail.Address{{Address: "one@example.com"}})
+			Expect(errors.Is(err, ChallengeController.ErrNotOpen)).Should(BeTrue())
+		})
+	})
+}
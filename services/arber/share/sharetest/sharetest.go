@@ -0,0 +1,29 @@
+// Package sharetest records referral chains for the tests of the packages
+// that read them.
+package sharetest
+
+import (
+	"context"
+	"net/mail"
+
+	. "github.com/onsi/gomega"
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	ShareController "gitlab.com/ncent/arber/api/services/arber/share"
+)
+
+// Share records a transaction of the challenge under parentID, "" for a
+// root, sent by sender to recipients, and returns it as stored afterwards.
+// It fails the running test through gomega when a step fails.
+func Share(ctx context.Context, resolver Resolver.Resolver, challengeID string, parentID string, sender string, recipients ...string) *appsync.Transaction {
+	transaction, err := ShareController.CreateShareActionAndTransactionWithParentTransaction(ctx, resolver, parentID, challengeID)
+	Expect(err).ShouldNot(HaveOccurred())
+	var tos []*mail.Address
+	for _, recipient := range recipients {
+		tos = append(tos, &mail.Address{Address: recipient})
+	}
+	Expect(ShareController.CreateShareActionContacts(ctx, resolver, *transaction.ID, &mail.Address{Address: sender}, tos)).Should(Succeed())
+	stored, err := resolver.GetTransactionContext(ctx, *transaction.ID)
+	Expect(err).ShouldNot(HaveOccurred())
+	return stored
+}
@@ -0,0 +1,106 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	AddressController "gitlab.com/ncent/arber/api/services/arber/address"
+)
+
+// FindUsers returns the users known by any of the addresses or an alias of
+// them. Users are matched on their canonical addresses, and on the exact
+// address for users stored before they had canonical ones.
+func FindUsers(ctx context.Context, resolver Resolver.Resolver, addresses []*string) ([]appsync.User, error) {
+	canonical := AddressController.CanonicalAll(addresses)
+	if len(canonical) == 0 {
+		return nil, nil
+	}
+	users, err := resolver.ListUsersByCanonicalEmailsContext(ctx, canonical)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get users by canonical email: %w", err)
+	}
+
+	var lowered []*string
+	for _, address := range addresses {
+		if address != nil && strings.TrimSpace(*address) != "" {
+			value := strings.ToLower(strings.TrimSpace(*address))
+			lowered = append(lowered, &value)
+		}
+	}
+	legacyUsers, err := resolver.ListUsersByEmailsContext(ctx, lowered)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get user: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for _, user := range users {
+		seen[*user.ID] = true
+	}
+	for _, user := range legacyUsers {
+		if !seen[*user.ID] {
+			seen[*user.ID] = true
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// FindUser returns the first user FindUsers finds for the address, or nil
+// when nobody is known by it.
+func FindUser(ctx context.Context, resolver Resolver.Resolver, address string) (*appsync.User, error) {
+	users, err := FindUsers(ctx, resolver, []*string{&address})
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+	if len(users) > 1 {
+		log.Printf("Found %d users for %v, using %v", len(users), address, *users[0].ID)
+	}
+	return &users[0], nil
+}
+
+// LinkAlias stores address and its canonical form on the user if it does
+// not have them yet, so later lookups of either find the user. Users stored
+// before canonical addresses existed get theirs on the way.
+func LinkAlias(ctx context.Context, resolver Resolver.Resolver, user *appsync.User, address string) (*appsync.User, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	emails := AddressController.CanonicalAll(append([]*string{&address}, user.Emails...))
+	if address == "" || (containsString(user.Emails, address) && containsAll(user.CanonicalEmails, emails)) {
+		return user, nil
+	}
+
+	linked, err := appsync.MergeUser(ctx, resolver, appsync.UpdateUserInput{
+		ID:              *user.ID,
+		Emails:          []*string{&address},
+		CanonicalEmails: emails,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to link %v to user %v: %w", address, *user.ID, err)
+	}
+	log.Printf("Linked %v to user %v", address, *user.ID)
+	return linked, nil
+}
+
+func containsString(values []*string, wanted string) bool {
+	for _, value := range values {
+		if value != nil && *value == wanted {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAll(values []*string, wanted []*string) bool {
+	for _, value := range wanted {
+		if !containsString(values, *value) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,42 +1,116 @@
 package user
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/mail"
 	"strings"
 
+	uuid "github.com/satori/go.uuid"
 	"gitlab.com/ncent/arber/api/services/appsync"
 	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+	AddressController "gitlab.com/ncent/arber/api/services/arber/address"
 )
 
-func CreateSparseUser(resolver Resolver.Resolver, from *mail.Address) (*Resolver.User, error) {
-	fromAddress := strings.ToLower(from.Address)
-	emails := []*string{&fromAddress}
-	existingUsers, err := resolver.ListUsersByEmails(
-		emails,
-	)
+// SparseUserID is the ID of the sparse user created for the canonical
+// address. Deriving it from the address makes the create conditional, so
+// aliases of one address arriving together create a single user. It is a
+// name-based UUID so the address does not show wherever the ID does.
+func SparseUserID(canonical string) string {
+	return uuid.NewV5(uuid.NamespaceURL, "mailto:"+canonical).String()
+}
+
+// CreateSparseUser returns the user known by the address, or by an alias of
+// it, creating a user with only that address when there is none. A new
+// alias of a known user is linked to them. The user keyed on the canonical
+// address is read first; users stored before that key existed are found by
+// their addresses.
+func CreateSparseUser(ctx context.Context, resolver Resolver.Resolver, from *mail.Address) (*Resolver.User, error) {
+	fromAddress := strings.ToLower(strings.TrimSpace(from.Address))
+	canonical := AddressController.Canonical(fromAddress)
+	existingUser, keyFree, err := findSparseUser(ctx, resolver, canonical)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to get user: %v", err)
+		return nil, err
+	}
+	if existingUser == nil {
+		existingUser, err = FindUser(ctx, resolver, fromAddress)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	log.Printf("Got existing user: %+v", existingUsers)
-	if len(existingUsers) != 0 {
-		return &existingUsers[0], nil
+	log.Printf("Got existing user: %+v", existingUser)
+	if existingUser != nil {
+		return LinkAlias(ctx, resolver, existingUser, fromAddress)
 	}
 
 	log.Printf("Creating a sparse user")
+	// A user deleted without a merge keeps its key, so its address starts
+	// over under a generated ID.
+	var id *string
+	if keyFree {
+		sparseUserID := SparseUserID(canonical)
+		id = &sparseUserID
+	}
+	emails := []*string{&fromAddress}
 	blankUserName := " "
-	user, err := resolver.CreateUser(
+	user, err := resolver.CreateUserContext(
+		ctx,
 		appsync.CreateUserInput{
-			Emails: emails,
-			Names:  []*string{&blankUserName},
+			ID:              id,
+			Emails:          emails,
+			CanonicalEmails: AddressController.CanonicalAll(emails),
+			Names:           []*string{&blankUserName},
 		},
 	)
+	if errors.Is(err, appsync.ErrConditionalCheckFailed) {
+		// An alias of the address created the user between our lookup and insert
+		log.Printf("Sparse user already created, fetching it: %v", err)
+		existingUser, _, err = findSparseUser(ctx, resolver, canonical)
+		if err != nil {
+			return nil, err
+		}
+		if existingUser == nil {
+			return nil, fmt.Errorf("Failed to find user after conflict for %v", fromAddress)
+		}
+		return LinkAlias(ctx, resolver, existingUser, fromAddress)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("Failed to create sprase user: %v", err)
+		return nil, fmt.Errorf("Failed to create sprase user: %w", err)
 	}
 	log.Printf("Created sparse user: %+v", user)
 
 	return user, nil
 }
+
+// findSparseUser reads the user keyed on the canonical address, following
+// merges to the user that survived them. It returns nil when there is none
+// or it was deleted without being merged, and reports whether the key is
+// still free to create a user under.
+func findSparseUser(ctx context.Context, resolver Resolver.Resolver, canonical string) (*appsync.User, bool, error) {
+	if canonical == "" {
+		return nil, false, nil
+	}
+	id := SparseUserID(canonical)
+	seen := map[string]bool{}
+	for !seen[id] {
+		seen[id] = true
+		user, err := resolver.IncludeDeleted().GetUserContext(ctx, id)
+		if errors.Is(err, appsync.ErrNotFound) {
+			return nil, len(seen) == 1, nil
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("Failed to get user %v: %w", id, err)
+		}
+		if user.MergedInto == nil {
+			if user.DeletedAt != nil {
+				return nil, false, nil
+			}
+			return user, false, nil
+		}
+		id = *user.MergedInto
+	}
+	return nil, false, fmt.Errorf("Failed to follow merges of %v: %v merges in a loop", canonical, id)
+}
@@ -0,0 +1,116 @@
+package user
+
+import (
+	"context"
+	"net/mail"
+	"sync"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+)
+
+func TestCreateSparseUser(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("CreateSparseUser", func() {
+		var resolver *Resolver.MemoryResolver
+		ctx := context.Background()
+
+		values := func(list []*string) []string {
+			var result []string
+			for _, value := range list {
+				result = append(result, *value)
+			}
+			return result
+		}
+
+		g.BeforeEach(func() {
+			resolver = Resolver.NewMemoryResolver()
+		})
+
+		g.It("Should link aliases of one address to one user", func() {
+			first, err := CreateSparseUser(ctx, resolver, &mail.Address{Address: "jane.doe+jobs@gmail.com"})
+			Expect(err).ShouldNot(HaveOccurred())
+			second, err := CreateSparseUser(ctx, resolver, &mail.Address{Address: "janedoe@gmail.com"})
+			Expect(err).ShouldNot(HaveOccurred())
+			third, err := CreateSparseUser(ctx, resolver, &mail.Address{Address: "JaneDoe@googlemail.com"})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(*second.ID).Should(Equal(*first.ID))
+			Expect(*third.ID).Should(Equal(*first.ID))
+			Expect(values(third.Emails)).Should(Equal([]string{"jane.doe+jobs@gmail.com", "janedoe@gmail.com", "janedoe@googlemail.com"}))
+			Expect(values(third.CanonicalEmails)).Should(Equal([]string{"janedoe@gmail.com"}))
+		})
+
+		g.It("Should create one user for aliases arriving together", func() {
+			addresses := []string{"jane.doe+jobs@gmail.com", "janedoe@gmail.com"}
+			ids := make([]string, len(addresses))
+			errs := make([]error, len(addresses))
+			var wg sync.WaitGroup
+			for i, address := range addresses {
+				wg.Add(1)
+				go func(i int, address string) {
+					defer wg.Done()
+					user, err := CreateSparseUser(ctx, resolver, &mail.Address{Address: address})
+					errs[i] = err
+					if err == nil {
+						ids[i] = *user.ID
+					}
+				}(i, address)
+			}
+			wg.Wait()
+
+			Expect(errs).Should(Equal([]error{nil, nil}))
+			Expect(ids[1]).Should(Equal(ids[0]))
+			Expect(ids[0]).Should(Equal(SparseUserID("janedoe@gmail.com")))
+			canonical := "janedoe@gmail.com"
+			users, err := resolver.ListUsersByCanonicalEmails([]*string{&canonical})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(users).Should(HaveLen(1))
+			Expect(values(users[0].Emails)).Should(ConsistOf(addresses))
+		})
+
+		g.It("Should find the survivor of a merged sparse user", func() {
+			merged, err := CreateSparseUser(ctx, resolver, &mail.Address{Address: "jane@example.com"})
+			Expect(err).ShouldNot(HaveOccurred())
+			survivorEmail := "jane@example.org"
+			survivor, err := resolver.CreateUser(Resolver.CreateUserInput{Emails: []*string{&survivorEmail}})
+			Expect(err).ShouldNot(HaveOccurred())
+			deletedAt := "2020-01-01T00:00:00Z"
+			_, err = resolver.UpdateUser(Resolver.UpdateUserInput{ID: *merged.ID, MergedInto: survivor.ID, DeletedAt: &deletedAt})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			user, err := CreateSparseUser(ctx, resolver, &mail.Address{Address: "jane@example.com"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*user.ID).Should(Equal(*survivor.ID))
+		})
+
+		g.It("Should keep tagged addresses apart on other domains", func() {
+			first, err := CreateSparseUser(ctx, resolver, &mail.Address{Address: "jane+jobs@example.com"})
+			Expect(err).ShouldNot(HaveOccurred())
+			second, err := CreateSparseUser(ctx, resolver, &mail.Address{Address: "jane@example.com"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*second.ID).ShouldNot(Equal(*first.ID))
+		})
+
+		g.It("Should find users stored without canonical addresses", func() {
+			legacyEmail := "jane.doe@gmail.com"
+			legacy, err := resolver.CreateUser(Resolver.CreateUserInput{Emails: []*string{&legacyEmail}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			user, err := CreateSparseUser(ctx, resolver, &mail.Address{Address: "Jane.Doe@gmail.com"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*user.ID).Should(Equal(*legacy.ID))
+			Expect(values(user.CanonicalEmails)).Should(Equal([]string{"janedoe@gmail.com"}))
+
+			user, err = CreateSparseUser(ctx, resolver, &mail.Address{Address: "janedoe+x@googlemail.com"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*user.ID).Should(Equal(*legacy.ID))
+		})
+	})
+}
@@ -0,0 +1,43 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"time"
+
+	"gitlab.com/ncent/arber/api/services/appsync"
+	Resolver "gitlab.com/ncent/arber/api/services/appsync"
+)
+
+// now is replaced in tests.
+var now = time.Now
+
+// Unsubscribe records that the user behind the address wants no more
+// notifications. Someone unknown is created as a sparse user first, so
+// their opt-out holds once they are shared with. Unsubscribing twice keeps
+// the first date.
+func Unsubscribe(ctx context.Context, resolver Resolver.Resolver, from *mail.Address) (*appsync.User, error) {
+	user, err := CreateSparseUser(ctx, resolver, from)
+	if err != nil {
+		return nil, err
+	}
+	if Unsubscribed(user) {
+		log.Printf("User %v already unsubscribed at %v", *user.ID, *user.UnsubscribedAt)
+		return user, nil
+	}
+
+	unsubscribedAt := now().UTC().Format(time.RFC3339)
+	user, err = appsync.MergeUser(ctx, resolver, appsync.UpdateUserInput{ID: *user.ID, UnsubscribedAt: &unsubscribedAt})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unsubscribe user: %w", err)
+	}
+	log.Printf("Unsubscribed user %v", *user.ID)
+	return user, nil
+}
+
+// Unsubscribed reports whether the user asked for no more notifications.
+func Unsubscribed(user *appsync.User) bool {
+	return user != nil && user.UnsubscribedAt != nil && *user.UnsubscribedAt != ""
+}
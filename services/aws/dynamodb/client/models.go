@@ -36,6 +36,17 @@ type DynamoDBEventRecord struct {
 	UserIdentity   *events.DynamoDBUserIdentity `json:"userIdentity,omitempty"`
 }
 
+// DynamoDBEventResponse reports the records of a batch that failed, for a
+// stream mapped with the ReportBatchItemFailures response type.
+type DynamoDBEventResponse struct {
+	BatchItemFailures []DynamoDBBatchItemFailure `json:"batchItemFailures"`
+}
+
+// DynamoDBBatchItemFailure names a failed record by its sequence number.
+type DynamoDBBatchItemFailure struct {
+	ItemIdentifier string `json:"itemIdentifier"`
+}
+
 type DynamoDBStreamRecord struct {
 	ApproximateCreationDateTime events.SecondsEpochTime `json:"ApproximateCreationDateTime,omitempty"`
 	// changed to map[string]*dynamodb.AttributeValue
@@ -0,0 +1,248 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	appsync "gitlab.com/ncent/arber/api/services/appsync"
+)
+
+// DefaultMaxAttempts is how many failed attempts a transaction gets before
+// the stream cancels it.
+const DefaultMaxAttempts = 3
+
+// ErrIllegalTransition is the kind of every TransitionError.
+var ErrIllegalTransition = errors.New("illegal transition")
+
+// TransitionError is a status change the transaction state machine refused.
+type TransitionError struct {
+	TransactionID string
+	From          string
+	To            string
+	Err           error
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("transaction %v cannot move from %v to %v: %v", e.TransactionID, e.From, e.To, e.Err)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrIllegalTransition
+}
+
+// Effects run when a transaction enters a status.
+type Effects interface {
+	// Notify runs when a transaction is scheduled.
+	Notify(ctx context.Context, transaction *appsync.Transaction) error
+	// ScheduleRetry runs after a failed attempt while attempts remain.
+	ScheduleRetry(ctx context.Context, transaction *appsync.Transaction) error
+	// Finalize runs once a transaction is completed or cancelled.
+	Finalize(ctx context.Context, transaction *appsync.Transaction) error
+}
+
+// StateProcessor keeps the status and attempt counter of transactions
+// consistent with the appsync.TransactionEvents state machine as their
+// changes come off the DynamoDB stream.
+//
+// A legal change runs the effects of the new status. An illegal one is
+// reverted and recorded on the transaction's transitionErrors, so it can be
+// found later without searching the logs.
+type StateProcessor struct {
+	Resolver    appsync.Resolver
+	Effects     Effects
+	MaxAttempts int
+}
+
+func NewStateProcessor(resolver appsync.Resolver, effects Effects) *StateProcessor {
+	return &StateProcessor{
+		Resolver:    resolver,
+		Effects:     effects,
+		MaxAttempts: DefaultMaxAttempts,
+	}
+}
+
+var now = time.Now
+
+// ProcessRecords handles the records in order and stops at the first one
+// that fails. That record and the ones after it are reported as batch item
+// failures: the stream delivers them again from the failed one, while the
+// records before it, whose effects already ran, are not delivered again.
+func (p *StateProcessor) ProcessRecords(ctx context.Context, records []DynamoDBStreamRecord) DynamoDBEventResponse {
+	response := DynamoDBEventResponse{BatchItemFailures: []DynamoDBBatchItemFailure{}}
+	for i, record := range records {
+		err := p.ProcessRecord(ctx, record)
+		if err == nil {
+			continue
+		}
+		log.Printf("Failed to process transaction record %v, leaving %d records to the next delivery: %v", record.SequenceNumber, len(records)-i, err)
+		for _, failed := range records[i:] {
+			response.BatchItemFailures = append(response.BatchItemFailures, DynamoDBBatchItemFailure{ItemIdentifier: failed.SequenceNumber})
+		}
+		break
+	}
+	return response
+}
+
+// ProcessRecord validates the status change of one stream record and runs
+// the effects of the status it entered.
+func (p *StateProcessor) ProcessRecord(ctx context.Context, record DynamoDBStreamRecord) error {
+	var original, updated appsync.Transaction
+	if err := dynamodbattribute.UnmarshalMap(record.OldImage, &original); err != nil {
+		return fmt.Errorf("Failed to unmarshal old image: %w", err)
+	}
+	if err := dynamodbattribute.UnmarshalMap(record.NewImage, &updated); err != nil {
+		return fmt.Errorf("Failed to unmarshal new image: %w", err)
+	}
+	if updated.ID == nil {
+		return nil
+	}
+	if len(updated.TransitionErrors) > len(original.TransitionErrors) {
+		// This write recorded and reverted a refused transition.
+		return nil
+	}
+
+	to, err := updated.CurrentStatus()
+	if err != nil {
+		return p.refuse(ctx, &original, &updated, err)
+	}
+	from, err := original.CurrentStatus()
+	if err != nil {
+		return p.refuse(ctx, &original, &updated, err)
+	}
+	if from == to {
+		return nil
+	}
+
+	log.Printf("Attempting to UpdateState for a transaction: %v", *updated.ID)
+	log.Printf("From %v to %v", from, to)
+	machine, err := original.InitStateMachine()
+	if err != nil {
+		return p.refuse(ctx, &original, &updated, err)
+	}
+	if err := machine.Event(to.String()); err != nil {
+		return p.refuse(ctx, &original, &updated, err)
+	}
+	return p.enter(ctx, &updated, to)
+}
+
+func (p *StateProcessor) enter(ctx context.Context, transaction *appsync.Transaction, status appsync.ActionStatus) error {
+	switch status {
+	case appsync.SCHEDULED:
+		return p.Effects.Notify(ctx, transaction)
+	case appsync.ATTEMPED:
+		return p.countAttempt(ctx, transaction)
+	case appsync.COMPLETED, appsync.CANCELLED:
+		return p.Effects.Finalize(ctx, transaction)
+	default:
+		return nil
+	}
+}
+
+// countAttempt counts the failed attempt the transaction entered ATTEMPED
+// with and schedules a retry, or cancels it once it ran out of attempts. The
+// stream may deliver the record again, so the count is checked against the
+// stored transaction first: an attempt already counted only has its retry
+// scheduled, and a transaction that moved on is left alone.
+func (p *StateProcessor) countAttempt(ctx context.Context, transaction *appsync.Transaction) error {
+	counted := 0
+	if transaction.AttemptCounter != nil {
+		counted = *transaction.AttemptCounter
+	}
+	attempts := counted + 1
+
+	stored, err := p.Resolver.GetTransactionContext(ctx, *transaction.ID)
+	if err != nil {
+		return fmt.Errorf("Failed to get transaction %v: %w", *transaction.ID, err)
+	}
+	storedAttempts := 0
+	if stored.AttemptCounter != nil {
+		storedAttempts = *stored.AttemptCounter
+	}
+	if stored.Status == nil || *stored.Status != appsync.ATTEMPED.String() || (storedAttempts != counted && storedAttempts != attempts) {
+		log.Printf("Transaction %v moved on since attempt %d, skipping", *transaction.ID, attempts)
+		return nil
+	}
+
+	if storedAttempts == counted {
+		input := appsync.UpdateTransactionInput{ID: *transaction.ID, AttemptCounter: &attempts}
+		if attempts >= p.MaxAttempts {
+			// Cancelling comes back through the stream as ATTEMPED -> CANCELLED,
+			// which finalizes the transaction.
+			log.Printf("Transaction %v failed %d attempts, cancelling", *transaction.ID, attempts)
+			cancelled := appsync.CANCELLED.String()
+			input.Status = &cancelled
+		}
+		stored, err = p.Resolver.UpdateTransactionContext(ctx, input)
+		if err != nil {
+			return fmt.Errorf("Failed to count attempt of transaction %v: %w", *transaction.ID, err)
+		}
+	} else {
+		log.Printf("Attempt %d of transaction %v is already counted", attempts, *transaction.ID)
+	}
+	if attempts >= p.MaxAttempts {
+		return nil
+	}
+	return p.Effects.ScheduleRetry(ctx, stored)
+}
+
+// refuse puts the transaction back to its previous status and appends the
+// refused transition to its transitionErrors.
+func (p *StateProcessor) refuse(ctx context.Context, original *appsync.Transaction, updated *appsync.Transaction, cause error) error {
+	previous := appsync.CREATED.String()
+	if original.Status != nil {
+		previous = *original.Status
+	}
+	attempted := ""
+	if updated.Status != nil {
+		attempted = *updated.Status
+	}
+	transitionErr := &TransitionError{
+		TransactionID: *updated.ID,
+		From:          previous,
+		To:            attempted,
+		Err:           cause,
+	}
+	log.Printf("Refusing transition: %v", transitionErr)
+
+	entry := fmt.Sprintf("%s %v", now().UTC().Format(time.RFC3339), transitionErr)
+	transitionErrors := append(append([]*string{}, updated.TransitionErrors...), &entry)
+	_, err := p.Resolver.UpdateTransactionContext(ctx, appsync.UpdateTransactionInput{
+		ID:               *updated.ID,
+		Status:           &previous,
+		TransitionErrors: transitionErrors,
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to record %v: %w", transitionErr, err)
+	}
+	return nil
+}
+
+// ResolverEffects are the effects the stream Lambda runs: a failed attempt
+// is scheduled again straight away, the rest is logged.
+type ResolverEffects struct {
+	Resolver appsync.Resolver
+}
+
+func (e ResolverEffects) Notify(ctx context.Context, transaction *appsync.Transaction) error {
+	log.Printf("Transaction %v is scheduled", *transaction.ID)
+	return nil
+}
+
+func (e ResolverEffects) ScheduleRetry(ctx context.Context, transaction *appsync.Transaction) error {
+	log.Printf("Scheduling transaction %v again", *transaction.ID)
+	scheduled := appsync.SCHEDULED.String()
+	_, err := e.Resolver.UpdateTransactionContext(ctx, appsync.UpdateTransactionInput{
+		ID:     *transaction.ID,
+		Status: &scheduled,
+	})
+	return err
+}
+
+func (e ResolverEffects) Finalize(ctx context.Context, transaction *appsync.Transaction) error {
+	log.Printf("Transaction %v is final: %v", *transaction.ID, *transaction.Status)
+	return nil
+}
@@ -0,0 +1,222 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+	appsync "gitlab.com/ncent/arber/api/services/appsync"
+)
+
+type recordedEffects struct {
+	notified  []string
+	retried   []string
+	finalized []string
+	err       error
+}
+
+func (e *recordedEffects) Notify(ctx context.Context, transaction *appsync.Transaction) error {
+	e.notified = append(e.notified, *transaction.ID)
+	return e.err
+}
+
+func (e *recordedEffects) ScheduleRetry(ctx context.Context, transaction *appsync.Transaction) error {
+	e.retried = append(e.retried, *transaction.ID)
+	return e.err
+}
+
+func (e *recordedEffects) Finalize(ctx context.Context, transaction *appsync.Transaction) error {
+	e.finalized = append(e.finalized, *transaction.Status)
+	return e.err
+}
+
+// write applies input the way a client would and returns the stream record
+// DynamoDB would emit for it.
+func write(resolver *appsync.MemoryResolver, input appsync.UpdateTransactionInput) DynamoDBStreamRecord {
+	before, err := resolver.GetTransaction(input.ID)
+	Expect(err).ShouldNot(HaveOccurred())
+	_, err = resolver.UpdateTransaction(input)
+	Expect(err).ShouldNot(HaveOccurred())
+	after, err := resolver.GetTransaction(input.ID)
+	Expect(err).ShouldNot(HaveOccurred())
+	return record(before, after)
+}
+
+func record(before *appsync.Transaction, after *appsync.Transaction) DynamoDBStreamRecord {
+	oldImage, err := dynamodbattribute.MarshalMap(before)
+	Expect(err).ShouldNot(HaveOccurred())
+	newImage, err := dynamodbattribute.MarshalMap(after)
+	Expect(err).ShouldNot(HaveOccurred())
+	return DynamoDBStreamRecord{OldImage: oldImage, NewImage: newImage}
+}
+
+func status(value appsync.ActionStatus) *string {
+	s := value.String()
+	return &s
+}
+
+func Test(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("StateProcessor", func() {
+		var resolver *appsync.MemoryResolver
+		var effects *recordedEffects
+		var processor *StateProcessor
+		var id string
+		ctx := context.Background()
+
+		g.BeforeEach(func() {
+			resolver = appsync.NewMemoryResolver()
+			effects = &recordedEffects{}
+			processor = NewStateProcessor(resolver, effects)
+			transaction, err := resolver.CreateTransaction(appsync.CreateTransactionInput{Status: status(appsync.CREATED)})
+			Expect(err).ShouldNot(HaveOccurred())
+			id = *transaction.ID
+		})
+
+		g.It("Should notify when a transaction is scheduled", func() {
+			err := processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.SCHEDULED)}))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(effects.notified).Should(Equal([]string{id}))
+		})
+
+		g.It("Should treat a new transaction as a change from CREATED", func() {
+			stored, err := resolver.GetTransaction(id)
+			Expect(err).ShouldNot(HaveOccurred())
+			inserted := record(&appsync.Transaction{}, stored)
+			inserted.OldImage = nil
+			Expect(processor.ProcessRecord(ctx, inserted)).Should(Succeed())
+			Expect(effects.notified).Should(BeEmpty())
+		})
+
+		g.It("Should count a failed attempt and schedule a retry", func() {
+			Expect(processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.SCHEDULED)}))).Should(Succeed())
+			Expect(processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.ATTEMPED)}))).Should(Succeed())
+
+			stored, err := resolver.GetTransaction(id)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*stored.AttemptCounter).Should(Equal(1))
+			Expect(effects.retried).Should(Equal([]string{id}))
+		})
+
+		g.It("Should cancel a transaction once it runs out of attempts", func() {
+			processor.MaxAttempts = 2
+			for i := 0; i < 2; i++ {
+				Expect(processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.SCHEDULED)}))).Should(Succeed())
+				Expect(processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.ATTEMPED)}))).Should(Succeed())
+			}
+
+			stored, err := resolver.GetTransaction(id)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*stored.AttemptCounter).Should(Equal(2))
+			Expect(*stored.Status).Should(Equal("CANCELLED"))
+			Expect(effects.retried).Should(HaveLen(1))
+		})
+
+		g.It("Should finalize completed and cancelled transactions", func() {
+			Expect(processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.COMPLETED)}))).Should(Succeed())
+
+			other, err := resolver.CreateTransaction(appsync.CreateTransactionInput{Status: status(appsync.SCHEDULED)})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: *other.ID, Status: status(appsync.CANCELLED)}))).Should(Succeed())
+
+			Expect(effects.finalized).Should(Equal([]string{"COMPLETED", "CANCELLED"}))
+		})
+
+		g.It("Should revert and record an illegal transition", func() {
+			Expect(processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.COMPLETED)}))).Should(Succeed())
+			illegal := write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.SCHEDULED)})
+			Expect(processor.ProcessRecord(ctx, illegal)).Should(Succeed())
+
+			stored, err := resolver.GetTransaction(id)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*stored.Status).Should(Equal("COMPLETED"))
+			Expect(stored.TransitionErrors).Should(HaveLen(1))
+			Expect(*stored.TransitionErrors[0]).Should(ContainSubstring("cannot move from COMPLETED to SCHEDULED"))
+			Expect(effects.notified).Should(BeEmpty())
+
+			// The revert comes back through the stream and must not be
+			// refused or recorded again.
+			var scheduled appsync.Transaction
+			Expect(dynamodbattribute.UnmarshalMap(illegal.NewImage, &scheduled)).Should(Succeed())
+			Expect(processor.ProcessRecord(ctx, record(&scheduled, stored))).Should(Succeed())
+			stored, err = resolver.GetTransaction(id)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.TransitionErrors).Should(HaveLen(1))
+			Expect(effects.finalized).Should(Equal([]string{"COMPLETED"}))
+		})
+
+		g.It("Should record an unknown status", func() {
+			unknown := "PAID"
+			Expect(processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: id, Status: &unknown}))).Should(Succeed())
+
+			stored, err := resolver.GetTransaction(id)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*stored.Status).Should(Equal("CREATED"))
+			Expect(*stored.TransitionErrors[0]).Should(ContainSubstring("unknown action status"))
+		})
+
+		g.It("Should ignore writes that keep the status", func() {
+			attempts := 4
+			Expect(processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: id, AttemptCounter: &attempts}))).Should(Succeed())
+			Expect(effects.notified).Should(BeEmpty())
+			Expect(effects.retried).Should(BeEmpty())
+			Expect(effects.finalized).Should(BeEmpty())
+		})
+
+		g.It("Should report the record an effect failed on and the ones after it", func() {
+			other, err := resolver.CreateTransaction(appsync.CreateTransactionInput{Status: status(appsync.CREATED)})
+			Expect(err).ShouldNot(HaveOccurred())
+			records := []DynamoDBStreamRecord{
+				write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.COMPLETED)}),
+				write(resolver, appsync.UpdateTransactionInput{ID: *other.ID, Status: status(appsync.SCHEDULED)}),
+				write(resolver, appsync.UpdateTransactionInput{ID: *other.ID, Status: status(appsync.ATTEMPED)}),
+			}
+			for i, sequenceNumber := range []string{"1", "2", "3"} {
+				records[i].SequenceNumber = sequenceNumber
+			}
+			Expect(processor.ProcessRecords(ctx, records).BatchItemFailures).Should(BeEmpty())
+
+			effects.err = errors.New("mailer down")
+			response := processor.ProcessRecords(ctx, records[1:])
+			Expect(response.BatchItemFailures).Should(Equal([]DynamoDBBatchItemFailure{{ItemIdentifier: "2"}, {ItemIdentifier: "3"}}))
+			Expect(effects.finalized).Should(Equal([]string{"COMPLETED"}))
+		})
+
+		g.It("Should count an attempt once when its record is delivered again", func() {
+			Expect(processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.SCHEDULED)}))).Should(Succeed())
+			attempted := write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.ATTEMPED)})
+
+			effects.err = errors.New("scheduler down")
+			Expect(processor.ProcessRecord(ctx, attempted)).ShouldNot(Succeed())
+			effects.err = nil
+			Expect(processor.ProcessRecord(ctx, attempted)).Should(Succeed())
+
+			stored, err := resolver.GetTransaction(id)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*stored.AttemptCounter).Should(Equal(1))
+			Expect(effects.retried).Should(Equal([]string{id, id}))
+
+			// Once the retry is scheduled the record is stale.
+			Expect(processor.ProcessRecord(ctx, write(resolver, appsync.UpdateTransactionInput{ID: id, Status: status(appsync.SCHEDULED)}))).Should(Succeed())
+			Expect(processor.ProcessRecord(ctx, attempted)).Should(Succeed())
+			stored, err = resolver.GetTransaction(id)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*stored.AttemptCounter).Should(Equal(1))
+			Expect(effects.retried).Should(HaveLen(2))
+		})
+	})
+
+	g.Describe("TransitionError", func() {
+		g.It("Should be an ErrIllegalTransition", func() {
+			var err error = &TransitionError{TransactionID: "t", From: "COMPLETED", To: "CREATED", Err: errors.New("event CREATED inappropriate")}
+			Expect(errors.Is(err, ErrIllegalTransition)).Should(BeTrue())
+		})
+	})
+}
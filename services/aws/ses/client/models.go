@@ -1,6 +1,9 @@
 package clients
 
 import (
+	"net/mail"
+
+	"github.com/DusanKasan/parsemail"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ses"
@@ -24,3 +27,57 @@ type EmailRequest struct {
 	Subject   string `json:"subject"`
 	ID        string `json:"id,omitempty"`
 }
+
+// Verdict statuses of the checks SES runs on received mail.
+const (
+	VerdictPass             = "PASS"
+	VerdictFail             = "FAIL"
+	VerdictGray             = "GRAY"
+	VerdictProcessingFailed = "PROCESSING_FAILED"
+	VerdictDisabled         = "DISABLED"
+)
+
+// Verdicts are the results of the spam, virus, SPF, DKIM and DMARC checks
+// SES ran on a received message, and the DMARC policy of the sender's
+// domain.
+type Verdicts struct {
+	Spam        string `json:"spam,omitempty"`
+	Virus       string `json:"virus,omitempty"`
+	SPF         string `json:"spf,omitempty"`
+	DKIM        string `json:"dkim,omitempty"`
+	DMARC       string `json:"dmarc,omitempty"`
+	DMARCPolicy string `json:"dmarcPolicy,omitempty"`
+}
+
+// Receipt is what SES reports about a received message besides the message
+// itself: where it is stored, who it was delivered to and how it checked
+// out. The receipt of a bare S3 event only knows where the message is.
+type Receipt struct {
+	MessageID string
+	Bucket    string
+	ObjectKey string
+	// Content is the raw message when SES sent it along, as an SNS action
+	// does; otherwise the message is read from Bucket.
+	Content string
+	// Recipients are the envelope recipients, the addresses the message
+	// was actually delivered to.
+	Recipients []string
+	// Verdicts are nil when SES reported none.
+	Verdicts *Verdicts
+}
+
+// InboundEmail is a received message as ConsumeEmail hands it on.
+type InboundEmail struct {
+	To          []*mail.Address
+	Cc          []*mail.Address
+	From        *mail.Address
+	Bcc         []*mail.Address
+	Body        string
+	Subject     string
+	Attachments []parsemail.Attachment
+	// Recipients are the envelope recipients, nil when the message came
+	// without an SES receipt. Bcc headers are usually stripped before
+	// delivery, so these are the only trace of a Bcc'd address.
+	Recipients []*mail.Address
+	Verdicts   *Verdicts
+}
@@ -0,0 +1,214 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"strings"
+
+	"github.com/DusanKasan/parsemail"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ErrNotReceived is returned for SES notifications other than of received
+// mail, like bounces and complaints.
+var ErrNotReceived = errors.New("not a notification of received mail")
+
+// receiptNotification is the notification SES publishes to SNS when a
+// receipt rule with an S3 or SNS action matches.
+type receiptNotification struct {
+	NotificationType string                    `json:"notificationType"`
+	Mail             events.SimpleEmailMessage `json:"mail"`
+	Receipt          events.SimpleEmailReceipt `json:"receipt"`
+	Content          string                    `json:"content"`
+}
+
+// ParseReceiptNotification parses the message of the SNS notification of
+// an SES receipt rule. The message is stored by the rule's S3 action or,
+// with an SNS action, sent along as the content.
+func ParseReceiptNotification(message string) (*Receipt, error) {
+	var notification receiptNotification
+	if err := json.Unmarshal([]byte(message), &notification); err != nil {
+		return nil, fmt.Errorf("Failed to parse SES notification: %w", err)
+	}
+	if notification.NotificationType != "Received" {
+		return nil, fmt.Errorf("%w: %q", ErrNotReceived, notification.NotificationType)
+	}
+	receipt := ReceiptFromSES(events.SimpleEmailService{Mail: notification.Mail, Receipt: notification.Receipt})
+	receipt.Content = notification.Content
+	if receipt.Content == "" && (receipt.Bucket == "" || receipt.ObjectKey == "") {
+		return nil, fmt.Errorf("SES notification of %v has neither content nor an S3 object", receipt.MessageID)
+	}
+	return receipt, nil
+}
+
+// ReceiptFromSES returns the receipt of an SES event. The envelope
+// recipients are those of the receipt rule, or every destination of the
+// message when the rule lists none.
+func ReceiptFromSES(service events.SimpleEmailService) *Receipt {
+	receipt := &Receipt{
+		MessageID:  service.Mail.MessageID,
+		Bucket:     service.Receipt.Action.BucketName,
+		ObjectKey:  service.Receipt.Action.ObjectKey,
+		Recipients: service.Receipt.Recipients,
+		Verdicts: &Verdicts{
+			Spam:        service.Receipt.SpamVerdict.Status,
+			Virus:       service.Receipt.VirusVerdict.Status,
+			SPF:         service.Receipt.SPFVerdict.Status,
+			DKIM:        service.Receipt.DKIMVerdict.Status,
+			DMARC:       service.Receipt.DMARCVerdict.Status,
+			DMARCPolicy: service.Receipt.DMARCPolicy,
+		},
+	}
+	if len(receipt.Recipients) == 0 {
+		receipt.Recipients = service.Mail.Destination
+	}
+	return receipt
+}
+
+// ReceiptFromS3 returns the receipt of a message SES stored in the inbox
+// bucket, which knows neither the envelope recipients nor the verdicts.
+// ReadEmail reads the verdicts from the headers SES adds to the message.
+func ReceiptFromS3(record events.S3EventRecord) *Receipt {
+	bucket := record.S3.Bucket.Name
+	if bucket == "" {
+		bucket = os.Getenv("S3_BUCKET")
+	}
+	return &Receipt{Bucket: bucket, ObjectKey: record.S3.Object.Key}
+}
+
+// ReadEmail parses the raw message of the receipt. A receipt without
+// verdicts takes those of the headers SES stamped on the message.
+func ReadEmail(receipt *Receipt, raw io.Reader) (*InboundEmail, error) {
+	parsedMail, err := parsemail.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("ReadMessage failed: %s", err)
+	}
+	if len(parsedMail.From) == 0 {
+		return nil, fmt.Errorf("Message %v has no sender", receipt.MessageID)
+	}
+	email := &InboundEmail{
+		To:          parsedMail.To,
+		Cc:          parsedMail.Cc,
+		From:        parsedMail.From[0],
+		Bcc:         parsedMail.Bcc,
+		Body:        parsedMail.TextBody,
+		Subject:     parsedMail.Subject,
+		Attachments: parsedMail.Attachments,
+		Verdicts:    receipt.Verdicts,
+	}
+	if email.Verdicts == nil {
+		email.Verdicts = HeaderVerdicts(parsedMail.Header)
+	}
+	for _, recipient := range receipt.Recipients {
+		recipient = strings.TrimSpace(recipient)
+		if recipient == "" {
+			continue
+		}
+		address, err := mail.ParseAddress(recipient)
+		if err != nil {
+			address = &mail.Address{Address: recipient}
+		}
+		email.Recipients = append(email.Recipients, address)
+	}
+	return email, nil
+}
+
+// SESAuthServID is the authserv-id of the Authentication-Results header SES
+// adds to received mail.
+const SESAuthServID = "amazonses.com"
+
+// HeaderVerdicts reads the verdicts SES stamped on a stored message: the
+// Authentication-Results it added, Received-SPF where that has no SPF
+// result, and the X-SES spam and virus verdicts. SES prepends its headers,
+// so only the topmost of each is read; Authentication-Results of anyone
+// else, the sender included, are ignored. It returns nil when the message
+// carries none of them.
+func HeaderVerdicts(header mail.Header) *Verdicts {
+	verdicts := &Verdicts{
+		Spam:  headerVerdict(header.Get("X-SES-Spam-Verdict")),
+		Virus: headerVerdict(header.Get("X-SES-Virus-Verdict")),
+	}
+	for _, results := range header["Authentication-Results"] {
+		parts := strings.Split(results, ";")
+		if !strings.EqualFold(strings.TrimSpace(parts[0]), SESAuthServID) {
+			continue
+		}
+		for _, part := range parts[1:] {
+			method, result, comment := authenticationResult(part)
+			switch method {
+			case "spf":
+				verdicts.SPF = headerVerdict(result)
+			case "dkim":
+				verdicts.DKIM = headerVerdict(result)
+			case "dmarc":
+				verdicts.DMARC = headerVerdict(result)
+				verdicts.DMARCPolicy = dmarcPolicy(comment)
+			}
+		}
+		break
+	}
+	if verdicts.SPF == "" {
+		if fields := strings.Fields(header.Get("Received-SPF")); len(fields) > 0 {
+			verdicts.SPF = headerVerdict(fields[0])
+		}
+	}
+	if *verdicts == (Verdicts{}) {
+		return nil
+	}
+	return verdicts
+}
+
+// authenticationResult splits one resinfo of an Authentication-Results
+// header, like "dmarc=fail (p=REJECT sp=REJECT pct=100) header.from=x.com",
+// into its method, result and comment.
+func authenticationResult(resinfo string) (string, string, string) {
+	resinfo = strings.TrimSpace(resinfo)
+	var comment string
+	if open := strings.Index(resinfo, "("); open >= 0 {
+		if end := strings.Index(resinfo[open:], ")"); end >= 0 {
+			comment = resinfo[open+1 : open+end]
+			resinfo = resinfo[:open] + resinfo[open+end+1:]
+		}
+	}
+	fields := strings.Fields(resinfo)
+	if len(fields) == 0 {
+		return "", "", ""
+	}
+	pair := strings.SplitN(fields[0], "=", 2)
+	if len(pair) != 2 {
+		return "", "", ""
+	}
+	return strings.ToLower(pair[0]), pair[1], comment
+}
+
+// dmarcPolicy reads p= from the comment of a DMARC result.
+func dmarcPolicy(comment string) string {
+	for _, field := range strings.Fields(comment) {
+		if strings.HasPrefix(strings.ToLower(field), "p=") {
+			return strings.ToUpper(field[2:])
+		}
+	}
+	return ""
+}
+
+// headerVerdict maps a result of the headers to the verdict status SES
+// reports for the same check.
+func headerVerdict(result string) string {
+	switch strings.ToLower(strings.TrimSpace(result)) {
+	case "":
+		return ""
+	case "pass":
+		return VerdictPass
+	case "fail", "hardfail":
+		return VerdictFail
+	case "temperror", "permerror":
+		return VerdictProcessingFailed
+	default:
+		// none, neutral, softfail and policy prove nothing either way.
+		return VerdictGray
+	}
+}
@@ -0,0 +1,130 @@
+package clients
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	goblin "github.com/franela/goblin"
+	. "github.com/onsi/gomega"
+)
+
+const notification = `{
+  "notificationType": "Received",
+  "mail": {
+    "timestamp": "2019-06-01T12:00:00.000Z",
+    "source": "jane@example.com",
+    "messageId": "o3vrnil0e2ic28trm7dfhrc2v0clambda4nbp0g1",
+    "destination": ["friend@example.com", "share+tx1@redb.ai"],
+    "commonHeaders": {"from": ["Jane <jane@example.com>"], "to": ["friend@example.com"], "subject": "A role for you"}
+  },
+  "receipt": {
+    "timestamp": "2019-06-01T12:00:00.000Z",
+    "recipients": ["share+tx1@redb.ai"],
+    "spamVerdict": {"status": "PASS"},
+    "virusVerdict": {"status": "PASS"},
+    "spfVerdict": {"status": "FAIL"},
+    "dkimVerdict": {"status": "GRAY"},
+    "dmarcVerdict": {"status": "FAIL"},
+    "dmarcPolicy": "REJECT",
+    "action": {"type": "S3", "topicArn": "arn:aws:sns:us-west-2:0:inbox", "bucketName": "redb-inbox", "objectKey": "o3vrnil0e2ic28trm7dfhrc2v0clambda4nbp0g1"}
+  }
+}`
+
+const rawEmail = "From: Jane <jane@example.com>\r\n" +
+	"To: friend@example.com\r\n" +
+	"Subject: A role for you\r\n" +
+	"Content-Type: text/plain; charset=UTF-8\r\n" +
+	"\r\n" +
+	"Have a look.\r\n"
+
+func TestReceipt(t *testing.T) {
+	g := goblin.Goblin(t)
+
+	//special hook for gomega
+	RegisterFailHandler(func(m string, _ ...int) { g.Fail(m) })
+
+	g.Describe("ParseReceiptNotification", func() {
+		g.It("Should read the S3 object, envelope recipients and verdicts", func() {
+			receipt, err := ParseReceiptNotification(notification)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(receipt.Bucket).Should(Equal("redb-inbox"))
+			Expect(receipt.ObjectKey).Should(Equal("o3vrnil0e2ic28trm7dfhrc2v0clambda4nbp0g1"))
+			Expect(receipt.Recipients).Should(Equal([]string{"share+tx1@redb.ai"}))
+			Expect(*receipt.Verdicts).Should(Equal(Verdicts{
+				Spam:        VerdictPass,
+				Virus:       VerdictPass,
+				SPF:         VerdictFail,
+				DKIM:        VerdictGray,
+				DMARC:       VerdictFail,
+				DMARCPolicy: "REJECT",
+			}))
+		})
+
+		g.It("Should fall back to the destination", func() {
+			receipt, err := ParseReceiptNotification(strings.Replace(notification, `"recipients": ["share+tx1@redb.ai"],`, "", 1))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(receipt.Recipients).Should(Equal([]string{"friend@example.com", "share+tx1@redb.ai"}))
+		})
+
+		g.It("Should refuse other notifications and missing messages", func() {
+			_, err := ParseReceiptNotification(`{"notificationType": "Bounce"}`)
+			Expect(errors.Is(err, ErrNotReceived)).Should(BeTrue())
+			_, err = ParseReceiptNotification(`{"notificationType": "Received", "receipt": {"action": {"type": "SNS"}}}`)
+			Expect(err).Should(HaveOccurred())
+			_, err = ParseReceiptNotification(`not json`)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	g.Describe("ReadEmail", func() {
+		g.It("Should add the envelope recipients to the headers", func() {
+			receipt, err := ParseReceiptNotification(notification)
+			Expect(err).ShouldNot(HaveOccurred())
+			email, err := ReadEmail(receipt, strings.NewReader(rawEmail))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(email.From.Address).Should(Equal("jane@example.com"))
+			Expect(email.To[0].Address).Should(Equal("friend@example.com"))
+			Expect(email.Bcc).Should(BeEmpty())
+			Expect(email.Recipients).Should(HaveLen(1))
+			Expect(email.Recipients[0].Address).Should(Equal("share+tx1@redb.ai"))
+			Expect(email.Verdicts.SPF).Should(Equal(VerdictFail))
+			Expect(strings.TrimSpace(email.Body)).Should(Equal("Have a look."))
+		})
+
+		g.It("Should leave mail of a bare S3 event to its headers", func() {
+			email, err := ReadEmail(&Receipt{Bucket: "redb-inbox", ObjectKey: "key"}, strings.NewReader(rawEmail))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(email.Recipients).Should(BeNil())
+			Expect(email.Verdicts).Should(BeNil())
+		})
+
+		g.It("Should read the verdicts SES stamped on mail of a bare S3 event", func() {
+			stamped := "X-SES-Spam-Verdict: PASS\r\n" +
+				"X-SES-Virus-Verdict: PASS\r\n" +
+				"Received-SPF: pass (spfCheck: domain of example.com designates 192.0.2.1 as permitted sender) client-ip=192.0.2.1;\r\n" +
+				"Authentication-Results: amazonses.com; spf=pass (spfCheck: domain of example.com designates 192.0.2.1 as permitted sender) smtp.mailfrom=jane@example.com; dkim=fail header.i=@example.com; dmarc=fail (p=REJECT sp=REJECT pct=100) header.from=example.com;\r\n" +
+				"Authentication-Results: mx.example.com; dmarc=pass header.from=example.com\r\n" +
+				rawEmail
+			email, err := ReadEmail(&Receipt{Bucket: "redb-inbox", ObjectKey: "key"}, strings.NewReader(stamped))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*email.Verdicts).Should(Equal(Verdicts{
+				Spam:        VerdictPass,
+				Virus:       VerdictPass,
+				SPF:         VerdictPass,
+				DKIM:        VerdictFail,
+				DMARC:       VerdictFail,
+				DMARCPolicy: "REJECT",
+			}))
+		})
+
+		g.It("Should ignore Authentication-Results SES did not add", func() {
+			forged := "Authentication-Results: mx.example.com; dmarc=pass header.from=example.com\r\n" +
+				"Received-SPF: softfail (spfCheck: transitioning domain) client-ip=192.0.2.1;\r\n" +
+				rawEmail
+			email, err := ReadEmail(&Receipt{Bucket: "redb-inbox", ObjectKey: "key"}, strings.NewReader(forged))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(*email.Verdicts).Should(Equal(Verdicts{SPF: VerdictGray}))
+		})
+	})
+}
@@ -3,13 +3,11 @@ package clients
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
-	"net/mail"
 	"os"
 	"strings"
 
-	"github.com/DusanKasan/parsemail"
-	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -33,43 +31,52 @@ func init() {
 	s3Client = s3.New(sess, aws.NewConfig().WithRegion(os.Getenv("AWS_REGION")))
 }
 
+// ConsumeEmail reads the message of the receipt, from its content or from
+// S3, and hands it to processEmail along with the envelope recipients and
+// verdicts of the receipt.
 func (sess SESService) ConsumeEmail(
 	ctx context.Context,
-	record events.S3EventRecord,
+	receipt *Receipt,
 	processEmail func(
+		ctx context.Context,
 		resolver Resolver.Resolver,
 		sess SESService,
-		toAddresses []*mail.Address,
-		fromAddress *mail.Address,
-		bccAddress []*mail.Address,
-		body string,
-		subject string,
-		attachments []parsemail.Attachment) error,
+		email InboundEmail) error,
 ) error {
-	log.Printf("record: %+v", record)
+	log.Printf("receipt: %+v", receipt)
 
-	obj, err := s3Client.GetObject(&s3.GetObjectInput{
-		Bucket: aws.String(os.Getenv("S3_BUCKET")),
-		Key:    aws.String(record.S3.Object.Key),
-	})
-	if err != nil {
-		return fmt.Errorf("S3 GetObject failed: %s", err)
+	var raw io.Reader
+	if receipt.Content != "" {
+		raw = strings.NewReader(receipt.Content)
+	} else {
+		obj, err := s3Client.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(receipt.Bucket),
+			Key:    aws.String(receipt.ObjectKey),
+		})
+		if err != nil {
+			return fmt.Errorf("S3 GetObject failed: %s", err)
+		}
+		defer obj.Body.Close()
+		raw = obj.Body
 	}
 
-	parsedMail, err := parsemail.Parse(obj.Body)
+	email, err := ReadEmail(receipt, raw)
 	if err != nil {
-		return fmt.Errorf("ReadMessage failed: %s", err)
+		return err
 	}
 
-	obj.Body.Close()
-
-	log.Printf("Found email Body: %v", parsedMail.TextBody)
-	log.Printf("Found email FROM: %v", parsedMail.From)
-	log.Printf("Found email To: %+v", parsedMail.To)
-	log.Printf("Found email BCC: %v", parsedMail.Bcc)
-	log.Printf("Found email Subject: %v", parsedMail.Subject)
-
-	return processEmail(resolver, sess, parsedMail.To, parsedMail.From[0], parsedMail.Bcc, parsedMail.TextBody, parsedMail.Subject, parsedMail.Attachments)
+	log.Printf("Found email Body: %v", email.Body)
+	log.Printf("Found email FROM: %v", email.From)
+	log.Printf("Found email To: %+v", email.To)
+	log.Printf("Found email BCC: %v", email.Bcc)
+	log.Printf("Found email recipients: %v", email.Recipients)
+	log.Printf("Found email verdicts: %+v", email.Verdicts)
+	log.Printf("Found email Subject: %v", email.Subject)
+
+	// Memoize lookups for this email only; every recipient resolves the same
+	// sender and challenge.
+	cachedResolver := Resolver.NewCachedResolver(resolver, Resolver.CacheTTL(), nil)
+	return processEmail(ctx, cachedResolver, sess, *email)
 }
 
 func (sess SESService) SendEmail(er EmailRequest) error {
@@ -151,11 +158,11 @@ func (sess SESService) checkMailerError(err error) error {
 	if aerr, ok := err.(awserr.Error); ok {
 		switch aerr.Code() {
 		case ses.ErrCodeMessageRejected:
-			log.Printf(ses.ErrCodeMessageRejected, aerr.Error())
+			log.Printf("%v: %v", ses.ErrCodeMessageRejected, aerr.Error())
 		case ses.ErrCodeMailFromDomainNotVerifiedException:
-			log.Printf(ses.ErrCodeMailFromDomainNotVerifiedException, aerr.Error())
+			log.Printf("%v: %v", ses.ErrCodeMailFromDomainNotVerifiedException, aerr.Error())
 		case ses.ErrCodeConfigurationSetDoesNotExistException:
-			log.Printf(ses.ErrCodeConfigurationSetDoesNotExistException, aerr.Error())
+			log.Printf("%v: %v", ses.ErrCodeConfigurationSetDoesNotExistException, aerr.Error())
 		default:
 			log.Printf(aerr.Error())
 		}
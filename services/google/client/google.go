@@ -19,23 +19,16 @@ func init() {
 		GOOGLE_OAUTH_ENDPOINT_TOKEN_URL,
 		nil,
 		&oauth2.Config{
-			GOOGLE_OAUTH_CLIENT_ID,
-			GOOGLE_OAUTH_CLIENT_SECRET,
-			oauth2.Endpoint{
-				"",
-				GOOGLE_OAUTH_ENDPOINT_TOKEN_URL,
-				0,
+			ClientID:     GOOGLE_OAUTH_CLIENT_ID,
+			ClientSecret: GOOGLE_OAUTH_CLIENT_SECRET,
+			Endpoint: oauth2.Endpoint{
+				TokenURL: GOOGLE_OAUTH_ENDPOINT_TOKEN_URL,
 			},
-			"",
-			nil,
 		},
 		&clientcredentials.Config{
-			GOOGLE_OAUTH_CLIENT_ID,
-			GOOGLE_OAUTH_CLIENT_SECRET,
-			GOOGLE_OAUTH_ENDPOINT_TOKEN_URL,
-			nil,
-			nil,
-			0,
+			ClientID:     GOOGLE_OAUTH_CLIENT_ID,
+			ClientSecret: GOOGLE_OAUTH_CLIENT_SECRET,
+			TokenURL:     GOOGLE_OAUTH_ENDPOINT_TOKEN_URL,
 		},
 	}
 }
@@ -3,13 +3,17 @@ package helpers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"strings"
 
+	uuid "github.com/satori/go.uuid"
 	"gitlab.com/ncent/arber/api/services/appsync"
 	r "gitlab.com/ncent/arber/api/services/appsync"
+	AddressController "gitlab.com/ncent/arber/api/services/arber/address"
 	"gitlab.com/ncent/arber/api/services/auth0"
 	lambdaClient "gitlab.com/ncent/arber/api/services/aws/lambda/client"
 	clients "gitlab.com/ncent/arber/api/services/aws/ses/client"
@@ -22,40 +26,51 @@ const CLIENT_APP_URL = ""
 const API_URL = ""
 const SHORTENER_URL = ""
 
-func CreateOrUpdateUser(resolver r.Resolver, existingUsers []appsync.User, usr *auth0.User, emails []*string, googleUserInfo *people.Person, names []*string, phones []*string, photos []*string, token oauth2.Token) (*appsync.User, error) {
+func CreateOrUpdateUser(ctx context.Context, resolver r.Resolver, existingUsers []appsync.User, usr *auth0.User, emails []*string, googleUserInfo *people.Person, names []*string, phones []*string, photos []*string, token oauth2.Token) (*appsync.User, error) {
 	var user *appsync.User
 	var err error
 
 	if len(existingUsers) > 0 {
 		log.Printf("Updating user: %v", usr)
 
-		user, err = resolver.UpdateUser(
+		// Contact sync may be writing the same user concurrently, so the
+		// profile lists are merged into what is stored rather than replacing it.
+		user, err = appsync.MergeUser(
+			ctx,
+			resolver,
 			appsync.UpdateUserInput{
-				ID:           *existingUsers[0].ID,
-				Identity:     &usr.ID,
-				Emails:       emails,
-				Etag:         &googleUserInfo.Etag,
-				Names:        names,
-				PhoneNumbers: phones,
-				Pictures:     photos,
-				Token:        &token.RefreshToken,
+				ID:              *existingUsers[0].ID,
+				Identity:        &usr.ID,
+				Emails:          emails,
+				CanonicalEmails: AddressController.CanonicalAll(emails),
+				Etag:            &googleUserInfo.Etag,
+				Names:           names,
+				PhoneNumbers:    phones,
+				Pictures:        photos,
+				Token:           &token.RefreshToken,
 			},
 		)
 	} else {
 		log.Printf("Creating user: %v", usr)
 
-		user, err = resolver.CreateUser(
+		user, err = resolver.CreateUserContext(
+			ctx,
 			appsync.CreateUserInput{
-				Identity:     &usr.ID,
-				Emails:       emails,
-				Etag:         &googleUserInfo.Etag,
-				Names:        names,
-				PhoneNumbers: phones,
-				Pictures:     photos,
-				Token:        &token.RefreshToken,
+				Identity:        &usr.ID,
+				Emails:          emails,
+				CanonicalEmails: AddressController.CanonicalAll(emails),
+				Etag:            &googleUserInfo.Etag,
+				Names:           names,
+				PhoneNumbers:    phones,
+				Pictures:        photos,
+				Token:           &token.RefreshToken,
 			},
 		)
 
+		if err != nil {
+			return nil, err
+		}
+
 		swe_err := sendWelcomeEmail(user)
 		if swe_err != nil {
 			log.Printf("Failed to send welcome email: %v", swe_err.Error())
@@ -123,6 +138,14 @@ func generateStartBody(challenge appsync.Challenge) (*string, error) {
 		*reshareLink,
 		CLIENT_APP_URL,
 	)
+	if challenge.SponsorToken != nil {
+		hireLink := CLIENT_APP_URL + "/hire/" + *challenge.ID + "?key=" + url.QueryEscape(*challenge.SponsorToken)
+		startBody += fmt.Sprintf(
+			`
+		<p>Once you hire someone who applied, <a href="%s">confirm the hire here</a> so your referrers are rewarded. Keep this link to yourself.</p>`,
+			hireLink,
+		)
+	}
 	return &startBody, nil
 }
 
@@ -142,49 +165,121 @@ func PopulateContacts(resolver r.Resolver, user *appsync.User, token oauth2.Toke
 			}
 		}
 	}
-	if len(contactsEmails) > 0 {
+	if len(contactsEmails) == 0 {
+		return nil
+	}
 
-		existingUsersToEmailMap, err := resolver.MapUsersByEmails(
-			contactsEmails,
-		)
+	existingUsersToEmailMap, err := resolver.MapUsersByEmailsContext(
+		ctx,
+		contactsEmails,
+	)
 
-		if err != nil {
-			log.Printf("There was a problem in PopulateContacts when getting map of emails: %v", err.Error())
-			return err
+	if err != nil {
+		log.Printf("There was a problem in PopulateContacts when getting map of emails: %v", err.Error())
+		return err
+	}
+
+	// Contacts are often known under an alias of the address in the
+	// address book, so users are also matched on canonical addresses.
+	canonicalUsers, err := resolver.ListUsersByCanonicalEmailsContext(
+		ctx,
+		AddressController.CanonicalAll(contactsEmails),
+	)
+	if err != nil {
+		log.Printf("There was a problem in PopulateContacts when getting users by canonical emails: %v", err.Error())
+		return err
+	}
+	existingUsersToCanonicalEmailMap := make(map[string]appsync.User)
+	for _, canonicalUser := range canonicalUsers {
+		for _, email := range canonicalUser.CanonicalEmails {
+			existingUsersToCanonicalEmailMap[*email] = canonicalUser
 		}
+	}
 
-		for _, gc := range googleUserContacts {
-			emails, names, phones, photos := ExtractGooglePersonInformation(resolver, gc)
-			for _, email := range emails {
-				if _, ok := existingUsersToEmailMap[*email]; ok {
-					_, err = resolver.UpdateUser(
-						appsync.UpdateUserInput{
-							ID:           *existingUsersToEmailMap[*email].ID,
-							Emails:       emails,
-							Etag:         &gc.Etag,
-							Names:        names,
-							PhoneNumbers: phones,
-							Pictures:     photos,
-						},
-					)
-				} else {
-					createdUser, _ := resolver.CreateUser(
-						appsync.CreateUserInput{
-							Emails:       emails,
-							Etag:         &gc.Etag,
-							Names:        names,
-							PhoneNumbers: phones,
-							Pictures:     photos,
-						},
-					)
-					resolver.CreateUserContact(
-						appsync.CreateUserContactInput{
-							UserContactUserId:    createdUser.ID,
-							UserContactContactId: user.ID,
-						},
-					)
-				}
+	// Every Google contact becomes one upsert. Known users have the contact's
+	// lists merged into what is stored; new users get an ID here so the
+	// contact edge can name them within the same batch.
+	var batch appsync.UserBatch
+	queued := map[string]bool{}
+	for _, gc := range googleUserContacts {
+		emails, names, phones, photos := ExtractGooglePersonInformation(resolver, gc)
+		if len(emails) == 0 {
+			continue
+		}
+
+		var existingUser *appsync.User
+		for _, email := range emails {
+			if found, ok := existingUsersToEmailMap[*email]; ok {
+				existingUser = &found
+				break
+			}
+			if found, ok := existingUsersToCanonicalEmailMap[AddressController.Canonical(*email)]; ok {
+				existingUser = &found
+				break
+			}
+		}
+
+		if existingUser != nil {
+			if queued[*existingUser.ID] {
+				continue
+			}
+			queued[*existingUser.ID] = true
+
+			// The contact's etag belongs to this user's address book,
+			// not to the contact's own profile, so only the lists are
+			// merged in.
+			input := appsync.MergeUserInput(existingUser, appsync.UpdateUserInput{
+				ID:              *existingUser.ID,
+				Emails:          emails,
+				CanonicalEmails: AddressController.CanonicalAll(emails),
+				Names:           names,
+				PhoneNumbers:    phones,
+				Pictures:        photos,
+			})
+			batch.Users = append(batch.Users, appsync.UserUpsert{Update: &input})
+			continue
+		}
+
+		id := uuid.NewV4().String()
+		batch.Users = append(batch.Users, appsync.UserUpsert{Create: &appsync.CreateUserInput{
+			ID:              &id,
+			Emails:          emails,
+			CanonicalEmails: AddressController.CanonicalAll(emails),
+			Etag:            &gc.Etag,
+			Names:           names,
+			PhoneNumbers:    phones,
+			Pictures:        photos,
+		}})
+		batch.Contacts = append(batch.Contacts, appsync.CreateUserContactInput{
+			UserContactUserID:    &id,
+			UserContactContactID: user.ID,
+		})
+	}
+
+	result, err := resolver.BatchUpsertUsersContext(ctx, batch)
+	if err != nil {
+		log.Printf("PopulateContacts stopped before every contact was written: %v", err)
+		return err
+	}
+
+	for i, item := range result.Users {
+		if item.Err == nil {
+			continue
+		}
+		upsert := batch.Users[i]
+		if upsert.Update != nil && errors.Is(item.Err, appsync.ErrConflict) {
+			// Someone wrote the user since it was read; merge again on
+			// top of the fresh copy.
+			if _, err := appsync.MergeUser(ctx, resolver, *upsert.Update); err != nil {
+				log.Printf("Failed to merge contact user %v: %v", upsert.Update.ID, err)
 			}
+			continue
+		}
+		log.Printf("Failed to write contact user: %v", item.Err)
+	}
+	for _, item := range result.Contacts {
+		if item.Err != nil {
+			log.Printf("Failed to create user contact: %v", item.Err)
 		}
 	}
 